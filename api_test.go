@@ -0,0 +1,2234 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/auth"
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/Geraetefreund/chirpy/internal/moderation"
+	"github.com/Geraetefreund/chirpy/internal/store"
+	"github.com/Geraetefreund/chirpy/internal/store/memory"
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/google/uuid"
+)
+
+const (
+	testSecret   = "test-secret"
+	testAdminKey = "test-admin-key"
+	testPolkaKey = "test-polka-key"
+	// testMetricsToken lets scrapeMetrics read /metrics.
+	testMetricsToken = "test-metrics-token"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, *apiConfig) {
+	t.Helper()
+	db := memory.New()
+	moderator, err := moderation.New(context.Background(), storeBannedWords{db})
+	if err != nil {
+		t.Fatalf("moderation.New: %v", err)
+	}
+	cfg := &apiConfig{
+		db:        db,
+		moderator: moderator,
+		platform:  "dev",
+		secret:    testSecret,
+		polkaKeys: []string{testPolkaKey},
+		adminKey:  testAdminKey,
+		plans:     testPlans(),
+		// Only scrapers sending the token may read /metrics.
+		metricsToken: testMetricsToken,
+		// Test receivers listen on loopback.
+		webhookClient: newWebhookClient(true),
+		chirpHub:      newChirpHub(),
+		userHub:       newUserHub(),
+		wsConns:       newConnLimiter(2),
+		metrics:       newMetrics(),
+	}
+	srv := httptest.NewServer(cfg.routes(t.TempDir()))
+	t.Cleanup(srv.Close)
+	return srv, cfg
+}
+
+// testPlans are the default plans without rate limits, since tests post
+// faster than any user.
+func testPlans() plans {
+	p := defaultPlans
+	p.Standard.ChirpsPerMinute = 0
+	p.Red.ChirpsPerMinute = 0
+	return p
+}
+
+// doJSON sends body as JSON and decodes the response into out when out is
+// non-nil. An empty token sends no Authorization header.
+func doJSON(t *testing.T, srv *httptest.Server, method, path, token string, body, out any) *http.Response {
+	t.Helper()
+	var rdr io.Reader
+	if body != nil {
+		dat, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshal request: %v", err)
+		}
+		rdr = bytes.NewReader(dat)
+	}
+	req, err := http.NewRequest(method, srv.URL+path, rdr)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, path, err)
+		}
+	}
+	return resp
+}
+
+// signUp creates a user and logs in, returning the login response.
+func signUp(t *testing.T, srv *httptest.Server, email string) User {
+	t.Helper()
+	creds := map[string]string{"email": email, "password": "hunter2"}
+	if resp := doJSON(t, srv, "POST", "/api/users", "", creds, nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create user: got status %d", resp.StatusCode)
+	}
+	var user User
+	if resp := doJSON(t, srv, "POST", "/api/login", "", creds, &user); resp.StatusCode != http.StatusOK {
+		t.Fatalf("login: got status %d", resp.StatusCode)
+	}
+	return user
+}
+
+// upgrade makes user a Chirpy Red member.
+func upgrade(t *testing.T, cfg *apiConfig, user User) {
+	t.Helper()
+	if _, err := cfg.db.UpgradeChirpyPlus(context.Background(), user.ID); err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+}
+
+func TestUsersCreateAndLogin(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	user := signUp(t, srv, "walt@breakingbad.com")
+	if user.Token == "" || user.RefreshToken == "" {
+		t.Fatalf("login did not return tokens: %+v", user)
+	}
+
+	dup := map[string]string{"email": "walt@breakingbad.com", "password": "other"}
+	if resp := doJSON(t, srv, "POST", "/api/users", "", dup, nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("duplicate email: want %d, got %d", http.StatusConflict, resp.StatusCode)
+	}
+
+	wrong := map[string]string{"email": "walt@breakingbad.com", "password": "wrong"}
+	if resp := doJSON(t, srv, "POST", "/api/login", "", wrong, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong password: want %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
+
+func TestChirpsCreateGetDelete(t *testing.T) {
+	srv, _ := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	jesse := signUp(t, srv, "jesse@breakingbad.com")
+
+	var chirp Chirp
+	resp := doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": "I am the one who knocks kerfuffle"}, &chirp)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create chirp: got status %d", resp.StatusCode)
+	}
+	if chirp.Body != "I am the one who knocks ****" {
+		t.Errorf("body was not cleaned: %q", chirp.Body)
+	}
+
+	if resp := doJSON(t, srv, "POST", "/api/chirps", "", map[string]string{"body": "hi"}, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("create without token: want %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	var got Chirp
+	if resp := doJSON(t, srv, "GET", "/api/chirps/"+chirp.ID, "", nil, &got); resp.StatusCode != http.StatusOK {
+		t.Fatalf("get chirp: got status %d", resp.StatusCode)
+	}
+	if got.ID != chirp.ID {
+		t.Errorf("want chirp %s, got %s", chirp.ID, got.ID)
+	}
+
+	if resp := doJSON(t, srv, "DELETE", "/api/chirps/"+chirp.ID, jesse.Token, nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("delete by non-author: want %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "DELETE", "/api/chirps/"+chirp.ID, walt.Token, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete by author: want %d, got %d", http.StatusNoContent, resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "GET", "/api/chirps/"+chirp.ID, "", nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("get deleted chirp: want %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestChirpsPagination(t *testing.T) {
+	srv, _ := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	jesse := signUp(t, srv, "jesse@breakingbad.com")
+
+	for i := 0; i < 5; i++ {
+		doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": "walt"}, nil)
+		doJSON(t, srv, "POST", "/api/chirps", jesse.Token, map[string]string{"body": "jesse"}, nil)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"global asc", "/api/chirps?limit=3", 10},
+		{"global desc", "/api/chirps?limit=4&sort=desc", 10},
+		{"by author", "/api/chirps?limit=2&author_id=" + walt.ID.String(), 5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			seen := make(map[string]bool)
+			next := tc.query
+			for pages := 0; next != ""; pages++ {
+				if pages > tc.want {
+					t.Fatalf("pagination did not terminate")
+				}
+				var page []Chirp
+				resp := doJSON(t, srv, "GET", next, "", nil, &page)
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("GET %s: got status %d", next, resp.StatusCode)
+				}
+				for _, c := range page {
+					if seen[c.ID] {
+						t.Errorf("chirp %s returned twice", c.ID)
+					}
+					seen[c.ID] = true
+				}
+				next = nextLink(resp.Header.Get("Link"))
+			}
+			if len(seen) != tc.want {
+				t.Errorf("want %d chirps, got %d", tc.want, len(seen))
+			}
+		})
+	}
+
+	// Ten chirps split evenly: the second page is the last and must not
+	// link to an empty third one.
+	var page []Chirp
+	resp := doJSON(t, srv, "GET", "/api/chirps?limit=5", "", nil, &page)
+	next := nextLink(resp.Header.Get("Link"))
+	if len(page) != 5 || next == "" {
+		t.Fatalf("first of two pages: want 5 chirps and a next link, got %d, %q", len(page), next)
+	}
+	resp = doJSON(t, srv, "GET", next, "", nil, &page)
+	if len(page) != 5 || resp.Header.Get("Link") != "" {
+		t.Errorf("last page: want 5 chirps and no Link header, got %d, %q", len(page), resp.Header.Get("Link"))
+	}
+	resp = doJSON(t, srv, "GET", "/api/chirps?limit=100", "", nil, &page)
+	if len(page) != 10 || resp.Header.Get("Link") != "" {
+		t.Errorf("single page: want 10 chirps and no Link header, got %d, %q", len(page), resp.Header.Get("Link"))
+	}
+	if resp := doJSON(t, srv, "GET", "/api/chirps?limit=1000", "", nil, &page); resp.StatusCode != http.StatusOK || len(page) != 10 {
+		t.Errorf("limit over the cap: want %d and 10 chirps, got %d and %d", http.StatusOK, resp.StatusCode, len(page))
+	}
+
+	for _, query := range []string{"cursor=garbage", "cursor=Z2FyYmFnZQ", "limit=0", "limit=ten"} {
+		if resp := doJSON(t, srv, "GET", "/api/chirps?"+query, "", nil, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: want %d, got %d", query, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+}
+
+// nextLink extracts the target of a rel="next" Link header.
+func nextLink(header string) string {
+	target, rel, ok := strings.Cut(header, ";")
+	if !ok || !strings.Contains(rel, `rel="next"`) {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(target), "<>")
+}
+
+func TestRefreshAndRevoke(t *testing.T) {
+	srv, _ := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+
+	var refreshed struct {
+		Token string `json:"token"`
+	}
+	if resp := doJSON(t, srv, "POST", "/api/refresh", walt.RefreshToken, nil, &refreshed); resp.StatusCode != http.StatusOK {
+		t.Fatalf("refresh: got status %d", resp.StatusCode)
+	}
+	if refreshed.Token == "" {
+		t.Errorf("refresh did not return an access token")
+	}
+
+	if resp := doJSON(t, srv, "POST", "/api/revoke", walt.RefreshToken, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("revoke: got status %d", resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "POST", "/api/refresh", walt.RefreshToken, nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("refresh after revoke: want %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "POST", "/api/revoke", walt.RefreshToken, nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("second revoke: want %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
+
+func TestChirpsEditKeepsRevisions(t *testing.T) {
+	srv, cfg := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	jesse := signUp(t, srv, "jesse@breakingbad.com")
+	upgrade(t, cfg, walt)
+	upgrade(t, cfg, jesse)
+
+	var chirp Chirp
+	doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": "say my name"}, &chirp)
+
+	edit := map[string]string{"body": "you're goddamn right fornax"}
+	if resp := doJSON(t, srv, "PUT", "/api/chirps/"+chirp.ID, jesse.Token, edit, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("edit by non-author: want %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	var edited Chirp
+	if resp := doJSON(t, srv, "PUT", "/api/chirps/"+chirp.ID, walt.Token, edit, &edited); resp.StatusCode != http.StatusOK {
+		t.Fatalf("edit by author: got status %d", resp.StatusCode)
+	}
+	if edited.Body != "you're goddamn right ****" {
+		t.Errorf("edited body was not cleaned: %q", edited.Body)
+	}
+	if !edited.UpdatedAt.After(chirp.UpdatedAt) {
+		t.Errorf("updated_at was not bumped: %v -> %v", chirp.UpdatedAt, edited.UpdatedAt)
+	}
+
+	var revisions []ChirpRevision
+	if resp := doJSON(t, srv, "GET", "/api/chirps/"+chirp.ID+"/revisions", "", nil, &revisions); resp.StatusCode != http.StatusOK {
+		t.Fatalf("revisions: got status %d", resp.StatusCode)
+	}
+	if len(revisions) != 1 || revisions[0].Body != "say my name" {
+		t.Errorf("want the original body as the only revision, got %+v", revisions)
+	}
+}
+
+func TestChirpThread(t *testing.T) {
+	srv, _ := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	jesse := signUp(t, srv, "jesse@breakingbad.com")
+
+	var root, reply, nested Chirp
+	doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]any{"body": "say my name"}, &root)
+	doJSON(t, srv, "POST", "/api/chirps", jesse.Token, map[string]any{"body": "heisenberg", "in_reply_to": root.ID}, &reply)
+	doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]any{"body": "you're goddamn right", "in_reply_to": reply.ID}, &nested)
+	for i := 0; i < 2; i++ {
+		doJSON(t, srv, "POST", "/api/chirps", jesse.Token, map[string]any{"body": "yo", "in_reply_to": root.ID}, nil)
+	}
+
+	missing := map[string]any{"body": "hello?", "in_reply_to": "00000000-0000-0000-0000-000000000001"}
+	if resp := doJSON(t, srv, "POST", "/api/chirps", walt.Token, missing, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("reply to missing chirp: want %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	var thread Thread
+	if resp := doJSON(t, srv, "GET", "/api/chirps/"+reply.ID+"/thread", "", nil, &thread); resp.StatusCode != http.StatusOK {
+		t.Fatalf("thread: got status %d", resp.StatusCode)
+	}
+	if len(thread.Ancestors) != 1 || thread.Ancestors[0].ID != root.ID {
+		t.Errorf("want root as the only ancestor, got %+v", thread.Ancestors)
+	}
+	if thread.Ancestors[0].ReplyCount != 3 {
+		t.Errorf("root reply_count: want 3, got %d", thread.Ancestors[0].ReplyCount)
+	}
+	if len(thread.Replies) != 1 || thread.Replies[0].ID != nested.ID || thread.Replies[0].Depth != 1 {
+		t.Errorf("want the nested reply at depth 1, got %+v", thread.Replies)
+	}
+
+	var tree []ThreadReply
+	path := "/api/chirps/" + root.ID + "/thread?limit=2"
+	for pages := 0; path != ""; pages++ {
+		if pages > 2 {
+			t.Fatalf("thread pagination did not terminate")
+		}
+		resp := doJSON(t, srv, "GET", path, "", nil, &thread)
+		tree = append(tree, thread.Replies...)
+		path = nextLink(resp.Header.Get("Link"))
+	}
+	if len(tree) != 4 {
+		t.Fatalf("want all 4 replies below the root, got %+v", tree)
+	}
+	if tree[0].ID != reply.ID || tree[0].Depth != 1 {
+		t.Errorf("want the first reply at depth 1, got %+v", tree[0])
+	}
+	if tree[1].ID != nested.ID || tree[1].Depth != 2 || tree[1].InReplyTo.UUID.String() != reply.ID {
+		t.Errorf("want the reply to the reply at depth 2, got %+v", tree[1])
+	}
+	for _, r := range tree[2:] {
+		if r.Depth != 1 || r.InReplyTo.UUID.String() != root.ID {
+			t.Errorf("want a direct reply at depth 1, got %+v", r)
+		}
+	}
+}
+
+func TestFollowsAndTimeline(t *testing.T) {
+	srv, _ := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	jesse := signUp(t, srv, "jesse@breakingbad.com")
+	skyler := signUp(t, srv, "skyler@breakingbad.com")
+
+	for _, u := range []User{jesse, skyler} {
+		for i := 0; i < 3; i++ {
+			doJSON(t, srv, "POST", "/api/chirps", u.Token, map[string]string{"body": u.Email}, nil)
+		}
+	}
+
+	follow := "/api/users/" + jesse.ID.String() + "/follow"
+	if resp := doJSON(t, srv, "POST", follow, walt.Token, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("follow: got status %d", resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "POST", follow, walt.Token, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("follow twice: want %d, got %d", http.StatusNoContent, resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "POST", "/api/users/"+walt.ID.String()+"/follow", walt.Token, nil, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("follow self: want %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "POST", "/api/users/"+uuid.NewString()+"/follow", walt.Token, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("follow unknown user: want %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+	var followers []Follow
+	doJSON(t, srv, "GET", "/api/users/"+jesse.ID.String()+"/followers", "", nil, &followers)
+	if len(followers) != 1 || followers[0].UserID != walt.ID {
+		t.Errorf("followers: want walt, got %+v", followers)
+	}
+	var following []Follow
+	doJSON(t, srv, "GET", "/api/users/"+walt.ID.String()+"/following", "", nil, &following)
+	if len(following) != 1 || following[0].UserID != jesse.ID {
+		t.Errorf("following: want jesse, got %+v", following)
+	}
+
+	var timeline []Chirp
+	if resp := doJSON(t, srv, "GET", "/api/timeline", walt.Token, nil, &timeline); resp.StatusCode != http.StatusOK {
+		t.Fatalf("timeline: got status %d", resp.StatusCode)
+	}
+	if len(timeline) != 3 {
+		t.Fatalf("timeline: want jesse's 3 chirps, got %d", len(timeline))
+	}
+	for i, c := range timeline {
+		if c.UserID != jesse.ID.String() {
+			t.Errorf("timeline contains a chirp by %s", c.UserID)
+		}
+		if i > 0 && c.CreatedAt.After(timeline[i-1].CreatedAt) {
+			t.Errorf("timeline is not newest first")
+		}
+	}
+
+	if resp := doJSON(t, srv, "DELETE", follow, walt.Token, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("unfollow: got status %d", resp.StatusCode)
+	}
+	doJSON(t, srv, "GET", "/api/timeline", walt.Token, nil, &timeline)
+	if len(timeline) != 0 {
+		t.Errorf("timeline after unfollow: want empty, got %d chirps", len(timeline))
+	}
+}
+
+func TestChirpLikes(t *testing.T) {
+	srv, _ := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	jesse := signUp(t, srv, "jesse@breakingbad.com")
+
+	var chirp Chirp
+	doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": "say my name"}, &chirp)
+	likes := "/api/chirps/" + chirp.ID + "/likes"
+
+	for _, u := range []User{walt, jesse, jesse} {
+		if resp := doJSON(t, srv, "POST", likes, u.Token, nil, nil); resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("like: got status %d", resp.StatusCode)
+		}
+	}
+	if resp := doJSON(t, srv, "POST", likes, "", nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("anonymous like: want %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "POST", "/api/chirps/"+uuid.NewString()+"/likes", jesse.Token, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("like unknown chirp: want %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+	var got Chirp
+	doJSON(t, srv, "GET", "/api/chirps/"+chirp.ID, jesse.Token, nil, &got)
+	if got.LikeCount != 2 || !got.LikedByMe {
+		t.Errorf("as jesse: want 2 likes and liked_by_me, got %d, %v", got.LikeCount, got.LikedByMe)
+	}
+	doJSON(t, srv, "GET", "/api/chirps/"+chirp.ID, "", nil, &got)
+	if got.LikeCount != 2 || got.LikedByMe {
+		t.Errorf("anonymous: want 2 likes and not liked_by_me, got %d, %v", got.LikeCount, got.LikedByMe)
+	}
+
+	var liked []Chirp
+	doJSON(t, srv, "GET", "/api/users/"+jesse.ID.String()+"/likes", "", nil, &liked)
+	if len(liked) != 1 || liked[0].ID != chirp.ID {
+		t.Errorf("jesse's likes: want the chirp, got %+v", liked)
+	}
+
+	if resp := doJSON(t, srv, "DELETE", likes, jesse.Token, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("unlike: got status %d", resp.StatusCode)
+	}
+	doJSON(t, srv, "GET", "/api/chirps/"+chirp.ID, jesse.Token, nil, &got)
+	if got.LikeCount != 1 || got.LikedByMe {
+		t.Errorf("after unlike: want 1 like and not liked_by_me, got %d, %v", got.LikeCount, got.LikedByMe)
+	}
+}
+
+func TestChirpsSearch(t *testing.T) {
+	srv, _ := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	jesse := signUp(t, srv, "jesse@breakingbad.com")
+
+	for _, body := range []string{"say my name", "my name is Heisenberg", "name name name"} {
+		doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": body}, nil)
+	}
+	doJSON(t, srv, "POST", "/api/chirps", jesse.Token, map[string]string{"body": "yeah science, name of the game"}, nil)
+
+	var got []Chirp
+	doJSON(t, srv, "GET", "/api/chirps/search?q=name", "", nil, &got)
+	if len(got) != 4 || got[0].Body != "name name name" {
+		t.Fatalf("relevance: want 4 results led by the most frequent match, got %+v", got)
+	}
+
+	doJSON(t, srv, "GET", `/api/chirps/search?q=%22my+name%22&author_id=`+walt.ID.String(), "", nil, &got)
+	if len(got) != 2 {
+		t.Errorf("phrase by author: want 2 results, got %d", len(got))
+	}
+	doJSON(t, srv, "GET", "/api/chirps/search?q=heisen*", "", nil, &got)
+	if len(got) != 1 || got[0].Body != "my name is Heisenberg" {
+		t.Errorf("prefix: got %+v", got)
+	}
+
+	var pages [][]Chirp
+	path := "/api/chirps/search?q=name&sort=recent&limit=3"
+	for path != "" {
+		var page []Chirp
+		resp := doJSON(t, srv, "GET", path, "", nil, &page)
+		pages = append(pages, page)
+		path = nextLink(resp.Header.Get("Link"))
+	}
+	if len(pages) != 2 || len(pages[0]) != 3 || len(pages[1]) != 1 {
+		t.Fatalf("recent: want pages of 3 and 1, got %v", pages)
+	}
+	if pages[0][0].UserID != jesse.ID.String() {
+		t.Errorf("recent: want the newest chirp first, got %+v", pages[0][0])
+	}
+
+	seen := map[string]bool{}
+	path = "/api/chirps/search?q=name&limit=1"
+	for path != "" {
+		var page []Chirp
+		resp := doJSON(t, srv, "GET", path, "", nil, &page)
+		for _, c := range page {
+			seen[c.ID] = true
+		}
+		path = nextLink(resp.Header.Get("Link"))
+	}
+	if len(seen) != 4 {
+		t.Errorf("relevance pagination: want 4 distinct chirps, got %d", len(seen))
+	}
+
+	for _, bad := range []string{"?q=", "?q=%22%22", "?q=name&sort=oldest", "?q=name&cursor=nope"} {
+		if resp := doJSON(t, srv, "GET", "/api/chirps/search"+bad, "", nil, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: want %d, got %d", bad, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+}
+
+func TestChirpEntities(t *testing.T) {
+	srv, cfg := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	jesse := signUp(t, srv, "jesse@breakingbad.com")
+	upgrade(t, cfg, walt)
+
+	body := "yo @jesse@breakingbad.com, #BreakingBad is back! ping @nobody@example.com #42 #Cook_2"
+	var chirp Chirp
+	if resp := doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": body}, &chirp); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: got status %d", resp.StatusCode)
+	}
+	wantTags := []Hashtag{{Tag: "breakingbad", Start: 27, End: 39}, {Tag: "cook_2", Start: 78, End: 85}}
+	if !reflect.DeepEqual(chirp.Entities.Hashtags, wantTags) {
+		t.Errorf("hashtags: want %+v, got %+v", wantTags, chirp.Entities.Hashtags)
+	}
+	// Unknown addresses are listed like known ones, and nothing tells which
+	// is which.
+	wantMentions := []Mention{{Start: 3, End: 25}, {Start: 54, End: 73}}
+	if !reflect.DeepEqual(chirp.Entities.Mentions, wantMentions) {
+		t.Errorf("mentions: want %+v, got %+v", wantMentions, chirp.Entities.Mentions)
+	}
+	var fetched json.RawMessage
+	doJSON(t, srv, "GET", "/api/chirps/"+chirp.ID, "", nil, &fetched)
+	if strings.Contains(string(fetched), jesse.ID.String()) {
+		t.Errorf("chirp gives away the mentioned user's ID: %s", fetched)
+	}
+	for _, h := range chirp.Entities.Hashtags {
+		if got := strings.ToLower(body[h.Start+1 : h.End]); got != h.Tag {
+			t.Errorf("offsets of %q point at %q", h.Tag, got)
+		}
+	}
+
+	doJSON(t, srv, "POST", "/api/chirps", jesse.Token, map[string]string{"body": "#breakingbad forever"}, nil)
+	var tagged []Chirp
+	doJSON(t, srv, "GET", "/api/tags/%23BreakingBad/chirps", "", nil, &tagged)
+	if len(tagged) != 2 {
+		t.Errorf("tag listing: want 2 chirps, got %d", len(tagged))
+	}
+	var mentioning []Chirp
+	doJSON(t, srv, "GET", "/api/users/"+jesse.ID.String()+"/mentions", jesse.Token, nil, &mentioning)
+	if len(mentioning) != 1 || mentioning[0].ID != chirp.ID {
+		t.Errorf("mentions listing: got %+v", mentioning)
+	}
+	if resp := doJSON(t, srv, "GET", "/api/users/"+jesse.ID.String()+"/mentions", walt.Token, nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("someone else's mentions: want %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "GET", "/api/users/"+jesse.ID.String()+"/mentions", "", nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("mentions without a token: want %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	doJSON(t, srv, "PUT", "/api/chirps/"+chirp.ID, walt.Token, map[string]string{"body": "never mind"}, &chirp)
+	if len(chirp.Entities.Hashtags) != 0 || len(chirp.Entities.Mentions) != 0 {
+		t.Errorf("entities survived an edit: %+v", chirp.Entities)
+	}
+	doJSON(t, srv, "GET", "/api/users/"+jesse.ID.String()+"/mentions", jesse.Token, nil, &mentioning)
+	if len(mentioning) != 0 {
+		t.Errorf("mentions listing after edit: want empty, got %d", len(mentioning))
+	}
+}
+
+func TestChirpModeration(t *testing.T) {
+	srv, cfg := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+
+	var validated struct {
+		CleanedBody string `json:"cleaned_body"`
+	}
+	doJSON(t, srv, "POST", "/api/validate_chirp", "", map[string]string{"body": "What a Kerfuffle!"}, &validated)
+	if validated.CleanedBody != "What a ****!" {
+		t.Errorf("validate: got %q", validated.CleanedBody)
+	}
+
+	rules := moderation.Rules{{Word: "fornax", Action: moderation.Reject}}
+	moderator, err := moderation.New(context.Background(), rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.moderator = moderator
+
+	if resp := doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": "F0rnax!"}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("rejected word: want %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	var chirp Chirp
+	doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": "kerfuffle is fine now"}, &chirp)
+	if chirp.Body != "kerfuffle is fine now" {
+		t.Errorf("after swapping the word list: got %q", chirp.Body)
+	}
+}
+
+// doAdmin is doJSON with an API key, as sent by admins and Polka. A raw
+// string body is sent as text/plain.
+func doAdmin(t *testing.T, srv *httptest.Server, method, path, key string, body, out any) *http.Response {
+	t.Helper()
+	var rdr io.Reader
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case string:
+		rdr = strings.NewReader(b)
+		contentType = "text/plain; charset=utf-8"
+	default:
+		dat, err := json.Marshal(b)
+		if err != nil {
+			t.Fatalf("marshal request: %v", err)
+		}
+		rdr = bytes.NewReader(dat)
+	}
+	req, err := http.NewRequest(method, srv.URL+path, rdr)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if key != "" {
+		req.Header.Set("Authorization", "ApiKey "+key)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, path, err)
+		}
+	}
+	return resp
+}
+
+func TestAdminBannedWords(t *testing.T) {
+	srv, _ := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+
+	if resp := doAdmin(t, srv, "GET", "/admin/moderation/words", "", nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("no key: want %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+	if resp := doAdmin(t, srv, "GET", "/admin/moderation/words", "wrong", nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("wrong key: want %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	var words []BannedWord
+	doAdmin(t, srv, "GET", "/admin/moderation/words", testAdminKey, nil, &words)
+	if len(words) != 3 {
+		t.Errorf("seeded list: got %+v", words)
+	}
+
+	for _, bad := range []map[string]string{
+		{"word": "two words"},
+		{"word": "heisenberg", "action": "shout"},
+	} {
+		if resp := doAdmin(t, srv, "POST", "/admin/moderation/words", testAdminKey, bad, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("add %v: want %d, got %d", bad, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+
+	var added BannedWord
+	resp := doAdmin(t, srv, "POST", "/admin/moderation/words", testAdminKey, map[string]string{"word": " Heisenberg ", "action": "reject"}, &added)
+	if resp.StatusCode != http.StatusCreated || added.Word != "heisenberg" || added.Action != "reject" {
+		t.Fatalf("add: got %d %+v", resp.StatusCode, added)
+	}
+	if resp := doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": "say my name: heisenberg"}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("chirp with new word: want %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	var imported struct {
+		Imported int64 `json:"imported"`
+	}
+	list := "# from the writers' room\nkerfuffle reject\ncapn\ncapn flag\n"
+	if resp := doAdmin(t, srv, "POST", "/admin/moderation/words/import", testAdminKey, list, &imported); resp.StatusCode != http.StatusOK || imported.Imported != 2 {
+		t.Fatalf("import text: got %d %+v", resp.StatusCode, imported)
+	}
+	doAdmin(t, srv, "POST", "/admin/moderation/words/import", testAdminKey, []map[string]string{{"word": "pollos"}}, &imported)
+	if imported.Imported != 1 {
+		t.Errorf("import JSON: got %+v", imported)
+	}
+
+	doAdmin(t, srv, "GET", "/admin/moderation/words", testAdminKey, nil, &words)
+	actions := make(map[string]string)
+	for _, w := range words {
+		actions[w.Word] = w.Action
+	}
+	want := map[string]string{"kerfuffle": "reject", "sharbert": "mask", "fornax": "mask", "heisenberg": "reject", "capn": "flag", "pollos": "mask"}
+	if !reflect.DeepEqual(actions, want) {
+		t.Errorf("after import: got %v, want %v", actions, want)
+	}
+
+	if resp := doAdmin(t, srv, "DELETE", "/admin/moderation/words/Heisenberg", testAdminKey, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete: want %d, got %d", http.StatusNoContent, resp.StatusCode)
+	}
+	if resp := doAdmin(t, srv, "DELETE", "/admin/moderation/words/heisenberg", testAdminKey, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("delete again: want %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+	var chirp Chirp
+	if resp := doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": "say my name: heisenberg"}, &chirp); resp.StatusCode != http.StatusCreated {
+		t.Errorf("chirp after delete: want %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	srv, cfg := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	jesse := signUp(t, srv, "jesse@breakingbad.com")
+	if walt.Role != "user" {
+		t.Errorf("new user: want role %q, got %q", "user", walt.Role)
+	}
+
+	if resp := doJSON(t, srv, "GET", "/admin/metrics", "", nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("metrics without credentials: want %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "GET", "/admin/moderation/words", walt.Token, nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("words as user: want %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	rolePath := "/admin/users/" + walt.ID.String() + "/role"
+	if resp := doAdmin(t, srv, "PUT", rolePath, testAdminKey, map[string]string{"role": "overlord"}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown role: want %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	if resp := doAdmin(t, srv, "PUT", "/admin/users/"+uuid.NewString()+"/role", testAdminKey, map[string]string{"role": "admin"}, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown user: want %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+	if resp := doAdmin(t, srv, "DELETE", "/admin/users/"+uuid.NewString()+"/role", testAdminKey, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("revoke from unknown user: want %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+	// A user deleted after pathUser found them.
+	rec := httptest.NewRecorder()
+	cfg.setUserRole(rec, httptest.NewRequest("PUT", rolePath, nil), database.SetUserRoleParams{ID: uuid.New(), Role: "admin"})
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("user gone before the update: want %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	var granted User
+	if resp := doAdmin(t, srv, "PUT", rolePath, testAdminKey, map[string]string{"role": "moderator"}, &granted); resp.StatusCode != http.StatusOK || granted.Role != "moderator" {
+		t.Fatalf("grant moderator: got status %d, role %q", resp.StatusCode, granted.Role)
+	}
+
+	// The role is in the JWT, so it takes effect with the next token.
+	if resp := doJSON(t, srv, "GET", "/admin/moderation/words", walt.Token, nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("words with old token: want %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+	var refreshed struct {
+		Token string `json:"token"`
+	}
+	doJSON(t, srv, "POST", "/api/refresh", walt.RefreshToken, nil, &refreshed)
+	if resp := doJSON(t, srv, "GET", "/admin/moderation/words", refreshed.Token, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("words as moderator: want %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "GET", "/admin/webhooks", refreshed.Token, nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("webhooks as moderator: want %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "PUT", "/admin/users/"+jesse.ID.String()+"/role", refreshed.Token, map[string]string{"role": "admin"}, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("grant as moderator: want %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	doAdmin(t, srv, "PUT", "/admin/users/"+jesse.ID.String()+"/role", testAdminKey, map[string]string{"role": "admin"}, nil)
+	creds := map[string]string{"email": "jesse@breakingbad.com", "password": "hunter2"}
+	doJSON(t, srv, "POST", "/api/login", "", creds, &jesse)
+	if jesse.Role != "admin" {
+		t.Fatalf("login after grant: want role %q, got %q", "admin", jesse.Role)
+	}
+	var revoked User
+	if resp := doJSON(t, srv, "DELETE", rolePath, jesse.Token, nil, &revoked); resp.StatusCode != http.StatusOK || revoked.Role != "user" {
+		t.Errorf("revoke as admin: got status %d, role %q", resp.StatusCode, revoked.Role)
+	}
+}
+
+func TestModeration(t *testing.T) {
+	srv, _ := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	jesse := signUp(t, srv, "jesse@breakingbad.com")
+	hank := signUp(t, srv, "hank@dea.gov")
+	doAdmin(t, srv, "PUT", "/admin/users/"+hank.ID.String()+"/role", testAdminKey, map[string]string{"role": "moderator"}, nil)
+	doJSON(t, srv, "POST", "/api/login", "", map[string]string{"email": "hank@dea.gov", "password": "hunter2"}, &hank)
+
+	var chirp Chirp
+	doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": "Say my name"}, &chirp)
+	reports := "/api/chirps/" + chirp.ID + "/reports"
+	events := openStream(t, srv, "", "")
+	// expectEvent checks that the stream announced name about the chirp.
+	expectEvent := func(name string) {
+		t.Helper()
+		if e := nextEvent(t, events); e.Name != name || !strings.Contains(e.Data, chirp.ID) {
+			t.Errorf("stream: want %s of the chirp, got %+v", name, e)
+		}
+	}
+
+	var report ChirpReport
+	if resp := doJSON(t, srv, "POST", reports, jesse.Token, map[string]string{"reason": " spam "}, &report); resp.StatusCode != http.StatusCreated || report.Reason != "spam" {
+		t.Fatalf("report: got status %d, %+v", resp.StatusCode, report)
+	}
+	if resp := doJSON(t, srv, "POST", reports, jesse.Token, map[string]string{"reason": "spam"}, nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("second report: want %d, got %d", http.StatusConflict, resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "POST", reports, walt.Token, map[string]string{"reason": "oops"}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("report own chirp: want %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "POST", reports, hank.Token, map[string]string{"reason": ""}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("report without reason: want %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	if resp := doJSON(t, srv, "GET", "/admin/moderation/reports", jesse.Token, nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("queue as user: want %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+	var queue []ModerationQueueItem
+	doJSON(t, srv, "GET", "/admin/moderation/reports", hank.Token, nil, &queue)
+	if len(queue) != 1 || queue[0].Chirp.ID != chirp.ID || queue[0].OpenReports != 1 || len(queue[0].Reports) != 1 {
+		t.Fatalf("queue: got %+v", queue)
+	}
+
+	moderate := "/admin/moderation/chirps/" + chirp.ID
+	var action ModerationAction
+	if resp := doJSON(t, srv, "POST", moderate+"/hide", hank.Token, map[string]string{"reason": "spam"}, &action); resp.StatusCode != http.StatusOK {
+		t.Fatalf("hide: got status %d", resp.StatusCode)
+	}
+	if action.Action != "hide" || action.ModeratorID.UUID != hank.ID || action.ChirpUserID != walt.ID {
+		t.Errorf("hide: got %+v", action)
+	}
+	expectEvent(eventChirpDeleted)
+	if resp := doJSON(t, srv, "GET", "/api/chirps/"+chirp.ID, "", nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("get hidden chirp: want %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+	var listed []Chirp
+	doJSON(t, srv, "GET", "/api/chirps?author_id="+walt.ID.String(), "", nil, &listed)
+	if len(listed) != 0 {
+		t.Errorf("list with hidden chirp: got %d chirps", len(listed))
+	}
+	if resp := doJSON(t, srv, "POST", reports, hank.Token, map[string]string{"reason": "spam"}, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("report hidden chirp: want %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+	doJSON(t, srv, "GET", "/admin/moderation/reports", hank.Token, nil, &queue)
+	if len(queue) != 0 {
+		t.Errorf("queue after hide: got %d chirps", len(queue))
+	}
+
+	if resp := doAdmin(t, srv, "POST", moderate+"/restore", testAdminKey, nil, &action); resp.StatusCode != http.StatusOK || action.ModeratorID.Valid {
+		t.Errorf("restore with admin key: got status %d, %+v", resp.StatusCode, action)
+	}
+	if resp := doJSON(t, srv, "GET", "/api/chirps/"+chirp.ID, "", nil, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("get restored chirp: want %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	expectEvent(eventChirpCreated)
+	// Restoring a visible chirp announces nothing.
+	doAdmin(t, srv, "POST", moderate+"/restore", testAdminKey, nil, nil)
+	if resp := doJSON(t, srv, "DELETE", moderate, hank.Token, nil, &action); resp.StatusCode != http.StatusOK || action.Action != "remove" {
+		t.Errorf("remove: got status %d, %+v", resp.StatusCode, action)
+	}
+	expectEvent(eventChirpDeleted)
+	if resp := doJSON(t, srv, "POST", moderate+"/hide", hank.Token, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("hide removed chirp: want %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+	var trail []ModerationAction
+	doJSON(t, srv, "GET", "/admin/moderation/actions?chirp_id="+chirp.ID, hank.Token, nil, &trail)
+	var got []string
+	for _, a := range trail {
+		got = append(got, a.Action)
+	}
+	if !slices.Equal(got, []string{"remove", "restore", "restore", "hide"}) {
+		t.Errorf("audit trail: want remove, restore, restore, hide, got %v", got)
+	}
+}
+
+func TestFlaggedChirps(t *testing.T) {
+	srv, cfg := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	doAdmin(t, srv, "POST", "/admin/moderation/words", testAdminKey, map[string]string{"word": "sharbert", "action": "flag"}, nil)
+
+	var clean, flagged Chirp
+	doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": "Say my name"}, &clean)
+	if resp := doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": "Sharbert, sharbert"}, &flagged); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("flagged chirp: want %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+
+	var queue []ModerationQueueItem
+	doAdmin(t, srv, "GET", "/admin/moderation/reports", testAdminKey, nil, &queue)
+	if len(queue) != 1 || queue[0].Chirp.ID != flagged.ID || len(queue[0].Reports) != 1 {
+		t.Fatalf("queue: want the flagged chirp, got %+v", queue)
+	}
+	if r := queue[0].Reports[0]; r.ReporterID.Valid || r.Reason != "flagged words: sharbert" {
+		t.Errorf("flag: got %+v", r)
+	}
+
+	// Edits are checked again.
+	upgrade(t, cfg, walt)
+	doJSON(t, srv, "PUT", "/api/chirps/"+clean.ID, walt.Token, map[string]string{"body": "sharbert"}, nil)
+	doAdmin(t, srv, "GET", "/admin/moderation/reports", testAdminKey, nil, &queue)
+	if len(queue) != 2 || queue[1].Chirp.ID != clean.ID {
+		t.Errorf("queue after an edit: want both chirps, got %+v", queue)
+	}
+}
+
+func TestSuspension(t *testing.T) {
+	srv, _ := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	jesse := signUp(t, srv, "jesse@breakingbad.com")
+	creds := map[string]string{"email": "walt@breakingbad.com", "password": "hunter2"}
+	suspension := "/admin/users/" + walt.ID.String() + "/suspension"
+
+	type suspendedError struct {
+		Code           string     `json:"code"`
+		Reason         string     `json:"reason"`
+		SuspendedUntil *time.Time `json:"suspended_until"`
+	}
+
+	if resp := doJSON(t, srv, "PUT", suspension, jesse.Token, map[string]string{"reason": "spam"}, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("suspend as user: want %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+	past := map[string]any{"reason": "spam", "until": time.Now().Add(-time.Hour)}
+	if resp := doAdmin(t, srv, "PUT", suspension, testAdminKey, past, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("suspend until the past: want %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	unknown := "/admin/users/" + uuid.NewString() + "/suspension"
+	if resp := doAdmin(t, srv, "PUT", unknown, testAdminKey, map[string]string{"reason": "spam"}, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("suspend unknown user: want %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+	if resp := doAdmin(t, srv, "DELETE", unknown, testAdminKey, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("lift suspension of unknown user: want %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+	if resp := doAdmin(t, srv, "DELETE", "/admin/users/nope/suspension", testAdminKey, nil, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("lift suspension of invalid id: want %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	var s Suspension
+	if resp := doAdmin(t, srv, "PUT", suspension, testAdminKey, map[string]string{"reason": "spam"}, &s); resp.StatusCode != http.StatusOK {
+		t.Fatalf("suspend: got status %d", resp.StatusCode)
+	}
+	if s.UserID != walt.ID || s.Reason != "spam" || s.SuspendedUntil != nil {
+		t.Errorf("suspend: got %+v", s)
+	}
+
+	var refused suspendedError
+	if resp := doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": "Say my name"}, &refused); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("chirp while suspended: want %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+	if refused.Code != errCodeSuspended || refused.Reason != "spam" {
+		t.Errorf("chirp while suspended: got %+v", refused)
+	}
+	if resp := doJSON(t, srv, "GET", "/api/chirps", walt.Token, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("read while suspended: want %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	refused = suspendedError{}
+	if resp := doJSON(t, srv, "POST", "/api/login", "", creds, &refused); resp.StatusCode != http.StatusForbidden || refused.Code != errCodeSuspended {
+		t.Errorf("login while suspended: got status %d, %+v", resp.StatusCode, refused)
+	}
+	if resp := doJSON(t, srv, "POST", "/api/refresh", walt.RefreshToken, nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("refresh after suspension: want %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	if resp := doAdmin(t, srv, "DELETE", suspension, testAdminKey, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("lift suspension: got status %d", resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": "Say my name"}, nil); resp.StatusCode != http.StatusCreated {
+		t.Errorf("chirp after suspension: want %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "POST", "/api/login", "", creds, &walt); resp.StatusCode != http.StatusOK {
+		t.Errorf("login after suspension: want %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	doAdmin(t, srv, "PUT", suspension, testAdminKey, map[string]any{"reason": "cool off", "until": until}, nil)
+	refused = suspendedError{}
+	doJSON(t, srv, "POST", "/api/login", "", creds, &refused)
+	if refused.SuspendedUntil == nil || !refused.SuspendedUntil.Equal(until) {
+		t.Errorf("login while suspended: want suspended_until %v, got %+v", until, refused)
+	}
+}
+
+func TestSuspendedScheduledChirps(t *testing.T) {
+	srv, cfg := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	upgrade(t, cfg, walt)
+	suspension := "/admin/users/" + walt.ID.String() + "/suspension"
+
+	later := time.Now().Add(time.Hour)
+	if resp := doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]any{"body": "later", "publish_at": later}, nil); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("schedule: got status %d", resp.StatusCode)
+	}
+	doAdmin(t, srv, "PUT", suspension, testAdminKey, map[string]string{"reason": "spam"}, nil)
+
+	events := openStream(t, srv, "", "")
+	cfg.publishDueChirps(context.Background(), later)
+	var chirps []Chirp
+	doJSON(t, srv, "GET", "/api/chirps?author_id="+walt.ID.String(), "", nil, &chirps)
+	if len(chirps) != 0 {
+		t.Fatalf("published while suspended: got %+v", chirps)
+	}
+
+	doAdmin(t, srv, "DELETE", suspension, testAdminKey, nil, nil)
+	cfg.publishDueChirps(context.Background(), later)
+	doJSON(t, srv, "GET", "/api/chirps?author_id="+walt.ID.String(), "", nil, &chirps)
+	if len(chirps) != 1 || chirps[0].Body != "later" {
+		t.Fatalf("after the suspension: got %+v", chirps)
+	}
+	// The first event on the stream is the chirp published after the
+	// suspension, not one published during it.
+	if e := nextEvent(t, events); e.Name != eventChirpCreated || !strings.Contains(e.Data, chirps[0].ID) {
+		t.Errorf("stream: want %s of %s, got %+v", eventChirpCreated, chirps[0].ID, e)
+	}
+}
+
+func TestChirpLength(t *testing.T) {
+	srv, cfg := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+
+	// Each family emoji is one character made of seven code points.
+	family := "👨‍👩‍👧‍👦"
+	var chirp Chirp
+	if resp := doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": strings.Repeat(family, 140)}, &chirp); resp.StatusCode != http.StatusCreated {
+		t.Errorf("140 emoji: want %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": strings.Repeat(family, 141)}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("141 emoji: want %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	body := "  cafe\u0301\a\r\n\n  tabs\tand  spaces \n"
+	doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": body}, &chirp)
+	if want := "caf\u00e9\n\n  tabs\tand  spaces"; chirp.Body != want {
+		t.Errorf("normalized body: got %q, want %q", chirp.Body, want)
+	}
+
+	upgrade(t, cfg, walt)
+	long := map[string]string{"body": strings.Repeat("a", 200)}
+	if resp := doJSON(t, srv, "POST", "/api/chirps", walt.Token, long, nil); resp.StatusCode != http.StatusCreated {
+		t.Errorf("Chirpy Red: want %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "POST", "/api/validate_chirp", "", long, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("validate signed out: want %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "POST", "/api/validate_chirp", walt.Token, long, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("validate as Chirpy Red: want %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestEntitlements(t *testing.T) {
+	srv, cfg := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	jesse := signUp(t, srv, "jesse@breakingbad.com")
+
+	var chirp Chirp
+	doJSON(t, srv, "POST", "/api/chirps", jesse.Token, map[string]string{"body": "yo"}, &chirp)
+	if resp := doJSON(t, srv, "PUT", "/api/chirps/"+chirp.ID, jesse.Token, map[string]string{"body": "yo yo"}, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("edit without Chirpy Red: want %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+	later := time.Now().Add(time.Hour)
+	scheduled := map[string]any{"body": "later", "publish_at": later}
+	if resp := doJSON(t, srv, "POST", "/api/chirps", jesse.Token, scheduled, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("schedule without Chirpy Red: want %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	cfg.plans.Standard.ChirpsPerMinute = 2
+	// A chirp that is refused does not count against the limit.
+	tooLong := map[string]string{"body": strings.Repeat("a", 141)}
+	if resp := doJSON(t, srv, "POST", "/api/chirps", jesse.Token, tooLong, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("too long: want %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	for range 2 {
+		if resp := doJSON(t, srv, "POST", "/api/chirps", jesse.Token, map[string]string{"body": "yo"}, nil); resp.StatusCode != http.StatusCreated {
+			t.Errorf("under the rate limit: want %d, got %d", http.StatusCreated, resp.StatusCode)
+		}
+	}
+	resp := doJSON(t, srv, "POST", "/api/chirps", jesse.Token, map[string]string{"body": "yo"}, nil)
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("over the rate limit: got %d, Retry-After %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	upgrade(t, cfg, walt)
+	past := map[string]any{"body": "earlier", "publish_at": time.Now().Add(-time.Minute)}
+	if resp := doJSON(t, srv, "POST", "/api/chirps", walt.Token, past, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("publish_at in the past: want %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	var sc ScheduledChirp
+	if resp := doJSON(t, srv, "POST", "/api/chirps", walt.Token, scheduled, &sc); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("schedule: got status %d", resp.StatusCode)
+	}
+	var cancelled ScheduledChirp
+	doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]any{"body": "never", "publish_at": later}, &cancelled)
+	if resp := doJSON(t, srv, "DELETE", "/api/scheduled_chirps/"+cancelled.ID, jesse.Token, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("cancel someone else's: want %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "DELETE", "/api/scheduled_chirps/"+cancelled.ID, walt.Token, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("cancel: want %d, got %d", http.StatusNoContent, resp.StatusCode)
+	}
+	var pending []ScheduledChirp
+	doJSON(t, srv, "GET", "/api/scheduled_chirps", walt.Token, nil, &pending)
+	if len(pending) != 1 || pending[0].ID != sc.ID {
+		t.Fatalf("scheduled chirps: got %+v", pending)
+	}
+
+	cfg.publishDueChirps(context.Background(), time.Now())
+	var chirps []Chirp
+	doJSON(t, srv, "GET", "/api/chirps?author_id="+walt.ID.String(), "", nil, &chirps)
+	if len(chirps) != 0 {
+		t.Errorf("published early: got %+v", chirps)
+	}
+	cfg.publishDueChirps(context.Background(), later)
+	doJSON(t, srv, "GET", "/api/chirps?author_id="+walt.ID.String(), "", nil, &chirps)
+	if len(chirps) != 1 || chirps[0].Body != "later" {
+		t.Errorf("after publishing: got %+v", chirps)
+	}
+	doJSON(t, srv, "GET", "/api/scheduled_chirps", walt.Token, nil, &pending)
+	if len(pending) != 0 {
+		t.Errorf("still scheduled after publishing: %+v", pending)
+	}
+
+	// Chirps scheduled on Chirpy Red are dropped if the user leaves it.
+	doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]any{"body": "even later", "publish_at": later}, nil)
+	if _, err := cfg.db.DowngradeChirpyPlus(context.Background(), walt.ID); err != nil {
+		t.Fatal(err)
+	}
+	cfg.publishDueChirps(context.Background(), later)
+	doJSON(t, srv, "GET", "/api/chirps?author_id="+walt.ID.String(), "", nil, &chirps)
+	if len(chirps) != 1 {
+		t.Errorf("published after downgrading: got %+v", chirps)
+	}
+	doJSON(t, srv, "GET", "/api/scheduled_chirps", walt.Token, nil, &pending)
+	if len(pending) != 0 {
+		t.Errorf("still scheduled after downgrading: %+v", pending)
+	}
+}
+
+func TestPolkaWebhook(t *testing.T) {
+	srv, cfg := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	isRed := func() bool {
+		t.Helper()
+		user, err := cfg.db.GetUser(context.Background(), walt.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user.IsChirpyRed
+	}
+	event := func(id, name string, userID any) map[string]any {
+		return map[string]any{"id": id, "event": name, "data": map[string]any{"user_id": userID}}
+	}
+	post := func(body any) int {
+		t.Helper()
+		return doAdmin(t, srv, "POST", "/api/polka/webhooks", testPolkaKey, body, nil).StatusCode
+	}
+
+	for name, tc := range map[string]struct {
+		body any
+		want int
+	}{
+		"malformed":    {"{", http.StatusBadRequest},
+		"no event":     {event("", "", walt.ID), http.StatusBadRequest},
+		"bad user":     {event("", "user.upgraded", "walt"), http.StatusBadRequest},
+		"unknown user": {event("", "user.upgraded", uuid.New()), http.StatusNotFound},
+		"other event":  {event("", "user.payment_failed", nil), http.StatusNoContent},
+	} {
+		if got := post(tc.body); got != tc.want {
+			t.Errorf("%s: want %d, got %d", name, tc.want, got)
+		}
+	}
+
+	if got := post(event("evt_1", "user.upgraded", walt.ID)); got != http.StatusNoContent || !isRed() {
+		t.Fatalf("upgrade: got %d, red %v", got, isRed())
+	}
+	if got := post(event("evt_2", "subscription.cancelled", walt.ID)); got != http.StatusNoContent || isRed() {
+		t.Fatalf("cancel: got %d, red %v", got, isRed())
+	}
+	if got := post(event("evt_1", "user.upgraded", walt.ID)); got != http.StatusNoContent || isRed() {
+		t.Errorf("replayed upgrade: got %d, red %v", got, isRed())
+	}
+
+	var events []WebhookEvent
+	if resp := doAdmin(t, srv, "GET", "/admin/webhooks/events?user_id="+walt.ID.String(), testAdminKey, nil, &events); resp.StatusCode != http.StatusOK {
+		t.Fatalf("list events: got status %d", resp.StatusCode)
+	}
+	if len(events) != 2 {
+		t.Fatalf("list events: got %+v", events)
+	}
+	byID := map[string]WebhookEvent{}
+	for _, e := range events {
+		byID[e.EventID] = e
+	}
+	if e := byID["evt_1"]; e.Status != "processed" || e.Deliveries != 2 {
+		t.Errorf("evt_1: got %+v", e)
+	}
+	if e := byID["evt_2"]; e.Event != "subscription.cancelled" || e.Deliveries != 1 {
+		t.Errorf("evt_2: got %+v", e)
+	}
+
+	doAdmin(t, srv, "GET", "/admin/webhooks/events", testAdminKey, nil, &events)
+	statuses := map[string]int{}
+	for _, e := range events {
+		statuses[e.Status]++
+	}
+	if want := map[string]int{"processed": 2, "failed": 1, "ignored": 1}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("statuses: got %v, want %v", statuses, want)
+	}
+}
+
+func TestPolkaWebhookSignature(t *testing.T) {
+	srv, cfg := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	cfg.polkaKeys = []string{"new-key", "old-key"}
+	cfg.polkaRequireSignature = true
+
+	var lastBody string
+	send := func(body string, sign func(h http.Header)) int {
+		t.Helper()
+		req, err := http.NewRequest("POST", srv.URL+"/api/polka/webhooks", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sign(req.Header)
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		lastBody = string(b)
+		return resp.StatusCode
+	}
+	body := `{"id": "evt_signed", "event": "user.upgraded", "data": {"user_id": "` + walt.ID.String() + `"}}`
+
+	for name, tc := range map[string]struct {
+		sign func(h http.Header)
+		want int
+	}{
+		"api key only": {func(h http.Header) { h.Set("Authorization", "ApiKey new-key") }, http.StatusUnauthorized},
+		"wrong key":    {func(h http.Header) { auth.SignWebhook(h, []byte(body), time.Now(), "other") }, http.StatusUnauthorized},
+		"stale":        {func(h http.Header) { auth.SignWebhook(h, []byte(body), time.Now().Add(-time.Hour), "new-key") }, http.StatusUnauthorized},
+		"old key":      {func(h http.Header) { auth.SignWebhook(h, []byte(body), time.Now(), "old-key") }, http.StatusNoContent},
+		"new key":      {func(h http.Header) { auth.SignWebhook(h, []byte(body), time.Now(), "new-key") }, http.StatusNoContent},
+	} {
+		if got := send(body, tc.sign); got != tc.want {
+			t.Errorf("%s: want %d, got %d", name, tc.want, got)
+		}
+		// Why a delivery failed verification stays in the server log.
+		if tc.want == http.StatusUnauthorized && !strings.Contains(lastBody, `"unauthorized"`) {
+			t.Errorf("%s: want a fixed error message, got %s", name, lastBody)
+		}
+	}
+
+	// A signed delivery can be replayed until its timestamp goes stale, so
+	// it needs an event ID to be deduplicated on.
+	noID := `{"event": "user.downgraded", "data": {"user_id": "` + walt.ID.String() + `"}}`
+	if got := send(noID, func(h http.Header) { auth.SignWebhook(h, []byte(noID), time.Now(), "new-key") }); got != http.StatusBadRequest {
+		t.Errorf("signed without an id: want %d, got %d", http.StatusBadRequest, got)
+	}
+	var events []WebhookEvent
+	doAdmin(t, srv, "GET", "/admin/webhooks/events", testAdminKey, nil, &events)
+	if len(events) != 1 || events[0].Deliveries != 2 || events[0].Status != "processed" {
+		t.Errorf("want the replayed event logged once, got %+v", events)
+	}
+
+	cfg.polkaRequireSignature = false
+	if got := send(noID, func(h http.Header) { h.Set("Authorization", "ApiKey old-key") }); got != http.StatusNoContent {
+		t.Errorf("api key without an id: want %d, got %d", http.StatusNoContent, got)
+	}
+	if got := send(body, func(h http.Header) { h.Set("Authorization", "ApiKey old-key") }); got != http.StatusNoContent {
+		t.Errorf("api key with signatures optional: want %d, got %d", http.StatusNoContent, got)
+	}
+	if got := send(body, func(h http.Header) { h.Set("Authorization", "ApiKey other") }); got != http.StatusUnauthorized {
+		t.Errorf("wrong api key: want %d, got %d", http.StatusUnauthorized, got)
+	}
+}
+
+// slowUpgrades holds every upgrade long enough for concurrent deliveries of
+// the same event to overlap.
+type slowUpgrades struct {
+	store.Store
+}
+
+func (s slowUpgrades) UpgradeChirpyPlus(ctx context.Context, id uuid.UUID) (database.User, error) {
+	time.Sleep(50 * time.Millisecond)
+	return s.Store.UpgradeChirpyPlus(ctx, id)
+}
+
+func TestPolkaWebhookConcurrentRedeliveries(t *testing.T) {
+	srv, cfg := newTestServer(t)
+	cfg.db = slowUpgrades{cfg.db}
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(receiver.Close)
+	var sub WebhookSubscription
+	params := map[string]any{"url": receiver.URL, "events": []string{"user.upgraded"}}
+	if resp := doAdmin(t, srv, "POST", "/admin/webhooks", testAdminKey, params, &sub); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("subscribe: got status %d", resp.StatusCode)
+	}
+
+	// Polka may redeliver an event before the first delivery is handled.
+	const n = 10
+	body := `{"id": "evt_race", "event": "user.upgraded", "data": {"user_id": "` + walt.ID.String() + `"}}`
+	var wg sync.WaitGroup
+	codes := make([]int, n)
+	for i := range n {
+		wg.Go(func() {
+			req, err := http.NewRequest("POST", srv.URL+"/api/polka/webhooks", strings.NewReader(body))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			req.Header.Set("Authorization", "ApiKey "+testPolkaKey)
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			codes[i] = resp.StatusCode
+		})
+	}
+	wg.Wait()
+	for i, code := range codes {
+		if code != http.StatusNoContent {
+			t.Errorf("delivery %d: want %d, got %d", i, http.StatusNoContent, code)
+		}
+	}
+
+	var events []WebhookEvent
+	doAdmin(t, srv, "GET", "/admin/webhooks/events", testAdminKey, nil, &events)
+	if len(events) != 1 || events[0].Deliveries != n || events[0].Status != "processed" {
+		t.Errorf("want one processed event with %d deliveries, got %+v", n, events)
+	}
+	var deliveries []WebhookDelivery
+	doAdmin(t, srv, "GET", "/admin/webhooks/"+sub.ID.String()+"/deliveries", testAdminKey, nil, &deliveries)
+	if len(deliveries) != 1 {
+		t.Errorf("want the upgrade emitted once, got %d deliveries", len(deliveries))
+	}
+	samples := scrapeMetrics(t, srv)
+	for series, want := range map[string]float64{
+		`chirpy_webhook_events_total{result="processed",source="polka"}`: 1,
+		`chirpy_webhook_events_total{result="duplicate",source="polka"}`: n - 1,
+	} {
+		if got := samples[series]; got != want {
+			t.Errorf("%s: want %v, got %v", series, want, got)
+		}
+	}
+}
+
+func TestOutgoingWebhooks(t *testing.T) {
+	srv, cfg := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	jesse := signUp(t, srv, "jesse@breakingbad.com")
+
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	var (
+		mu    sync.Mutex
+		got   []received
+		fail  = true
+		calls int
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if fail {
+			fail = false
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		got = append(got, received{r.Header, body})
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(receiver.Close)
+
+	subscribe := func(path, token string, events ...string) WebhookSubscription {
+		t.Helper()
+		var sub WebhookSubscription
+		params := map[string]any{"url": receiver.URL, "events": events}
+		var resp *http.Response
+		if token == "" {
+			resp = doAdmin(t, srv, "POST", path, testAdminKey, params, &sub)
+		} else {
+			resp = doJSON(t, srv, "POST", path, token, params, &sub)
+		}
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("subscribe: got status %d", resp.StatusCode)
+		}
+		if sub.Secret == "" {
+			t.Fatal("subscribe: no secret in response")
+		}
+		return sub
+	}
+	waltSub := subscribe("/api/webhooks", walt.Token, "chirp.created")
+	subscribe("/api/webhooks", jesse.Token, "user.upgraded")
+	adminSub := subscribe("/admin/webhooks", "", "user.upgraded")
+
+	bad := map[string]any{"url": receiver.URL, "events": []string{"chirp.exploded"}}
+	if resp := doJSON(t, srv, "POST", "/api/webhooks", walt.Token, bad, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown event: want 400, got %d", resp.StatusCode)
+	}
+	bad = map[string]any{"url": "ftp://example.com", "events": []string{"chirp.created"}}
+	if resp := doJSON(t, srv, "POST", "/api/webhooks", walt.Token, bad, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("ftp url: want 400, got %d", resp.StatusCode)
+	}
+
+	var subs []WebhookSubscription
+	doJSON(t, srv, "GET", "/api/webhooks", walt.Token, nil, &subs)
+	if len(subs) != 1 || subs[0].ID != waltSub.ID || subs[0].Secret != "" {
+		t.Errorf("list: want walt's subscription without its secret, got %+v", subs)
+	}
+
+	var chirp Chirp
+	if resp := doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": "Say my name"}, &chirp); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create chirp: got status %d", resp.StatusCode)
+	}
+	polka := `{"event": "user.upgraded", "data": {"user_id": "` + walt.ID.String() + `"}}`
+	if resp := doAdmin(t, srv, "POST", "/api/polka/webhooks", testPolkaKey, polka, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("polka: got status %d", resp.StatusCode)
+	}
+
+	deliver := func(now time.Time) (int, int) {
+		t.Helper()
+		cfg.deliverDueWebhooks(context.Background(), now)
+		mu.Lock()
+		defer mu.Unlock()
+		return calls, len(got)
+	}
+	// Jesse doesn't hear about Walt's upgrade. The first attempt fails and
+	// is retried after a backoff.
+	now := time.Now()
+	deliver(now)
+	if c, n := deliver(now); c != 2 || n != 1 {
+		t.Fatalf("first round: want 2 calls and 1 delivery, got %d and %d", c, n)
+	}
+	if c, n := deliver(now.Add(time.Minute)); c != 3 || n != 2 {
+		t.Fatalf("retry: want 3 calls and 2 deliveries, got %d and %d", c, n)
+	}
+
+	secrets := map[string]string{waltSub.ID.String(): waltSub.Secret, adminSub.ID.String(): adminSub.Secret}
+	events := map[string]bool{}
+	for _, r := range got {
+		var payload struct {
+			Event string          `json:"event"`
+			Data  json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(r.body, &payload); err != nil {
+			t.Fatalf("decode delivery: %v", err)
+		}
+		events[payload.Event] = true
+		var ok bool
+		for _, secret := range secrets {
+			if auth.VerifyWebhookSignature(r.header, r.body, []string{secret}, time.Now(), time.Minute) == nil {
+				ok = true
+			}
+		}
+		if !ok {
+			t.Errorf("%s: signature doesn't verify", payload.Event)
+		}
+	}
+	if !events["chirp.created"] || !events["user.upgraded"] {
+		t.Errorf("want chirp.created and user.upgraded, got %v", events)
+	}
+
+	var deliveries, adminDeliveries []WebhookDelivery
+	doJSON(t, srv, "GET", "/api/webhooks/"+waltSub.ID.String()+"/deliveries", walt.Token, nil, &deliveries)
+	doAdmin(t, srv, "GET", "/admin/webhooks/"+adminSub.ID.String()+"/deliveries", testAdminKey, nil, &adminDeliveries)
+	deliveries = append(deliveries, adminDeliveries...)
+	attempts := 0
+	for _, d := range deliveries {
+		if d.Status != "delivered" || d.ResponseStatus == nil || *d.ResponseStatus != http.StatusNoContent {
+			t.Errorf("%s: want delivered with 204, got %+v", d.Event, d)
+		}
+		attempts += int(d.Attempts)
+	}
+	if len(deliveries) != 2 || attempts != 3 {
+		t.Errorf("deliveries: want 2 with 3 attempts, got %d with %d", len(deliveries), attempts)
+	}
+
+	path := "/api/webhooks/" + adminSub.ID.String() + "/deliveries"
+	if resp := doJSON(t, srv, "GET", path, walt.Token, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("someone else's deliveries: want 404, got %d", resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "DELETE", "/api/webhooks/"+adminSub.ID.String(), walt.Token, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("delete someone else's: want 404, got %d", resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "DELETE", "/api/webhooks/"+waltSub.ID.String(), walt.Token, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete: want 204, got %d", resp.StatusCode)
+	}
+}
+
+func TestWebhookClientRefusesPrivateAddresses(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(receiver.Close)
+	_, err := newWebhookClient(false).Get(receiver.URL)
+	if !errors.Is(err, errPrivateAddress) {
+		t.Errorf("want %v, got %v", errPrivateAddress, err)
+	}
+}
+
+type sseEvent struct {
+	ID, Name, Data string
+	Comment        bool
+}
+
+// openStream connects to the chirp stream and returns its events, comments
+// included, as they arrive.
+func openStream(t *testing.T, srv *httptest.Server, query, lastID string) <-chan sseEvent {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/chirps/stream"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("open stream: got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+		sc := bufio.NewScanner(resp.Body)
+		var e sseEvent
+		for sc.Scan() {
+			line := sc.Text()
+			switch {
+			case line == "":
+				events <- e
+				e = sseEvent{}
+			case strings.HasPrefix(line, ":"):
+				e.Comment = true
+			default:
+				field, value, _ := strings.Cut(line, ": ")
+				switch field {
+				case "id":
+					e.ID = value
+				case "event":
+					e.Name = value
+				case "data":
+					e.Data = value
+				}
+			}
+		}
+	}()
+	return events
+}
+
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatal("stream closed")
+			}
+			if !e.Comment {
+				return e
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an event")
+		}
+	}
+}
+
+func TestChirpStream(t *testing.T) {
+	srv, _ := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	jesse := signUp(t, srv, "jesse@breakingbad.com")
+
+	all := openStream(t, srv, "", "")
+	waltOnly := openStream(t, srv, "?author_id="+walt.ID.String(), "")
+
+	post := func(user User, body string) Chirp {
+		t.Helper()
+		var c Chirp
+		if resp := doJSON(t, srv, "POST", "/api/chirps", user.Token, map[string]string{"body": body}, &c); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create chirp: got status %d", resp.StatusCode)
+		}
+		return c
+	}
+	jesseChirp := post(jesse, "Yeah, science!")
+	waltChirp := post(walt, "Say my name")
+	if resp := doJSON(t, srv, "DELETE", "/api/chirps/"+waltChirp.ID, walt.Token, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete chirp: got status %d", resp.StatusCode)
+	}
+
+	var first sseEvent
+	for i, want := range []struct{ name, id string }{
+		{"chirp.created", jesseChirp.ID},
+		{"chirp.created", waltChirp.ID},
+		{"chirp.deleted", waltChirp.ID},
+	} {
+		e := nextEvent(t, all)
+		var data struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal([]byte(e.Data), &data); err != nil {
+			t.Fatalf("decode %q: %v", e.Data, err)
+		}
+		if e.Name != want.name || data.ID != want.id || e.ID == "" {
+			t.Errorf("event %d: want %s of %s, got %+v", i, want.name, want.id, e)
+		}
+		if i == 0 {
+			first = e
+		}
+	}
+	for _, want := range []string{"chirp.created", "chirp.deleted"} {
+		if e := nextEvent(t, waltOnly); e.Name != want || !strings.Contains(e.Data, waltChirp.ID) {
+			t.Errorf("author stream: want %s of walt's chirp, got %+v", want, e)
+		}
+	}
+
+	resumed := openStream(t, srv, "", first.ID)
+	for _, want := range []string{"chirp.created", "chirp.deleted"} {
+		if e := nextEvent(t, resumed); e.Name != want || !strings.Contains(e.Data, waltChirp.ID) {
+			t.Errorf("resume: want %s of walt's chirp, got %+v", want, e)
+		}
+	}
+	if e := nextEvent(t, openStream(t, srv, "", "0-1")); e.Name != streamEventReset {
+		t.Errorf("unknown Last-Event-ID: want a reset event, got %+v", e)
+	}
+}
+
+func TestChirpStreamHeartbeat(t *testing.T) {
+	srv, cfg := newTestServer(t)
+	cfg.chirpHub.heartbeat = 10 * time.Millisecond
+	events := openStream(t, srv, "", "")
+	select {
+	case e := <-events:
+		if !e.Comment {
+			t.Errorf("want a heartbeat comment, got %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no heartbeat")
+	}
+}
+
+func TestChirpHubDropsSlowSubscribers(t *testing.T) {
+	h := newChirpHub()
+	slow, _, _ := h.subscribe(uuid.NullUUID{}, "")
+	author := uuid.New()
+	for i := range chirpHubBuffer + 1 {
+		h.publish(eventChirpCreated, author, i)
+	}
+	n := 0
+	for range slow.C {
+		n++
+	}
+	if n != chirpHubBuffer {
+		t.Errorf("want %d buffered events before the drop, got %d", chirpHubBuffer, n)
+	}
+
+	// The dropped subscriber picks up where it left off.
+	_, replay, missed := h.subscribe(uuid.NullUUID{}, h.eventID(uint64(n)))
+	if missed || len(replay) != 1 || string(replay[0].Data) != strconv.Itoa(chirpHubBuffer) {
+		t.Errorf("resume: want the one missed event, got %v (missed %v)", replay, missed)
+	}
+
+	for i := range chirpHubBacklog {
+		h.publish(eventChirpCreated, author, i)
+	}
+	if _, _, missed := h.subscribe(uuid.NullUUID{}, h.eventID(1)); !missed {
+		t.Error("resume past the backlog: want missed")
+	}
+}
+
+type wsClient struct {
+	t    *testing.T
+	c    *websocket.Conn
+	msgs chan wsMessage
+	// err is why reading stopped, set before msgs is closed.
+	err error
+}
+
+// dialWS opens a WebSocket as the user with token and reads its messages in
+// the background.
+func dialWS(t *testing.T, srv *httptest.Server, token string) (*wsClient, error) {
+	t.Helper()
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	c, resp, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http")+"/api/ws", &websocket.DialOptions{
+		HTTPHeader: header,
+	})
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("%w: status %d", err, resp.StatusCode)
+		}
+		return nil, err
+	}
+	t.Cleanup(func() { c.CloseNow() })
+	ws := &wsClient{t: t, c: c, msgs: make(chan wsMessage, 16)}
+	go func() {
+		defer close(ws.msgs)
+		for {
+			var m wsMessage
+			if err := wsjson.Read(context.Background(), c, &m); err != nil {
+				ws.err = err
+				return
+			}
+			ws.msgs <- m
+		}
+	}()
+	return ws, nil
+}
+
+func (ws *wsClient) send(cmd any) {
+	ws.t.Helper()
+	if err := wsjson.Write(context.Background(), ws.c, cmd); err != nil {
+		ws.t.Fatalf("websocket write: %v", err)
+	}
+}
+
+func (ws *wsClient) next() wsMessage {
+	ws.t.Helper()
+	select {
+	case m, ok := <-ws.msgs:
+		if !ok {
+			ws.t.Fatal("websocket closed")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		ws.t.Fatal("timed out waiting for a websocket message")
+	}
+	return wsMessage{}
+}
+
+// events reads n messages and returns them as "channel event" strings.
+func (ws *wsClient) events(n int) []string {
+	ws.t.Helper()
+	var got []string
+	for range n {
+		m := ws.next()
+		got = append(got, m.Channel+" "+m.Event)
+	}
+	slices.Sort(got)
+	return got
+}
+
+func TestWebSocket(t *testing.T) {
+	srv, _ := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	jesse := signUp(t, srv, "jesse@breakingbad.com")
+
+	if _, err := dialWS(t, srv, ""); err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Errorf("without a token: want 401, got %v", err)
+	}
+
+	waltWS, err := dialWS(t, srv, walt.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jesseWS, err := dialWS(t, srv, jesse.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sub := range []struct {
+		ws  *wsClient
+		cmd wsCommand
+	}{
+		{waltWS, wsCommand{Type: "subscribe", Channel: "feed"}},
+		{waltWS, wsCommand{Type: "subscribe", Channel: "notifications"}},
+		{jesseWS, wsCommand{Type: "subscribe", Channel: "notifications"}},
+		{jesseWS, wsCommand{Type: "subscribe", Channel: "author", AuthorID: walt.ID}},
+	} {
+		sub.ws.send(sub.cmd)
+		if m := sub.ws.next(); m.Type != "subscribed" || m.Channel != sub.cmd.Channel {
+			t.Fatalf("%+v: want subscribed, got %+v", sub.cmd, m)
+		}
+	}
+	jesseWS.send(wsCommand{Type: "subscribe", Channel: "author"})
+	if m := jesseWS.next(); m.Type != "error" {
+		t.Errorf("author without author_id: want error, got %+v", m)
+	}
+	jesseWS.send("hello")
+	if m := jesseWS.next(); m.Type != "error" {
+		t.Errorf("not a command: want error, got %+v", m)
+	}
+
+	var waltChirp, reply Chirp
+	doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": "Say my name, @jesse@breakingbad.com"}, &waltChirp)
+	if got, want := waltWS.events(1), []string{"feed chirp.created"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("walt: want %v, got %v", want, got)
+	}
+	if got, want := jesseWS.events(2), []string{"author chirp.created", "notifications mention"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("jesse: want %v, got %v", want, got)
+	}
+
+	doJSON(t, srv, "POST", "/api/chirps", jesse.Token, map[string]any{"body": "Heisenberg", "in_reply_to": waltChirp.ID}, &reply)
+	if got, want := waltWS.events(2), []string{"feed chirp.created", "notifications reply"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("walt after reply: want %v, got %v", want, got)
+	}
+
+	jesseWS.send(wsCommand{Type: "unsubscribe", Channel: "author", AuthorID: walt.ID})
+	if m := jesseWS.next(); m.Type != "unsubscribed" {
+		t.Errorf("unsubscribe: want unsubscribed, got %+v", m)
+	}
+	doJSON(t, srv, "DELETE", "/api/chirps/"+waltChirp.ID, walt.Token, nil, nil)
+	if m := waltWS.next(); m.Event != "chirp.deleted" || !strings.Contains(string(m.Data), waltChirp.ID) {
+		t.Errorf("walt after delete: want chirp.deleted, got %+v", m)
+	}
+	select {
+	case m := <-jesseWS.msgs:
+		t.Errorf("jesse after unsubscribing: want nothing, got %+v", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := waltWS.c.Ping(ctx); err != nil {
+		t.Errorf("ping: %v", err)
+	}
+
+	// The test server allows two connections per user.
+	if _, err := dialWS(t, srv, walt.Token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dialWS(t, srv, walt.Token); err == nil || !strings.Contains(err.Error(), "status 429") {
+		t.Errorf("third connection: want 429, got %v", err)
+	}
+}
+
+// closed waits for the server to close the connection and returns the close
+// status it sent.
+func (ws *wsClient) closed(timeout time.Duration) websocket.StatusCode {
+	ws.t.Helper()
+	deadline := time.After(timeout)
+	for {
+		select {
+		case m, ok := <-ws.msgs:
+			if !ok {
+				return websocket.CloseStatus(ws.err)
+			}
+			ws.t.Errorf("want the connection closed, got %+v", m)
+		case <-deadline:
+			ws.t.Fatal("timed out waiting for the websocket to close")
+		}
+	}
+}
+
+func TestWebSocketSessionEnds(t *testing.T) {
+	srv, _ := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	jesse := signUp(t, srv, "jesse@breakingbad.com")
+
+	waltWS, err := dialWS(t, srv, walt.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waltWS.send(wsCommand{Type: "subscribe", Channel: "notifications"})
+	if m := waltWS.next(); m.Type != "subscribed" {
+		t.Fatalf("want subscribed, got %+v", m)
+	}
+	shortLived, err := auth.MakeJWT(jesse.ID, auth.RoleUser, testSecret, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jesseWS, err := dialWS(t, srv, shortLived)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suspension := "/admin/users/" + walt.ID.String() + "/suspension"
+	if resp := doAdmin(t, srv, "PUT", suspension, testAdminKey, map[string]string{"reason": "spam"}, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("suspend: got status %d", resp.StatusCode)
+	}
+	if got := waltWS.closed(time.Second); got != websocket.StatusPolicyViolation {
+		t.Errorf("suspended user: want close status %v, got %v", websocket.StatusPolicyViolation, got)
+	}
+	if _, err := dialWS(t, srv, walt.Token); err == nil || !strings.Contains(err.Error(), "status 403") {
+		t.Errorf("reconnect while suspended: want 403, got %v", err)
+	}
+	if got := jesseWS.closed(5 * time.Second); got != websocket.StatusPolicyViolation {
+		t.Errorf("expired token: want close status %v, got %v", websocket.StatusPolicyViolation, got)
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	srv, _ := newTestServer(t)
+	walt := signUp(t, srv, "walt@breakingbad.com")
+	jesse := signUp(t, srv, "jesse@breakingbad.com")
+
+	var chirp Chirp
+	doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": "Say my name, @jesse@breakingbad.com"}, &chirp)
+	doJSON(t, srv, "POST", "/api/chirps", jesse.Token, map[string]any{"body": "Heisenberg", "in_reply_to": chirp.ID}, nil)
+	doJSON(t, srv, "POST", "/api/chirps/"+chirp.ID+"/likes", jesse.Token, nil, nil)
+	doJSON(t, srv, "POST", "/api/chirps/"+chirp.ID+"/likes", jesse.Token, nil, nil)
+	doJSON(t, srv, "DELETE", "/api/chirps/"+chirp.ID+"/likes", jesse.Token, nil, nil)
+	doJSON(t, srv, "POST", "/api/chirps/"+chirp.ID+"/likes", jesse.Token, nil, nil)
+	doJSON(t, srv, "POST", "/api/chirps/"+chirp.ID+"/likes", walt.Token, nil, nil)
+	doJSON(t, srv, "POST", "/api/users/"+walt.ID.String()+"/follow", jesse.Token, nil, nil)
+	doJSON(t, srv, "DELETE", "/api/users/"+walt.ID.String()+"/follow", jesse.Token, nil, nil)
+	doJSON(t, srv, "POST", "/api/users/"+walt.ID.String()+"/follow", jesse.Token, nil, nil)
+
+	kinds := func(user User, query string) []string {
+		t.Helper()
+		var list []Notification
+		if resp := doJSON(t, srv, "GET", "/api/notifications"+query, user.Token, nil, &list); resp.StatusCode != http.StatusOK {
+			t.Fatalf("list notifications: got status %d", resp.StatusCode)
+		}
+		var out []string
+		for _, n := range list {
+			out = append(out, n.Kind)
+		}
+		return out
+	}
+	// Liking twice, liking again after unliking, following again after
+	// unfollowing and liking your own chirp don't notify.
+	if got, want := kinds(walt, ""), []string{"follow", "like", "reply"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("walt: want %v, got %v", want, got)
+	}
+	if got, want := kinds(jesse, ""), []string{"mention"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("jesse: want %v, got %v", want, got)
+	}
+
+	var page []Notification
+	resp := doJSON(t, srv, "GET", "/api/notifications?limit=2", walt.Token, nil, &page)
+	if len(page) != 2 || nextLink(resp.Header.Get("Link")) == "" {
+		t.Fatalf("first page: want 2 and a next link, got %d and %q", len(page), resp.Header.Get("Link"))
+	}
+
+	var marked map[string]int64
+	doJSON(t, srv, "POST", "/api/notifications/read", walt.Token, map[string]any{"ids": []uuid.UUID{page[0].ID}}, &marked)
+	if marked["marked"] != 1 {
+		t.Errorf("mark one read: got %v", marked)
+	}
+	if got, want := kinds(walt, "?unread=true"), []string{"like", "reply"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unread: want %v, got %v", want, got)
+	}
+	if resp := doJSON(t, srv, "POST", "/api/notifications/read", walt.Token, map[string]any{}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("mark read without ids: want 400, got %d", resp.StatusCode)
+	}
+	doJSON(t, srv, "POST", "/api/notifications/read", walt.Token, map[string]any{"all": true}, &marked)
+	if marked["marked"] != 2 || len(kinds(walt, "?unread=true")) != 0 {
+		t.Errorf("mark all read: got %v", marked)
+	}
+
+	var prefs NotificationPreferences
+	doJSON(t, srv, "GET", "/api/notifications/preferences", walt.Token, nil, &prefs)
+	if prefs != (NotificationPreferences{Mentions: true, Replies: true, Likes: true, Follows: true}) {
+		t.Errorf("default preferences: got %+v", prefs)
+	}
+	doJSON(t, srv, "PUT", "/api/notifications/preferences", walt.Token, map[string]bool{"likes": false}, &prefs)
+	if prefs != (NotificationPreferences{Mentions: true, Replies: true, Likes: false, Follows: true}) {
+		t.Errorf("after turning likes off: got %+v", prefs)
+	}
+	var other Chirp
+	doJSON(t, srv, "POST", "/api/chirps", walt.Token, map[string]string{"body": "I am the one who knocks"}, &other)
+	doJSON(t, srv, "POST", "/api/chirps/"+other.ID+"/likes", jesse.Token, nil, nil)
+	if got := kinds(walt, "?unread=true"); len(got) != 0 {
+		t.Errorf("muted like: want no notification, got %v", got)
+	}
+}
+
+// captureLogs sends everything logged through slog during the test to the
+// returned buffer, as JSON lines.
+func captureLogs(t *testing.T) *syncBuffer {
+	t.Helper()
+	buf := &syncBuffer{}
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(old) })
+	return buf
+}
+
+// syncBuffer is a bytes.Buffer that handlers may write to concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// lines decodes the log lines with the given message.
+func (b *syncBuffer) lines(t *testing.T, msg string) []map[string]any {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		if m["msg"] == msg {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func TestMiddleware(t *testing.T) {
+	srv, cfg := newTestServer(t)
+	logs := captureLogs(t)
+	user := signUp(t, srv, "walt@example.com")
+
+	req, _ := http.NewRequest("GET", srv.URL+"/api/chirps/"+uuid.NewString(), nil)
+	req.Header.Set("Authorization", "Bearer "+user.Token)
+	req.Header.Set(requestIDHeader, "req-42")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get(requestIDHeader); got != "req-42" {
+		t.Errorf("client request ID: want it echoed, got %q", got)
+	}
+
+	var access map[string]any
+	for _, l := range logs.lines(t, "request") {
+		if l["request_id"] == "req-42" {
+			access = l
+		}
+	}
+	if access == nil {
+		t.Fatal("no access log line for req-42")
+	}
+	want := map[string]any{
+		"method":  "GET",
+		"route":   "GET /api/chirps/{chirpID}",
+		"status":  float64(http.StatusNotFound),
+		"user_id": user.ID.String(),
+	}
+	for k, v := range want {
+		if access[k] != v {
+			t.Errorf("access log %s: want %v, got %v", k, v, access[k])
+		}
+	}
+	errLines := logs.lines(t, "responding with error")
+	if len(errLines) == 0 || errLines[len(errLines)-1]["request_id"] != "req-42" {
+		t.Errorf("error log: want request_id req-42, got %v", errLines)
+	}
+
+	req, _ = http.NewRequest("GET", srv.URL+"/api/healthz", nil)
+	req.Header.Set(requestIDHeader, "has spaces")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get(requestIDHeader); got == "" || got == "has spaces" {
+		t.Errorf("invalid request ID: want a generated one, got %q", got)
+	}
+
+	panicky := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), withRequestID, cfg.accessLog, recoverPanic)
+	rec := httptest.NewRecorder()
+	panicky.ServeHTTP(rec, httptest.NewRequest("POST", "/anything", nil))
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("panic: want a JSON 500, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	id := rec.Header().Get(requestIDHeader)
+	panics := logs.lines(t, "panic serving request")
+	if len(panics) != 1 || panics[0]["request_id"] != id || panics[0]["panic"] != "boom" {
+		t.Errorf("panic log: want one for request %s, got %v", id, panics)
+	}
+}
+
+// scrapeMetrics reads /metrics as the admin and returns every sample by its
+// series, e.g. `chirpy_logins_total{result="success"}`.
+func scrapeMetrics(t *testing.T, srv *httptest.Server) map[string]float64 {
+	t.Helper()
+	req, err := http.NewRequest("GET", srv.URL+"/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testMetricsToken)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("scrape: got status %d", resp.StatusCode)
+	}
+	samples := make(map[string]float64)
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("scrape: bad sample %q: %v", line, err)
+		}
+		samples[line[:i]] = v
+	}
+	return samples
+}
+
+// waitForMetric scrapes until series has the value want, for things counted
+// after the client has its response.
+func waitForMetric(t *testing.T, srv *httptest.Server, series string, want float64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := scrapeMetrics(t, srv)[series]
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: want %v, got %v", series, want, got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	srv, cfg := newTestServer(t)
+	if resp := doJSON(t, srv, "GET", "/metrics", "", nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("metrics without credentials: want 401, got %d", resp.StatusCode)
+	}
+	if resp := doJSON(t, srv, "GET", "/metrics", "wrong", nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("metrics with the wrong token: want 401, got %d", resp.StatusCode)
+	}
+	if resp := doAdmin(t, srv, "GET", "/metrics", testAdminKey, nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("metrics with the admin key: want 401, got %d", resp.StatusCode)
+	}
+
+	walt := signUp(t, srv, "walt@example.com")
+	doJSON(t, srv, "POST", "/api/login", "", map[string]string{"email": walt.Email, "password": "wrong"}, nil)
+	doJSON(t, srv, "GET", "/nowhere", "", nil, nil)
+	doAdmin(t, srv, "POST", "/api/polka/webhooks", testPolkaKey, map[string]any{"event": "user.payment_failed", "data": map[string]any{}}, nil)
+	failed := map[string]any{"id": "evt_1", "event": "user.payment_failed", "data": map[string]any{}}
+	doAdmin(t, srv, "POST", "/api/polka/webhooks", testPolkaKey, failed, nil)
+	doAdmin(t, srv, "POST", "/api/polka/webhooks", testPolkaKey, failed, nil)
+	doAdmin(t, srv, "POST", "/api/polka/webhooks", "wrong", failed, nil)
+	doAdmin(t, srv, "POST", "/api/polka/webhooks", testPolkaKey, "{", nil)
+	cfg.metrics.observeQuery("GetChirp", 3*time.Millisecond)
+
+	samples := scrapeMetrics(t, srv)
+	for series, want := range map[string]float64{
+		`chirpy_logins_total{result="success"}`:                                                      1,
+		`chirpy_logins_total{result="failure"}`:                                                      1,
+		`chirpy_http_requests_total{method="POST",route="POST /api/login",status="200"}`:             1,
+		`chirpy_http_requests_total{method="POST",route="POST /api/login",status="401"}`:             1,
+		`chirpy_http_requests_total{method="GET",route="unmatched",status="404"}`:                    1,
+		`chirpy_http_request_duration_seconds_count{method="POST",route="POST /api/login"}`:          2,
+		`chirpy_webhook_events_total{result="ignored",source="polka"}`:                               2,
+		`chirpy_webhook_events_total{result="duplicate",source="polka"}`:                             1,
+		`chirpy_webhook_events_total{result="unauthorized",source="polka"}`:                          1,
+		`chirpy_webhook_events_total{result="bad_request",source="polka"}`:                           1,
+		`chirpy_db_query_duration_seconds_count{query="GetChirp"}`:                                   1,
+		`chirpy_db_query_duration_seconds_bucket{query="GetChirp",le="0.0025"}`:                      0,
+		`chirpy_db_query_duration_seconds_bucket{query="GetChirp",le="0.005"}`:                       1,
+		`chirpy_http_requests_total{method="GET",route="GET /metrics",status="401"}`:                 3,
+		`chirpy_http_request_duration_seconds_count{method="POST",route="POST /api/polka/webhooks"}`: 5,
+	} {
+		if got := samples[series]; got != want {
+			t.Errorf("%s: want %v, got %v", series, want, got)
+		}
+	}
+
+	streamCtx, closeStream := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(streamCtx, "GET", srv.URL+"/api/chirps/stream", nil)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	waitForMetric(t, srv, `chirpy_stream_connections{kind="sse"}`, 1)
+	ws, err := dialWS(t, srv, walt.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForMetric(t, srv, `chirpy_stream_connections{kind="websocket"}`, 1)
+
+	closeStream()
+	ws.c.Close(websocket.StatusNormalClosure, "")
+	waitForMetric(t, srv, `chirpy_stream_connections{kind="sse"}`, 0)
+	waitForMetric(t, srv, `chirpy_stream_connections{kind="websocket"}`, 0)
+
+	cfg.metricsToken = ""
+	if resp := doJSON(t, srv, "GET", "/metrics", testMetricsToken, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("metrics without METRICS_TOKEN: want 404, got %d", resp.StatusCode)
+	}
+}
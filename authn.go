@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/Geraetefreund/chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+// errWrongAPIKey is returned by role for an ApiKey that isn't ADMIN_API_KEY.
+var errWrongAPIKey = errors.New("wrong API key")
+
+// authenticate returns the user ID from the request's bearer JWT.
+func (cfg *apiConfig) authenticate(r *http.Request) (uuid.UUID, error) {
+	tokenStr, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return auth.ValidateJWT(tokenStr, cfg.secret)
+}
+
+// role returns the caller's role. ADMIN_API_KEY, sent as an "ApiKey"
+// Authorization header, counts as an admin, so that the first admin can be
+// made and services can call admin routes without a user. Otherwise the role
+// is the one in the bearer JWT.
+func (cfg *apiConfig) role(r *http.Request) (auth.Role, error) {
+	if key, err := auth.GetAPIKey(r.Header); err == nil {
+		if cfg.adminKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(cfg.adminKey)) != 1 {
+			return "", errWrongAPIKey
+		}
+		return auth.RoleAdmin, nil
+	}
+	tokenStr, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return "", err
+	}
+	_, role, err := auth.ValidateJWTRole(tokenStr, cfg.secret)
+	return role, err
+}
+
+// requireRole only lets requests through from callers with at least the
+// given role.
+func (cfg *apiConfig) requireRole(min auth.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role, err := cfg.role(r)
+		if errors.Is(err, errWrongAPIKey) {
+			respondWithError(w, http.StatusForbidden, "Forbidden", nil)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "missing or invalid credentials", err)
+			return
+		}
+		if !role.AtLeast(min) {
+			respondWithError(w, http.StatusForbidden, "Forbidden", nil)
+			return
+		}
+		next(w, r)
+	}
+}
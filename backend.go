@@ -0,0 +1,50 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/Geraetefreund/chirpy/internal/dbmetrics"
+	"github.com/Geraetefreund/chirpy/internal/migrate"
+	"github.com/Geraetefreund/chirpy/internal/store"
+	"github.com/Geraetefreund/chirpy/internal/store/sqlite"
+	_ "github.com/lib/pq"
+)
+
+// backend is an open database together with the store and migration
+// dialect that go with it.
+type backend struct {
+	store   store.Store
+	db      *sql.DB
+	dialect migrate.Dialect
+}
+
+// openBackend connects to the database named by dbURL. The URL scheme picks
+// the backend: postgres:// or postgresql:// for Postgres, sqlite:// for an
+// embedded SQLite file. Every query the store runs is timed by observe,
+// unless it is nil.
+func openBackend(dbURL string, observe dbmetrics.Observer) (backend, error) {
+	scheme, _, _ := strings.Cut(dbURL, "://")
+	switch scheme {
+	case "postgres", "postgresql":
+		db, err := sql.Open("postgres", dbURL)
+		if err != nil {
+			return backend{}, err
+		}
+		var q database.DBTX = db
+		if observe != nil {
+			q = dbmetrics.Wrap(db, observe)
+		}
+		return backend{store: database.New(q), db: db, dialect: migrate.Postgres}, nil
+	case "sqlite":
+		db, err := sqlite.Open(dbURL)
+		if err != nil {
+			return backend{}, err
+		}
+		return backend{store: sqlite.NewObserved(db, observe), db: db, dialect: migrate.SQLite}, nil
+	default:
+		return backend{}, fmt.Errorf("unsupported DB_URL scheme %q", scheme)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// chirpHubBacklog is how many recent events are kept for clients that
+	// resume with Last-Event-ID.
+	chirpHubBacklog = 1024
+	// chirpHubBuffer is how many events a subscriber may fall behind by
+	// before it is dropped.
+	chirpHubBuffer = 64
+)
+
+// streamEvent is one event of the chirp stream, ready to be written.
+type streamEvent struct {
+	Seq      uint64
+	Name     string
+	AuthorID uuid.UUID
+	Data     []byte
+}
+
+// chirpHub fans chirp events out to stream subscribers. Publishing never
+// blocks: a subscriber whose buffer is full is dropped, and can reconnect
+// with Last-Event-ID to pick up where it left off from the backlog.
+//
+// Event IDs are "<epoch>-<seq>", where the epoch changes with every hub, so
+// IDs from before a restart are recognised as unknown.
+type chirpHub struct {
+	epoch     string
+	heartbeat time.Duration
+
+	mu      sync.Mutex
+	seq     uint64
+	backlog []streamEvent
+	subs    map[*chirpSub]struct{}
+}
+
+// chirpSub receives the events of one stream. C is closed when the
+// subscriber is dropped or unsubscribed.
+type chirpSub struct {
+	author uuid.NullUUID
+	C      chan streamEvent
+}
+
+func (s *chirpSub) wants(e streamEvent) bool {
+	return !s.author.Valid || s.author.UUID == e.AuthorID
+}
+
+func newChirpHub() *chirpHub {
+	return &chirpHub{
+		epoch:     strconv.FormatInt(time.Now().UnixNano(), 36),
+		heartbeat: 15 * time.Second,
+		subs:      make(map[*chirpSub]struct{}),
+	}
+}
+
+func (h *chirpHub) eventID(seq uint64) string {
+	return h.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+// publish sends an event about a chirp by author to every subscriber that
+// wants it.
+func (h *chirpHub) publish(name string, author uuid.UUID, data any) {
+	dat, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("couldn't encode stream event", "event", name, "error", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	e := streamEvent{Seq: h.seq, Name: name, AuthorID: author, Data: dat}
+	if len(h.backlog) == chirpHubBacklog {
+		h.backlog = append(h.backlog[:0], h.backlog[1:]...)
+	}
+	h.backlog = append(h.backlog, e)
+
+	for sub := range h.subs {
+		if !sub.wants(e) {
+			continue
+		}
+		select {
+		case sub.C <- e:
+		default:
+			delete(h.subs, sub)
+			close(sub.C)
+		}
+	}
+}
+
+// subscribe starts a stream of events, by author if it is set. If lastID
+// is set, the events after it are returned to be sent first; missed is
+// true if some of them are no longer in the backlog, or lastID is unknown.
+func (h *chirpHub) subscribe(author uuid.NullUUID, lastID string) (sub *chirpSub, replay []streamEvent, missed bool) {
+	sub = &chirpSub{author: author, C: make(chan streamEvent, chirpHubBuffer)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subs[sub] = struct{}{}
+	if lastID == "" {
+		return sub, nil, false
+	}
+
+	seq, err := h.parseEventID(lastID)
+	if err != nil || seq > h.seq {
+		return sub, nil, true
+	}
+	if len(h.backlog) > 0 && seq+1 < h.backlog[0].Seq {
+		missed = true
+	}
+	for _, e := range h.backlog {
+		if e.Seq > seq && sub.wants(e) {
+			replay = append(replay, e)
+		}
+	}
+	return sub, replay, missed
+}
+
+func (h *chirpHub) parseEventID(id string) (uint64, error) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != h.epoch {
+		return 0, fmt.Errorf("unknown event ID %q", id)
+	}
+	return strconv.ParseUint(seq, 10, 64)
+}
+
+// unsubscribe ends sub's stream, if it hasn't been dropped already.
+func (h *chirpHub) unsubscribe(sub *chirpSub) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.C)
+	}
+}
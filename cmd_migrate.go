@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Geraetefreund/chirpy/internal/migrate"
+)
+
+const migrateUsage = "usage: chirpy migrate up|down|status"
+
+// runMigrate implements the `chirpy migrate` subcommands and returns the
+// process exit code.
+func runMigrate(ctx context.Context, b backend, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	m, err := migrate.New(b.db, b.dialect)
+	if err != nil {
+		log.Printf("error loading migrations: %s", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "up":
+		results, err := m.Up(ctx)
+		for _, r := range results {
+			fmt.Printf("applied %s (%s)\n", r.Source.Path, r.Duration)
+		}
+		if err != nil {
+			log.Printf("migrate up: %s", err)
+			return 1
+		}
+		if len(results) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		r, err := m.Down(ctx)
+		if err != nil {
+			log.Printf("migrate down: %s", err)
+			return 1
+		}
+		fmt.Printf("rolled back %s (%s)\n", r.Source.Path, r.Duration)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			log.Printf("migrate status: %s", err)
+			return 1
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+		for _, s := range statuses {
+			appliedAt := "-"
+			if !s.AppliedAt.IsZero() {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Source.Version, s.State, appliedAt, s.Source.Path)
+		}
+		tw.Flush()
+		current, latest, err := m.Versions(ctx)
+		if err != nil {
+			log.Printf("migrate status: %s", err)
+			return 1
+		}
+		fmt.Printf("database is at version %d of %d\n", current, latest)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
+
+// prepareSchema makes sure the database schema matches this binary. With
+// apply set, pending migrations are run; otherwise a schema that is behind
+// is an error.
+func prepareSchema(ctx context.Context, b backend, apply bool) error {
+	m, err := migrate.New(b.db, b.dialect)
+	if err != nil {
+		return err
+	}
+	if apply {
+		results, err := m.Up(ctx)
+		for _, r := range results {
+			log.Printf("applied migration %s", r.Source.Path)
+		}
+		return err
+	}
+	if err := m.Check(ctx); err != nil {
+		if errors.Is(err, migrate.ErrSchemaBehind) {
+			return fmt.Errorf("%w; run `chirpy migrate up` or set MIGRATE_ON_START=true", err)
+		}
+		return err
+	}
+	return nil
+}
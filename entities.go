@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Entities are the hashtags and mentions in a chirp body. Offsets are byte
+// offsets into the body, end exclusive.
+type Entities struct {
+	Hashtags []Hashtag `json:"hashtags"`
+	Mentions []Mention `json:"mentions"`
+	// mentioned are the users the mentions resolved to, for storing and
+	// notifying them. They are never sent to clients: the user ID behind an
+	// @email would tell anyone whether that address is registered.
+	mentioned []mentionedUser
+}
+
+type Hashtag struct {
+	Tag   string `json:"tag"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Mention is an @email in a chirp body, whether or not it names a user.
+type Mention struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// mentionedUser is a mention resolved to the user it names.
+type mentionedUser struct {
+	UserID uuid.UUID
+	Start  int
+	End    int
+}
+
+// mentionText is an @mention before it has been resolved to a user.
+type mentionText struct {
+	Email string
+	Start int
+	End   int
+}
+
+// parseEntities finds the hashtags and mentions in a cleaned chirp body.
+// It works on whitespace separated words; an entity has to start a word.
+// A hashtag is # followed by letters, digits and underscores, at least one
+// of them a letter, and is normalised to lower case. A mention is @
+// followed by a user's email address.
+func parseEntities(body string) ([]Hashtag, []mentionText) {
+	var tags []Hashtag
+	var mentions []mentionText
+	for start, word := range words(body) {
+		switch {
+		case strings.HasPrefix(word, "#"):
+			name := word[1:]
+			if end := strings.IndexFunc(name, func(r rune) bool { return !isTagRune(r) }); end >= 0 {
+				name = name[:end]
+			}
+			if strings.IndexFunc(name, unicode.IsLetter) >= 0 {
+				tags = append(tags, Hashtag{Tag: strings.ToLower(name), Start: start, End: start + 1 + len(name)})
+			}
+		case strings.HasPrefix(word, "@"):
+			email := strings.TrimRight(word[1:], `.,;:!?)"'`)
+			if at := strings.Index(email, "@"); at > 0 && at < len(email)-1 {
+				mentions = append(mentions, mentionText{Email: email, Start: start, End: start + 1 + len(email)})
+			}
+		}
+	}
+	return tags, mentions
+}
+
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// words yields the whitespace separated words of s with their byte offsets.
+func words(s string) func(yield func(int, string) bool) {
+	return func(yield func(int, string) bool) {
+		start := -1
+		for i, r := range s {
+			if unicode.IsSpace(r) {
+				if start >= 0 && !yield(start, s[start:i]) {
+					return
+				}
+				start = -1
+			} else if start < 0 {
+				start = i
+			}
+		}
+		if start >= 0 {
+			yield(start, s[start:])
+		}
+	}
+}
+
+// findEntities parses body and resolves its mentions. Mentions of unknown
+// email addresses are returned like any other, but are not stored.
+func (cfg *apiConfig) findEntities(ctx context.Context, body string) (Entities, error) {
+	tags, texts := parseEntities(body)
+	e := Entities{Hashtags: tags, Mentions: mentionsOf(texts)}
+	users := make(map[string]uuid.UUID)
+	for _, m := range texts {
+		id, ok := users[m.Email]
+		if !ok {
+			user, err := cfg.db.LookUpUserByEmail(ctx, m.Email)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return Entities{}, err
+			}
+			id = user.ID
+			users[m.Email] = id
+		}
+		if id != uuid.Nil {
+			e.mentioned = append(e.mentioned, mentionedUser{UserID: id, Start: m.Start, End: m.End})
+		}
+	}
+	return e, nil
+}
+
+// mentionsOf returns the public form of the mentions parsed from a body.
+func mentionsOf(texts []mentionText) []Mention {
+	out := make([]Mention, len(texts))
+	for i, m := range texts {
+		out[i] = Mention{Start: m.Start, End: m.End}
+	}
+	return out
+}
+
+// columns flattens e into the parallel arrays the chirp queries take.
+func (e Entities) columns() (tags []string, tagStarts, tagEnds []int32, mentionUserIDs []uuid.UUID, mentionStarts, mentionEnds []int32) {
+	for _, h := range e.Hashtags {
+		tags = append(tags, h.Tag)
+		tagStarts = append(tagStarts, int32(h.Start))
+		tagEnds = append(tagEnds, int32(h.End))
+	}
+	for _, m := range e.mentioned {
+		mentionUserIDs = append(mentionUserIDs, m.UserID)
+		mentionStarts = append(mentionStarts, int32(m.Start))
+		mentionEnds = append(mentionEnds, int32(m.End))
+	}
+	return
+}
+
+// loadEntities fetches the stored hashtags of a page of chirps. Mentions
+// are found in the bodies again, so that unresolved ones are listed too and
+// nothing tells a reader which of them named a user.
+func (cfg *apiConfig) loadEntities(ctx context.Context, rows []database.Chirp) (map[uuid.UUID]Entities, error) {
+	ids := make([]uuid.UUID, len(rows))
+	for i, c := range rows {
+		ids[i] = c.ID
+	}
+	hashtags, err := cfg.db.ListChirpHashtags(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[uuid.UUID]Entities, len(rows))
+	for _, c := range rows {
+		_, texts := parseEntities(c.Body)
+		out[c.ID] = Entities{Hashtags: []Hashtag{}, Mentions: mentionsOf(texts)}
+	}
+	for _, h := range hashtags {
+		e := out[h.ChirpID]
+		e.Hashtags = append(e.Hashtags, Hashtag{Tag: h.Tag, Start: int(h.StartOffset), End: int(h.EndOffset)})
+		out[h.ChirpID] = e
+	}
+	return out, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
+)
+
+// Entitlements are the features and limits that come with a user's plan.
+// Handlers ask for them through cfg.entitlements rather than reading
+// is_chirpy_red themselves.
+type Entitlements struct {
+	// MaxChirpLength is counted in user-perceived characters.
+	MaxChirpLength int
+	EditChirps     bool
+	ScheduleChirps bool
+	// ChirpsPerMinute limits how fast a user can post, with bursts of the
+	// same size. Zero means no limit.
+	ChirpsPerMinute int
+}
+
+// plans maps each kind of user to their entitlements.
+type plans struct {
+	Standard Entitlements
+	Red      Entitlements
+}
+
+var defaultPlans = plans{
+	Standard: Entitlements{
+		MaxChirpLength:  140,
+		ChirpsPerMinute: 10,
+	},
+	Red: Entitlements{
+		MaxChirpLength:  280,
+		EditChirps:      true,
+		ScheduleChirps:  true,
+		ChirpsPerMinute: 60,
+	},
+}
+
+func (p plans) For(user database.User) Entitlements {
+	if user.IsChirpyRed {
+		return p.Red
+	}
+	return p.Standard
+}
+
+// entitlements returns what userID's plan allows.
+func (cfg *apiConfig) entitlements(ctx context.Context, userID uuid.UUID) (Entitlements, error) {
+	user, err := cfg.db.GetUser(ctx, userID)
+	if err != nil {
+		return Entitlements{}, err
+	}
+	return cfg.plans.For(user), nil
+}
+
+// chirpRateLimiter keeps a token bucket per user. The zero value is ready to
+// use.
+type chirpRateLimiter struct {
+	mu       sync.Mutex
+	limiters map[uuid.UUID]*rate.Limiter
+}
+
+// reserve takes a token from userID's bucket, sized by e. It returns 0 if
+// the user may post now and otherwise how long they have to wait.
+func (l *chirpRateLimiter) reserve(userID uuid.UUID, e Entitlements) time.Duration {
+	if e.ChirpsPerMinute <= 0 {
+		return 0
+	}
+	limit := rate.Every(time.Minute / time.Duration(e.ChirpsPerMinute))
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limiters == nil {
+		l.limiters = make(map[uuid.UUID]*rate.Limiter)
+	}
+	lim, ok := l.limiters[userID]
+	if !ok {
+		l.sweep(now)
+		lim = rate.NewLimiter(limit, e.ChirpsPerMinute)
+		l.limiters[userID] = lim
+	} else if lim.Limit() != limit || lim.Burst() != e.ChirpsPerMinute {
+		// The user changed plans.
+		lim.SetLimitAt(now, limit)
+		lim.SetBurstAt(now, e.ChirpsPerMinute)
+	}
+
+	r := lim.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return delay
+	}
+	return 0
+}
+
+// sweep forgets users whose buckets have refilled, since a fresh bucket
+// behaves the same. It only runs once the map has grown, to keep reserve
+// cheap.
+func (l *chirpRateLimiter) sweep(now time.Time) {
+	if len(l.limiters) < 1024 {
+		return
+	}
+	for id, lim := range l.limiters {
+		if lim.TokensAt(now) >= float64(lim.Burst()) {
+			delete(l.limiters, id)
+		}
+	}
+}
+
+// allowChirp applies the posting rate limit, responding with 429 and
+// returning false if userID has to wait.
+func (cfg *apiConfig) allowChirp(w http.ResponseWriter, userID uuid.UUID, e Entitlements) bool {
+	delay := cfg.chirpLimiter.reserve(userID, e)
+	if delay == 0 {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+	respondWithError(w, http.StatusTooManyRequests, "too many chirps, slow down", nil)
+	return false
+}
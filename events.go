@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// chirpCreated announces a new chirp: to webhook subscribers, to the chirp
+// streams, and as a notification to the users it mentions or replies to.
+func (cfg *apiConfig) chirpCreated(ctx context.Context, chirp database.Chirp, entities Entities) {
+	out := newChirp(chirp)
+	out.Entities = entities
+	cfg.emit(ctx, eventChirpCreated, uuid.NullUUID{}, out)
+	cfg.chirpHub.publish(eventChirpCreated, chirp.UserID, out)
+
+	about := uuid.NullUUID{UUID: chirp.ID, Valid: true}
+	notified := map[uuid.UUID]bool{chirp.UserID: true}
+	for _, m := range entities.mentioned {
+		if !notified[m.UserID] {
+			notified[m.UserID] = true
+			cfg.notify(ctx, m.UserID, chirp.UserID, notifyMention, about)
+		}
+	}
+	if chirp.InReplyTo.Valid {
+		parent, err := cfg.db.GetChirp(ctx, chirp.InReplyTo.UUID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			requestLogger(ctx).Error("couldn't load parent of chirp", "chirp_id", chirp.ID, "error", err)
+		}
+		if err == nil && !notified[parent.UserID] {
+			cfg.notify(ctx, parent.UserID, chirp.UserID, notifyReply, about)
+		}
+	}
+}
+
+// chirpRestored announces a chirp a moderator made visible again as if it
+// were new, to the same audience that was told it was gone. Nobody is
+// notified a second time.
+func (cfg *apiConfig) chirpRestored(ctx context.Context, chirp database.Chirp) error {
+	entities, err := cfg.loadEntities(ctx, []database.Chirp{chirp})
+	if err != nil {
+		return err
+	}
+	out := newChirp(chirp)
+	out.Entities = entities[chirp.ID]
+	cfg.emit(ctx, eventChirpCreated, uuid.NullUUID{}, out)
+	cfg.chirpHub.publish(eventChirpCreated, chirp.UserID, out)
+	return nil
+}
+
+// chirpDeleted announces that chirp is gone, also when a moderator hides it.
+func (cfg *apiConfig) chirpDeleted(ctx context.Context, chirp database.Chirp) {
+	deleted := map[string]uuid.UUID{"id": chirp.ID, "user_id": chirp.UserID}
+	cfg.emit(ctx, eventChirpDeleted, uuid.NullUUID{}, deleted)
+	cfg.chirpHub.publish(eventChirpDeleted, chirp.UserID, deleted)
+}
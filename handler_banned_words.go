@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/Geraetefreund/chirpy/internal/moderation"
+)
+
+type BannedWord struct {
+	Word      string    `json:"word"`
+	Action    string    `json:"action"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// bannedWordParams is a word to ban as sent by an admin. Action defaults to
+// mask.
+type bannedWordParams struct {
+	Word   string `json:"word"`
+	Action string `json:"action"`
+}
+
+// normalize checks p and brings it into the form it is stored in.
+func (p bannedWordParams) normalize() (bannedWordParams, error) {
+	word := strings.ToLower(strings.TrimSpace(p.Word))
+	if !moderation.ValidWord(word) {
+		return p, fmt.Errorf("%q is not a single word", p.Word)
+	}
+	action := moderation.Mask
+	if p.Action != "" {
+		var err error
+		if action, err = moderation.ParseAction(p.Action); err != nil {
+			return p, err
+		}
+	}
+	return bannedWordParams{Word: word, Action: action.String()}, nil
+}
+
+func (cfg *apiConfig) handlerListBannedWords(w http.ResponseWriter, r *http.Request) {
+	words, err := cfg.db.ListBannedWords(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't list banned words", err)
+		return
+	}
+	out := make([]BannedWord, 0, len(words))
+	for _, bw := range words {
+		out = append(out, BannedWord(bw))
+	}
+	respondWithJSON(w, http.StatusOK, out)
+}
+
+func (cfg *apiConfig) handlerAddBannedWord(w http.ResponseWriter, r *http.Request) {
+	var params bannedWordParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid JSON", err)
+		return
+	}
+	params, err := params.normalize()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	bw, err := cfg.db.UpsertBannedWord(r.Context(), database.UpsertBannedWordParams{
+		Word:   params.Word,
+		Action: params.Action,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't save banned word", err)
+		return
+	}
+	if !cfg.reloadBannedWords(w, r) {
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, BannedWord(bw))
+}
+
+// handlerImportBannedWords adds many words at once. The body is either a
+// JSON array of words or, with Content-Type text/plain, a word list file as
+// described in the README. Words that are already banned get the new
+// action.
+func (cfg *apiConfig) handlerImportBannedWords(w http.ResponseWriter, r *http.Request) {
+	var list []bannedWordParams
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/plain" {
+		rules, err := moderation.ParseRules(r.Body)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		for _, rule := range rules {
+			list = append(list, bannedWordParams{Word: rule.Word, Action: rule.Action.String()})
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid JSON", err)
+		return
+	}
+
+	// A word may only appear once per statement; the last mention wins.
+	index := make(map[string]int)
+	var arg database.ImportBannedWordsParams
+	for _, p := range list {
+		p, err := p.normalize()
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		if i, ok := index[p.Word]; ok {
+			arg.Actions[i] = p.Action
+			continue
+		}
+		index[p.Word] = len(arg.Words)
+		arg.Words = append(arg.Words, p.Word)
+		arg.Actions = append(arg.Actions, p.Action)
+	}
+
+	n, err := cfg.db.ImportBannedWords(r.Context(), arg)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't import banned words", err)
+		return
+	}
+	if !cfg.reloadBannedWords(w, r) {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]int64{"imported": n})
+}
+
+func (cfg *apiConfig) handlerDeleteBannedWord(w http.ResponseWriter, r *http.Request) {
+	word := strings.ToLower(r.PathValue("word"))
+	n, err := cfg.db.DeleteBannedWord(r.Context(), word)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't delete banned word", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "word is not banned", nil)
+		return
+	}
+	if !cfg.reloadBannedWords(w, r) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reloadBannedWords makes a change to the word list take effect, writing an
+// error response and returning false if it can't.
+func (cfg *apiConfig) reloadBannedWords(w http.ResponseWriter, r *http.Request) bool {
+	if err := cfg.moderator.Reload(r.Context()); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "word list saved but not reloaded", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/auth"
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type ChirpRevision struct {
+	ID        string    `json:"id"`
+	ChirpID   string    `json:"chirp_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) handlerChirpsUpdate(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid JSON", err)
+		return
+	}
+
+	tokenStr, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(tokenStr, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id", nil)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirp(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "chirp not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "database error", err)
+		return
+	}
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "only the author can edit a chirp", nil)
+		return
+	}
+
+	ent, err := cfg.entitlements(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error", err)
+		return
+	}
+	if !ent.EditChirps {
+		respondWithError(w, http.StatusForbidden, "editing chirps requires Chirpy Red", nil)
+		return
+	}
+
+	cleanedBody, err := cfg.validateAndClean(params.Body, ent.MaxChirpLength)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	entities, err := cfg.findEntities(r.Context(), cleanedBody)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error", err)
+		return
+	}
+
+	dbParams := database.UpdateChirpParams{
+		Body: cleanedBody,
+		ID:   id,
+	}
+	dbParams.Tags, dbParams.TagStarts, dbParams.TagEnds,
+		dbParams.MentionUserIds, dbParams.MentionStarts, dbParams.MentionEnds = entities.columns()
+
+	updated, err := cfg.db.UpdateChirp(r.Context(), dbParams)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't update chirp", err)
+		return
+	}
+	cfg.emit(r.Context(), eventChirpUpdated, uuid.NullUUID{}, newChirp(updated))
+	cfg.flagForReview(r.Context(), updated)
+	out, err := cfg.newChirps(r, []database.Chirp{updated})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, out[0])
+}
+
+// handlerChirpRevisions lists the earlier versions of a chirp, oldest first.
+// The current version is the chirp itself.
+func (cfg *apiConfig) handlerChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id", nil)
+		return
+	}
+
+	if _, err := cfg.db.GetChirp(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "chirp not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "database error", err)
+		return
+	}
+
+	revisions, err := cfg.db.ListChirpRevisions(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't retrieve revisions", err)
+		return
+	}
+
+	out := make([]ChirpRevision, 0, len(revisions))
+	for _, rev := range revisions {
+		out = append(out, ChirpRevision{
+			ID:        rev.ID.String(),
+			ChirpID:   rev.ChirpID.String(),
+			Body:      rev.Body,
+			CreatedAt: rev.CreatedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// streamEventReset tells a resuming client that events were missed and it
+// should reload instead.
+const streamEventReset = "reset"
+
+// handlerChirpStream streams new and deleted chirps as Server-Sent Events,
+// optionally only those by ?author_id=.
+func (cfg *apiConfig) handlerChirpStream(w http.ResponseWriter, r *http.Request) {
+	var author uuid.NullUUID
+	if s := r.URL.Query().Get("author_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid author_id", err)
+			return
+		}
+		author = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	rc := http.NewResponseController(w)
+	// Streams outlive any server write timeout.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		respondWithError(w, http.StatusInternalServerError, "couldn't start stream", err)
+		return
+	}
+
+	sub, replay, missed := cfg.chirpHub.subscribe(author, r.Header.Get("Last-Event-ID"))
+	defer cfg.chirpHub.unsubscribe(sub)
+	defer cfg.metrics.streamOpened(streamSSE)()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(e streamEvent) error {
+		_, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", cfg.chirpHub.eventID(e.Seq), e.Name, e.Data)
+		return err
+	}
+	if missed {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", streamEventReset)
+	}
+	for _, e := range replay {
+		if send(e) != nil {
+			return
+		}
+	}
+	if rc.Flush() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(cfg.chirpHub.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects with
+				// Last-Event-ID.
+				return
+			}
+			if send(e) != nil {
+				return
+			}
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
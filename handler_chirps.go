@@ -13,14 +13,93 @@ import (
 )
 
 type parameters struct {
-	Body string `json:"body"`
+	Body      string        `json:"body"`
+	InReplyTo uuid.NullUUID `json:"in_reply_to"`
+	// PublishAt schedules a new chirp instead of posting it right away.
+	PublishAt *time.Time `json:"publish_at"`
 }
 type Chirp struct {
-	ID        string    `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    string    `json:"user_id"`
+	ID         string        `json:"id"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+	Body       string        `json:"body"`
+	UserID     string        `json:"user_id"`
+	InReplyTo  uuid.NullUUID `json:"in_reply_to"`
+	ReplyCount int64         `json:"reply_count"`
+	LikeCount  int64         `json:"like_count"`
+	LikedByMe  bool          `json:"liked_by_me"`
+	Entities   Entities      `json:"entities"`
+}
+
+// newChirp converts a database row into its API representation.
+func newChirp(c database.Chirp) Chirp {
+	return Chirp{
+		ID:        c.ID.String(),
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Body:      c.Body,
+		UserID:    c.UserID.String(),
+		InReplyTo: c.InReplyTo,
+	}
+}
+
+// newChirps converts a page of rows and fills in their reply and like
+// counts and their entities with one query each. If the request carries a
+// valid bearer token, liked_by_me is filled in for that user as well.
+func (cfg *apiConfig) newChirps(r *http.Request, rows []database.Chirp) ([]Chirp, error) {
+	ctx := r.Context()
+	ids := make([]uuid.UUID, len(rows))
+	for i, c := range rows {
+		ids[i] = c.ID
+	}
+
+	replyCounts, err := cfg.db.CountReplies(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	replies := make(map[uuid.UUID]int64, len(replyCounts))
+	for _, c := range replyCounts {
+		replies[c.ChirpID] = c.ReplyCount
+	}
+
+	likeCounts, err := cfg.db.CountLikes(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	likes := make(map[uuid.UUID]int64, len(likeCounts))
+	for _, c := range likeCounts {
+		likes[c.ChirpID] = c.LikeCount
+	}
+
+	entities, err := cfg.loadEntities(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	liked := make(map[uuid.UUID]bool)
+	if viewer, err := cfg.authenticate(r); err == nil {
+		likedIDs, err := cfg.db.ListLikedChirpIDs(ctx, database.ListLikedChirpIDsParams{
+			UserID:   viewer,
+			ChirpIds: ids,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range likedIDs {
+			liked[id] = true
+		}
+	}
+
+	out := make([]Chirp, 0, len(rows))
+	for _, c := range rows {
+		chirp := newChirp(c)
+		chirp.ReplyCount = replies[c.ID]
+		chirp.LikeCount = likes[c.ID]
+		chirp.LikedByMe = liked[c.ID]
+		chirp.Entities = entities[c.ID]
+		out = append(out, chirp)
+	}
+	return out, nil
 }
 
 func (cfg *apiConfig) handlerChirpsCreate(w http.ResponseWriter, r *http.Request) {
@@ -47,32 +126,77 @@ func (cfg *apiConfig) handlerChirpsCreate(w http.ResponseWriter, r *http.Request
 
 	// set dbParams.UserID from the token's subject/claim
 
+	ent, err := cfg.entitlements(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error", err)
+		return
+	}
+	if params.PublishAt != nil && !ent.ScheduleChirps {
+		respondWithError(w, http.StatusForbidden, "scheduling chirps requires Chirpy Red", nil)
+		return
+	}
 	// validate + sanitize -> cleanedBody
-	cleanedBody, err := validateAndClean(params.Body)
+	cleanedBody, err := cfg.validateAndClean(params.Body, ent.MaxChirpLength)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	if params.InReplyTo.Valid {
+		_, err := cfg.db.GetChirp(r.Context(), params.InReplyTo.UUID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				respondWithError(w, http.StatusBadRequest, "in_reply_to chirp not found", err)
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, "database error", err)
+			return
+		}
+	}
+
+	// Only chirps that would be accepted count against the rate limit.
+	if !cfg.allowChirp(w, userId, ent) {
+		return
+	}
+
+	if params.PublishAt != nil {
+		cfg.scheduleChirp(w, r, database.CreateScheduledChirpParams{
+			UserID:    userId,
+			Body:      cleanedBody,
+			InReplyTo: params.InReplyTo,
+			PublishAt: *params.PublishAt,
+		})
+		return
+	}
+
+	entities, err := cfg.findEntities(r.Context(), cleanedBody)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "", err)
+		respondWithError(w, http.StatusInternalServerError, "database error", err)
 		return
 	}
 
 	dbParams := database.CreateChirpParams{
-		Body:   cleanedBody,
-		UserID: userId,
+		Body:      cleanedBody,
+		UserID:    userId,
+		InReplyTo: params.InReplyTo,
 	}
+	dbParams.Tags, dbParams.TagStarts, dbParams.TagEnds,
+		dbParams.MentionUserIds, dbParams.MentionStarts, dbParams.MentionEnds = entities.columns()
 
 	chirp, err := cfg.db.CreateChirp(r.Context(), dbParams)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "could not create chirp", err)
 		return
 	}
+	cfg.chirpCreated(r.Context(), chirp, entities)
+	cfg.flagForReview(r.Context(), chirp)
 
-	response := Chirp{
-		ID:        chirp.ID.String(),
-		Body:      chirp.Body,
-		UserID:    chirp.UserID.String(),
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.CreatedAt,
+	out, err := cfg.newChirps(r, []database.Chirp{chirp})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error", err)
+		return
 	}
-	respondWithJSON(w, http.StatusCreated, response)
+	respondWithJSON(w, http.StatusCreated, out[0])
 }
 
 func (cfg *apiConfig) handlerGetChirpByID(w http.ResponseWriter, r *http.Request) {
@@ -93,14 +217,12 @@ func (cfg *apiConfig) handlerGetChirpByID(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	resp := Chirp{
-		ID:        chirp.ID.String(),
-		Body:      chirp.Body,
-		UserID:    chirp.UserID.String(),
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
+	out, err := cfg.newChirps(r, []database.Chirp{chirp})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error", err)
+		return
 	}
-	respondWithJSON(w, http.StatusOK, resp)
+	respondWithJSON(w, http.StatusOK, out[0])
 
 }
 
@@ -108,11 +230,13 @@ func (cfg *apiConfig) handlerDeleteChirpByID(w http.ResponseWriter, r *http.Requ
 	tokenStr, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
 	}
 
 	userId, err := auth.ValidateJWT(tokenStr, cfg.secret)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
 	}
 
 	idStr := r.PathValue("chirpID")
@@ -143,49 +267,78 @@ func (cfg *apiConfig) handlerDeleteChirpByID(w http.ResponseWriter, r *http.Requ
 		respondWithError(w, http.StatusInternalServerError, "couldn't delete chirp", err)
 		return
 	}
+	cfg.chirpDeleted(r.Context(), chirp)
 	respondWithJSON(w, http.StatusNoContent, "chirp deleted successfully")
 
 }
 
 func (cfg *apiConfig) handlerGetAllChirpsByID(w http.ResponseWriter, r *http.Request) {
-	userID := r.URL.Query().Get("author_id")
-	sort := r.URL.Query().Get("sort")
-	var dbChirps []database.Chirp
-	var err error
-	id, _ := uuid.Parse(userID)
+	authorID := r.URL.Query().Get("author_id")
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
 
-	if userID == "" {
-		if sort == "desc" {
-			dbChirps, err = cfg.db.GetAllChirpsDesc(r.Context())
+	var dbChirps []database.Chirp
+	if authorID == "" {
+		if page.Desc {
+			dbChirps, err = cfg.db.ListChirpsDesc(r.Context(), database.ListChirpsDescParams{
+				CursorCreatedAt: page.Cursor.CreatedAt,
+				CursorID:        page.Cursor.ID,
+				PageSize:        page.fetchSize(),
+			})
 		} else {
-			dbChirps, err = cfg.db.GetChirps(r.Context())
+			dbChirps, err = cfg.db.ListChirps(r.Context(), database.ListChirpsParams{
+				CursorCreatedAt: page.Cursor.CreatedAt,
+				CursorID:        page.Cursor.ID,
+				PageSize:        page.fetchSize(),
+			})
 		}
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps from database", err)
+	} else {
+		id, parseErr := uuid.Parse(authorID)
+		if parseErr != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid author_id", parseErr)
 			return
 		}
-	} else {
-		if sort == "desc" {
-			dbChirps, err = cfg.db.GetChirpsByIDDesc(r.Context(), id)
+		if page.Desc {
+			dbChirps, err = cfg.db.ListChirpsByAuthorDesc(r.Context(), database.ListChirpsByAuthorDescParams{
+				UserID:          id,
+				CursorCreatedAt: page.Cursor.CreatedAt,
+				CursorID:        page.Cursor.ID,
+				PageSize:        page.fetchSize(),
+			})
 		} else {
-			dbChirps, err = cfg.db.GetChirpsByID(r.Context(), id)
-		}
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps from database", err)
-			return
+			dbChirps, err = cfg.db.ListChirpsByAuthor(r.Context(), database.ListChirpsByAuthorParams{
+				UserID:          id,
+				CursorCreatedAt: page.Cursor.CreatedAt,
+				CursorID:        page.Cursor.ID,
+				PageSize:        page.fetchSize(),
+			})
 		}
 	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps from database", err)
+		return
+	}
 
-	out := make([]Chirp, 0, len(dbChirps))
-	for _, c := range dbChirps {
-		out = append(out, Chirp{
-			ID:        c.ID.String(),
-			CreatedAt: c.CreatedAt,
-			UpdatedAt: c.UpdatedAt,
-			Body:      c.Body,
-			UserID:    c.UserID.String(),
-		})
+	cfg.respondWithChirpPage(w, r, page, dbChirps)
+}
+
+// respondWithChirpPage writes one page of a chirp listing. dbChirps holds
+// up to page.fetchSize() rows; a row past page.Limit means there is a next
+// page and turns into its Link header.
+func (cfg *apiConfig) respondWithChirpPage(w http.ResponseWriter, r *http.Request, page pageRequest, dbChirps []database.Chirp) {
+	if len(dbChirps) > page.Limit {
+		dbChirps = dbChirps[:page.Limit]
+		last := dbChirps[len(dbChirps)-1]
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
 	}
-	respondWithJSON(w, http.StatusOK, out)
 
+	out, err := cfg.newChirps(r, dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps from database", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, out)
 }
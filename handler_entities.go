@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+)
+
+// handlerChirpsByTag serves the chirps carrying a hashtag, newest first.
+// The tag may be given with or without its leading #.
+func (cfg *apiConfig) handlerChirpsByTag(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(strings.TrimPrefix(r.PathValue("tag"), "#"))
+	if tag == "" || strings.IndexFunc(tag, func(r rune) bool { return !isTagRune(r) }) >= 0 {
+		respondWithError(w, http.StatusBadRequest, "invalid tag", nil)
+		return
+	}
+	page, err := parsePageRequestSorted(r, true)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	dbChirps, err := cfg.db.ListChirpsByHashtag(r.Context(), database.ListChirpsByHashtagParams{
+		Tag:             tag,
+		CursorCreatedAt: page.Cursor.CreatedAt,
+		CursorID:        page.Cursor.ID,
+		PageSize:        page.fetchSize(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't retrieve chirps", err)
+		return
+	}
+	cfg.respondWithChirpPage(w, r, page, dbChirps)
+}
+
+// handlerUserMentions serves the chirps mentioning a user, newest first.
+// Only the user may list them: anyone else could mention an email address
+// and look for the chirp here to learn whose address it is.
+func (cfg *apiConfig) handlerUserMentions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.pathUser(w, r)
+	if !ok {
+		return
+	}
+	viewer, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+	if viewer != userID {
+		respondWithError(w, http.StatusForbidden, "mentions are only listed to the user mentioned", nil)
+		return
+	}
+	page, err := parsePageRequestSorted(r, true)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	dbChirps, err := cfg.db.ListChirpsMentioning(r.Context(), database.ListChirpsMentioningParams{
+		UserID:          userID,
+		CursorCreatedAt: page.Cursor.CreatedAt,
+		CursorID:        page.Cursor.ID,
+		PageSize:        page.fetchSize(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't retrieve chirps", err)
+		return
+	}
+	cfg.respondWithChirpPage(w, r, page, dbChirps)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Follow is one edge of the follow graph as seen from a user's followers or
+// following list: the other user and when the follow happened.
+type Follow struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+func (cfg *apiConfig) handlerFollow(w http.ResponseWriter, r *http.Request) {
+	followerID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+	followeeID, ok := cfg.pathUser(w, r)
+	if !ok {
+		return
+	}
+	if followeeID == followerID {
+		respondWithError(w, http.StatusBadRequest, "you can't follow yourself", nil)
+		return
+	}
+
+	n, err := cfg.db.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't follow user", err)
+		return
+	}
+	if n > 0 {
+		cfg.notify(r.Context(), followeeID, followerID, notifyFollow, uuid.NullUUID{})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnfollow(w http.ResponseWriter, r *http.Request) {
+	followerID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id", err)
+		return
+	}
+
+	_, err = cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't unfollow user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerListFollowers(w http.ResponseWriter, r *http.Request) {
+	cfg.listFollows(w, r, func(userID uuid.UUID, page pageRequest) ([]database.Follow, error) {
+		return cfg.db.ListFollowers(r.Context(), database.ListFollowersParams{
+			UserID:          userID,
+			CursorCreatedAt: page.Cursor.CreatedAt,
+			CursorID:        page.Cursor.ID,
+			PageSize:        page.fetchSize(),
+		})
+	}, func(f database.Follow) uuid.UUID { return f.FollowerID })
+}
+
+func (cfg *apiConfig) handlerListFollowing(w http.ResponseWriter, r *http.Request) {
+	cfg.listFollows(w, r, func(userID uuid.UUID, page pageRequest) ([]database.Follow, error) {
+		return cfg.db.ListFollowing(r.Context(), database.ListFollowingParams{
+			UserID:          userID,
+			CursorCreatedAt: page.Cursor.CreatedAt,
+			CursorID:        page.Cursor.ID,
+			PageSize:        page.fetchSize(),
+		})
+	}, func(f database.Follow) uuid.UUID { return f.FolloweeID })
+}
+
+// listFollows serves one page of a follow listing, newest first. other picks
+// the user on the far side of each edge.
+func (cfg *apiConfig) listFollows(
+	w http.ResponseWriter,
+	r *http.Request,
+	list func(userID uuid.UUID, page pageRequest) ([]database.Follow, error),
+	other func(database.Follow) uuid.UUID,
+) {
+	userID, ok := cfg.pathUser(w, r)
+	if !ok {
+		return
+	}
+	page, err := parsePageRequestSorted(r, true)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	follows, err := list(userID, page)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't retrieve follows", err)
+		return
+	}
+	if len(follows) > page.Limit {
+		follows = follows[:page.Limit]
+		last := follows[len(follows)-1]
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: other(last)})
+	}
+
+	out := make([]Follow, 0, len(follows))
+	for _, f := range follows {
+		out = append(out, Follow{UserID: other(f), FollowedAt: f.CreatedAt})
+	}
+	respondWithJSON(w, http.StatusOK, out)
+}
+
+// pathUser resolves the {userID} path value to an existing user, writing
+// an error response and returning false if it can't.
+func (cfg *apiConfig) pathUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id", err)
+		return uuid.Nil, false
+	}
+	if _, err := cfg.db.GetUser(r.Context(), userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "user not found", err)
+			return uuid.Nil, false
+		}
+		respondWithError(w, http.StatusInternalServerError, "database error", err)
+		return uuid.Nil, false
+	}
+	return userID, true
+}
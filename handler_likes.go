@@ -0,0 +1,109 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerLikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id", err)
+		return
+	}
+	chirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "chirp not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "database error", err)
+		return
+	}
+
+	n, err := cfg.db.LikeChirp(r.Context(), database.LikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't like chirp", err)
+		return
+	}
+	if n > 0 {
+		cfg.notify(r.Context(), chirp.UserID, userID, notifyLike, uuid.NullUUID{UUID: chirpID, Valid: true})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id", err)
+		return
+	}
+
+	_, err = cfg.db.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't unlike chirp", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerListUserLikes serves the chirps a user has liked, most recently
+// liked first.
+func (cfg *apiConfig) handlerListUserLikes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.pathUser(w, r)
+	if !ok {
+		return
+	}
+	page, err := parsePageRequestSorted(r, true)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	rows, err := cfg.db.ListLikes(r.Context(), database.ListLikesParams{
+		UserID:          userID,
+		CursorCreatedAt: page.Cursor.CreatedAt,
+		CursorID:        page.Cursor.ID,
+		PageSize:        page.fetchSize(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't retrieve likes", err)
+		return
+	}
+	if len(rows) > page.Limit {
+		rows = rows[:page.Limit]
+		last := rows[len(rows)-1]
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.LikedAt, ID: last.Chirp.ID})
+	}
+
+	dbChirps := make([]database.Chirp, len(rows))
+	for i, row := range rows {
+		dbChirps[i] = row.Chirp
+	}
+	out, err := cfg.newChirps(r, dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't retrieve likes", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, out)
+}
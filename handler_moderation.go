@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/Geraetefreund/chirpy/internal/moderation"
+	"github.com/google/uuid"
+)
+
+// maxReportReason is the longest reason a report or moderator action may
+// give, in characters.
+const maxReportReason = 500
+
+// Moderator actions on a chirp.
+const (
+	moderationHide    = "hide"
+	moderationRestore = "restore"
+	moderationDismiss = "dismiss"
+	moderationRemove  = "remove"
+)
+
+// ChirpReport is a user's report of a chirp. ReporterID is null for chirps
+// the banned word list flagged for review.
+type ChirpReport struct {
+	ID         uuid.UUID     `json:"id"`
+	ChirpID    uuid.UUID     `json:"chirp_id"`
+	ReporterID uuid.NullUUID `json:"reporter_id"`
+	Reason     string        `json:"reason"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+func newChirpReport(r database.ChirpReport) ChirpReport {
+	return ChirpReport{
+		ID:         r.ID,
+		ChirpID:    r.ChirpID,
+		ReporterID: r.ReporterID,
+		Reason:     r.Reason,
+		CreatedAt:  r.CreatedAt,
+	}
+}
+
+// ModerationQueueItem is a chirp with open reports.
+type ModerationQueueItem struct {
+	Chirp           Chirp         `json:"chirp"`
+	Hidden          bool          `json:"hidden"`
+	OpenReports     int64         `json:"open_reports"`
+	FirstReportedAt time.Time     `json:"first_reported_at"`
+	Reports         []ChirpReport `json:"reports"`
+}
+
+// ModerationAction is an entry in the audit trail. ModeratorID is null for
+// actions taken with ADMIN_API_KEY.
+type ModerationAction struct {
+	ID          uuid.UUID     `json:"id"`
+	ChirpID     uuid.UUID     `json:"chirp_id"`
+	ChirpUserID uuid.UUID     `json:"chirp_user_id"`
+	ModeratorID uuid.NullUUID `json:"moderator_id"`
+	Action      string        `json:"action"`
+	Reason      string        `json:"reason"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+func newModerationAction(a database.ModerationAction) ModerationAction {
+	return ModerationAction{
+		ID:          a.ID,
+		ChirpID:     a.ChirpID,
+		ChirpUserID: a.ChirpUserID,
+		ModeratorID: a.ModeratorID,
+		Action:      a.Action,
+		Reason:      a.Reason,
+		CreatedAt:   a.CreatedAt,
+	}
+}
+
+// parseReason trims a report or action reason and checks its length.
+func parseReason(s string, required bool) (string, error) {
+	s = strings.TrimSpace(s)
+	if required && s == "" {
+		return "", errors.New("reason is required")
+	}
+	if utf8.RuneCountInString(s) > maxReportReason {
+		return "", errors.New("reason is too long")
+	}
+	return s, nil
+}
+
+// flagForReview queues chirp for moderators if it contains words the banned
+// word list flags. Like notify, it only logs failures.
+func (cfg *apiConfig) flagForReview(ctx context.Context, chirp database.Chirp) {
+	var words []string
+	for _, m := range cfg.moderator.Moderate(chirp.Body).Matches {
+		if m.Rule.Action == moderation.Flag && !slices.Contains(words, m.Rule.Word) {
+			words = append(words, m.Rule.Word)
+		}
+	}
+	if len(words) == 0 {
+		return
+	}
+	_, err := cfg.db.CreateChirpFlag(ctx, database.CreateChirpFlagParams{
+		ChirpID: chirp.ID,
+		Reason:  "flagged words: " + strings.Join(words, ", "),
+	})
+	if err != nil {
+		requestLogger(ctx).Error("couldn't flag chirp for review", "chirp_id", chirp.ID, "error", err)
+	}
+}
+
+func (cfg *apiConfig) handlerReportChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Reason string `json:"reason"`
+	}
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id", err)
+		return
+	}
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid JSON", err)
+		return
+	}
+	reason, err := parseReason(params.Reason, true)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "chirp not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "database error", err)
+		return
+	}
+	if chirp.UserID == userID {
+		respondWithError(w, http.StatusBadRequest, "you can't report your own chirp", nil)
+		return
+	}
+
+	report, err := cfg.db.CreateChirpReport(r.Context(), database.CreateChirpReportParams{
+		ChirpID:    chirpID,
+		ReporterID: uuid.NullUUID{UUID: userID, Valid: true},
+		Reason:     reason,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "you have already reported this chirp", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't report chirp", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, newChirpReport(report))
+}
+
+// handlerModerationQueue lists the chirps with open reports, the one
+// reported longest ago first.
+func (cfg *apiConfig) handlerModerationQueue(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePageRequestSorted(r, false)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	rows, err := cfg.db.ListModerationQueue(r.Context(), database.ListModerationQueueParams{
+		CursorCreatedAt: page.Cursor.CreatedAt,
+		CursorID:        page.Cursor.ID,
+		PageSize:        page.fetchSize(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't retrieve reports", err)
+		return
+	}
+	if len(rows) > page.Limit {
+		rows = rows[:page.Limit]
+		last := rows[len(rows)-1]
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.FirstReportedAt, ID: last.Chirp.ID})
+	}
+
+	chirps := make([]database.Chirp, len(rows))
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		chirps[i] = row.Chirp
+		ids[i] = row.Chirp.ID
+	}
+	converted, err := cfg.newChirps(r, chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error", err)
+		return
+	}
+	reports, err := cfg.db.ListOpenChirpReports(r.Context(), ids)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't retrieve reports", err)
+		return
+	}
+	byChirp := make(map[uuid.UUID][]ChirpReport, len(rows))
+	for _, rep := range reports {
+		byChirp[rep.ChirpID] = append(byChirp[rep.ChirpID], newChirpReport(rep))
+	}
+
+	out := make([]ModerationQueueItem, len(rows))
+	for i, row := range rows {
+		out[i] = ModerationQueueItem{
+			Chirp:           converted[i],
+			Hidden:          row.Chirp.HiddenAt.Valid,
+			OpenReports:     row.OpenReports,
+			FirstReportedAt: row.FirstReportedAt,
+			Reports:         byChirp[row.Chirp.ID],
+		}
+	}
+	respondWithJSON(w, http.StatusOK, out)
+}
+
+// handlerModerateChirp returns a handler that takes action on a chirp and
+// resolves its reports. The reason is optional. Streams and webhook
+// subscribers are told when the chirp disappears or comes back.
+func (cfg *apiConfig) handlerModerateChirp(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chirpID, reason, ok := moderationRequest(w, r)
+		if !ok {
+			return
+		}
+		// Hidden chirps are not found, so this tells whether hiding or
+		// restoring changes anything.
+		_, err := cfg.db.GetChirp(r.Context(), chirpID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusInternalServerError, "database error", err)
+			return
+		}
+		wasVisible := err == nil
+
+		var a database.ModerationAction
+		if action == moderationRemove {
+			a, err = cfg.db.RemoveChirp(r.Context(), database.RemoveChirpParams{
+				ChirpID:     chirpID,
+				ModeratorID: cfg.actingModerator(r),
+				Reason:      reason,
+			})
+		} else {
+			a, err = cfg.db.ModerateChirp(r.Context(), database.ModerateChirpParams{
+				ChirpID:     chirpID,
+				ModeratorID: cfg.actingModerator(r),
+				Action:      action,
+				Reason:      reason,
+			})
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "chirp not found", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "couldn't moderate chirp", err)
+			return
+		}
+		switch {
+		case (action == moderationHide || action == moderationRemove) && wasVisible:
+			cfg.chirpDeleted(r.Context(), database.Chirp{ID: a.ChirpID, UserID: a.ChirpUserID})
+		case action == moderationRestore && !wasVisible:
+			chirp, err := cfg.db.GetChirp(r.Context(), a.ChirpID)
+			if err == nil {
+				err = cfg.chirpRestored(r.Context(), chirp)
+			}
+			if err != nil {
+				requestLogger(r.Context()).Error("couldn't announce restored chirp", "chirp_id", a.ChirpID, "error", err)
+			}
+		}
+		respondWithJSON(w, http.StatusOK, newModerationAction(a))
+	}
+}
+
+// moderationRequest reads the chirp ID and the optional {"reason": ...}
+// body of a moderator action.
+func moderationRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, string, bool) {
+	type parameters struct {
+		Reason string `json:"reason"`
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id", err)
+		return uuid.Nil, "", false
+	}
+	var params parameters
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid JSON", err)
+			return uuid.Nil, "", false
+		}
+	}
+	reason, err := parseReason(params.Reason, false)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return uuid.Nil, "", false
+	}
+	return chirpID, reason, true
+}
+
+// actingModerator returns the user taking a moderator action, or null when the
+// request was made with ADMIN_API_KEY.
+func (cfg *apiConfig) actingModerator(r *http.Request) uuid.NullUUID {
+	id, err := cfg.authenticate(r)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}
+}
+
+// handlerListModerationActions pages through the audit trail, newest first,
+// optionally only the actions about ?chirp_id=.
+func (cfg *apiConfig) handlerListModerationActions(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePageRequestSorted(r, true)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	arg := database.ListModerationActionsParams{
+		CursorCreatedAt: page.Cursor.CreatedAt,
+		CursorID:        page.Cursor.ID,
+		PageSize:        page.fetchSize(),
+	}
+	if s := r.URL.Query().Get("chirp_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid chirp_id", err)
+			return
+		}
+		arg.ChirpID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	rows, err := cfg.db.ListModerationActions(r.Context(), arg)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't retrieve moderation actions", err)
+		return
+	}
+	if len(rows) > page.Limit {
+		rows = rows[:page.Limit]
+		last := rows[len(rows)-1]
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	out := make([]ModerationAction, len(rows))
+	for i, a := range rows {
+		out[i] = newModerationAction(a)
+	}
+	respondWithJSON(w, http.StatusOK, out)
+}
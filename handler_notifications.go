@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Kinds of notification. They are also the event names on the WebSocket
+// notifications channel.
+const (
+	notifyMention = "mention"
+	notifyReply   = "reply"
+	notifyLike    = "like"
+	notifyFollow  = "follow"
+)
+
+type Notification struct {
+	ID        uuid.UUID     `json:"id"`
+	Kind      string        `json:"kind"`
+	ActorID   uuid.UUID     `json:"actor_id"`
+	ChirpID   uuid.NullUUID `json:"chirp_id"`
+	CreatedAt time.Time     `json:"created_at"`
+	ReadAt    *time.Time    `json:"read_at"`
+}
+
+func newNotification(n database.Notification) Notification {
+	out := Notification{
+		ID:        n.ID,
+		Kind:      n.Kind,
+		ActorID:   n.ActorID,
+		ChirpID:   n.ChirpID,
+		CreatedAt: n.CreatedAt,
+	}
+	if n.ReadAt.Valid {
+		out.ReadAt = &n.ReadAt.Time
+	}
+	return out
+}
+
+// notify tells user that actor did something of kind, unless they did it
+// to themselves or the user has turned kind off. Like emit, it only logs
+// failures.
+func (cfg *apiConfig) notify(ctx context.Context, user, actor uuid.UUID, kind string, chirpID uuid.NullUUID) {
+	if user == actor {
+		return
+	}
+	n, err := cfg.db.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  user,
+		ActorID: actor,
+		Kind:    kind,
+		ChirpID: chirpID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		requestLogger(ctx).Error("couldn't create notification", "kind", kind, "error", err)
+		return
+	}
+	cfg.userHub.publish(user, kind, newNotification(n))
+}
+
+// handlerListNotifications pages through the user's notifications, newest
+// first, or only the unread ones with ?unread=true.
+func (cfg *apiConfig) handlerListNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+	page, err := parsePageRequestSorted(r, true)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	rows, err := cfg.db.ListNotifications(r.Context(), database.ListNotificationsParams{
+		UserID:          userID,
+		UnreadOnly:      r.URL.Query().Get("unread") == "true",
+		CursorCreatedAt: page.Cursor.CreatedAt,
+		CursorID:        page.Cursor.ID,
+		PageSize:        page.fetchSize(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't retrieve notifications", err)
+		return
+	}
+	if len(rows) > page.Limit {
+		rows = rows[:page.Limit]
+		last := rows[len(rows)-1]
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	out := make([]Notification, 0, len(rows))
+	for _, n := range rows {
+		out = append(out, newNotification(n))
+	}
+	respondWithJSON(w, http.StatusOK, out)
+}
+
+// handlerMarkNotificationsRead marks the notifications in ids as read, or
+// all of them with {"all": true}.
+func (cfg *apiConfig) handlerMarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+	var params struct {
+		IDs []uuid.UUID `json:"ids"`
+		All bool        `json:"all"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid JSON", err)
+		return
+	}
+	if params.All == (len(params.IDs) > 0) {
+		respondWithError(w, http.StatusBadRequest, "send either ids or all", nil)
+		return
+	}
+
+	var n int64
+	if params.All {
+		n, err = cfg.db.MarkAllNotificationsRead(r.Context(), userID)
+	} else {
+		n, err = cfg.db.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{
+			UserID: userID,
+			Ids:    params.IDs,
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't mark notifications read", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]int64{"marked": n})
+}
+
+// NotificationPreferences says which kinds of notification a user gets.
+type NotificationPreferences struct {
+	Mentions bool `json:"mentions"`
+	Replies  bool `json:"replies"`
+	Likes    bool `json:"likes"`
+	Follows  bool `json:"follows"`
+}
+
+// notificationPreferences returns the user's preferences, or the defaults:
+// everything on.
+func (cfg *apiConfig) notificationPreferences(ctx context.Context, userID uuid.UUID) (NotificationPreferences, error) {
+	p, err := cfg.db.GetNotificationPreferences(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return NotificationPreferences{Mentions: true, Replies: true, Likes: true, Follows: true}, nil
+	}
+	if err != nil {
+		return NotificationPreferences{}, err
+	}
+	return NotificationPreferences{Mentions: p.Mentions, Replies: p.Replies, Likes: p.Likes, Follows: p.Follows}, nil
+}
+
+func (cfg *apiConfig) handlerGetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+	prefs, err := cfg.notificationPreferences(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, prefs)
+}
+
+// handlerSetNotificationPreferences changes the preferences present in the
+// body and leaves the others as they were.
+func (cfg *apiConfig) handlerSetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+	prefs, err := cfg.notificationPreferences(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error", err)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid JSON", err)
+		return
+	}
+	p, err := cfg.db.SetNotificationPreferences(r.Context(), database.SetNotificationPreferencesParams{
+		UserID:   userID,
+		Mentions: prefs.Mentions,
+		Replies:  prefs.Replies,
+		Likes:    prefs.Likes,
+		Follows:  prefs.Follows,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't save preferences", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, NotificationPreferences{Mentions: p.Mentions, Replies: p.Replies, Likes: p.Likes, Follows: p.Follows})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Geraetefreund/chirpy/internal/auth"
+	"github.com/Geraetefreund/chirpy/internal/database"
+)
+
+// handlerSetUserRole grants a user a role. The user's tokens keep their old
+// role until they expire or are refreshed.
+func (cfg *apiConfig) handlerSetUserRole(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Role string `json:"role"`
+	}
+	userID, ok := cfg.pathUser(w, r)
+	if !ok {
+		return
+	}
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid JSON", err)
+		return
+	}
+	role, err := auth.ParseRole(params.Role)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "role must be user, moderator or admin", err)
+		return
+	}
+	cfg.setUserRole(w, r, database.SetUserRoleParams{ID: userID, Role: string(role)})
+}
+
+// handlerRevokeUserRole makes a user a plain user again.
+func (cfg *apiConfig) handlerRevokeUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.pathUser(w, r)
+	if !ok {
+		return
+	}
+	cfg.setUserRole(w, r, database.SetUserRoleParams{ID: userID, Role: string(auth.RoleUser)})
+}
+
+func (cfg *apiConfig) setUserRole(w http.ResponseWriter, r *http.Request, arg database.SetUserRoleParams) {
+	user, err := cfg.db.SetUserRole(r.Context(), arg)
+	if err != nil {
+		// pathUser has checked, but the user may be deleted since.
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "user not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "couldn't set role", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, User{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+		Role:        user.Role,
+	})
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/rivo/uniseg"
+)
+
+type ScheduledChirp struct {
+	ID        string        `json:"id"`
+	Body      string        `json:"body"`
+	UserID    string        `json:"user_id"`
+	InReplyTo uuid.NullUUID `json:"in_reply_to"`
+	PublishAt time.Time     `json:"publish_at"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+func newScheduledChirp(c database.ScheduledChirp) ScheduledChirp {
+	return ScheduledChirp{
+		ID:        c.ID.String(),
+		Body:      c.Body,
+		UserID:    c.UserID.String(),
+		InReplyTo: c.InReplyTo,
+		PublishAt: c.PublishAt,
+		CreatedAt: c.CreatedAt,
+	}
+}
+
+// scheduleChirp finishes a POST /api/chirps that carries publish_at. The
+// body has already been validated and cleaned.
+func (cfg *apiConfig) scheduleChirp(w http.ResponseWriter, r *http.Request, arg database.CreateScheduledChirpParams) {
+	if !arg.PublishAt.After(time.Now()) {
+		respondWithError(w, http.StatusBadRequest, "publish_at must be in the future", nil)
+		return
+	}
+	c, err := cfg.db.CreateScheduledChirp(r.Context(), arg)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't schedule chirp", err)
+		return
+	}
+	respondWithJSON(w, http.StatusAccepted, newScheduledChirp(c))
+}
+
+func (cfg *apiConfig) handlerListScheduledChirps(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+	rows, err := cfg.db.ListScheduledChirps(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't list scheduled chirps", err)
+		return
+	}
+	out := make([]ScheduledChirp, 0, len(rows))
+	for _, c := range rows {
+		out = append(out, newScheduledChirp(c))
+	}
+	respondWithJSON(w, http.StatusOK, out)
+}
+
+func (cfg *apiConfig) handlerDeleteScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+	id, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id", nil)
+		return
+	}
+	n, err := cfg.db.DeleteScheduledChirp(r.Context(), database.DeleteScheduledChirpParams{ID: id, UserID: userID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't delete scheduled chirp", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "scheduled chirp not found", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// publishScheduledChirps posts due chirps every interval until ctx is done.
+func (cfg *apiConfig) publishScheduledChirps(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			cfg.publishDueChirps(ctx, now)
+		}
+	}
+}
+
+// publishDueChirps posts every chirp scheduled for now or earlier. A chirp
+// that fails to post is logged and dropped; it has already been claimed.
+func (cfg *apiConfig) publishDueChirps(ctx context.Context, now time.Time) {
+	due, err := cfg.db.ClaimDueScheduledChirps(ctx, now)
+	if err != nil {
+		slog.Error("couldn't claim scheduled chirps", "error", err)
+		return
+	}
+	for _, c := range due {
+		if err := cfg.publishScheduledChirp(ctx, c); err != nil {
+			slog.Error("couldn't publish scheduled chirp", "chirp_id", c.ID, "error", err)
+		}
+	}
+}
+
+// publishScheduledChirp posts c as a new chirp. Mentions are resolved now,
+// so they pick up users who signed up after it was scheduled. The author's
+// plan is checked again too: a chirp their plan no longer allows, because
+// they have since left Chirpy Red, is dropped.
+func (cfg *apiConfig) publishScheduledChirp(ctx context.Context, c database.ScheduledChirp) error {
+	ent, err := cfg.entitlements(ctx, c.UserID)
+	if err != nil {
+		return err
+	}
+	if !ent.ScheduleChirps || uniseg.GraphemeClusterCount(c.Body) > ent.MaxChirpLength {
+		slog.Info("dropping scheduled chirp the author's plan no longer allows", "chirp_id", c.ID, "user_id", c.UserID)
+		return nil
+	}
+
+	entities, err := cfg.findEntities(ctx, c.Body)
+	if err != nil {
+		return err
+	}
+	arg := database.CreateChirpParams{
+		Body:      c.Body,
+		UserID:    c.UserID,
+		InReplyTo: c.InReplyTo,
+	}
+	arg.Tags, arg.TagStarts, arg.TagEnds,
+		arg.MentionUserIds, arg.MentionStarts, arg.MentionEnds = entities.columns()
+	chirp, err := cfg.db.CreateChirp(ctx, arg)
+	if err != nil {
+		return err
+	}
+	cfg.chirpCreated(ctx, chirp, entities)
+	cfg.flagForReview(ctx, chirp)
+	return nil
+}
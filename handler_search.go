@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/Geraetefreund/chirpy/internal/search"
+	"github.com/google/uuid"
+)
+
+// searchCursor is the keyset position of the last result on a page of
+// search results ranked by relevance.
+type searchCursor struct {
+	Relevance float64
+	pageCursor
+}
+
+func firstSearchCursor() searchCursor {
+	return searchCursor{Relevance: math.MaxFloat64, pageCursor: firstPageCursor(true)}
+}
+
+func (c searchCursor) encode() string {
+	raw := strconv.FormatFloat(c.Relevance, 'g', -1, 64) + "|" + c.pageCursor.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSearchCursor(s string) (searchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return searchCursor{}, errors.New("malformed cursor")
+	}
+	rel, rest, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return searchCursor{}, errors.New("malformed cursor")
+	}
+	relevance, err := strconv.ParseFloat(rel, 64)
+	if err != nil {
+		return searchCursor{}, errors.New("malformed cursor")
+	}
+	page, err := parseCursor(rest)
+	if err != nil {
+		return searchCursor{}, err
+	}
+	return searchCursor{Relevance: relevance, pageCursor: page}, nil
+}
+
+// handlerChirpsSearch serves GET /api/chirps/search?q=. Results are ranked
+// by relevance unless sort=recent asks for newest first, and can be
+// narrowed down with author_id.
+func (cfg *apiConfig) handlerChirpsSearch(w http.ResponseWriter, r *http.Request) {
+	q, err := search.Parse(r.URL.Query().Get("q"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "q must contain at least one word", err)
+		return
+	}
+
+	var authorID uuid.NullUUID
+	if s := r.URL.Query().Get("author_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid author_id", err)
+			return
+		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	var dbChirps []database.Chirp
+	switch r.URL.Query().Get("sort") {
+	case "", "relevance":
+		limit, err := parseLimit(r)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		cursor := firstSearchCursor()
+		if s := r.URL.Query().Get("cursor"); s != "" {
+			cursor, err = decodeSearchCursor(s)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, err.Error(), err)
+				return
+			}
+		}
+
+		rows, err := cfg.db.SearchChirps(r.Context(), database.SearchChirpsParams{
+			Query:           q.TSQuery(),
+			AuthorID:        authorID,
+			CursorRelevance: cursor.Relevance,
+			CursorCreatedAt: cursor.CreatedAt,
+			CursorID:        cursor.ID,
+			PageSize:        int32(limit + 1),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "couldn't search chirps", err)
+			return
+		}
+		if len(rows) > limit {
+			rows = rows[:limit]
+			last := rows[len(rows)-1]
+			setNextPageLink(w, r, searchCursor{
+				Relevance:  last.Relevance,
+				pageCursor: pageCursor{CreatedAt: last.Chirp.CreatedAt, ID: last.Chirp.ID},
+			})
+		}
+		for _, row := range rows {
+			dbChirps = append(dbChirps, row.Chirp)
+		}
+
+	case "recent":
+		page, err := parsePageRequestSorted(r, true)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		dbChirps, err = cfg.db.SearchChirpsRecent(r.Context(), database.SearchChirpsRecentParams{
+			Query:           q.TSQuery(),
+			AuthorID:        authorID,
+			CursorCreatedAt: page.Cursor.CreatedAt,
+			CursorID:        page.Cursor.ID,
+			PageSize:        page.fetchSize(),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "couldn't search chirps", err)
+			return
+		}
+		if len(dbChirps) > page.Limit {
+			dbChirps = dbChirps[:page.Limit]
+			last := dbChirps[len(dbChirps)-1]
+			setNextPageLink(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		}
+
+	default:
+		respondWithError(w, http.StatusBadRequest, "sort must be relevance or recent", nil)
+		return
+	}
+
+	out, err := cfg.newChirps(r, dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't search chirps", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// errCodeSuspended is the error code of responses refused because the user
+// is suspended.
+const errCodeSuspended = "account_suspended"
+
+// Suspension is a user's current suspension. SuspendedUntil is null for a
+// suspension without an end.
+type Suspension struct {
+	UserID         uuid.UUID  `json:"user_id"`
+	Reason         string     `json:"reason"`
+	SuspendedAt    time.Time  `json:"suspended_at"`
+	SuspendedUntil *time.Time `json:"suspended_until"`
+}
+
+// suspendedAt reports whether a suspension from at until until is in effect
+// at now.
+func suspendedAt(at, until sql.NullTime, now time.Time) bool {
+	return at.Valid && (!until.Valid || until.Time.After(now))
+}
+
+// respondSuspended refuses a request from a suspended user, with the reason
+// and end of the suspension so clients can show them.
+func respondSuspended(w http.ResponseWriter, until sql.NullTime, reason string) {
+	type response struct {
+		Error          string     `json:"error"`
+		Code           string     `json:"code"`
+		Reason         string     `json:"reason"`
+		SuspendedUntil *time.Time `json:"suspended_until"`
+	}
+	resp := response{
+		Error:  "account suspended",
+		Code:   errCodeSuspended,
+		Reason: reason,
+	}
+	if until.Valid {
+		resp.SuspendedUntil = &until.Time
+	}
+	respondWithJSON(w, http.StatusForbidden, resp)
+}
+
+// rejectSuspended refuses every write made with the access token of a
+// suspended user. Access tokens outlive the suspension of their user, so the
+// user is looked up on each write; reads are let through.
+func (cfg *apiConfig) rejectSuspended(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		userID, err := cfg.authenticate(r)
+		if err != nil {
+			// Not a user's access token; the handler decides.
+			next.ServeHTTP(w, r)
+			return
+		}
+		user, err := cfg.db.GetUser(r.Context(), userID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusInternalServerError, "database error", err)
+			return
+		}
+		if err == nil && suspendedAt(user.SuspendedAt, user.SuspendedUntil, time.Now()) {
+			respondSuspended(w, user.SuspendedUntil, user.SuspensionReason)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handlerSuspendUser suspends a user, until the given time or for good, and
+// revokes their refresh tokens. Suspending a suspended user replaces the
+// suspension.
+func (cfg *apiConfig) handlerSuspendUser(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Reason string     `json:"reason"`
+		Until  *time.Time `json:"until"`
+	}
+	// An unknown user is found by SuspendUser, without a lookup first.
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id", err)
+		return
+	}
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid JSON", err)
+		return
+	}
+	reason, err := parseReason(params.Reason, false)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	arg := database.SuspendUserParams{ID: userID, SuspensionReason: reason}
+	if params.Until != nil {
+		if !params.Until.After(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "until must be in the future", nil)
+			return
+		}
+		arg.SuspendedUntil = sql.NullTime{Time: *params.Until, Valid: true}
+	}
+
+	user, err := cfg.db.SuspendUser(r.Context(), arg)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "user not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "couldn't suspend user", err)
+		return
+	}
+	cfg.userHub.publish(user.ID, eventUserSuspended, nil)
+	out := Suspension{
+		UserID:      user.ID,
+		Reason:      user.SuspensionReason,
+		SuspendedAt: user.SuspendedAt.Time,
+	}
+	if user.SuspendedUntil.Valid {
+		out.SuspendedUntil = &user.SuspendedUntil.Time
+	}
+	respondWithJSON(w, http.StatusOK, out)
+}
+
+func (cfg *apiConfig) handlerUnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id", err)
+		return
+	}
+	if _, err := cfg.db.UnsuspendUser(r.Context(), userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "user not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "couldn't lift suspension", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
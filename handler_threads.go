@@ -0,0 +1,104 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// maxThreadDepth bounds how many ancestors a thread response walks up, and
+// how many levels of replies it walks down. Replies nested deeper than that
+// are reached through the thread of a chirp further down.
+const maxThreadDepth = 100
+
+type Thread struct {
+	// Ancestors runs from the root of the conversation down to the parent
+	// of Chirp.
+	Ancestors []Chirp `json:"ancestors"`
+	Chirp     Chirp   `json:"chirp"`
+	// Replies is one page of the whole reply tree below Chirp, oldest
+	// first, so every reply comes after the one it answers. in_reply_to
+	// and depth place each reply in the tree.
+	Replies []ThreadReply `json:"replies"`
+}
+
+// ThreadReply is a chirp in the reply tree of a thread. Depth is 1 for a
+// direct reply, 2 for a reply to that, and so on.
+type ThreadReply struct {
+	Chirp
+	Depth int32 `json:"depth"`
+}
+
+func (cfg *apiConfig) handlerChirpThread(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id", nil)
+		return
+	}
+	page, err := parsePageRequestSorted(r, false)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirp(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "chirp not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "database error", err)
+		return
+	}
+
+	ancestors, err := cfg.db.ListChirpAncestors(r.Context(), database.ListChirpAncestorsParams{
+		ID:       id,
+		MaxDepth: maxThreadDepth,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't retrieve thread", err)
+		return
+	}
+	slices.Reverse(ancestors)
+
+	replies, err := cfg.db.ListChirpDescendants(r.Context(), database.ListChirpDescendantsParams{
+		ID:              id,
+		MaxDepth:        maxThreadDepth,
+		CursorCreatedAt: page.Cursor.CreatedAt,
+		CursorID:        page.Cursor.ID,
+		PageSize:        page.fetchSize(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't retrieve thread", err)
+		return
+	}
+	if len(replies) > page.Limit {
+		replies = replies[:page.Limit]
+		last := replies[len(replies)-1].Chirp
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	// One round trip for every reply count in the response.
+	all := slices.Concat(ancestors, []database.Chirp{chirp})
+	for _, reply := range replies {
+		all = append(all, reply.Chirp)
+	}
+	out, err := cfg.newChirps(r, all)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't retrieve thread", err)
+		return
+	}
+	thread := Thread{
+		Ancestors: out[:len(ancestors)],
+		Chirp:     out[len(ancestors)],
+		Replies:   make([]ThreadReply, len(replies)),
+	}
+	for i, reply := range replies {
+		thread.Replies[i] = ThreadReply{Chirp: out[len(ancestors)+1+i], Depth: reply.Depth}
+	}
+	respondWithJSON(w, http.StatusOK, thread)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+)
+
+// handlerTimeline serves the authenticated user's home timeline: chirps by
+// the accounts they follow, newest first.
+func (cfg *apiConfig) handlerTimeline(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+	page, err := parsePageRequestSorted(r, true)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	dbChirps, err := cfg.db.ListTimeline(r.Context(), database.ListTimelineParams{
+		UserID:          userID,
+		CursorCreatedAt: page.Cursor.CreatedAt,
+		CursorID:        page.Cursor.ID,
+		PageSize:        page.fetchSize(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't retrieve timeline", err)
+		return
+	}
+	cfg.respondWithChirpPage(w, r, page, dbChirps)
+}
@@ -53,6 +53,7 @@ func (cfg *apiConfig) handlerUpdateEmailAndPW(w http.ResponseWriter, r *http.Req
 
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "couldn't update user credentials", err)
+		return
 	}
 
 	respondWithJSON(w, http.StatusOK, response{
@@ -61,6 +62,7 @@ func (cfg *apiConfig) handlerUpdateEmailAndPW(w http.ResponseWriter, r *http.Req
 			CreatedAt: user.CreatedAt,
 			UpdatedAt: user.UpdatedAt,
 			Email:     user.Email,
+			Role:      user.Role,
 		},
 	})
 }
@@ -97,9 +99,14 @@ func (cfg *apiConfig) handlerRefreshToken(w http.ResponseWriter, r *http.Request
 	user, err := cfg.db.GetUserFromRefreshToken(r.Context(), refreshToken)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "invalid refresh token", err)
+		return
+	}
+	if suspendedAt(user.SuspendedAt, user.SuspendedUntil, time.Now()) {
+		respondSuspended(w, user.SuspendedUntil, user.SuspensionReason)
+		return
 	}
 
-	jwtToken, err := auth.MakeJWT(user.ID, cfg.secret, time.Hour)
+	jwtToken, err := auth.MakeJWT(user.ID, auth.Role(user.Role), cfg.secret, time.Hour)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "error creating token: ", err)
 		return
@@ -17,6 +17,7 @@ type User struct {
 	Token        string    `json:"token"`
 	RefreshToken string    `json:"refresh_token"`
 	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	Role         string    `json:"role"`
 }
 
 func (cfg *apiConfig) handlerUsersCreate(w http.ResponseWriter, r *http.Request) {
@@ -61,6 +62,7 @@ func (cfg *apiConfig) handlerUsersCreate(w http.ResponseWriter, r *http.Request)
 			UpdatedAt:   user.UpdatedAt,
 			Email:       user.Email,
 			IsChirpyRed: user.IsChirpyRed,
+			Role:        user.Role,
 		},
 	})
 }
@@ -83,25 +85,33 @@ func (cfg *apiConfig) handlerUsersLogin(w http.ResponseWriter, r *http.Request)
 
 	user, err := cfg.db.LookUpUserByEmail(r.Context(), params.Email)
 	if err != nil {
+		cfg.metrics.logins.WithLabelValues(loginFailure).Inc()
 		respondWithError(w, http.StatusUnauthorized, "incorrect email or password", nil)
 		return
 	}
 
 	passwordOK, err := auth.CheckPasswordHash(params.Password, user.HashedPassword)
 	if err != nil {
+		cfg.metrics.logins.WithLabelValues(loginFailure).Inc()
 		respondWithError(w, http.StatusForbidden, "wrong password", err)
 		return
 
 	}
 
 	if !passwordOK {
+		cfg.metrics.logins.WithLabelValues(loginFailure).Inc()
 		respondWithError(w, http.StatusUnauthorized, "incorrect email or password", nil)
 		return
 	}
+	if suspendedAt(user.SuspendedAt, user.SuspendedUntil, time.Now()) {
+		cfg.metrics.logins.WithLabelValues(loginSuspended).Inc()
+		respondSuspended(w, user.SuspendedUntil, user.SuspensionReason)
+		return
+	}
 
 	expires := time.Duration(3600) * time.Second
 
-	token, err := auth.MakeJWT(user.ID, cfg.secret, expires)
+	token, err := auth.MakeJWT(user.ID, auth.Role(user.Role), cfg.secret, expires)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "error creating token: ", err)
 		return
@@ -127,7 +137,9 @@ func (cfg *apiConfig) handlerUsersLogin(w http.ResponseWriter, r *http.Request)
 	_, err = cfg.db.CreateRefreshToken(r.Context(), createRefreshTokenParams)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "failed to create refresh token in database", err)
+		return
 	}
+	cfg.metrics.logins.WithLabelValues(loginSuccess).Inc()
 
 	respondWithJSON(w, http.StatusOK, response{
 		User: User{
@@ -138,48 +150,7 @@ func (cfg *apiConfig) handlerUsersLogin(w http.ResponseWriter, r *http.Request)
 			Token:        token,
 			RefreshToken: refreshToken,
 			IsChirpyRed:  user.IsChirpyRed,
+			Role:         user.Role,
 		},
 	})
 }
-
-func (cfg *apiConfig) webhookChirpyRed(w http.ResponseWriter, r *http.Request) {
-	apiKEY, err := auth.GetAPIKey(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "API Key missing or malformed", err)
-		return
-	}
-	if cfg.polkaKey != apiKEY {
-		respondWithError(w, http.StatusUnauthorized, "API Key mismatch", err)
-		return
-	}
-
-	type parameters struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID string `json:"user_id"`
-		} `json:"data"`
-	}
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode json", err)
-		return
-	}
-	userID, err := uuid.Parse(params.Data.UserID)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't parse UUID", err)
-		return
-	}
-
-	if params.Event != "user.upgraded" {
-		respondWithJSON(w, http.StatusNoContent, nil)
-	} else if params.Event == "user.upgraded" {
-		_, err := cfg.db.UpgradeChirpyPlus(r.Context(), userID)
-		if err != nil {
-			respondWithError(w, http.StatusNotFound, "Couldn't update status in database", err)
-			return
-		}
-		respondWithJSON(w, http.StatusNoContent, nil)
-	}
-}
@@ -3,10 +3,9 @@ package main
 import (
 	"encoding/json"
 	"net/http"
-	"strings"
 )
 
-func handlerChirpsValidate(w http.ResponseWriter, r *http.Request) {
+func (cfg *apiConfig) handlerChirpsValidate(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Body string `json:"body"`
 	}
@@ -22,35 +21,20 @@ func handlerChirpsValidate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	const maxChirpLength = 140
-	if len(params.Body) > maxChirpLength {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
-		return
-	}
-	/*
-		we could use a nested loop (simple, easy to read) but O(n) or a map for O(1)
-		normally, if profanities is data or too large, we create the map from a slice
-		profanities := []string{"kerfuffle", "sharbert", "fornax"}
-		profanityMap := make(map[string]struct{})
-		for _, p := range profanities {
-			profanityMap[p] = struct{}{}
+	// Signed-in users are checked against their own limit.
+	ent := cfg.plans.Standard
+	if userID, err := cfg.authenticate(r); err == nil {
+		if ent, err = cfg.entitlements(r.Context(), userID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error", err)
+			return
 		}
-
-		below, I simply created the map inline since it was only three words.
-	*/
-
-	profanityMap := map[string]struct{}{
-		"kerfuffle": {},
-		"sharbert":  {},
-		"fornax":    {},
 	}
-	words := strings.Fields(params.Body)
-	for i, w := range words {
-		if _, ok := profanityMap[strings.ToLower(w)]; ok {
-			words[i] = "****"
-		}
+
+	cleanedBody, err := cfg.validateAndClean(params.Body, ent.MaxChirpLength)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
 	}
-	cleanedBody := strings.Join(words, " ")
 
 	respondWithJSON(w, http.StatusOK, returnVals{
 		CleanedBody: cleanedBody,
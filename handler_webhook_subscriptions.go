@@ -0,0 +1,247 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/auth"
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type WebhookSubscription struct {
+	ID     uuid.UUID     `json:"id"`
+	UserID uuid.NullUUID `json:"user_id"`
+	URL    string        `json:"url"`
+	Events []string      `json:"events"`
+	// Secret is only shown when the subscription is created.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newWebhookSubscription(s database.WebhookSubscription) WebhookSubscription {
+	return WebhookSubscription{
+		ID:        s.ID,
+		UserID:    s.UserID,
+		URL:       s.Url,
+		Events:    strings.Fields(s.Events),
+		CreatedAt: s.CreatedAt,
+	}
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastError      string          `json:"last_error,omitempty"`
+	ResponseStatus *int32          `json:"response_status"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+func newWebhookDelivery(d database.WebhookDelivery) WebhookDelivery {
+	out := WebhookDelivery{
+		ID:        d.ID,
+		Event:     d.Event,
+		Payload:   json.RawMessage(d.Payload),
+		Status:    d.Status,
+		Attempts:  d.Attempts,
+		LastError: d.LastError,
+		CreatedAt: d.CreatedAt,
+	}
+	if d.Status == "pending" {
+		out.NextAttemptAt = &d.NextAttemptAt
+	}
+	if d.ResponseStatus.Valid {
+		out.ResponseStatus = &d.ResponseStatus.Int32
+	}
+	if d.DeliveredAt.Valid {
+		out.DeliveredAt = &d.DeliveredAt.Time
+	}
+	return out
+}
+
+// webhookOwner says whose subscriptions a request manages: a user's under
+// /api/webhooks, or the admins' (a null user) under /admin/webhooks.
+type webhookOwner func(r *http.Request) (uuid.NullUUID, error)
+
+func (cfg *apiConfig) webhookUser(r *http.Request) (uuid.NullUUID, error) {
+	id, err := cfg.authenticate(r)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}, nil
+}
+
+// webhookAdmin is the owner of admin subscriptions. Routes using it must be
+// wrapped in requireRole(auth.RoleAdmin, ...).
+func webhookAdmin(r *http.Request) (uuid.NullUUID, error) {
+	return uuid.NullUUID{}, nil
+}
+
+type webhookSubscriptionParams struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+func (p webhookSubscriptionParams) validate() error {
+	u, err := url.Parse(p.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if len(p.Events) == 0 {
+		return errors.New("events must not be empty")
+	}
+	for _, e := range p.Events {
+		if !slices.Contains(webhookEventNames, e) {
+			return errors.New("unknown event " + e)
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerCreateWebhookSubscription(owner webhookOwner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := owner(r)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+			return
+		}
+		var params webhookSubscriptionParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid JSON", err)
+			return
+		}
+		if err := params.validate(); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		slices.Sort(params.Events)
+
+		secret, err := auth.MakeRefreshToken()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "couldn't generate secret", err)
+			return
+		}
+		sub, err := cfg.db.CreateWebhookSubscription(r.Context(), database.CreateWebhookSubscriptionParams{
+			UserID: userID,
+			Url:    params.URL,
+			Secret: secret,
+			Events: strings.Join(slices.Compact(params.Events), " "),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "couldn't create webhook", err)
+			return
+		}
+		out := newWebhookSubscription(sub)
+		out.Secret = sub.Secret
+		respondWithJSON(w, http.StatusCreated, out)
+	}
+}
+
+func (cfg *apiConfig) handlerListWebhookSubscriptions(owner webhookOwner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := owner(r)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+			return
+		}
+		rows, err := cfg.db.ListWebhookSubscriptions(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "couldn't list webhooks", err)
+			return
+		}
+		out := make([]WebhookSubscription, 0, len(rows))
+		for _, s := range rows {
+			out = append(out, newWebhookSubscription(s))
+		}
+		respondWithJSON(w, http.StatusOK, out)
+	}
+}
+
+func (cfg *apiConfig) handlerDeleteWebhookSubscription(owner webhookOwner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := owner(r)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+			return
+		}
+		id, err := uuid.Parse(r.PathValue("webhookID"))
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid id", nil)
+			return
+		}
+		n, err := cfg.db.DeleteWebhookSubscription(r.Context(), database.DeleteWebhookSubscriptionParams{ID: id, UserID: userID})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "couldn't delete webhook", err)
+			return
+		}
+		if n == 0 {
+			respondWithError(w, http.StatusNotFound, "webhook not found", nil)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handlerListWebhookDeliveries pages through a subscription's delivery log,
+// newest first.
+func (cfg *apiConfig) handlerListWebhookDeliveries(owner webhookOwner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := owner(r)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+			return
+		}
+		id, err := uuid.Parse(r.PathValue("webhookID"))
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid id", nil)
+			return
+		}
+		page, err := parsePageRequestSorted(r, true)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+
+		sub, err := cfg.db.GetWebhookSubscription(r.Context(), id)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusInternalServerError, "database error", err)
+			return
+		}
+		if err != nil || sub.UserID != userID {
+			respondWithError(w, http.StatusNotFound, "webhook not found", err)
+			return
+		}
+
+		rows, err := cfg.db.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+			SubscriptionID:  id,
+			CursorCreatedAt: page.Cursor.CreatedAt,
+			CursorID:        page.Cursor.ID,
+			PageSize:        page.fetchSize(),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "couldn't retrieve deliveries", err)
+			return
+		}
+		if len(rows) > page.Limit {
+			rows = rows[:page.Limit]
+			last := rows[len(rows)-1]
+			setNextPageLink(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		}
+		out := make([]WebhookDelivery, 0, len(rows))
+		for _, d := range rows {
+			out = append(out, newWebhookDelivery(d))
+		}
+		respondWithJSON(w, http.StatusOK, out)
+	}
+}
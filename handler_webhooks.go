@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/auth"
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/Geraetefreund/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// polkaEvent is a webhook delivery from Polka. When ID is set, redeliveries
+// of an event that was already handled are acknowledged without being
+// applied again. It is optional for deliveries authenticated by ApiKey, but
+// a signed delivery must carry it, or it could be replayed as often as the
+// signature tolerance allows.
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID string `json:"user_id"`
+	} `json:"data"`
+}
+
+// polkaHandlers apply the Polka events we act on. Other events are
+// acknowledged and logged as ignored.
+var polkaHandlers = map[string]func(store.Users, context.Context, uuid.UUID) (database.User, error){
+	"user.upgraded":          store.Users.UpgradeChirpyPlus,
+	"user.downgraded":        store.Users.DowngradeChirpyPlus,
+	"subscription.cancelled": store.Users.DowngradeChirpyPlus,
+}
+
+const (
+	maxWebhookBody = 1 << 20
+	// polkaSignatureTolerance is how far a signed delivery's timestamp may
+	// be from our clock. Replays inside the window are caught by event ID.
+	polkaSignatureTolerance = 5 * time.Minute
+)
+
+// verifyPolka authenticates a delivery and reports whether it was signed. A
+// signed delivery must carry a valid signature by one of the Polka keys. An
+// unsigned one must carry one of the keys in an ApiKey header, unless
+// signatures are required.
+func (cfg *apiConfig) verifyPolka(h http.Header, body []byte) (signed bool, err error) {
+	if len(cfg.polkaKeys) == 0 {
+		return false, errors.New("Polka webhooks are not configured")
+	}
+	err = auth.VerifyWebhookSignature(h, body, cfg.polkaKeys, time.Now(), polkaSignatureTolerance)
+	if !errors.Is(err, auth.ErrMissingSignature) || cfg.polkaRequireSignature {
+		return !errors.Is(err, auth.ErrMissingSignature), err
+	}
+
+	apiKey, err := auth.GetAPIKey(h)
+	if err != nil {
+		return false, err
+	}
+	match := 0
+	for _, key := range cfg.polkaKeys {
+		match |= subtle.ConstantTimeCompare([]byte(apiKey), []byte(key))
+	}
+	if match != 1 {
+		return false, errors.New("API Key mismatch")
+	}
+	return false, nil
+}
+
+func (cfg *apiConfig) webhookChirpyRed(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		cfg.metrics.webhookReceived("polka", webhookBadRequest)
+		respondWithError(w, http.StatusBadRequest, "Couldn't read body", err)
+		return
+	}
+	signed, err := cfg.verifyPolka(r.Header, payload)
+	if err != nil {
+		cfg.metrics.webhookReceived("polka", webhookUnauthorized)
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+
+	var params polkaEvent
+	if err := json.Unmarshal(payload, &params); err != nil {
+		cfg.metrics.webhookReceived("polka", webhookBadRequest)
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode json", err)
+		return
+	}
+	if params.Event == "" {
+		cfg.metrics.webhookReceived("polka", webhookBadRequest)
+		respondWithError(w, http.StatusBadRequest, "event is missing", nil)
+		return
+	}
+	if signed && params.ID == "" {
+		cfg.metrics.webhookReceived("polka", webhookBadRequest)
+		respondWithError(w, http.StatusBadRequest, "id is required in signed deliveries", nil)
+		return
+	}
+	apply := polkaHandlers[params.Event]
+	var userID uuid.NullUUID
+	if id, err := uuid.Parse(params.Data.UserID); err == nil {
+		userID = uuid.NullUUID{UUID: id, Valid: true}
+	} else if apply != nil {
+		cfg.metrics.webhookReceived("polka", webhookBadRequest)
+		respondWithError(w, http.StatusBadRequest, "Couldn't parse UUID", err)
+		return
+	}
+
+	event, err := cfg.db.RecordWebhookEvent(r.Context(), database.RecordWebhookEventParams{
+		Source:  "polka",
+		EventID: sql.NullString{String: params.ID, Valid: params.ID != ""},
+		Event:   params.Event,
+		UserID:  userID,
+		Payload: string(payload),
+	})
+	if err != nil {
+		cfg.metrics.webhookReceived("polka", webhookError)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record event", err)
+		return
+	}
+	if event.Deliveries > 1 {
+		// A redelivery: the delivery that stored the event applies it, even
+		// if it is still doing so or has failed.
+		cfg.metrics.webhookReceived(event.Source, webhookDuplicate)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if apply == nil {
+		cfg.setWebhookEventStatus(r.Context(), event, webhookIgnored, nil)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	user, err := apply(cfg.db, r.Context(), userID.UUID)
+	if err != nil {
+		cfg.setWebhookEventStatus(r.Context(), event, webhookFailed, err)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update status in database", err)
+		return
+	}
+	cfg.setWebhookEventStatus(r.Context(), event, webhookProcessed, nil)
+
+	change := eventUserDowngraded
+	if user.IsChirpyRed {
+		change = eventUserUpgraded
+	}
+	cfg.emit(r.Context(), change, userID, map[string]any{
+		"user_id":       user.ID,
+		"is_chirpy_red": user.IsChirpyRed,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setWebhookEventStatus records and counts the outcome of an event. Failing
+// to record it is only logged: the event itself has been handled.
+func (cfg *apiConfig) setWebhookEventStatus(ctx context.Context, event database.WebhookEvent, status string, cause error) {
+	cfg.metrics.webhookReceived(event.Source, status)
+	arg := database.SetWebhookEventStatusParams{ID: event.ID, Status: status}
+	if cause != nil {
+		arg.Error = cause.Error()
+	}
+	if err := cfg.db.SetWebhookEventStatus(ctx, arg); err != nil {
+		requestLogger(ctx).Error("couldn't set status of webhook event", "event_id", event.ID, "error", err)
+	}
+}
+
+type WebhookEvent struct {
+	ID             uuid.UUID       `json:"id"`
+	Source         string          `json:"source"`
+	EventID        string          `json:"event_id,omitempty"`
+	Event          string          `json:"event"`
+	UserID         uuid.NullUUID   `json:"user_id"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Error          string          `json:"error,omitempty"`
+	Deliveries     int32           `json:"deliveries"`
+	ReceivedAt     time.Time       `json:"received_at"`
+	LastReceivedAt time.Time       `json:"last_received_at"`
+}
+
+// handlerListWebhookEvents serves the webhook log, newest first, optionally
+// filtered by ?user_id=.
+func (cfg *apiConfig) handlerListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePageRequestSorted(r, true)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	arg := database.ListWebhookEventsParams{
+		CursorReceivedAt: page.Cursor.CreatedAt,
+		CursorID:         page.Cursor.ID,
+		PageSize:         page.fetchSize(),
+	}
+	if s := r.URL.Query().Get("user_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid user_id", err)
+			return
+		}
+		arg.UserID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	rows, err := cfg.db.ListWebhookEvents(r.Context(), arg)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't retrieve webhook events", err)
+		return
+	}
+	if len(rows) > page.Limit {
+		rows = rows[:page.Limit]
+		last := rows[len(rows)-1]
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.ReceivedAt, ID: last.ID})
+	}
+
+	out := make([]WebhookEvent, 0, len(rows))
+	for _, e := range rows {
+		payload := json.RawMessage(e.Payload)
+		if !json.Valid(payload) {
+			payload = nil
+		}
+		out = append(out, WebhookEvent{
+			ID:             e.ID,
+			Source:         e.Source,
+			EventID:        e.EventID.String,
+			Event:          e.Event,
+			UserID:         e.UserID,
+			Payload:        payload,
+			Status:         e.Status,
+			Error:          e.Error,
+			Deliveries:     e.Deliveries,
+			ReceivedAt:     e.ReceivedAt,
+			LastReceivedAt: e.LastReceivedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/auth"
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+)
+
+const (
+	wsPingInterval = 30 * time.Second
+	wsWriteTimeout = 10 * time.Second
+	wsMaxMessage   = 4 << 10
+	// wsMaxTopics bounds the subscriptions of one connection.
+	wsMaxTopics = 50
+)
+
+// WebSocket channels a client can subscribe to.
+const (
+	wsFeed          = "feed"
+	wsAuthor        = "author"
+	wsNotifications = "notifications"
+)
+
+// wsCommand is a message from the client:
+//
+//	{"type": "subscribe", "channel": "author", "author_id": "..."}
+type wsCommand struct {
+	Type     string    `json:"type"`
+	Channel  string    `json:"channel"`
+	AuthorID uuid.UUID `json:"author_id"`
+}
+
+// wsMessage is a message to the client. Type is "subscribed",
+// "unsubscribed", "event" or "error".
+type wsMessage struct {
+	Type     string          `json:"type"`
+	Channel  string          `json:"channel,omitempty"`
+	AuthorID *uuid.UUID      `json:"author_id,omitempty"`
+	Event    string          `json:"event,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// wsTopic identifies a subscription. AuthorID is only set for wsAuthor.
+type wsTopic struct {
+	Channel  string
+	AuthorID uuid.UUID
+}
+
+func (t wsTopic) message(typ string) wsMessage {
+	m := wsMessage{Type: typ, Channel: t.Channel}
+	if t.Channel == wsAuthor {
+		m.AuthorID = &t.AuthorID
+	}
+	return m
+}
+
+// connLimiter caps the number of open connections per user.
+type connLimiter struct {
+	max  int
+	mu   sync.Mutex
+	open map[uuid.UUID]int
+}
+
+func newConnLimiter(max int) *connLimiter {
+	return &connLimiter{max: max, open: make(map[uuid.UUID]int)}
+}
+
+func (l *connLimiter) acquire(user uuid.UUID) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.open[user] >= l.max {
+		return false
+	}
+	l.open[user]++
+	return true
+}
+
+func (l *connLimiter) release(user uuid.UUID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.open[user]--; l.open[user] <= 0 {
+		delete(l.open, user)
+	}
+}
+
+// handlerWebSocket upgrades to a WebSocket on which the client subscribes to
+// the global feed, an author's chirps or its own notifications. The
+// connection lasts no longer than the token it was opened with.
+func (cfg *apiConfig) handlerWebSocket(w http.ResponseWriter, r *http.Request) {
+	tokenStr, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+	userID, expires, err := auth.ValidateJWTExpiry(tokenStr, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+	// rejectSuspended lets GETs through, but a socket keeps pushing events
+	// for as long as it is open.
+	user, err := cfg.db.GetUser(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusUnauthorized, "unauthorized", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "database error", err)
+		return
+	}
+	if suspendedAt(user.SuspendedAt, user.SuspendedUntil, time.Now()) {
+		respondSuspended(w, user.SuspendedUntil, user.SuspensionReason)
+		return
+	}
+	if !cfg.wsConns.acquire(userID) {
+		respondWithError(w, http.StatusTooManyRequests, "too many open connections", nil)
+		return
+	}
+	defer cfg.wsConns.release(userID)
+
+	c, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		// Accept has already responded.
+		requestLogger(r.Context()).Info("websocket upgrade failed", "error", err)
+		return
+	}
+	defer c.CloseNow()
+	defer cfg.metrics.streamOpened(streamWebSocket)()
+	c.SetReadLimit(wsMaxMessage)
+
+	conn := &wsConn{
+		cfg:     cfg,
+		c:       c,
+		user:    userID,
+		expires: expires,
+		out:     make(chan wsMessage, chirpHubBuffer),
+		topics:  make(map[wsTopic]func()),
+	}
+	conn.serve(r.Context())
+}
+
+// wsConn is one WebSocket connection. The read loop owns topics; events
+// are queued on out for the write loop.
+type wsConn struct {
+	cfg  *apiConfig
+	c    *websocket.Conn
+	user uuid.UUID
+	// expires is when the token the connection was opened with expires.
+	expires time.Time
+	out     chan wsMessage
+	topics  map[wsTopic]func()
+}
+
+func (conn *wsConn) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer func() {
+		for _, unsubscribe := range conn.topics {
+			unsubscribe()
+		}
+	}()
+	go conn.writeLoop(ctx)
+	go conn.keepAlive(ctx)
+	// Subscribed before reading, so that a suspension right after the
+	// upgrade isn't missed.
+	account := conn.cfg.userHub.subscribe(conn.user)
+	defer conn.cfg.userHub.unsubscribe(account)
+	go conn.watchAccount(ctx, account)
+
+	for {
+		typ, dat, err := conn.c.Read(ctx)
+		if err != nil {
+			return
+		}
+		var cmd wsCommand
+		if typ != websocket.MessageText || json.Unmarshal(dat, &cmd) != nil {
+			conn.send(wsMessage{Type: "error", Error: "messages must be JSON text"})
+			continue
+		}
+		conn.handle(cmd)
+	}
+}
+
+func (conn *wsConn) handle(cmd wsCommand) {
+	topic := wsTopic{Channel: cmd.Channel}
+	switch cmd.Channel {
+	case wsFeed, wsNotifications:
+	case wsAuthor:
+		if cmd.AuthorID == uuid.Nil {
+			conn.send(wsMessage{Type: "error", Error: "author_id is required"})
+			return
+		}
+		topic.AuthorID = cmd.AuthorID
+	default:
+		conn.send(wsMessage{Type: "error", Error: "unknown channel " + cmd.Channel})
+		return
+	}
+
+	switch cmd.Type {
+	case "subscribe":
+		if _, ok := conn.topics[topic]; !ok {
+			if len(conn.topics) >= wsMaxTopics {
+				conn.send(wsMessage{Type: "error", Error: "too many subscriptions"})
+				return
+			}
+			conn.topics[topic] = conn.subscribe(topic)
+		}
+		conn.send(topic.message("subscribed"))
+	case "unsubscribe":
+		if unsubscribe, ok := conn.topics[topic]; ok {
+			unsubscribe()
+			delete(conn.topics, topic)
+		}
+		conn.send(topic.message("unsubscribed"))
+	default:
+		conn.send(wsMessage{Type: "error", Error: "unknown type " + cmd.Type})
+	}
+}
+
+// subscribe starts forwarding topic's events and returns a function that
+// stops it.
+func (conn *wsConn) subscribe(topic wsTopic) func() {
+	var stopped atomic.Bool
+	// dropped closes the connection if a hub gave up on it for falling
+	// behind, rather than silently ending the subscription.
+	dropped := func() {
+		if !stopped.Load() {
+			conn.c.Close(websocket.StatusTryAgainLater, "too slow")
+		}
+	}
+
+	if topic.Channel == wsNotifications {
+		sub := conn.cfg.userHub.subscribe(conn.user)
+		go func() {
+			for e := range sub.C {
+				if e.Name == eventUserSuspended {
+					continue
+				}
+				m := topic.message("event")
+				m.Event, m.Data = e.Name, e.Data
+				conn.send(m)
+			}
+			dropped()
+		}()
+		return func() {
+			stopped.Store(true)
+			conn.cfg.userHub.unsubscribe(sub)
+		}
+	}
+
+	author := uuid.NullUUID{UUID: topic.AuthorID, Valid: topic.Channel == wsAuthor}
+	sub, _, _ := conn.cfg.chirpHub.subscribe(author, "")
+	go func() {
+		for e := range sub.C {
+			m := topic.message("event")
+			m.Event, m.Data = e.Name, e.Data
+			conn.send(m)
+		}
+		dropped()
+	}()
+	return func() {
+		stopped.Store(true)
+		conn.cfg.chirpHub.unsubscribe(sub)
+	}
+}
+
+// send queues m without blocking. A client that can't keep up is
+// disconnected.
+func (conn *wsConn) send(m wsMessage) {
+	select {
+	case conn.out <- m:
+	default:
+		conn.c.Close(websocket.StatusTryAgainLater, "too slow")
+	}
+}
+
+func (conn *wsConn) writeLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m := <-conn.out:
+			dat, err := json.Marshal(m)
+			if err != nil {
+				requestLogger(ctx).Error("couldn't encode websocket message", "error", err)
+				continue
+			}
+			wctx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+			err = conn.c.Write(wctx, websocket.MessageText, dat)
+			cancel()
+			if err != nil {
+				conn.c.CloseNow()
+				return
+			}
+		}
+	}
+}
+
+// watchAccount closes the connection when its token expires or its user is
+// suspended. A client that still may connect reconnects with a fresh token.
+func (conn *wsConn) watchAccount(ctx context.Context, account *userSub) {
+	var expired <-chan time.Time
+	if !conn.expires.IsZero() {
+		timer := time.NewTimer(time.Until(conn.expires))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	events := account.C
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-expired:
+			conn.c.Close(websocket.StatusPolicyViolation, "token expired")
+			return
+		case e, ok := <-events:
+			if !ok {
+				// The hub dropped us for falling behind; the expiry
+				// still applies.
+				events = nil
+				continue
+			}
+			if e.Name == eventUserSuspended {
+				conn.c.Close(websocket.StatusPolicyViolation, "account suspended")
+				return
+			}
+		}
+	}
+}
+
+// keepAlive pings the client every wsPingInterval and drops the connection
+// if a pong doesn't come back in time.
+func (conn *wsConn) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pctx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+			err := conn.c.Ping(pctx)
+			cancel()
+			if err != nil {
+				conn.c.CloseNow()
+				return
+			}
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	_ "crypto/ecdsa"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"github.com/alexedwards/argon2id"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
@@ -35,12 +36,22 @@ func CheckPasswordHash(password, hash string) (bool, error) {
 	return match, err
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	claims := &jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userID.String(),
+// Claims are the claims of an access token. Role is the user's role when
+// the token was issued.
+type Claims struct {
+	Role Role `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func MakeJWT(userID uuid.UUID, role Role, tokenSecret string, expiresIn time.Duration) (string, error) {
+	claims := &Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -50,24 +61,56 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	id, _, err := ValidateJWTRole(tokenString, tokenSecret)
+	return id, err
+}
+
+// ValidateJWTRole is ValidateJWT that also returns the role claim. Tokens
+// issued without one are for RoleUser.
+func ValidateJWTRole(tokenString, tokenSecret string) (uuid.UUID, Role, error) {
+	id, claims, err := validateClaims(tokenString, tokenSecret)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	if claims.Role == "" {
+		claims.Role = RoleUser
+	}
+	return id, claims.Role, nil
+}
+
+// ValidateJWTExpiry is ValidateJWT that also returns when the token expires,
+// for connections that outlive the request they were opened with. It is the
+// zero time for a token without an exp claim.
+func ValidateJWTExpiry(tokenString, tokenSecret string) (uuid.UUID, time.Time, error) {
+	id, claims, err := validateClaims(tokenString, tokenSecret)
+	if err != nil {
+		return uuid.Nil, time.Time{}, err
+	}
+	if claims.ExpiresAt == nil {
+		return id, time.Time{}, nil
+	}
+	return id, claims.ExpiresAt.Time, nil
+}
+
+func validateClaims(tokenString, tokenSecret string) (uuid.UUID, *Claims, error) {
 	token, err := jwt.ParseWithClaims(
 		tokenString,
-		&jwt.RegisteredClaims{}, func(token *jwt.Token) (any, error) {
+		&Claims{}, func(token *jwt.Token) (any, error) {
 			return []byte(tokenSecret), nil
 		})
 
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, nil, err
 	}
 
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	claims, ok := token.Claims.(*Claims)
 	if !ok || !token.Valid {
-		return uuid.Nil, err
+		return uuid.Nil, nil, errors.New("invalid token")
 	}
 
 	id, err := uuid.Parse(claims.Subject)
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, nil, err
 	}
-	return id, nil
+	return id, claims, nil
 }
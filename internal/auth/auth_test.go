@@ -87,7 +87,7 @@ func TestJWTFunctions(t *testing.T) {
 	userID := uuid.New()
 
 	t.Run("Valid token", func(t *testing.T) {
-		token, err := MakeJWT(userID, secret, time.Hour)
+		token, err := MakeJWT(userID, RoleUser, secret, time.Hour)
 		if err != nil {
 			t.Fatalf("MakeJWT failed: %v", err)
 		}
@@ -103,7 +103,7 @@ func TestJWTFunctions(t *testing.T) {
 	})
 
 	t.Run("Expired token", func(t *testing.T) {
-		token, err := MakeJWT(userID, secret, -time.Minute)
+		token, err := MakeJWT(userID, RoleUser, secret, -time.Minute)
 		if err != nil {
 			t.Errorf("MakeJWT failed: %v", err)
 		}
@@ -114,8 +114,37 @@ func TestJWTFunctions(t *testing.T) {
 		}
 	})
 
+	t.Run("Role claim", func(t *testing.T) {
+		token, err := MakeJWT(userID, RoleModerator, secret, time.Hour)
+		if err != nil {
+			t.Fatalf("MakeJWT failed: %v", err)
+		}
+		id, role, err := ValidateJWTRole(token, secret)
+		if err != nil || id != userID || role != RoleModerator {
+			t.Errorf("Expected %v as %s, got %v as %s, %v", userID, RoleModerator, id, role, err)
+		}
+		if !role.AtLeast(RoleUser) || role.AtLeast(RoleAdmin) {
+			t.Errorf("Expected %s to include user but not admin", role)
+		}
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		before := time.Now().Add(time.Hour).Truncate(time.Second)
+		token, err := MakeJWT(userID, RoleUser, secret, time.Hour)
+		if err != nil {
+			t.Fatalf("MakeJWT failed: %v", err)
+		}
+		id, exp, err := ValidateJWTExpiry(token, secret)
+		if err != nil || id != userID {
+			t.Fatalf("Expected %v, got %v, %v", userID, id, err)
+		}
+		if exp.Before(before) || exp.After(time.Now().Add(time.Hour)) {
+			t.Errorf("Expected expiry in an hour, got %v", exp)
+		}
+	})
+
 	t.Run("Wrong secret", func(t *testing.T) {
-		token, err := MakeJWT(userID, secret, time.Hour)
+		token, err := MakeJWT(userID, RoleUser, secret, time.Hour)
 		if err != nil {
 			t.Fatalf("MakeJWT failed: %v", err)
 		}
@@ -0,0 +1,33 @@
+package auth
+
+import "fmt"
+
+// Role is what a user may do beyond using the API as themselves. Roles are
+// ordered: every role can do what the roles below it can.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleUser:      0,
+	RoleModerator: 1,
+	RoleAdmin:     2,
+}
+
+// ParseRole checks that s names a role.
+func ParseRole(s string) (Role, error) {
+	if _, ok := roleRanks[Role(s)]; !ok {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+	return Role(s), nil
+}
+
+// AtLeast reports whether r includes min. Unknown roles include nothing.
+func (r Role) AtLeast(min Role) bool {
+	rank, ok := roleRanks[r]
+	return ok && rank >= roleRanks[min]
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook signatures are sent in two headers: the Unix time of sending, and
+// one or more "v1=<hex>" HMAC-SHA256 signatures of "<timestamp>.<body>",
+// comma separated. A sender that is rotating keys signs with each of them.
+const (
+	SignatureTimestampHeader = "Webhook-Timestamp"
+	SignatureHeader          = "Webhook-Signature"
+)
+
+var (
+	ErrMissingSignature = errors.New("missing webhook signature")
+	ErrStaleSignature   = errors.New("webhook timestamp outside the allowed window")
+	ErrBadSignature     = errors.New("webhook signature does not match")
+)
+
+// SignWebhook sets the signature headers for body, sent at ts, signing with
+// each of keys.
+func SignWebhook(h http.Header, body []byte, ts time.Time, keys ...string) {
+	stamp := strconv.FormatInt(ts.Unix(), 10)
+	sigs := make([]string, len(keys))
+	for i, key := range keys {
+		sigs[i] = "v1=" + hex.EncodeToString(webhookMAC(key, stamp, body))
+	}
+	h.Set(SignatureTimestampHeader, stamp)
+	h.Set(SignatureHeader, strings.Join(sigs, ","))
+}
+
+// VerifyWebhookSignature checks that body was signed with one of keys less
+// than tolerance before or after now. Signatures are compared in constant
+// time.
+func VerifyWebhookSignature(h http.Header, body []byte, keys []string, now time.Time, tolerance time.Duration) error {
+	stamp := h.Get(SignatureTimestampHeader)
+	header := h.Get(SignatureHeader)
+	if stamp == "" || header == "" {
+		return ErrMissingSignature
+	}
+	unix, err := strconv.ParseInt(stamp, 10, 64)
+	if err != nil {
+		return errors.New("malformed webhook timestamp")
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return ErrStaleSignature
+	}
+
+	for _, sig := range strings.Split(header, ",") {
+		version, value, ok := strings.Cut(strings.TrimSpace(sig), "=")
+		if !ok || version != "v1" {
+			continue
+		}
+		got, err := hex.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		for _, key := range keys {
+			if hmac.Equal(got, webhookMAC(key, stamp, body)) {
+				return nil
+			}
+		}
+	}
+	return ErrBadSignature
+}
+
+func webhookMAC(key, stamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(stamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
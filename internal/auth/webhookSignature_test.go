@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"event":"user.upgraded"}`)
+	now := time.Unix(1_700_000_000, 0)
+	const tolerance = 5 * time.Minute
+
+	tests := []struct {
+		name    string
+		signed  func(h http.Header)
+		keys    []string
+		wantErr error
+	}{
+		{
+			name:   "valid",
+			signed: func(h http.Header) { SignWebhook(h, body, now, "new") },
+			keys:   []string{"new"},
+		},
+		{
+			name:   "old key during rotation",
+			signed: func(h http.Header) { SignWebhook(h, body, now, "old") },
+			keys:   []string{"new", "old"},
+		},
+		{
+			name:   "sender signs with both keys",
+			signed: func(h http.Header) { SignWebhook(h, body, now, "retired", "new") },
+			keys:   []string{"new"},
+		},
+		{
+			name:    "wrong key",
+			signed:  func(h http.Header) { SignWebhook(h, body, now, "other") },
+			keys:    []string{"new"},
+			wantErr: ErrBadSignature,
+		},
+		{
+			name:    "stale",
+			signed:  func(h http.Header) { SignWebhook(h, body, now.Add(-10*time.Minute), "new") },
+			keys:    []string{"new"},
+			wantErr: ErrStaleSignature,
+		},
+		{
+			name:    "from the future",
+			signed:  func(h http.Header) { SignWebhook(h, body, now.Add(10*time.Minute), "new") },
+			keys:    []string{"new"},
+			wantErr: ErrStaleSignature,
+		},
+		{
+			name:    "unsigned",
+			signed:  func(h http.Header) {},
+			keys:    []string{"new"},
+			wantErr: ErrMissingSignature,
+		},
+		{
+			name: "tampered body",
+			signed: func(h http.Header) {
+				SignWebhook(h, []byte(`{"event":"user.downgraded"}`), now, "new")
+			},
+			keys:    []string{"new"},
+			wantErr: ErrBadSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			tt.signed(h)
+			err := VerifyWebhookSignature(h, body, tt.keys, now, tolerance)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("want %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: banned_words.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+)
+
+const deleteBannedWord = `-- name: DeleteBannedWord :execrows
+DELETE FROM banned_words WHERE word = $1
+`
+
+func (q *Queries) DeleteBannedWord(ctx context.Context, word string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteBannedWord, word)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const importBannedWords = `-- name: ImportBannedWords :execrows
+INSERT INTO banned_words (word, action, created_at)
+SELECT ($1::text[])[i], ($2::text[])[i], NOW()
+FROM generate_subscripts($1::text[], 1) AS i
+ON CONFLICT (word) DO UPDATE SET action = excluded.action
+`
+
+type ImportBannedWordsParams struct {
+	Words   []string
+	Actions []string
+}
+
+// words and actions are parallel arrays. Words must not repeat.
+func (q *Queries) ImportBannedWords(ctx context.Context, arg ImportBannedWordsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, importBannedWords, pq.Array(arg.Words), pq.Array(arg.Actions))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listBannedWords = `-- name: ListBannedWords :many
+SELECT word, action, created_at FROM banned_words
+ORDER BY word
+`
+
+func (q *Queries) ListBannedWords(ctx context.Context) ([]BannedWord, error) {
+	rows, err := q.db.QueryContext(ctx, listBannedWords)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BannedWord
+	for rows.Next() {
+		var i BannedWord
+		if err := rows.Scan(&i.Word, &i.Action, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertBannedWord = `-- name: UpsertBannedWord :one
+INSERT INTO banned_words (word, action, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (word) DO UPDATE SET action = excluded.action
+RETURNING word, action, created_at
+`
+
+type UpsertBannedWordParams struct {
+	Word   string
+	Action string
+}
+
+func (q *Queries) UpsertBannedWord(ctx context.Context, arg UpsertBannedWordParams) (BannedWord, error) {
+	row := q.db.QueryRowContext(ctx, upsertBannedWord, arg.Word, arg.Action)
+	var i BannedWord
+	err := row.Scan(&i.Word, &i.Action, &i.CreatedAt)
+	return i, err
+}
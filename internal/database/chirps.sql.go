@@ -7,29 +7,100 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countReplies = `-- name: CountReplies :many
+SELECT in_reply_to::uuid AS chirp_id, COUNT(*) AS reply_count
+FROM chirps
+WHERE in_reply_to = ANY($1::uuid[])
+  AND hidden_at IS NULL
+GROUP BY in_reply_to
+`
+
+type CountRepliesRow struct {
+	ChirpID    uuid.UUID
+	ReplyCount int64
+}
+
+func (q *Queries) CountReplies(ctx context.Context, chirpIds []uuid.UUID) ([]CountRepliesRow, error) {
+	rows, err := q.db.QueryContext(ctx, countReplies, pq.Array(chirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountRepliesRow
+	for rows.Next() {
+		var i CountRepliesRow
+		if err := rows.Scan(&i.ChirpID, &i.ReplyCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (
-  gen_random_uuid(),
-  NOW(),
-  NOW(),
-  $1,
-  $2
+WITH new_chirp AS (
+  SELECT gen_random_uuid() AS id
+), hashtags AS (
+  INSERT INTO chirp_hashtags (chirp_id, tag, start_offset, end_offset)
+  SELECT new_chirp.id,
+    ($4::text[])[i],
+    ($5::int[])[i],
+    ($6::int[])[i]
+  FROM new_chirp, generate_subscripts($4::text[], 1) AS i
+), mentions AS (
+  INSERT INTO chirp_mentions (chirp_id, user_id, start_offset, end_offset)
+  SELECT new_chirp.id,
+    ($7::uuid[])[i],
+    ($8::int[])[i],
+    ($9::int[])[i]
+  FROM new_chirp, generate_subscripts($7::uuid[], 1) AS i
 )
-RETURNING id, created_at, updated_at, body, user_id
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to)
+SELECT new_chirp.id, NOW(), NOW(), $1, $2, $3
+FROM new_chirp
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, search_vector, hidden_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body           string
+	UserID         uuid.UUID
+	InReplyTo      uuid.NullUUID
+	Tags           []string
+	TagStarts      []int32
+	TagEnds        []int32
+	MentionUserIds []uuid.UUID
+	MentionStarts  []int32
+	MentionEnds    []int32
 }
 
+// Hashtags and mentions come in as parallel arrays, indexed by
+// generate_subscripts, and are stored by the same statement. Foreign keys
+// are checked at the end of the statement, by which time the chirp row
+// exists.
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.InReplyTo,
+		pq.Array(arg.Tags),
+		pq.Array(arg.TagStarts),
+		pq.Array(arg.TagEnds),
+		pq.Array(arg.MentionUserIds),
+		pq.Array(arg.MentionStarts),
+		pq.Array(arg.MentionEnds),
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +108,9 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.SearchVector,
+		&i.HiddenAt,
 	)
 	return i, err
 }
@@ -51,13 +125,55 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
-const getAllChirpsDesc = `-- name: GetAllChirpsDesc :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY created_at DESC
+const getChirp = `-- name: GetChirp :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, search_vector, hidden_at FROM chirps
+WHERE id = $1
+  AND hidden_at IS NULL
+`
+
+func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.SearchVector,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const listChirpAncestors = `-- name: ListChirpAncestors :many
+WITH RECURSIVE ancestors (id, in_reply_to, depth) AS (
+  SELECT parent.id, parent.in_reply_to, 1
+  FROM chirps AS child
+  JOIN chirps AS parent ON parent.id = child.in_reply_to
+  WHERE child.id = $1
+  UNION ALL
+  SELECT parent.id, parent.in_reply_to, ancestors.depth + 1
+  FROM ancestors
+  JOIN chirps AS parent ON parent.id = ancestors.in_reply_to
+  WHERE ancestors.depth < $2::int
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.search_vector, chirps.hidden_at
+FROM ancestors
+JOIN chirps ON chirps.id = ancestors.id
+WHERE chirps.hidden_at IS NULL
+ORDER BY ancestors.depth ASC
 `
 
-func (q *Queries) GetAllChirpsDesc(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirpsDesc)
+type ListChirpAncestorsParams struct {
+	ID       uuid.UUID
+	MaxDepth int32
+}
+
+// Walks in_reply_to up from the given chirp, nearest parent first.
+func (q *Queries) ListChirpAncestors(ctx context.Context, arg ListChirpAncestorsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpAncestors, arg.ID, arg.MaxDepth)
 	if err != nil {
 		return nil, err
 	}
@@ -71,6 +187,9 @@ func (q *Queries) GetAllChirpsDesc(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.SearchVector,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -85,31 +204,132 @@ func (q *Queries) GetAllChirpsDesc(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE id =$1
+const listChirpDescendants = `-- name: ListChirpDescendants :many
+WITH RECURSIVE descendants (id, depth) AS (
+  SELECT chirps.id, 1
+  FROM chirps
+  WHERE chirps.in_reply_to = $4::uuid
+    AND chirps.hidden_at IS NULL
+  UNION ALL
+  SELECT chirps.id, descendants.depth + 1
+  FROM descendants
+  JOIN chirps ON chirps.in_reply_to = descendants.id
+  WHERE chirps.hidden_at IS NULL
+    AND descendants.depth < $5::int
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.search_vector, chirps.hidden_at, descendants.depth::int AS depth
+FROM descendants
+JOIN chirps ON chirps.id = descendants.id
+WHERE (chirps.created_at, chirps.id) > ($1::timestamptz, $2::uuid)
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT $3
 `
 
-func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirp, id)
-	var i Chirp
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Body,
-		&i.UserID,
+type ListChirpDescendantsParams struct {
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int32
+	ID              uuid.UUID
+	MaxDepth        int32
+}
+
+type ListChirpDescendantsRow struct {
+	Chirp Chirp
+	Depth int32
+}
+
+// Walks in_reply_to down from the given chirp, max_depth levels at most.
+// A hidden chirp is left out together with the replies below it. The whole
+// subtree is paged oldest first, so a reply always comes after its parent.
+func (q *Queries) ListChirpDescendants(ctx context.Context, arg ListChirpDescendantsParams) ([]ListChirpDescendantsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpDescendants,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+		arg.ID,
+		arg.MaxDepth,
 	)
-	return i, err
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListChirpDescendantsRow
+	for rows.Next() {
+		var i ListChirpDescendantsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.SearchVector,
+			&i.Chirp.HiddenAt,
+			&i.Depth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpRevisions = `-- name: ListChirpRevisions :many
+SELECT id, chirp_id, body, created_at FROM chirp_revisions
+WHERE chirp_id = $1
+ORDER BY created_at ASC, id ASC
+`
+
+func (q *Queries) ListChirpRevisions(ctx context.Context, chirpID uuid.UUID) ([]ChirpRevision, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpRevisions, chirpID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChirpRevision
+	for rows.Next() {
+		var i ChirpRevision
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChirpID,
+			&i.Body,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY created_at ASC
+const listChirps = `-- name: ListChirps :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, search_vector, hidden_at FROM chirps
+WHERE (created_at, id) > ($1::timestamptz, $2::uuid)
+  AND hidden_at IS NULL
+ORDER BY created_at ASC, id ASC
+LIMIT $3
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+type ListChirpsParams struct {
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int32
+}
+
+func (q *Queries) ListChirps(ctx context.Context, arg ListChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirps, arg.CursorCreatedAt, arg.CursorID, arg.PageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -123,6 +343,9 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.SearchVector,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -137,14 +360,29 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getChirpsByID = `-- name: GetChirpsByID :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id =$1
-ORDER BY created_at ASC
+const listChirpsByAuthor = `-- name: ListChirpsByAuthor :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, search_vector, hidden_at FROM chirps
+WHERE user_id = $1
+  AND (created_at, id) > ($2::timestamptz, $3::uuid)
+  AND hidden_at IS NULL
+ORDER BY created_at ASC, id ASC
+LIMIT $4
 `
 
-func (q *Queries) GetChirpsByID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByID, userID)
+type ListChirpsByAuthorParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int32
+}
+
+func (q *Queries) ListChirpsByAuthor(ctx context.Context, arg ListChirpsByAuthorParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsByAuthor,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -158,6 +396,9 @@ func (q *Queries) GetChirpsByID(ctx context.Context, userID uuid.UUID) ([]Chirp,
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.SearchVector,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -172,14 +413,29 @@ func (q *Queries) GetChirpsByID(ctx context.Context, userID uuid.UUID) ([]Chirp,
 	return items, nil
 }
 
-const getChirpsByIDDesc = `-- name: GetChirpsByIDDesc :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id =$1
-ORDER BY created_at DESC
+const listChirpsByAuthorDesc = `-- name: ListChirpsByAuthorDesc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, search_vector, hidden_at FROM chirps
+WHERE user_id = $1
+  AND (created_at, id) < ($2::timestamptz, $3::uuid)
+  AND hidden_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT $4
 `
 
-func (q *Queries) GetChirpsByIDDesc(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByIDDesc, userID)
+type ListChirpsByAuthorDescParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int32
+}
+
+func (q *Queries) ListChirpsByAuthorDesc(ctx context.Context, arg ListChirpsByAuthorDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsByAuthorDesc,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -193,6 +449,9 @@ func (q *Queries) GetChirpsByIDDesc(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.SearchVector,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -206,3 +465,122 @@ func (q *Queries) GetChirpsByIDDesc(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, search_vector, hidden_at FROM chirps
+WHERE (created_at, id) < ($1::timestamptz, $2::uuid)
+  AND hidden_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT $3
+`
+
+type ListChirpsDescParams struct {
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int32
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc, arg.CursorCreatedAt, arg.CursorID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.SearchVector,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateChirp = `-- name: UpdateChirp :one
+WITH revision AS (
+  INSERT INTO chirp_revisions (id, chirp_id, body, created_at)
+  SELECT gen_random_uuid(), chirps.id, chirps.body, chirps.updated_at
+  FROM chirps
+  WHERE chirps.id = $2
+), old_hashtags AS (
+  DELETE FROM chirp_hashtags WHERE chirp_hashtags.chirp_id = $2
+), old_mentions AS (
+  DELETE FROM chirp_mentions WHERE chirp_mentions.chirp_id = $2
+), hashtags AS (
+  INSERT INTO chirp_hashtags (chirp_id, tag, start_offset, end_offset)
+  SELECT chirps.id,
+    ($3::text[])[i],
+    ($4::int[])[i],
+    ($5::int[])[i]
+  FROM chirps, generate_subscripts($3::text[], 1) AS i
+  WHERE chirps.id = $2
+), mentions AS (
+  INSERT INTO chirp_mentions (chirp_id, user_id, start_offset, end_offset)
+  SELECT chirps.id,
+    ($6::uuid[])[i],
+    ($7::int[])[i],
+    ($8::int[])[i]
+  FROM chirps, generate_subscripts($6::uuid[], 1) AS i
+  WHERE chirps.id = $2
+)
+UPDATE chirps
+SET body = $1,
+  updated_at = NOW()
+WHERE chirps.id = $2
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, search_vector, hidden_at
+`
+
+type UpdateChirpParams struct {
+	Body           string
+	ID             uuid.UUID
+	Tags           []string
+	TagStarts      []int32
+	TagEnds        []int32
+	MentionUserIds []uuid.UUID
+	MentionStarts  []int32
+	MentionEnds    []int32
+}
+
+// The previous body is kept as a revision, stamped with the time it was
+// written, and the hashtags and mentions are replaced with those of the new
+// body.
+func (q *Queries) UpdateChirp(ctx context.Context, arg UpdateChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirp,
+		arg.Body,
+		arg.ID,
+		pq.Array(arg.Tags),
+		pq.Array(arg.TagStarts),
+		pq.Array(arg.TagEnds),
+		pq.Array(arg.MentionUserIds),
+		pq.Array(arg.MentionStarts),
+		pq.Array(arg.MentionEnds),
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.SearchVector,
+		&i.HiddenAt,
+	)
+	return i, err
+}
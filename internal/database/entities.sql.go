@@ -0,0 +1,196 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: entities.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const listChirpHashtags = `-- name: ListChirpHashtags :many
+SELECT chirp_id, tag, start_offset, end_offset FROM chirp_hashtags
+WHERE chirp_id = ANY($1::uuid[])
+ORDER BY chirp_id, start_offset
+`
+
+func (q *Queries) ListChirpHashtags(ctx context.Context, chirpIds []uuid.UUID) ([]ChirpHashtag, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpHashtags, pq.Array(chirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChirpHashtag
+	for rows.Next() {
+		var i ChirpHashtag
+		if err := rows.Scan(
+			&i.ChirpID,
+			&i.Tag,
+			&i.StartOffset,
+			&i.EndOffset,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpMentions = `-- name: ListChirpMentions :many
+SELECT chirp_id, user_id, start_offset, end_offset FROM chirp_mentions
+WHERE chirp_id = ANY($1::uuid[])
+ORDER BY chirp_id, start_offset
+`
+
+func (q *Queries) ListChirpMentions(ctx context.Context, chirpIds []uuid.UUID) ([]ChirpMention, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpMentions, pq.Array(chirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChirpMention
+	for rows.Next() {
+		var i ChirpMention
+		if err := rows.Scan(
+			&i.ChirpID,
+			&i.UserID,
+			&i.StartOffset,
+			&i.EndOffset,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsByHashtag = `-- name: ListChirpsByHashtag :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, search_vector, hidden_at FROM chirps
+WHERE EXISTS (
+    SELECT 1 FROM chirp_hashtags
+    WHERE chirp_hashtags.chirp_id = chirps.id
+      AND chirp_hashtags.tag = $1
+  )
+  AND (created_at, id) < ($2::timestamptz, $3::uuid)
+  AND hidden_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type ListChirpsByHashtagParams struct {
+	Tag             string
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int32
+}
+
+func (q *Queries) ListChirpsByHashtag(ctx context.Context, arg ListChirpsByHashtagParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsByHashtag,
+		arg.Tag,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.SearchVector,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsMentioning = `-- name: ListChirpsMentioning :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, search_vector, hidden_at FROM chirps
+WHERE EXISTS (
+    SELECT 1 FROM chirp_mentions
+    WHERE chirp_mentions.chirp_id = chirps.id
+      AND chirp_mentions.user_id = $1
+  )
+  AND (created_at, id) < ($2::timestamptz, $3::uuid)
+  AND hidden_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type ListChirpsMentioningParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int32
+}
+
+func (q *Queries) ListChirpsMentioning(ctx context.Context, arg ListChirpsMentioningParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsMentioning,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.SearchVector,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,200 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: follows.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const followUser = `-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING
+`
+
+type FollowUserParams struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+}
+
+func (q *Queries) FollowUser(ctx context.Context, arg FollowUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, followUser, arg.FollowerID, arg.FolloweeID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listFollowers = `-- name: ListFollowers :many
+SELECT follower_id, followee_id, created_at FROM follows
+WHERE followee_id = $1
+  AND (created_at, follower_id) < ($2::timestamptz, $3::uuid)
+ORDER BY created_at DESC, follower_id DESC
+LIMIT $4
+`
+
+type ListFollowersParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int32
+}
+
+func (q *Queries) ListFollowers(ctx context.Context, arg ListFollowersParams) ([]Follow, error) {
+	rows, err := q.db.QueryContext(ctx, listFollowers,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Follow
+	for rows.Next() {
+		var i Follow
+		if err := rows.Scan(&i.FollowerID, &i.FolloweeID, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFollowing = `-- name: ListFollowing :many
+SELECT follower_id, followee_id, created_at FROM follows
+WHERE follower_id = $1
+  AND (created_at, followee_id) < ($2::timestamptz, $3::uuid)
+ORDER BY created_at DESC, followee_id DESC
+LIMIT $4
+`
+
+type ListFollowingParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int32
+}
+
+func (q *Queries) ListFollowing(ctx context.Context, arg ListFollowingParams) ([]Follow, error) {
+	rows, err := q.db.QueryContext(ctx, listFollowing,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Follow
+	for rows.Next() {
+		var i Follow
+		if err := rows.Scan(&i.FollowerID, &i.FolloweeID, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTimeline = `-- name: ListTimeline :many
+SELECT recent.id, recent.created_at, recent.updated_at, recent.body, recent.user_id, recent.in_reply_to, recent.search_vector, recent.hidden_at FROM follows
+CROSS JOIN LATERAL (
+  SELECT id, created_at, updated_at, body, user_id, in_reply_to, search_vector, hidden_at FROM chirps
+  WHERE chirps.user_id = follows.followee_id
+    AND (chirps.created_at, chirps.id) < ($1::timestamptz, $2::uuid)
+    AND chirps.hidden_at IS NULL
+  ORDER BY chirps.created_at DESC, chirps.id DESC
+  LIMIT $3
+) AS recent
+WHERE follows.follower_id = $4
+ORDER BY recent.created_at DESC, recent.id DESC
+LIMIT $3
+`
+
+type ListTimelineParams struct {
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int32
+	UserID          uuid.UUID
+}
+
+// Newest chirps by everyone user_id follows. The lateral subquery reads at
+// most page_size rows per followee from chirps_user_id_created_at_id_idx, so
+// the cost is bounded by followees * page_size rather than by the followees'
+// full history.
+func (q *Queries) ListTimeline(ctx context.Context, arg ListTimelineParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTimeline,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+		arg.UserID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.SearchVector,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const unfollowUser = `-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = $1
+  AND followee_id = $2
+`
+
+type UnfollowUserParams struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+}
+
+func (q *Queries) UnfollowUser(ctx context.Context, arg UnfollowUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unfollowUser, arg.FollowerID, arg.FolloweeID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
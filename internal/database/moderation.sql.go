@@ -0,0 +1,303 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: moderation.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createChirpFlag = `-- name: CreateChirpFlag :one
+INSERT INTO chirp_reports (id, chirp_id, reporter_id, reason, created_at)
+VALUES (gen_random_uuid(), $1, NULL, $2, NOW())
+RETURNING id, chirp_id, reporter_id, reason, created_at, resolved_at
+`
+
+type CreateChirpFlagParams struct {
+	ChirpID uuid.UUID
+	Reason  string
+}
+
+// Queues the chirp for review on behalf of the banned word list.
+func (q *Queries) CreateChirpFlag(ctx context.Context, arg CreateChirpFlagParams) (ChirpReport, error) {
+	row := q.db.QueryRowContext(ctx, createChirpFlag, arg.ChirpID, arg.Reason)
+	var i ChirpReport
+	err := row.Scan(
+		&i.ID,
+		&i.ChirpID,
+		&i.ReporterID,
+		&i.Reason,
+		&i.CreatedAt,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const createChirpReport = `-- name: CreateChirpReport :one
+INSERT INTO chirp_reports (id, chirp_id, reporter_id, reason, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, NOW())
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+RETURNING id, chirp_id, reporter_id, reason, created_at, resolved_at
+`
+
+type CreateChirpReportParams struct {
+	ChirpID    uuid.UUID
+	ReporterID uuid.NullUUID
+	Reason     string
+}
+
+// Returns no row if the user has already reported the chirp.
+func (q *Queries) CreateChirpReport(ctx context.Context, arg CreateChirpReportParams) (ChirpReport, error) {
+	row := q.db.QueryRowContext(ctx, createChirpReport, arg.ChirpID, arg.ReporterID, arg.Reason)
+	var i ChirpReport
+	err := row.Scan(
+		&i.ID,
+		&i.ChirpID,
+		&i.ReporterID,
+		&i.Reason,
+		&i.CreatedAt,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const listModerationActions = `-- name: ListModerationActions :many
+SELECT id, chirp_id, chirp_user_id, moderator_id, action, reason, created_at FROM moderation_actions
+WHERE ($1::uuid IS NULL OR chirp_id = $1)
+  AND (created_at, id) < ($2::timestamptz, $3::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type ListModerationActionsParams struct {
+	ChirpID         uuid.NullUUID
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int32
+}
+
+func (q *Queries) ListModerationActions(ctx context.Context, arg ListModerationActionsParams) ([]ModerationAction, error) {
+	rows, err := q.db.QueryContext(ctx, listModerationActions,
+		arg.ChirpID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ModerationAction
+	for rows.Next() {
+		var i ModerationAction
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChirpID,
+			&i.ChirpUserID,
+			&i.ModeratorID,
+			&i.Action,
+			&i.Reason,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listModerationQueue = `-- name: ListModerationQueue :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.search_vector, chirps.hidden_at,
+  COUNT(*) AS open_reports,
+  MIN(chirp_reports.created_at)::timestamptz AS first_reported_at
+FROM chirp_reports
+JOIN chirps ON chirps.id = chirp_reports.chirp_id
+WHERE chirp_reports.resolved_at IS NULL
+GROUP BY chirps.id
+HAVING (MIN(chirp_reports.created_at), chirps.id) > ($1::timestamptz, $2::uuid)
+ORDER BY first_reported_at ASC, chirps.id ASC
+LIMIT $3
+`
+
+type ListModerationQueueParams struct {
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int32
+}
+
+type ListModerationQueueRow struct {
+	Chirp           Chirp
+	OpenReports     int64
+	FirstReportedAt time.Time
+}
+
+// Chirps with open reports, longest waiting first.
+func (q *Queries) ListModerationQueue(ctx context.Context, arg ListModerationQueueParams) ([]ListModerationQueueRow, error) {
+	rows, err := q.db.QueryContext(ctx, listModerationQueue, arg.CursorCreatedAt, arg.CursorID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListModerationQueueRow
+	for rows.Next() {
+		var i ListModerationQueueRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.SearchVector,
+			&i.Chirp.HiddenAt,
+			&i.OpenReports,
+			&i.FirstReportedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOpenChirpReports = `-- name: ListOpenChirpReports :many
+SELECT id, chirp_id, reporter_id, reason, created_at, resolved_at FROM chirp_reports
+WHERE chirp_id = ANY($1::uuid[])
+  AND resolved_at IS NULL
+ORDER BY chirp_id, created_at, id
+`
+
+func (q *Queries) ListOpenChirpReports(ctx context.Context, chirpIds []uuid.UUID) ([]ChirpReport, error) {
+	rows, err := q.db.QueryContext(ctx, listOpenChirpReports, pq.Array(chirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChirpReport
+	for rows.Next() {
+		var i ChirpReport
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChirpID,
+			&i.ReporterID,
+			&i.Reason,
+			&i.CreatedAt,
+			&i.ResolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const moderateChirp = `-- name: ModerateChirp :one
+WITH target AS (
+  UPDATE chirps
+  SET hidden_at = CASE $2::text
+      WHEN 'hide' THEN COALESCE(chirps.hidden_at, NOW())
+      WHEN 'restore' THEN NULL
+      ELSE chirps.hidden_at
+    END
+  WHERE chirps.id = $4
+  RETURNING chirps.id, chirps.user_id
+), resolved AS (
+  UPDATE chirp_reports
+  SET resolved_at = NOW()
+  WHERE chirp_reports.chirp_id IN (SELECT id FROM target)
+    AND chirp_reports.resolved_at IS NULL
+)
+INSERT INTO moderation_actions (id, chirp_id, chirp_user_id, moderator_id, action, reason, created_at)
+SELECT gen_random_uuid(), target.id, target.user_id, $1, $2, $3, NOW()
+FROM target
+RETURNING id, chirp_id, chirp_user_id, moderator_id, action, reason, created_at
+`
+
+type ModerateChirpParams struct {
+	ModeratorID uuid.NullUUID
+	Action      string
+	Reason      string
+	ChirpID     uuid.UUID
+}
+
+// Hides or restores the chirp, or leaves it as it is for 'dismiss', resolves
+// its open reports and records the action. Returns no row if there is no
+// such chirp.
+func (q *Queries) ModerateChirp(ctx context.Context, arg ModerateChirpParams) (ModerationAction, error) {
+	row := q.db.QueryRowContext(ctx, moderateChirp,
+		arg.ModeratorID,
+		arg.Action,
+		arg.Reason,
+		arg.ChirpID,
+	)
+	var i ModerationAction
+	err := row.Scan(
+		&i.ID,
+		&i.ChirpID,
+		&i.ChirpUserID,
+		&i.ModeratorID,
+		&i.Action,
+		&i.Reason,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const removeChirp = `-- name: RemoveChirp :one
+WITH target AS (
+  DELETE FROM chirps
+  WHERE chirps.id = $3
+  RETURNING chirps.id, chirps.user_id
+)
+INSERT INTO moderation_actions (id, chirp_id, chirp_user_id, moderator_id, action, reason, created_at)
+SELECT gen_random_uuid(), target.id, target.user_id, $1, 'remove', $2, NOW()
+FROM target
+RETURNING id, chirp_id, chirp_user_id, moderator_id, action, reason, created_at
+`
+
+type RemoveChirpParams struct {
+	ModeratorID uuid.NullUUID
+	Reason      string
+	ChirpID     uuid.UUID
+}
+
+// Deletes the chirp, and with it its reports, and records the action.
+// Returns no row if there is no such chirp.
+func (q *Queries) RemoveChirp(ctx context.Context, arg RemoveChirpParams) (ModerationAction, error) {
+	row := q.db.QueryRowContext(ctx, removeChirp, arg.ModeratorID, arg.Reason, arg.ChirpID)
+	var i ModerationAction
+	err := row.Scan(
+		&i.ID,
+		&i.ChirpID,
+		&i.ChirpUserID,
+		&i.ModeratorID,
+		&i.Action,
+		&i.Reason,
+		&i.CreatedAt,
+	)
+	return i, err
+}
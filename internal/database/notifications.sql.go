@@ -0,0 +1,216 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: notifications.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createNotification = `-- name: CreateNotification :one
+INSERT INTO notifications (id, user_id, actor_id, kind, chirp_id, created_at)
+SELECT gen_random_uuid(), $1::uuid, $2::uuid, $3::text, $4::uuid, NOW()
+WHERE NOT EXISTS (
+  SELECT 1 FROM notifications n
+  WHERE n.user_id = $1::uuid
+    AND n.actor_id = $2::uuid
+    AND n.kind = $3::text
+    AND n.chirp_id IS NOT DISTINCT FROM $4::uuid
+)
+AND COALESCE((
+  SELECT CASE $3::text
+    WHEN 'mention' THEN p.mentions
+    WHEN 'reply' THEN p.replies
+    WHEN 'like' THEN p.likes
+    WHEN 'follow' THEN p.follows
+  END
+  FROM notification_preferences p
+  WHERE p.user_id = $1::uuid
+), TRUE)
+RETURNING id, user_id, actor_id, kind, chirp_id, created_at, read_at
+`
+
+type CreateNotificationParams struct {
+	UserID  uuid.UUID
+	ActorID uuid.UUID
+	Kind    string
+	ChirpID uuid.NullUUID
+}
+
+// Adds a notification unless the user has turned its kind off, or has
+// already been told that this actor did this to this chirp, in which case
+// no row is returned. Unliking and liking again, or unfollowing and
+// following again, doesn't notify a second time.
+func (q *Queries) CreateNotification(ctx context.Context, arg CreateNotificationParams) (Notification, error) {
+	row := q.db.QueryRowContext(ctx, createNotification,
+		arg.UserID,
+		arg.ActorID,
+		arg.Kind,
+		arg.ChirpID,
+	)
+	var i Notification
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ActorID,
+		&i.Kind,
+		&i.ChirpID,
+		&i.CreatedAt,
+		&i.ReadAt,
+	)
+	return i, err
+}
+
+const getNotificationPreferences = `-- name: GetNotificationPreferences :one
+SELECT user_id, mentions, replies, likes, follows, updated_at FROM notification_preferences WHERE user_id = $1
+`
+
+func (q *Queries) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (NotificationPreference, error) {
+	row := q.db.QueryRowContext(ctx, getNotificationPreferences, userID)
+	var i NotificationPreference
+	err := row.Scan(
+		&i.UserID,
+		&i.Mentions,
+		&i.Replies,
+		&i.Likes,
+		&i.Follows,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listNotifications = `-- name: ListNotifications :many
+SELECT id, user_id, actor_id, kind, chirp_id, created_at, read_at FROM notifications
+WHERE user_id = $1
+  AND (NOT $2::boolean OR read_at IS NULL)
+  AND (created_at, id) < ($3::timestamptz, $4::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT $5
+`
+
+type ListNotificationsParams struct {
+	UserID          uuid.UUID
+	UnreadOnly      bool
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int32
+}
+
+func (q *Queries) ListNotifications(ctx context.Context, arg ListNotificationsParams) ([]Notification, error) {
+	rows, err := q.db.QueryContext(ctx, listNotifications,
+		arg.UserID,
+		arg.UnreadOnly,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Notification
+	for rows.Next() {
+		var i Notification
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ActorID,
+			&i.Kind,
+			&i.ChirpID,
+			&i.CreatedAt,
+			&i.ReadAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markAllNotificationsRead = `-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1
+  AND read_at IS NULL
+`
+
+func (q *Queries) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markAllNotificationsRead, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const markNotificationsRead = `-- name: MarkNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1
+  AND id = ANY($2::uuid[])
+  AND read_at IS NULL
+`
+
+type MarkNotificationsReadParams struct {
+	UserID uuid.UUID
+	Ids    []uuid.UUID
+}
+
+func (q *Queries) MarkNotificationsRead(ctx context.Context, arg MarkNotificationsReadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markNotificationsRead, arg.UserID, pq.Array(arg.Ids))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setNotificationPreferences = `-- name: SetNotificationPreferences :one
+INSERT INTO notification_preferences (user_id, mentions, replies, likes, follows, updated_at)
+VALUES ($1, $2, $3, $4, $5, NOW())
+ON CONFLICT (user_id) DO UPDATE
+SET mentions = EXCLUDED.mentions,
+  replies = EXCLUDED.replies,
+  likes = EXCLUDED.likes,
+  follows = EXCLUDED.follows,
+  updated_at = EXCLUDED.updated_at
+RETURNING user_id, mentions, replies, likes, follows, updated_at
+`
+
+type SetNotificationPreferencesParams struct {
+	UserID   uuid.UUID
+	Mentions bool
+	Replies  bool
+	Likes    bool
+	Follows  bool
+}
+
+func (q *Queries) SetNotificationPreferences(ctx context.Context, arg SetNotificationPreferencesParams) (NotificationPreference, error) {
+	row := q.db.QueryRowContext(ctx, setNotificationPreferences,
+		arg.UserID,
+		arg.Mentions,
+		arg.Replies,
+		arg.Likes,
+		arg.Follows,
+	)
+	var i NotificationPreference
+	err := row.Scan(
+		&i.UserID,
+		&i.Mentions,
+		&i.Replies,
+		&i.Likes,
+		&i.Follows,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
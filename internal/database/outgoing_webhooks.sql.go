@@ -0,0 +1,288 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: outgoing_webhooks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = $1
+WHERE id IN (
+  SELECT d.id FROM webhook_deliveries d
+  WHERE d.status = 'pending'
+    AND d.next_attempt_at <= $2
+  ORDER BY d.next_attempt_at
+  LIMIT $3
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id, subscription_id, event, payload, status, attempts, next_attempt_at, last_error, response_status, created_at, delivered_at
+`
+
+type ClaimWebhookDeliveriesParams struct {
+	LeaseUntil    time.Time
+	Now           time.Time
+	MaxDeliveries int32
+}
+
+// Leases up to MaxDeliveries due deliveries by pushing their next attempt
+// to LeaseUntil. A worker that dies mid-delivery leaves them to be retried
+// once the lease runs out.
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, claimWebhookDeliveries, arg.LeaseUntil, arg.Now, arg.MaxDeliveries)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.SubscriptionID,
+			&i.Event,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastError,
+			&i.ResponseStatus,
+			&i.CreatedAt,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createWebhookSubscription = `-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (id, user_id, url, secret, events, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW())
+RETURNING id, user_id, url, secret, events, created_at
+`
+
+type CreateWebhookSubscriptionParams struct {
+	UserID uuid.NullUUID
+	Url    string
+	Secret string
+	Events string
+}
+
+func (q *Queries) CreateWebhookSubscription(ctx context.Context, arg CreateWebhookSubscriptionParams) (WebhookSubscription, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookSubscription,
+		arg.UserID,
+		arg.Url,
+		arg.Secret,
+		arg.Events,
+	)
+	var i WebhookSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteWebhookSubscription = `-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE id = $1
+  AND user_id IS NOT DISTINCT FROM $2::uuid
+`
+
+type DeleteWebhookSubscriptionParams struct {
+	ID     uuid.UUID
+	UserID uuid.NullUUID
+}
+
+func (q *Queries) DeleteWebhookSubscription(ctx context.Context, arg DeleteWebhookSubscriptionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebhookSubscription, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const enqueueWebhookEvent = `-- name: EnqueueWebhookEvent :execrows
+INSERT INTO webhook_deliveries (id, subscription_id, event, payload, next_attempt_at, created_at)
+SELECT gen_random_uuid(), s.id, $1::text, $2::text, NOW(), NOW()
+FROM webhook_subscriptions s
+WHERE ' ' || s.events || ' ' LIKE '% ' || $1::text || ' %'
+  AND ($3::uuid IS NULL OR s.user_id IS NULL OR s.user_id = $3::uuid)
+`
+
+type EnqueueWebhookEventParams struct {
+	Event   string
+	Payload string
+	UserID  uuid.NullUUID
+}
+
+// Adds a delivery for every subscription to the event. Events about a user
+// (UserID set) only go to that user's and the admins' subscriptions.
+func (q *Queries) EnqueueWebhookEvent(ctx context.Context, arg EnqueueWebhookEventParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enqueueWebhookEvent, arg.Event, arg.Payload, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const finishWebhookAttempt = `-- name: FinishWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET status = $1,
+  attempts = attempts + 1,
+  next_attempt_at = $2,
+  last_error = $3,
+  response_status = $4,
+  delivered_at = CASE WHEN $1 = 'delivered' THEN NOW() END
+WHERE id = $5
+`
+
+type FinishWebhookAttemptParams struct {
+	Status         string
+	NextAttemptAt  time.Time
+	LastError      string
+	ResponseStatus sql.NullInt32
+	ID             uuid.UUID
+}
+
+// Records the outcome of one delivery attempt. Status is 'delivered',
+// 'pending' to retry at NextAttemptAt, or 'failed' to give up.
+func (q *Queries) FinishWebhookAttempt(ctx context.Context, arg FinishWebhookAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, finishWebhookAttempt,
+		arg.Status,
+		arg.NextAttemptAt,
+		arg.LastError,
+		arg.ResponseStatus,
+		arg.ID,
+	)
+	return err
+}
+
+const getWebhookSubscription = `-- name: GetWebhookSubscription :one
+SELECT id, user_id, url, secret, events, created_at FROM webhook_subscriptions WHERE id = $1
+`
+
+func (q *Queries) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (WebhookSubscription, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookSubscription, id)
+	var i WebhookSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT id, subscription_id, event, payload, status, attempts, next_attempt_at, last_error, response_status, created_at, delivered_at FROM webhook_deliveries
+WHERE subscription_id = $1
+  AND (created_at, id) < ($2::timestamptz, $3::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type ListWebhookDeliveriesParams struct {
+	SubscriptionID  uuid.UUID
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int32
+}
+
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookDeliveries,
+		arg.SubscriptionID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.SubscriptionID,
+			&i.Event,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastError,
+			&i.ResponseStatus,
+			&i.CreatedAt,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookSubscriptions = `-- name: ListWebhookSubscriptions :many
+SELECT id, user_id, url, secret, events, created_at FROM webhook_subscriptions
+WHERE user_id IS NOT DISTINCT FROM $1::uuid
+ORDER BY created_at, id
+`
+
+func (q *Queries) ListWebhookSubscriptions(ctx context.Context, userID uuid.NullUUID) ([]WebhookSubscription, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookSubscriptions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookSubscription
+	for rows.Next() {
+		var i WebhookSubscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Url,
+			&i.Secret,
+			&i.Events,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
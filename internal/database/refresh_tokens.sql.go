@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,7 +19,11 @@ SELECT
   users.created_at,
   users.updated_at,
   users.email,
-  users.hashed_password
+  users.hashed_password,
+  users.role,
+  users.suspended_at,
+  users.suspended_until,
+  users.suspension_reason
 FROM users
 JOIN refresh_tokens ON refresh_tokens.user_id = users.id
 WHERE refresh_tokens.token = $1
@@ -27,11 +32,15 @@ WHERE refresh_tokens.token = $1
 `
 
 type GetUserFromRefreshTokenRow struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Email            string
+	HashedPassword   string
+	Role             string
+	SuspendedAt      sql.NullTime
+	SuspendedUntil   sql.NullTime
+	SuspensionReason string
 }
 
 func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (GetUserFromRefreshTokenRow, error) {
@@ -43,6 +52,10 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Ge
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
 	)
 	return i, err
 }
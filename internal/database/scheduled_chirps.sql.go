@@ -0,0 +1,144 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: scheduled_chirps.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimDueScheduledChirps = `-- name: ClaimDueScheduledChirps :many
+DELETE FROM scheduled_chirps
+WHERE publish_at <= $1
+  AND user_id NOT IN (
+    SELECT id FROM users
+    WHERE suspended_at IS NOT NULL
+      AND (suspended_until IS NULL OR suspended_until > $1)
+  )
+RETURNING id, user_id, body, in_reply_to, publish_at, created_at
+`
+
+// Removes and returns the chirps that are due, so that concurrent publishers
+// never see the same one twice. The chirps of a suspended user are left
+// until the suspension ends.
+func (q *Queries) ClaimDueScheduledChirps(ctx context.Context, now time.Time) ([]ScheduledChirp, error) {
+	rows, err := q.db.QueryContext(ctx, claimDueScheduledChirps, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ScheduledChirp
+	for rows.Next() {
+		var i ScheduledChirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.InReplyTo,
+			&i.PublishAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createScheduledChirp = `-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, user_id, body, in_reply_to, publish_at, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW())
+RETURNING id, user_id, body, in_reply_to, publish_at, created_at
+`
+
+type CreateScheduledChirpParams struct {
+	UserID    uuid.UUID
+	Body      string
+	InReplyTo uuid.NullUUID
+	PublishAt time.Time
+}
+
+func (q *Queries) CreateScheduledChirp(ctx context.Context, arg CreateScheduledChirpParams) (ScheduledChirp, error) {
+	row := q.db.QueryRowContext(ctx, createScheduledChirp,
+		arg.UserID,
+		arg.Body,
+		arg.InReplyTo,
+		arg.PublishAt,
+	)
+	var i ScheduledChirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		&i.InReplyTo,
+		&i.PublishAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteScheduledChirp = `-- name: DeleteScheduledChirp :execrows
+DELETE FROM scheduled_chirps
+WHERE id = $1
+  AND user_id = $2
+`
+
+type DeleteScheduledChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteScheduledChirp(ctx context.Context, arg DeleteScheduledChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteScheduledChirp, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listScheduledChirps = `-- name: ListScheduledChirps :many
+SELECT id, user_id, body, in_reply_to, publish_at, created_at FROM scheduled_chirps
+WHERE user_id = $1
+ORDER BY publish_at, id
+`
+
+func (q *Queries) ListScheduledChirps(ctx context.Context, userID uuid.UUID) ([]ScheduledChirp, error) {
+	rows, err := q.db.QueryContext(ctx, listScheduledChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ScheduledChirp
+	for rows.Next() {
+		var i ScheduledChirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.InReplyTo,
+			&i.PublishAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,137 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: search.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.search_vector, chirps.hidden_at, ts_rank(chirps.search_vector, query)::float8 AS relevance
+FROM chirps, to_tsquery('english', $1) AS query
+WHERE chirps.search_vector @@ query
+  AND ($2::uuid IS NULL OR chirps.user_id = $2)
+  AND (ts_rank(chirps.search_vector, query)::float8, chirps.created_at, chirps.id)
+    < ($3::float8, $4::timestamptz, $5::uuid)
+  AND chirps.hidden_at IS NULL
+ORDER BY relevance DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT $6
+`
+
+type SearchChirpsParams struct {
+	Query           string
+	AuthorID        uuid.NullUUID
+	CursorRelevance float64
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int32
+}
+
+type SearchChirpsRow struct {
+	Chirp     Chirp
+	Relevance float64
+}
+
+// Matches ranked by relevance, best first. query is tsquery syntax.
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.Query,
+		arg.AuthorID,
+		arg.CursorRelevance,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRow
+	for rows.Next() {
+		var i SearchChirpsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.SearchVector,
+			&i.Chirp.HiddenAt,
+			&i.Relevance,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchChirpsRecent = `-- name: SearchChirpsRecent :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.search_vector, chirps.hidden_at FROM chirps
+WHERE chirps.search_vector @@ to_tsquery('english', $1)
+  AND ($2::uuid IS NULL OR chirps.user_id = $2)
+  AND (chirps.created_at, chirps.id) < ($3::timestamptz, $4::uuid)
+  AND chirps.hidden_at IS NULL
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $5
+`
+
+type SearchChirpsRecentParams struct {
+	Query           string
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int32
+}
+
+// Matches newest first. query is tsquery syntax.
+func (q *Queries) SearchChirpsRecent(ctx context.Context, arg SearchChirpsRecentParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirpsRecent,
+		arg.Query,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.SearchVector,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -53,7 +54,7 @@ VALUES (
   $1,
   $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_until, suspension_reason
 `
 
 type CreateUserParams struct {
@@ -71,12 +72,63 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+	)
+	return i, err
+}
+
+const downgradeChirpyPlus = `-- name: DowngradeChirpyPlus :one
+UPDATE users
+SET is_chirpy_red = FALSE
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_until, suspension_reason
+`
+
+func (q *Queries) DowngradeChirpyPlus(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, downgradeChirpyPlus, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+	)
+	return i, err
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_until, suspension_reason FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
 	)
 	return i, err
 }
 
 const lookUpUserByEmail = `-- name: LookUpUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE email =$1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_until, suspension_reason FROM users WHERE email =$1
 `
 
 func (q *Queries) LookUpUserByEmail(ctx context.Context, email string) (User, error) {
@@ -89,6 +141,111 @@ func (q *Queries) LookUpUserByEmail(ctx context.Context, email string) (User, er
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+	)
+	return i, err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $2,
+  updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_until, suspension_reason
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+	)
+	return i, err
+}
+
+const suspendUser = `-- name: SuspendUser :one
+WITH revoked AS (
+  UPDATE refresh_tokens
+  SET revoked_at = NOW(),
+    updated_at = NOW()
+  WHERE refresh_tokens.user_id = $3
+    AND refresh_tokens.revoked_at IS NULL
+)
+UPDATE users
+SET suspended_at = NOW(),
+  suspended_until = $1,
+  suspension_reason = $2,
+  updated_at = NOW()
+WHERE users.id = $3
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_until, suspension_reason
+`
+
+type SuspendUserParams struct {
+	SuspendedUntil   sql.NullTime
+	SuspensionReason string
+	ID               uuid.UUID
+}
+
+// Also revokes the user's refresh tokens.
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, suspendUser, arg.SuspendedUntil, arg.SuspensionReason, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+	)
+	return i, err
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL,
+  suspended_until = NULL,
+  suspension_reason = '',
+  updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_until, suspension_reason
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, unsuspendUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
 	)
 	return i, err
 }
@@ -99,7 +256,7 @@ SET email = $1,
   hashed_password = $2,
   updated_at = Now()
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_until, suspension_reason
 `
 
 type UpdateEmailAndPWParams struct {
@@ -118,6 +275,10 @@ func (q *Queries) UpdateEmailAndPW(ctx context.Context, arg UpdateEmailAndPWPara
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
 	)
 	return i, err
 }
@@ -126,7 +287,7 @@ const upgradeChirpyPlus = `-- name: UpgradeChirpyPlus :one
 UPDATE users
 SET is_chirpy_red = TRUE
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_until, suspension_reason
 `
 
 func (q *Queries) UpgradeChirpyPlus(ctx context.Context, id uuid.UUID) (User, error) {
@@ -139,6 +300,10 @@ func (q *Queries) UpgradeChirpyPlus(ctx context.Context, id uuid.UUID) (User, er
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
 	)
 	return i, err
 }
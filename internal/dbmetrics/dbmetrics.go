@@ -0,0 +1,69 @@
+// Package dbmetrics times the queries sqlc generated code runs, by wrapping
+// the connection or transaction the generated Queries are built on.
+package dbmetrics
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// DBTX is what sqlc generated Queries run their queries on. database.DBTX
+// and sqlitedb.DBTX are both this interface.
+type DBTX interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+// Observer is told how long each query took, by its sqlc name.
+type Observer func(name string, d time.Duration)
+
+// Wrap returns db with every query it runs timed by observe. The time of a
+// query that returns rows is the time until the first row is ready; reading
+// the rest is not counted.
+func Wrap(db DBTX, observe Observer) DBTX {
+	return &timed{db: db, observe: observe}
+}
+
+type timed struct {
+	db      DBTX
+	observe Observer
+}
+
+func (t *timed) since(query string, start time.Time) {
+	t.observe(QueryName(query), time.Since(start))
+}
+
+func (t *timed) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer t.since(query, time.Now())
+	return t.db.ExecContext(ctx, query, args...)
+}
+
+// PrepareContext is not timed; sqlc only prepares statements when asked to.
+func (t *timed) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return t.db.PrepareContext(ctx, query)
+}
+
+func (t *timed) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer t.since(query, time.Now())
+	return t.db.QueryContext(ctx, query, args...)
+}
+
+func (t *timed) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer t.since(query, time.Now())
+	return t.db.QueryRowContext(ctx, query, args...)
+}
+
+// QueryName returns the name sqlc gives query in its "-- name: GetChirp :one"
+// header, or "unnamed" for a query without one.
+func QueryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "unnamed"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
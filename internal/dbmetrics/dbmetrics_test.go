@@ -0,0 +1,68 @@
+package dbmetrics
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestQueryName(t *testing.T) {
+	cases := map[string]string{
+		"-- name: GetChirp :one\nSELECT * FROM chirps WHERE id = $1": "GetChirp",
+		"-- name: Reset :exec\nDELETE FROM users":                    "Reset",
+		"SELECT 1": "unnamed",
+	}
+	for query, want := range cases {
+		if got := QueryName(query); got != want {
+			t.Errorf("QueryName(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+// fakeDB runs no queries; it only takes a while to.
+type fakeDB struct{ delay time.Duration }
+
+func (f fakeDB) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	time.Sleep(f.delay)
+	return nil, nil
+}
+
+func (f fakeDB) PrepareContext(context.Context, string) (*sql.Stmt, error) { return nil, nil }
+
+func (f fakeDB) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	time.Sleep(f.delay)
+	return nil, nil
+}
+
+func (f fakeDB) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	time.Sleep(f.delay)
+	return nil
+}
+
+func TestWrap(t *testing.T) {
+	type observation struct {
+		name string
+		d    time.Duration
+	}
+	var got []observation
+	db := Wrap(fakeDB{delay: time.Millisecond}, func(name string, d time.Duration) {
+		got = append(got, observation{name, d})
+	})
+
+	ctx := context.Background()
+	db.ExecContext(ctx, "-- name: DeleteChirp :exec\nDELETE FROM chirps")
+	db.QueryContext(ctx, "-- name: ListChirps :many\nSELECT * FROM chirps")
+	db.QueryRowContext(ctx, "-- name: GetChirp :one\nSELECT * FROM chirps")
+	db.PrepareContext(ctx, "-- name: GetUser :one\nSELECT * FROM users")
+
+	want := []string{"DeleteChirp", "ListChirps", "GetChirp"}
+	if len(got) != len(want) {
+		t.Fatalf("want %d observations, got %v", len(want), got)
+	}
+	for i, o := range got {
+		if o.name != want[i] || o.d < time.Millisecond {
+			t.Errorf("observation %d: want %s taking at least 1ms, got %s taking %s", i, want[i], o.name, o.d)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Package migrate applies the goose migrations embedded from sql/schema and
+// sql/sqlite/schema.
+//
+// Migrations use the same goose_db_version table as the goose CLI, so a
+// database migrated by hand is picked up where it left off. Every migration
+// runs in its own transaction.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	pgschema "github.com/Geraetefreund/chirpy/sql/schema"
+	sqliteschema "github.com/Geraetefreund/chirpy/sql/sqlite/schema"
+	"github.com/pressly/goose/v3"
+)
+
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// ErrSchemaBehind is returned by Check when migrations are pending.
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+type Migrator struct {
+	provider *goose.Provider
+}
+
+func New(db *sql.DB, dialect Dialect) (*Migrator, error) {
+	var (
+		gooseDialect goose.Dialect
+		fsys         fs.FS
+	)
+	switch dialect {
+	case Postgres:
+		gooseDialect, fsys = goose.DialectPostgres, pgschema.FS
+	case SQLite:
+		gooseDialect, fsys = goose.DialectSQLite3, sqliteschema.FS
+	default:
+		return nil, fmt.Errorf("unknown dialect %q", dialect)
+	}
+
+	provider, err := goose.NewProvider(gooseDialect, db, fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{provider: provider}, nil
+}
+
+// Up applies all pending migrations and returns their results.
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.provider.Up(ctx)
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) (*goose.MigrationResult, error) {
+	return m.provider.Down(ctx)
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
+
+// Versions returns the applied version and the newest embedded version.
+func (m *Migrator) Versions(ctx context.Context) (current, latest int64, err error) {
+	return m.provider.GetVersions(ctx)
+}
+
+// Check returns ErrSchemaBehind if the database is missing migrations.
+func (m *Migrator) Check(ctx context.Context) error {
+	pending, err := m.provider.HasPending(ctx)
+	if err != nil {
+		return err
+	}
+	if pending {
+		current, latest, err := m.Versions(ctx)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: at version %d, want %d", ErrSchemaBehind, current, latest)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/pressly/goose/v3"
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLiteUpDownCheck(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "chirpy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := New(db, SQLite)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := m.Check(ctx); !errors.Is(err, ErrSchemaBehind) {
+		t.Fatalf("fresh database: want ErrSchemaBehind, got %v", err)
+	}
+
+	results, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(results) == 0 {
+		t.Fatalf("Up applied nothing")
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Errorf("after Up: %v", err)
+	}
+	current, latest, err := m.Versions(ctx)
+	if err != nil || current != latest {
+		t.Errorf("Versions: got %d of %d, %v", current, latest, err)
+	}
+
+	if _, err := m.Down(ctx); err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if last := statuses[len(statuses)-1]; last.State != goose.StatePending {
+		t.Errorf("after Down: want last migration pending, got %s", last.State)
+	}
+	if err := m.Check(ctx); !errors.Is(err, ErrSchemaBehind) {
+		t.Errorf("after Down: want ErrSchemaBehind, got %v", err)
+	}
+}
+
+func TestEmbeddedPostgresMigrations(t *testing.T) {
+	// Collecting sources parses every file without needing a server.
+	db, _ := sql.Open("sqlite", ":memory:")
+	defer db.Close()
+	m, err := New(db, Postgres)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if n := len(m.provider.ListSources()); n < 6 {
+		t.Errorf("want at least 6 embedded migrations, got %d", n)
+	}
+}
@@ -0,0 +1,183 @@
+// Package moderation checks chirp bodies against a configurable chain of
+// filters.
+//
+// Every filter reports the spans of a text that break one of its rules, and
+// each rule carries an action: mask the span, flag the text for review, or
+// reject it outright. A Moderator runs the chain and can swap in a new word
+// list while it is in use.
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Action is what happens to a text that breaks a rule. Actions are ordered
+// by severity.
+type Action int
+
+const (
+	Mask Action = iota
+	Flag
+	Reject
+)
+
+func (a Action) String() string {
+	switch a {
+	case Mask:
+		return "mask"
+	case Flag:
+		return "flag"
+	case Reject:
+		return "reject"
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+// ParseAction is the inverse of Action.String.
+func ParseAction(s string) (Action, error) {
+	switch strings.ToLower(s) {
+	case "mask":
+		return Mask, nil
+	case "flag":
+		return Flag, nil
+	case "reject":
+		return Reject, nil
+	}
+	return 0, fmt.Errorf("unknown moderation action %q", s)
+}
+
+// Rule is a banned word and what to do about it.
+type Rule struct {
+	Word   string
+	Action Action
+}
+
+// Match is a span of a text, in byte offsets, that breaks Rule.
+type Match struct {
+	Rule  Rule
+	Start int
+	End   int
+}
+
+// Filter finds rule violations in a text.
+type Filter interface {
+	Match(text string) []Match
+}
+
+// Chain runs several filters over the same text.
+type Chain []Filter
+
+// MaskText is what masked spans are replaced with.
+const MaskText = "****"
+
+// Result is the outcome of moderating a text.
+type Result struct {
+	// Text is the input with every masked span replaced by MaskText.
+	Text    string
+	Matches []Match
+}
+
+// Rejected reports whether any rule asked for the text to be rejected.
+func (r Result) Rejected() bool {
+	return slices.ContainsFunc(r.Matches, func(m Match) bool { return m.Rule.Action == Reject })
+}
+
+// Flagged reports whether any rule asked for the text to be reviewed.
+func (r Result) Flagged() bool {
+	return slices.ContainsFunc(r.Matches, func(m Match) bool { return m.Rule.Action == Flag })
+}
+
+// Moderate runs every filter over text and masks the spans that call for
+// it. Where matches overlap, the earliest one wins.
+func (c Chain) Moderate(text string) Result {
+	var matches []Match
+	for _, f := range c {
+		matches = append(matches, f.Match(text)...)
+	}
+	slices.SortStableFunc(matches, func(a, b Match) int { return a.Start - b.Start })
+
+	var b strings.Builder
+	pos := 0
+	for _, m := range matches {
+		if m.Rule.Action != Mask || m.Start < pos {
+			continue
+		}
+		b.WriteString(text[pos:m.Start])
+		b.WriteString(MaskText)
+		pos = m.End
+	}
+	b.WriteString(text[pos:])
+	return Result{Text: b.String(), Matches: matches}
+}
+
+// Source provides the rules of a Moderator's word list.
+type Source interface {
+	Rules(ctx context.Context) ([]Rule, error)
+}
+
+// Rules is a fixed Source.
+type Rules []Rule
+
+func (r Rules) Rules(ctx context.Context) ([]Rule, error) {
+	return r, nil
+}
+
+// Merge is a Source that combines the rules of several sources.
+type Merge []Source
+
+func (m Merge) Rules(ctx context.Context) ([]Rule, error) {
+	var rules []Rule
+	for _, src := range m {
+		r, err := src.Rules(ctx)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r...)
+	}
+	return rules, nil
+}
+
+// Moderator runs a word list loaded from a Source, followed by any extra
+// filters. It is safe for concurrent use.
+type Moderator struct {
+	source Source
+	extra  []Filter
+
+	mu    sync.RWMutex
+	chain Chain
+}
+
+// New loads the word list from source.
+func New(ctx context.Context, source Source, extra ...Filter) (*Moderator, error) {
+	m := &Moderator{source: source, extra: extra}
+	if err := m.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Reload reads the word list from the source again. On error the previous
+// list stays in effect.
+func (m *Moderator) Reload(ctx context.Context) error {
+	rules, err := m.source.Rules(ctx)
+	if err != nil {
+		return err
+	}
+	chain := append(Chain{NewWordList(rules)}, m.extra...)
+
+	m.mu.Lock()
+	m.chain = chain
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Moderator) Moderate(text string) Result {
+	m.mu.RLock()
+	chain := m.chain
+	m.mu.RUnlock()
+	return chain.Moderate(text)
+}
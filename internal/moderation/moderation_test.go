@@ -0,0 +1,89 @@
+package moderation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChainModerate(t *testing.T) {
+	chain := Chain{NewWordList([]Rule{
+		{Word: "kerfuffle", Action: Mask},
+		{Word: "sharbert", Action: Mask},
+		{Word: "fornax", Action: Reject},
+		{Word: "meth", Action: Flag},
+	})}
+
+	tests := []struct {
+		in       string
+		want     string
+		rejected bool
+		flagged  bool
+	}{
+		{"what a kerfuffle", "what a ****", false, false},
+		{"Kerfuffle! and SHARBERT.", "****! and ****.", false, false},
+		{"k3rfuffl3 $harbert", "**** ****", false, false},
+		{"kërfüffle", "****", false, false},
+		{"@kerfuffle", "****", false, false},
+		{"kerfuffles", "kerfuffles", false, false},
+		{"blue meth", "blue meth", false, true},
+		{"f0rnax", "f0rnax", true, false},
+	}
+	for _, tt := range tests {
+		got := chain.Moderate(tt.in)
+		if got.Text != tt.want || got.Rejected() != tt.rejected || got.Flagged() != tt.flagged {
+			t.Errorf("Moderate(%q) = %q rejected=%v flagged=%v, want %q rejected=%v flagged=%v",
+				tt.in, got.Text, got.Rejected(), got.Flagged(), tt.want, tt.rejected, tt.flagged)
+		}
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader("# banned\nkerfuffle\n\nfornax reject\n"))
+	if err != nil {
+		t.Fatalf("ParseRules: %v", err)
+	}
+	want := []Rule{{Word: "kerfuffle", Action: Mask}, {Word: "fornax", Action: Reject}}
+	if len(rules) != len(want) || rules[0] != want[0] || rules[1] != want[1] {
+		t.Errorf("ParseRules: got %v, want %v", rules, want)
+	}
+
+	for _, bad := range []string{"fornax obliterate\n", "too many words here\n"} {
+		if _, err := ParseRules(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseRules(%q): want error", bad)
+		}
+	}
+}
+
+func TestModeratorReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(path, []byte("kerfuffle\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(ctx, File(path))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := m.Moderate("kerfuffle fornax").Text; got != "**** fornax" {
+		t.Errorf("before reload: got %q", got)
+	}
+
+	os.WriteFile(path, []byte("fornax\n"), 0o644)
+	if err := m.Reload(ctx); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := m.Moderate("kerfuffle fornax").Text; got != "kerfuffle ****" {
+		t.Errorf("after reload: got %q", got)
+	}
+
+	os.WriteFile(path, []byte("fornax obliterate\n"), 0o644)
+	if err := m.Reload(ctx); err == nil {
+		t.Errorf("Reload of a broken file: want error")
+	}
+	if got := m.Moderate("kerfuffle fornax").Text; got != "kerfuffle ****" {
+		t.Errorf("a failed reload replaced the word list: got %q", got)
+	}
+}
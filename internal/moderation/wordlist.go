@@ -0,0 +1,160 @@
+package moderation
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// WordList matches whole words against a list of rules. Words are compared
+// after Normalize, so "Kerfuffle!", "KERFÜFFLE" and "k3rfuffl3" all match
+// the rule for kerfuffle.
+type WordList struct {
+	rules map[string]Rule
+}
+
+func NewWordList(rules []Rule) *WordList {
+	w := &WordList{rules: make(map[string]Rule, len(rules))}
+	for _, r := range rules {
+		key := Normalize(r.Word)
+		// When a word is listed twice the stricter rule applies.
+		if prev, ok := w.rules[key]; !ok || r.Action > prev.Action {
+			w.rules[key] = r
+		}
+	}
+	return w
+}
+
+func (w *WordList) Match(text string) []Match {
+	var matches []Match
+	start := -1
+	check := func(end int) {
+		word := text[start:end]
+		rule, ok := w.rules[Normalize(word)]
+		if !ok {
+			// Leetspeak symbols at the edges are more likely punctuation,
+			// as in "@kerfuffle".
+			rule, ok = w.rules[Normalize(strings.TrimFunc(word, isLeetSymbol))]
+		}
+		if ok {
+			matches = append(matches, Match{Rule: rule, Start: start, End: end})
+		}
+	}
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			check(i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		check(len(text))
+	}
+	return matches
+}
+
+// ValidWord reports whether w can be matched by a WordList: it has to be a
+// single word, without spaces or punctuation.
+func ValidWord(w string) bool {
+	return w != "" && strings.IndexFunc(w, func(r rune) bool { return !isWordRune(r) }) < 0
+}
+
+var leet = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'8': 'b',
+	'@': 'a',
+	'$': 's',
+}
+
+func isLeetSymbol(r rune) bool {
+	_, ok := leet[r]
+	return ok && !unicode.IsDigit(r)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || isLeetSymbol(r)
+}
+
+var stripMarks = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// Normalize folds a word to the form word lists are compared in: lower
+// case, without diacritics, and with leetspeak digits and symbols replaced
+// by the letters they stand for.
+func Normalize(word string) string {
+	s, _, err := transform.String(stripMarks, word)
+	if err != nil {
+		s = word
+	}
+	return strings.Map(func(r rune) rune {
+		if l, ok := leet[r]; ok {
+			return l
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
+
+// ParseRules reads a word list: one word per line, optionally followed by
+// an action (mask if omitted). Blank lines and lines starting with # are
+// ignored.
+//
+//	kerfuffle
+//	sharbert reject
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		rule := Rule{Word: fields[0], Action: Mask}
+		switch len(fields) {
+		case 1:
+		case 2:
+			action, err := ParseAction(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			rule.Action = action
+		default:
+			return nil, fmt.Errorf("line %d: want a word and an optional action", n)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, sc.Err()
+}
+
+// File is a Source that reads a word list file in the format of ParseRules
+// each time it is asked for rules.
+type File string
+
+func (f File) Rules(ctx context.Context) ([]Rule, error) {
+	file, err := os.Open(string(f))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rules, err := ParseRules(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f, err)
+	}
+	return rules, nil
+}
@@ -0,0 +1,158 @@
+// Package search implements the query language of GET /api/chirps/search.
+//
+// A query is a list of terms that must all match. A term is a single word, or
+// a "double quoted" phrase whose words must appear next to each other. A
+// trailing * makes the last word of a term match as a prefix. Words are runs
+// of letters and digits; everything else separates them, so walter-white is
+// the phrase "walter white".
+//
+// The stores take queries in Postgres tsquery syntax. ParseTSQuery reads back
+// the subset that TSQuery produces, for backends that need to translate it.
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// ErrEmpty is returned for queries without a single word to search for.
+var ErrEmpty = errors.New("search query has no words")
+
+// Term is a word or phrase that must appear in a matching chirp.
+type Term struct {
+	Words  []string
+	Prefix bool
+}
+
+type Query []Term
+
+// Parse parses a query as typed by a user.
+func Parse(s string) (Query, error) {
+	var q Query
+	for s != "" {
+		var raw string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end < 0 {
+				raw, s = s[1:], ""
+			} else {
+				raw, s = s[1:end+1], s[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(s, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+			if end < 0 {
+				end = len(s)
+			}
+			raw, s = s[:end], s[end:]
+		}
+
+		prefix := strings.HasPrefix(s, "*")
+		if prefix {
+			s = s[1:]
+		} else {
+			trimmed := strings.TrimRightFunc(raw, unicode.IsSpace)
+			prefix = strings.HasSuffix(trimmed, "*")
+		}
+		if words := Words(raw); len(words) > 0 {
+			q = append(q, Term{Words: words, Prefix: prefix})
+		}
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	}
+	if len(q) == 0 {
+		return nil, ErrEmpty
+	}
+	return q, nil
+}
+
+// Words splits text into lower case words the same way queries are split.
+func Words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// TSQuery renders q for Postgres' to_tsquery.
+func (q Query) TSQuery() string {
+	terms := make([]string, len(q))
+	for i, t := range q {
+		words := make([]string, len(t.Words))
+		for j, w := range t.Words {
+			words[j] = "'" + w + "'"
+		}
+		terms[i] = strings.Join(words, " <-> ")
+		if t.Prefix {
+			terms[i] += ":*"
+		}
+	}
+	return strings.Join(terms, " & ")
+}
+
+// ParseTSQuery parses the output of TSQuery.
+func ParseTSQuery(s string) (Query, error) {
+	var q Query
+	for _, term := range strings.Split(s, " & ") {
+		t := Term{}
+		term, t.Prefix = strings.CutSuffix(term, ":*")
+		for _, w := range strings.Split(term, " <-> ") {
+			word, ok := strings.CutPrefix(w, "'")
+			if ok {
+				word, ok = strings.CutSuffix(word, "'")
+			}
+			if !ok || word == "" || len(Words(word)) != 1 || Words(word)[0] != word {
+				return nil, errors.New("unsupported tsquery: " + s)
+			}
+			t.Words = append(t.Words, word)
+		}
+		q = append(q, t)
+	}
+	return q, nil
+}
+
+// FTS5 renders q as an SQLite FTS5 match expression.
+func (q Query) FTS5() string {
+	terms := make([]string, len(q))
+	for i, t := range q {
+		words := make([]string, len(t.Words))
+		for j, w := range t.Words {
+			words[j] = `"` + w + `"`
+		}
+		terms[i] = strings.Join(words, " + ")
+		if t.Prefix {
+			terms[i] += " *"
+		}
+	}
+	return strings.Join(terms, " AND ")
+}
+
+// Score counts how often the terms of q occur in text, or returns 0 unless
+// every term occurs. Words are compared exactly; there is no stemming.
+func (q Query) Score(text string) int {
+	words := Words(text)
+	total := 0
+	for _, t := range q {
+		n := 0
+		for i := 0; i+len(t.Words) <= len(words); i++ {
+			if t.matchAt(words[i:]) {
+				n++
+			}
+		}
+		if n == 0 {
+			return 0
+		}
+		total += n
+	}
+	return total
+}
+
+func (t Term) matchAt(words []string) bool {
+	for i, w := range t.Words {
+		if i == len(t.Words)-1 && t.Prefix {
+			return strings.HasPrefix(words[i], w)
+		}
+		if words[i] != w {
+			return false
+		}
+	}
+	return true
+}
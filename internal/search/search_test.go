@@ -0,0 +1,55 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		tsquery string
+		fts5    string
+	}{
+		{"walter", `'walter'`, `"walter"`},
+		{"Walter  White", `'walter' & 'white'`, `"walter" AND "white"`},
+		{`"say my name"`, `'say' <-> 'my' <-> 'name'`, `"say" + "my" + "name"`},
+		{"heisen*", `'heisen':*`, `"heisen" *`},
+		{`"blue sk"* meth`, `'blue' <-> 'sk':* & 'meth'`, `"blue" + "sk" * AND "meth"`},
+		{"walter-white's", `'walter' <-> 'white' <-> 's'`, `"walter" + "white" + "s"`},
+		{`"unterminated phrase`, `'unterminated' <-> 'phrase'`, `"unterminated" + "phrase"`},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got := q.TSQuery(); got != tt.tsquery {
+			t.Errorf("Parse(%q).TSQuery() = %q, want %q", tt.in, got, tt.tsquery)
+		}
+		if got := q.FTS5(); got != tt.fts5 {
+			t.Errorf("Parse(%q).FTS5() = %q, want %q", tt.in, got, tt.fts5)
+		}
+		back, err := ParseTSQuery(q.TSQuery())
+		if err != nil || !reflect.DeepEqual(back, q) {
+			t.Errorf("ParseTSQuery round trip of %q: got %v, %v", tt.in, back, err)
+		}
+	}
+
+	for _, in := range []string{"", "   ", `"" * -`} {
+		if _, err := Parse(in); err != ErrEmpty {
+			t.Errorf("Parse(%q): want ErrEmpty, got %v", in, err)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	q, _ := Parse(`"my name" heisen*`)
+	if got := q.Score("Say my name. My name is Heisenberg."); got != 3 {
+		t.Errorf("Score = %d, want 3", got)
+	}
+	if got := q.Score("say my name"); got != 0 {
+		t.Errorf("Score without every term = %d, want 0", got)
+	}
+}
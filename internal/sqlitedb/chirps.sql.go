@@ -0,0 +1,473 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: chirps.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const countReplies = `-- name: CountReplies :many
+SELECT in_reply_to AS chirp_id, COUNT(*) AS reply_count
+FROM chirps
+WHERE in_reply_to IN (/*SLICE:chirp_ids*/?)
+  AND hidden_at IS NULL
+GROUP BY in_reply_to
+`
+
+type CountRepliesRow struct {
+	ChirpID    uuid.NullUUID
+	ReplyCount int64
+}
+
+func (q *Queries) CountReplies(ctx context.Context, chirpIds []uuid.NullUUID) ([]CountRepliesRow, error) {
+	query := countReplies
+	var queryParams []interface{}
+	if len(chirpIds) > 0 {
+		for _, v := range chirpIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:chirp_ids*/?", strings.Repeat(",?", len(chirpIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:chirp_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountRepliesRow
+	for rows.Next() {
+		var i CountRepliesRow
+		if err := rows.Scan(&i.ChirpID, &i.ReplyCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createChirp = `-- name: CreateChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, hidden_at
+`
+
+type CreateChirpParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	InReplyTo uuid.NullUUID
+}
+
+func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Body,
+		arg.UserID,
+		arg.InReplyTo,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const createChirpRevision = `-- name: CreateChirpRevision :exec
+INSERT INTO chirp_revisions (id, chirp_id, body, created_at)
+SELECT ?1, chirps.id, chirps.body, chirps.updated_at
+FROM chirps
+WHERE chirps.id = ?2
+`
+
+type CreateChirpRevisionParams struct {
+	ID      uuid.UUID
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) CreateChirpRevision(ctx context.Context, arg CreateChirpRevisionParams) error {
+	_, err := q.db.ExecContext(ctx, createChirpRevision, arg.ID, arg.ChirpID)
+	return err
+}
+
+const deleteChirp = `-- name: DeleteChirp :exec
+DELETE FROM chirps
+WHERE id = ?
+`
+
+func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteChirp, id)
+	return err
+}
+
+const getChirp = `-- name: GetChirp :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, hidden_at FROM chirps
+WHERE id = ?
+  AND hidden_at IS NULL
+`
+
+func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const getChirpIncludingHidden = `-- name: GetChirpIncludingHidden :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, hidden_at FROM chirps
+WHERE id = ?
+`
+
+func (q *Queries) GetChirpIncludingHidden(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpIncludingHidden, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const listChirpRevisions = `-- name: ListChirpRevisions :many
+SELECT id, chirp_id, body, created_at FROM chirp_revisions
+WHERE chirp_id = ?
+ORDER BY created_at ASC, id ASC
+`
+
+func (q *Queries) ListChirpRevisions(ctx context.Context, chirpID uuid.UUID) ([]ChirpRevision, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpRevisions, chirpID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChirpRevision
+	for rows.Next() {
+		var i ChirpRevision
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChirpID,
+			&i.Body,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirps = `-- name: ListChirps :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, hidden_at FROM chirps
+WHERE (created_at > ?1
+    OR (created_at = ?1 AND id > ?2))
+  AND hidden_at IS NULL
+ORDER BY created_at ASC, id ASC
+LIMIT ?3
+`
+
+type ListChirpsParams struct {
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int64
+}
+
+func (q *Queries) ListChirps(ctx context.Context, arg ListChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirps, arg.CursorCreatedAt, arg.CursorID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsByAuthor = `-- name: ListChirpsByAuthor :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, hidden_at FROM chirps
+WHERE user_id = ?1
+  AND (created_at > ?2
+    OR (created_at = ?2 AND id > ?3))
+  AND hidden_at IS NULL
+ORDER BY created_at ASC, id ASC
+LIMIT ?4
+`
+
+type ListChirpsByAuthorParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int64
+}
+
+func (q *Queries) ListChirpsByAuthor(ctx context.Context, arg ListChirpsByAuthorParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsByAuthor,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsByAuthorDesc = `-- name: ListChirpsByAuthorDesc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, hidden_at FROM chirps
+WHERE user_id = ?1
+  AND (created_at < ?2
+    OR (created_at = ?2 AND id < ?3))
+  AND hidden_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT ?4
+`
+
+type ListChirpsByAuthorDescParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int64
+}
+
+func (q *Queries) ListChirpsByAuthorDesc(ctx context.Context, arg ListChirpsByAuthorDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsByAuthorDesc,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, hidden_at FROM chirps
+WHERE (created_at < ?1
+    OR (created_at = ?1 AND id < ?2))
+  AND hidden_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT ?3
+`
+
+type ListChirpsDescParams struct {
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int64
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc, arg.CursorCreatedAt, arg.CursorID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRepliesTo = `-- name: ListRepliesTo :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, hidden_at FROM chirps
+WHERE in_reply_to IN (/*SLICE:chirp_ids*/?)
+  AND hidden_at IS NULL
+`
+
+func (q *Queries) ListRepliesTo(ctx context.Context, chirpIds []uuid.NullUUID) ([]Chirp, error) {
+	query := listRepliesTo
+	var queryParams []interface{}
+	if len(chirpIds) > 0 {
+		for _, v := range chirpIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:chirp_ids*/?", strings.Repeat(",?", len(chirpIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:chirp_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateChirp = `-- name: UpdateChirp :one
+UPDATE chirps
+SET body = ?1,
+  updated_at = ?2
+WHERE id = ?3
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, hidden_at
+`
+
+type UpdateChirpParams struct {
+	Body      string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) UpdateChirp(ctx context.Context, arg UpdateChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirp, arg.Body, arg.UpdatedAt, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.HiddenAt,
+	)
+	return i, err
+}
@@ -0,0 +1,278 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: entities.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createChirpHashtag = `-- name: CreateChirpHashtag :exec
+INSERT INTO chirp_hashtags (chirp_id, tag, start_offset, end_offset)
+VALUES (?, ?, ?, ?)
+`
+
+type CreateChirpHashtagParams struct {
+	ChirpID     uuid.UUID
+	Tag         string
+	StartOffset int64
+	EndOffset   int64
+}
+
+func (q *Queries) CreateChirpHashtag(ctx context.Context, arg CreateChirpHashtagParams) error {
+	_, err := q.db.ExecContext(ctx, createChirpHashtag,
+		arg.ChirpID,
+		arg.Tag,
+		arg.StartOffset,
+		arg.EndOffset,
+	)
+	return err
+}
+
+const createChirpMention = `-- name: CreateChirpMention :exec
+INSERT INTO chirp_mentions (chirp_id, user_id, start_offset, end_offset)
+VALUES (?, ?, ?, ?)
+`
+
+type CreateChirpMentionParams struct {
+	ChirpID     uuid.UUID
+	UserID      uuid.UUID
+	StartOffset int64
+	EndOffset   int64
+}
+
+func (q *Queries) CreateChirpMention(ctx context.Context, arg CreateChirpMentionParams) error {
+	_, err := q.db.ExecContext(ctx, createChirpMention,
+		arg.ChirpID,
+		arg.UserID,
+		arg.StartOffset,
+		arg.EndOffset,
+	)
+	return err
+}
+
+const deleteChirpHashtags = `-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags WHERE chirp_id = ?
+`
+
+func (q *Queries) DeleteChirpHashtags(ctx context.Context, chirpID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteChirpHashtags, chirpID)
+	return err
+}
+
+const deleteChirpMentions = `-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions WHERE chirp_id = ?
+`
+
+func (q *Queries) DeleteChirpMentions(ctx context.Context, chirpID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteChirpMentions, chirpID)
+	return err
+}
+
+const listChirpHashtags = `-- name: ListChirpHashtags :many
+SELECT chirp_id, tag, start_offset, end_offset FROM chirp_hashtags
+WHERE chirp_id IN (/*SLICE:chirp_ids*/?)
+ORDER BY chirp_id, start_offset
+`
+
+func (q *Queries) ListChirpHashtags(ctx context.Context, chirpIds []uuid.UUID) ([]ChirpHashtag, error) {
+	query := listChirpHashtags
+	var queryParams []interface{}
+	if len(chirpIds) > 0 {
+		for _, v := range chirpIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:chirp_ids*/?", strings.Repeat(",?", len(chirpIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:chirp_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChirpHashtag
+	for rows.Next() {
+		var i ChirpHashtag
+		if err := rows.Scan(
+			&i.ChirpID,
+			&i.Tag,
+			&i.StartOffset,
+			&i.EndOffset,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpMentions = `-- name: ListChirpMentions :many
+SELECT chirp_id, user_id, start_offset, end_offset FROM chirp_mentions
+WHERE chirp_id IN (/*SLICE:chirp_ids*/?)
+ORDER BY chirp_id, start_offset
+`
+
+func (q *Queries) ListChirpMentions(ctx context.Context, chirpIds []uuid.UUID) ([]ChirpMention, error) {
+	query := listChirpMentions
+	var queryParams []interface{}
+	if len(chirpIds) > 0 {
+		for _, v := range chirpIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:chirp_ids*/?", strings.Repeat(",?", len(chirpIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:chirp_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChirpMention
+	for rows.Next() {
+		var i ChirpMention
+		if err := rows.Scan(
+			&i.ChirpID,
+			&i.UserID,
+			&i.StartOffset,
+			&i.EndOffset,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsByHashtag = `-- name: ListChirpsByHashtag :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, hidden_at FROM chirps
+WHERE EXISTS (
+    SELECT 1 FROM chirp_hashtags
+    WHERE chirp_hashtags.chirp_id = chirps.id
+      AND chirp_hashtags.tag = ?1
+  )
+  AND (created_at < ?2
+    OR (created_at = ?2 AND id < ?3))
+  AND hidden_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT ?4
+`
+
+type ListChirpsByHashtagParams struct {
+	Tag             string
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int64
+}
+
+func (q *Queries) ListChirpsByHashtag(ctx context.Context, arg ListChirpsByHashtagParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsByHashtag,
+		arg.Tag,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsMentioning = `-- name: ListChirpsMentioning :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, hidden_at FROM chirps
+WHERE EXISTS (
+    SELECT 1 FROM chirp_mentions
+    WHERE chirp_mentions.chirp_id = chirps.id
+      AND chirp_mentions.user_id = ?1
+  )
+  AND (created_at < ?2
+    OR (created_at = ?2 AND id < ?3))
+  AND hidden_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT ?4
+`
+
+type ListChirpsMentioningParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int64
+}
+
+func (q *Queries) ListChirpsMentioning(ctx context.Context, arg ListChirpsMentioningParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsMentioning,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,204 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: likes.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const countLikes = `-- name: CountLikes :many
+SELECT chirp_id, COUNT(*) AS like_count
+FROM likes
+WHERE chirp_id IN (/*SLICE:chirp_ids*/?)
+GROUP BY chirp_id
+`
+
+type CountLikesRow struct {
+	ChirpID   uuid.UUID
+	LikeCount int64
+}
+
+func (q *Queries) CountLikes(ctx context.Context, chirpIds []uuid.UUID) ([]CountLikesRow, error) {
+	query := countLikes
+	var queryParams []interface{}
+	if len(chirpIds) > 0 {
+		for _, v := range chirpIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:chirp_ids*/?", strings.Repeat(",?", len(chirpIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:chirp_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountLikesRow
+	for rows.Next() {
+		var i CountLikesRow
+		if err := rows.Scan(&i.ChirpID, &i.LikeCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const likeChirp = `-- name: LikeChirp :execrows
+INSERT INTO likes (user_id, chirp_id, created_at)
+VALUES (?, ?, ?)
+ON CONFLICT DO NOTHING
+`
+
+type LikeChirpParams struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) LikeChirp(ctx context.Context, arg LikeChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, likeChirp, arg.UserID, arg.ChirpID, arg.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listLikedChirpIDs = `-- name: ListLikedChirpIDs :many
+SELECT chirp_id FROM likes
+WHERE user_id = ?1
+  AND chirp_id IN (/*SLICE:chirp_ids*/?)
+`
+
+type ListLikedChirpIDsParams struct {
+	UserID   uuid.UUID
+	ChirpIds []uuid.UUID
+}
+
+func (q *Queries) ListLikedChirpIDs(ctx context.Context, arg ListLikedChirpIDsParams) ([]uuid.UUID, error) {
+	query := listLikedChirpIDs
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.UserID)
+	if len(arg.ChirpIds) > 0 {
+		for _, v := range arg.ChirpIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:chirp_ids*/?", strings.Repeat(",?", len(arg.ChirpIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:chirp_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var chirp_id uuid.UUID
+		if err := rows.Scan(&chirp_id); err != nil {
+			return nil, err
+		}
+		items = append(items, chirp_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLikes = `-- name: ListLikes :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.hidden_at, likes.created_at AS liked_at
+FROM likes
+JOIN chirps ON chirps.id = likes.chirp_id
+WHERE likes.user_id = ?1
+  AND (likes.created_at < ?2
+    OR (likes.created_at = ?2 AND likes.chirp_id < ?3))
+  AND chirps.hidden_at IS NULL
+ORDER BY likes.created_at DESC, likes.chirp_id DESC
+LIMIT ?4
+`
+
+type ListLikesParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int64
+}
+
+type ListLikesRow struct {
+	Chirp   Chirp
+	LikedAt time.Time
+}
+
+func (q *Queries) ListLikes(ctx context.Context, arg ListLikesParams) ([]ListLikesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listLikes,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListLikesRow
+	for rows.Next() {
+		var i ListLikesRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.HiddenAt,
+			&i.LikedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const unlikeChirp = `-- name: UnlikeChirp :execrows
+DELETE FROM likes
+WHERE user_id = ?
+  AND chirp_id = ?
+`
+
+type UnlikeChirpParams struct {
+	UserID  uuid.UUID
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) UnlikeChirp(ctx context.Context, arg UnlikeChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unlikeChirp, arg.UserID, arg.ChirpID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
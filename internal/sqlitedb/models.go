@@ -0,0 +1,171 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+
+package sqlitedb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type BannedWord struct {
+	Word      string
+	Action    string
+	CreatedAt time.Time
+}
+
+type Chirp struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	InReplyTo uuid.NullUUID
+	HiddenAt  sql.NullTime
+}
+
+type ChirpHashtag struct {
+	ChirpID     uuid.UUID
+	Tag         string
+	StartOffset int64
+	EndOffset   int64
+}
+
+type ChirpMention struct {
+	ChirpID     uuid.UUID
+	UserID      uuid.UUID
+	StartOffset int64
+	EndOffset   int64
+}
+
+type ChirpReport struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	ReporterID uuid.NullUUID
+	Reason     string
+	CreatedAt  time.Time
+	ResolvedAt sql.NullTime
+}
+
+type ChirpRevision struct {
+	ID        uuid.UUID
+	ChirpID   uuid.UUID
+	Body      string
+	CreatedAt time.Time
+}
+
+type ChirpsFt struct {
+	Body string
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Like struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type ModerationAction struct {
+	ID          uuid.UUID
+	ChirpID     uuid.UUID
+	ChirpUserID uuid.UUID
+	ModeratorID uuid.NullUUID
+	Action      string
+	Reason      string
+	CreatedAt   time.Time
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	ActorID   uuid.UUID
+	Kind      string
+	ChirpID   uuid.NullUUID
+	CreatedAt time.Time
+	ReadAt    sql.NullTime
+}
+
+type NotificationPreference struct {
+	UserID    uuid.UUID
+	Mentions  bool
+	Replies   bool
+	Likes     bool
+	Follows   bool
+	UpdatedAt time.Time
+}
+
+type RefreshToken struct {
+	Token     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	RevokedAt sql.NullTime
+}
+
+type ScheduledChirp struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Body      string
+	InReplyTo uuid.NullUUID
+	PublishAt time.Time
+	CreatedAt time.Time
+}
+
+type User struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Email            string
+	HashedPassword   string
+	IsChirpyRed      bool
+	Role             string
+	SuspendedAt      sql.NullTime
+	SuspendedUntil   sql.NullTime
+	SuspensionReason string
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+	Event          string
+	Payload        string
+	Status         string
+	Attempts       int64
+	NextAttemptAt  time.Time
+	LastError      string
+	ResponseStatus sql.NullInt64
+	CreatedAt      time.Time
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookEvent struct {
+	ID             uuid.UUID
+	Source         string
+	EventID        sql.NullString
+	Event          string
+	UserID         uuid.NullUUID
+	Payload        string
+	Status         string
+	Error          string
+	Deliveries     int64
+	ReceivedAt     time.Time
+	LastReceivedAt time.Time
+}
+
+type WebhookSubscription struct {
+	ID        uuid.UUID
+	UserID    uuid.NullUUID
+	Url       string
+	Secret    string
+	Events    string
+	CreatedAt time.Time
+}
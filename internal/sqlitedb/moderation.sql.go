@@ -0,0 +1,321 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: moderation.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createChirpFlag = `-- name: CreateChirpFlag :one
+INSERT INTO chirp_reports (id, chirp_id, reporter_id, reason, created_at)
+VALUES (?, ?, NULL, ?, ?)
+RETURNING id, chirp_id, reporter_id, reason, created_at, resolved_at
+`
+
+type CreateChirpFlagParams struct {
+	ID        uuid.UUID
+	ChirpID   uuid.UUID
+	Reason    string
+	CreatedAt time.Time
+}
+
+func (q *Queries) CreateChirpFlag(ctx context.Context, arg CreateChirpFlagParams) (ChirpReport, error) {
+	row := q.db.QueryRowContext(ctx, createChirpFlag,
+		arg.ID,
+		arg.ChirpID,
+		arg.Reason,
+		arg.CreatedAt,
+	)
+	var i ChirpReport
+	err := row.Scan(
+		&i.ID,
+		&i.ChirpID,
+		&i.ReporterID,
+		&i.Reason,
+		&i.CreatedAt,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const createChirpReport = `-- name: CreateChirpReport :one
+INSERT INTO chirp_reports (id, chirp_id, reporter_id, reason, created_at)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+RETURNING id, chirp_id, reporter_id, reason, created_at, resolved_at
+`
+
+type CreateChirpReportParams struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	ReporterID uuid.NullUUID
+	Reason     string
+	CreatedAt  time.Time
+}
+
+func (q *Queries) CreateChirpReport(ctx context.Context, arg CreateChirpReportParams) (ChirpReport, error) {
+	row := q.db.QueryRowContext(ctx, createChirpReport,
+		arg.ID,
+		arg.ChirpID,
+		arg.ReporterID,
+		arg.Reason,
+		arg.CreatedAt,
+	)
+	var i ChirpReport
+	err := row.Scan(
+		&i.ID,
+		&i.ChirpID,
+		&i.ReporterID,
+		&i.Reason,
+		&i.CreatedAt,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const createModerationAction = `-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, chirp_id, chirp_user_id, moderator_id, action, reason, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING id, chirp_id, chirp_user_id, moderator_id, "action", reason, created_at
+`
+
+type CreateModerationActionParams struct {
+	ID          uuid.UUID
+	ChirpID     uuid.UUID
+	ChirpUserID uuid.UUID
+	ModeratorID uuid.NullUUID
+	Action      string
+	Reason      string
+	CreatedAt   time.Time
+}
+
+func (q *Queries) CreateModerationAction(ctx context.Context, arg CreateModerationActionParams) (ModerationAction, error) {
+	row := q.db.QueryRowContext(ctx, createModerationAction,
+		arg.ID,
+		arg.ChirpID,
+		arg.ChirpUserID,
+		arg.ModeratorID,
+		arg.Action,
+		arg.Reason,
+		arg.CreatedAt,
+	)
+	var i ModerationAction
+	err := row.Scan(
+		&i.ID,
+		&i.ChirpID,
+		&i.ChirpUserID,
+		&i.ModeratorID,
+		&i.Action,
+		&i.Reason,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listModerationActions = `-- name: ListModerationActions :many
+SELECT id, chirp_id, chirp_user_id, moderator_id, "action", reason, created_at FROM moderation_actions
+WHERE (?1 IS NULL OR chirp_id = ?1)
+  AND (created_at < ?2
+    OR (created_at = ?2 AND id < ?3))
+ORDER BY created_at DESC, id DESC
+LIMIT ?4
+`
+
+type ListModerationActionsParams struct {
+	ChirpID         interface{}
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int64
+}
+
+func (q *Queries) ListModerationActions(ctx context.Context, arg ListModerationActionsParams) ([]ModerationAction, error) {
+	rows, err := q.db.QueryContext(ctx, listModerationActions,
+		arg.ChirpID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ModerationAction
+	for rows.Next() {
+		var i ModerationAction
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChirpID,
+			&i.ChirpUserID,
+			&i.ModeratorID,
+			&i.Action,
+			&i.Reason,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listModerationQueue = `-- name: ListModerationQueue :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.hidden_at,
+  (SELECT COUNT(*) FROM chirp_reports AS open
+    WHERE open.chirp_id = chirps.id AND open.resolved_at IS NULL) AS open_reports,
+  first.created_at AS first_reported_at
+FROM chirp_reports AS first
+JOIN chirps ON chirps.id = first.chirp_id
+WHERE first.resolved_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM chirp_reports AS older
+    WHERE older.chirp_id = first.chirp_id
+      AND older.resolved_at IS NULL
+      AND (older.created_at < first.created_at
+        OR (older.created_at = first.created_at AND older.id < first.id))
+  )
+  AND (first.created_at > ?1
+    OR (first.created_at = ?1 AND chirps.id > ?2))
+ORDER BY first.created_at ASC, chirps.id ASC
+LIMIT ?3
+`
+
+type ListModerationQueueParams struct {
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int64
+}
+
+type ListModerationQueueRow struct {
+	Chirp           Chirp
+	OpenReports     int64
+	FirstReportedAt time.Time
+}
+
+// Joins each chirp's oldest open report rather than taking MIN(created_at),
+// which SQLite would return as text.
+func (q *Queries) ListModerationQueue(ctx context.Context, arg ListModerationQueueParams) ([]ListModerationQueueRow, error) {
+	rows, err := q.db.QueryContext(ctx, listModerationQueue, arg.CursorCreatedAt, arg.CursorID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListModerationQueueRow
+	for rows.Next() {
+		var i ListModerationQueueRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.HiddenAt,
+			&i.OpenReports,
+			&i.FirstReportedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOpenChirpReports = `-- name: ListOpenChirpReports :many
+SELECT id, chirp_id, reporter_id, reason, created_at, resolved_at FROM chirp_reports
+WHERE chirp_id IN (/*SLICE:chirp_ids*/?)
+  AND resolved_at IS NULL
+ORDER BY chirp_id, created_at, id
+`
+
+func (q *Queries) ListOpenChirpReports(ctx context.Context, chirpIds []uuid.UUID) ([]ChirpReport, error) {
+	query := listOpenChirpReports
+	var queryParams []interface{}
+	if len(chirpIds) > 0 {
+		for _, v := range chirpIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:chirp_ids*/?", strings.Repeat(",?", len(chirpIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:chirp_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChirpReport
+	for rows.Next() {
+		var i ChirpReport
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChirpID,
+			&i.ReporterID,
+			&i.Reason,
+			&i.CreatedAt,
+			&i.ResolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const resolveChirpReports = `-- name: ResolveChirpReports :exec
+UPDATE chirp_reports
+SET resolved_at = ?1
+WHERE chirp_id = ?2
+  AND resolved_at IS NULL
+`
+
+type ResolveChirpReportsParams struct {
+	Now     sql.NullTime
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) ResolveChirpReports(ctx context.Context, arg ResolveChirpReportsParams) error {
+	_, err := q.db.ExecContext(ctx, resolveChirpReports, arg.Now, arg.ChirpID)
+	return err
+}
+
+const setChirpHidden = `-- name: SetChirpHidden :exec
+UPDATE chirps
+SET hidden_at = ?
+WHERE id = ?
+`
+
+type SetChirpHiddenParams struct {
+	HiddenAt sql.NullTime
+	ID       uuid.UUID
+}
+
+func (q *Queries) SetChirpHidden(ctx context.Context, arg SetChirpHiddenParams) error {
+	_, err := q.db.ExecContext(ctx, setChirpHidden, arg.HiddenAt, arg.ID)
+	return err
+}
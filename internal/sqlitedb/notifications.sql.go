@@ -0,0 +1,254 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: notifications.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createNotification = `-- name: CreateNotification :one
+INSERT INTO notifications (id, user_id, actor_id, kind, chirp_id, created_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING id, user_id, actor_id, kind, chirp_id, created_at, read_at
+`
+
+type CreateNotificationParams struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	ActorID   uuid.UUID
+	Kind      string
+	ChirpID   uuid.NullUUID
+	CreatedAt time.Time
+}
+
+// The preferences and earlier notifications are checked by the caller, in
+// the same transaction.
+func (q *Queries) CreateNotification(ctx context.Context, arg CreateNotificationParams) (Notification, error) {
+	row := q.db.QueryRowContext(ctx, createNotification,
+		arg.ID,
+		arg.UserID,
+		arg.ActorID,
+		arg.Kind,
+		arg.ChirpID,
+		arg.CreatedAt,
+	)
+	var i Notification
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ActorID,
+		&i.Kind,
+		&i.ChirpID,
+		&i.CreatedAt,
+		&i.ReadAt,
+	)
+	return i, err
+}
+
+const getNotificationPreferences = `-- name: GetNotificationPreferences :one
+SELECT user_id, mentions, replies, likes, follows, updated_at FROM notification_preferences WHERE user_id = ?
+`
+
+func (q *Queries) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (NotificationPreference, error) {
+	row := q.db.QueryRowContext(ctx, getNotificationPreferences, userID)
+	var i NotificationPreference
+	err := row.Scan(
+		&i.UserID,
+		&i.Mentions,
+		&i.Replies,
+		&i.Likes,
+		&i.Follows,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const hasNotification = `-- name: HasNotification :one
+SELECT EXISTS (
+  SELECT 1 FROM notifications
+  WHERE user_id = ?1
+    AND actor_id = ?2
+    AND kind = ?3
+    AND chirp_id IS ?4
+)
+`
+
+type HasNotificationParams struct {
+	UserID  uuid.UUID
+	ActorID uuid.UUID
+	Kind    string
+	ChirpID uuid.NullUUID
+}
+
+// Whether the user has already been told that this actor did this to this
+// chirp.
+func (q *Queries) HasNotification(ctx context.Context, arg HasNotificationParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, hasNotification,
+		arg.UserID,
+		arg.ActorID,
+		arg.Kind,
+		arg.ChirpID,
+	)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const listNotifications = `-- name: ListNotifications :many
+SELECT id, user_id, actor_id, kind, chirp_id, created_at, read_at FROM notifications
+WHERE user_id = ?1
+  AND (CAST(?2 AS BOOLEAN) = FALSE OR read_at IS NULL)
+  AND (created_at < ?3
+    OR (created_at = ?3 AND id < ?4))
+ORDER BY created_at DESC, id DESC
+LIMIT ?5
+`
+
+type ListNotificationsParams struct {
+	UserID          uuid.UUID
+	UnreadOnly      bool
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int64
+}
+
+func (q *Queries) ListNotifications(ctx context.Context, arg ListNotificationsParams) ([]Notification, error) {
+	rows, err := q.db.QueryContext(ctx, listNotifications,
+		arg.UserID,
+		arg.UnreadOnly,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Notification
+	for rows.Next() {
+		var i Notification
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ActorID,
+			&i.Kind,
+			&i.ChirpID,
+			&i.CreatedAt,
+			&i.ReadAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markAllNotificationsRead = `-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = ?1
+WHERE user_id = ?2
+  AND read_at IS NULL
+`
+
+type MarkAllNotificationsReadParams struct {
+	Now    sql.NullTime
+	UserID uuid.UUID
+}
+
+func (q *Queries) MarkAllNotificationsRead(ctx context.Context, arg MarkAllNotificationsReadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markAllNotificationsRead, arg.Now, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const markNotificationsRead = `-- name: MarkNotificationsRead :execrows
+UPDATE notifications
+SET read_at = ?1
+WHERE user_id = ?2
+  AND id IN (/*SLICE:ids*/?)
+  AND read_at IS NULL
+`
+
+type MarkNotificationsReadParams struct {
+	Now    sql.NullTime
+	UserID uuid.UUID
+	Ids    []uuid.UUID
+}
+
+func (q *Queries) MarkNotificationsRead(ctx context.Context, arg MarkNotificationsReadParams) (int64, error) {
+	query := markNotificationsRead
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.Now)
+	queryParams = append(queryParams, arg.UserID)
+	if len(arg.Ids) > 0 {
+		for _, v := range arg.Ids {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:ids*/?", strings.Repeat(",?", len(arg.Ids))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:ids*/?", "NULL", 1)
+	}
+	result, err := q.db.ExecContext(ctx, query, queryParams...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setNotificationPreferences = `-- name: SetNotificationPreferences :one
+INSERT INTO notification_preferences (user_id, mentions, replies, likes, follows, updated_at)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (user_id) DO UPDATE
+SET mentions = excluded.mentions,
+  replies = excluded.replies,
+  likes = excluded.likes,
+  follows = excluded.follows,
+  updated_at = excluded.updated_at
+RETURNING user_id, mentions, replies, likes, follows, updated_at
+`
+
+type SetNotificationPreferencesParams struct {
+	UserID    uuid.UUID
+	Mentions  bool
+	Replies   bool
+	Likes     bool
+	Follows   bool
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetNotificationPreferences(ctx context.Context, arg SetNotificationPreferencesParams) (NotificationPreference, error) {
+	row := q.db.QueryRowContext(ctx, setNotificationPreferences,
+		arg.UserID,
+		arg.Mentions,
+		arg.Replies,
+		arg.Likes,
+		arg.Follows,
+		arg.UpdatedAt,
+	)
+	var i NotificationPreference
+	err := row.Scan(
+		&i.UserID,
+		&i.Mentions,
+		&i.Replies,
+		&i.Likes,
+		&i.Follows,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
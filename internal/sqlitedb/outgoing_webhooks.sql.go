@@ -0,0 +1,325 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: outgoing_webhooks.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = ?1
+WHERE id IN (
+  SELECT d.id FROM webhook_deliveries d
+  WHERE d.status = 'pending'
+    AND d.next_attempt_at <= ?2
+  ORDER BY d.next_attempt_at
+  LIMIT ?3
+)
+RETURNING id, subscription_id, event, payload, status, attempts, next_attempt_at, last_error, response_status, created_at, delivered_at
+`
+
+type ClaimWebhookDeliveriesParams struct {
+	LeaseUntil    time.Time
+	Now           time.Time
+	MaxDeliveries int64
+}
+
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, claimWebhookDeliveries, arg.LeaseUntil, arg.Now, arg.MaxDeliveries)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.SubscriptionID,
+			&i.Event,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastError,
+			&i.ResponseStatus,
+			&i.CreatedAt,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (id, subscription_id, event, payload, next_attempt_at, created_at)
+VALUES (?, ?, ?, ?, ?5, ?5)
+`
+
+type CreateWebhookDeliveryParams struct {
+	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+	Event          string
+	Payload        string
+	Now            time.Time
+}
+
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, createWebhookDelivery,
+		arg.ID,
+		arg.SubscriptionID,
+		arg.Event,
+		arg.Payload,
+		arg.Now,
+	)
+	return err
+}
+
+const createWebhookSubscription = `-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (id, user_id, url, secret, events, created_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING id, user_id, url, secret, events, created_at
+`
+
+type CreateWebhookSubscriptionParams struct {
+	ID        uuid.UUID
+	UserID    uuid.NullUUID
+	Url       string
+	Secret    string
+	Events    string
+	CreatedAt time.Time
+}
+
+func (q *Queries) CreateWebhookSubscription(ctx context.Context, arg CreateWebhookSubscriptionParams) (WebhookSubscription, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookSubscription,
+		arg.ID,
+		arg.UserID,
+		arg.Url,
+		arg.Secret,
+		arg.Events,
+		arg.CreatedAt,
+	)
+	var i WebhookSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteWebhookSubscription = `-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE id = ?1
+  AND user_id IS ?2
+`
+
+type DeleteWebhookSubscriptionParams struct {
+	ID     uuid.UUID
+	UserID uuid.NullUUID
+}
+
+func (q *Queries) DeleteWebhookSubscription(ctx context.Context, arg DeleteWebhookSubscriptionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebhookSubscription, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const finishWebhookAttempt = `-- name: FinishWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET status = ?1,
+  attempts = attempts + 1,
+  next_attempt_at = ?2,
+  last_error = ?3,
+  response_status = ?4,
+  delivered_at = ?5
+WHERE id = ?6
+`
+
+type FinishWebhookAttemptParams struct {
+	Status         string
+	NextAttemptAt  time.Time
+	LastError      string
+	ResponseStatus sql.NullInt64
+	DeliveredAt    sql.NullTime
+	ID             uuid.UUID
+}
+
+func (q *Queries) FinishWebhookAttempt(ctx context.Context, arg FinishWebhookAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, finishWebhookAttempt,
+		arg.Status,
+		arg.NextAttemptAt,
+		arg.LastError,
+		arg.ResponseStatus,
+		arg.DeliveredAt,
+		arg.ID,
+	)
+	return err
+}
+
+const getWebhookSubscription = `-- name: GetWebhookSubscription :one
+SELECT id, user_id, url, secret, events, created_at FROM webhook_subscriptions WHERE id = ?
+`
+
+func (q *Queries) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (WebhookSubscription, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookSubscription, id)
+	var i WebhookSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT id, subscription_id, event, payload, status, attempts, next_attempt_at, last_error, response_status, created_at, delivered_at FROM webhook_deliveries
+WHERE subscription_id = ?1
+  AND (created_at < ?2
+    OR (created_at = ?2 AND id < ?3))
+ORDER BY created_at DESC, id DESC
+LIMIT ?4
+`
+
+type ListWebhookDeliveriesParams struct {
+	SubscriptionID  uuid.UUID
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int64
+}
+
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookDeliveries,
+		arg.SubscriptionID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.SubscriptionID,
+			&i.Event,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastError,
+			&i.ResponseStatus,
+			&i.CreatedAt,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookSubscribers = `-- name: ListWebhookSubscribers :many
+SELECT id FROM webhook_subscriptions
+WHERE ' ' || events || ' ' LIKE '% ' || CAST(?1 AS TEXT) || ' %'
+  AND (?2 IS NULL OR user_id IS NULL OR user_id = ?2)
+`
+
+type ListWebhookSubscribersParams struct {
+	Event  string
+	UserID interface{}
+}
+
+// SQLite can't generate UUIDs, so EnqueueWebhookEvent is a query for the
+// subscriptions followed by an insert for each.
+func (q *Queries) ListWebhookSubscribers(ctx context.Context, arg ListWebhookSubscribersParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookSubscribers, arg.Event, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookSubscriptions = `-- name: ListWebhookSubscriptions :many
+SELECT id, user_id, url, secret, events, created_at FROM webhook_subscriptions
+WHERE user_id IS ?1
+ORDER BY created_at, id
+`
+
+func (q *Queries) ListWebhookSubscriptions(ctx context.Context, userID uuid.NullUUID) ([]WebhookSubscription, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookSubscriptions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookSubscription
+	for rows.Next() {
+		var i WebhookSubscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Url,
+			&i.Secret,
+			&i.Events,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,105 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: refresh_tokens.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
+SELECT
+  users.id,
+  users.created_at,
+  users.updated_at,
+  users.email,
+  users.hashed_password,
+  users.role,
+  users.suspended_at,
+  users.suspended_until,
+  users.suspension_reason
+FROM users
+JOIN refresh_tokens ON refresh_tokens.user_id = users.id
+WHERE refresh_tokens.token = ?1
+  AND refresh_tokens.revoked_at IS NULL
+  AND refresh_tokens.expires_at > ?2
+`
+
+type GetUserFromRefreshTokenParams struct {
+	Token string
+	Now   time.Time
+}
+
+type GetUserFromRefreshTokenRow struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Email            string
+	HashedPassword   string
+	Role             string
+	SuspendedAt      sql.NullTime
+	SuspendedUntil   sql.NullTime
+	SuspensionReason string
+}
+
+func (q *Queries) GetUserFromRefreshToken(ctx context.Context, arg GetUserFromRefreshTokenParams) (GetUserFromRefreshTokenRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserFromRefreshToken, arg.Token, arg.Now)
+	var i GetUserFromRefreshTokenRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+	)
+	return i, err
+}
+
+const revokeRefreshToken = `-- name: RevokeRefreshToken :execrows
+UPDATE refresh_tokens
+SET revoked_at = ?1,
+  updated_at = ?1
+WHERE token = ?2
+  AND revoked_at IS NULL
+`
+
+type RevokeRefreshTokenParams struct {
+	Now   sql.NullTime
+	Token string
+}
+
+func (q *Queries) RevokeRefreshToken(ctx context.Context, arg RevokeRefreshTokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeRefreshToken, arg.Now, arg.Token)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = ?1,
+  updated_at = ?1
+WHERE user_id = ?2
+  AND revoked_at IS NULL
+`
+
+type RevokeUserRefreshTokensParams struct {
+	Now    sql.NullTime
+	UserID uuid.UUID
+}
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, arg RevokeUserRefreshTokensParams) error {
+	_, err := q.db.ExecContext(ctx, revokeUserRefreshTokens, arg.Now, arg.UserID)
+	return err
+}
@@ -0,0 +1,145 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: scheduled_chirps.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimDueScheduledChirps = `-- name: ClaimDueScheduledChirps :many
+DELETE FROM scheduled_chirps
+WHERE publish_at <= ?1
+  AND user_id NOT IN (
+    SELECT id FROM users
+    WHERE suspended_at IS NOT NULL
+      AND (suspended_until IS NULL OR suspended_until > ?1)
+  )
+RETURNING id, user_id, body, in_reply_to, publish_at, created_at
+`
+
+func (q *Queries) ClaimDueScheduledChirps(ctx context.Context, now time.Time) ([]ScheduledChirp, error) {
+	rows, err := q.db.QueryContext(ctx, claimDueScheduledChirps, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ScheduledChirp
+	for rows.Next() {
+		var i ScheduledChirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.InReplyTo,
+			&i.PublishAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createScheduledChirp = `-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, user_id, body, in_reply_to, publish_at, created_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING id, user_id, body, in_reply_to, publish_at, created_at
+`
+
+type CreateScheduledChirpParams struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Body      string
+	InReplyTo uuid.NullUUID
+	PublishAt time.Time
+	CreatedAt time.Time
+}
+
+func (q *Queries) CreateScheduledChirp(ctx context.Context, arg CreateScheduledChirpParams) (ScheduledChirp, error) {
+	row := q.db.QueryRowContext(ctx, createScheduledChirp,
+		arg.ID,
+		arg.UserID,
+		arg.Body,
+		arg.InReplyTo,
+		arg.PublishAt,
+		arg.CreatedAt,
+	)
+	var i ScheduledChirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		&i.InReplyTo,
+		&i.PublishAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteScheduledChirp = `-- name: DeleteScheduledChirp :execrows
+DELETE FROM scheduled_chirps
+WHERE id = ?
+  AND user_id = ?
+`
+
+type DeleteScheduledChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteScheduledChirp(ctx context.Context, arg DeleteScheduledChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteScheduledChirp, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listScheduledChirps = `-- name: ListScheduledChirps :many
+SELECT id, user_id, body, in_reply_to, publish_at, created_at FROM scheduled_chirps
+WHERE user_id = ?
+ORDER BY publish_at, id
+`
+
+func (q *Queries) ListScheduledChirps(ctx context.Context, userID uuid.UUID) ([]ScheduledChirp, error) {
+	rows, err := q.db.QueryContext(ctx, listScheduledChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ScheduledChirp
+	for rows.Next() {
+		var i ScheduledChirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.InReplyTo,
+			&i.PublishAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,139 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: search.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.hidden_at, CAST(-bm25(chirps_fts) AS REAL) AS relevance
+FROM chirps_fts
+JOIN chirps ON chirps.rowid = chirps_fts.rowid
+WHERE chirps_fts.body MATCH ?1
+  AND (?2 IS NULL OR chirps.user_id = ?2)
+  AND (-bm25(chirps_fts) < ?3
+    OR (-bm25(chirps_fts) = ?3 AND chirps.created_at < ?4)
+    OR (-bm25(chirps_fts) = ?3 AND chirps.created_at = ?4 AND chirps.id < ?5))
+  AND chirps.hidden_at IS NULL
+ORDER BY relevance DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT ?6
+`
+
+type SearchChirpsParams struct {
+	Query           string
+	AuthorID        interface{}
+	CursorRelevance interface{}
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int64
+}
+
+type SearchChirpsRow struct {
+	Chirp     Chirp
+	Relevance float64
+}
+
+// bm25() is lower for better matches, so it is negated to rank like
+// ts_rank in Postgres.
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.Query,
+		arg.AuthorID,
+		arg.CursorRelevance,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRow
+	for rows.Next() {
+		var i SearchChirpsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.HiddenAt,
+			&i.Relevance,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchChirpsRecent = `-- name: SearchChirpsRecent :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.hidden_at FROM chirps_fts
+JOIN chirps ON chirps.rowid = chirps_fts.rowid
+WHERE chirps_fts.body MATCH ?1
+  AND (?2 IS NULL OR chirps.user_id = ?2)
+  AND (chirps.created_at < ?3
+    OR (chirps.created_at = ?3 AND chirps.id < ?4))
+  AND chirps.hidden_at IS NULL
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT ?5
+`
+
+type SearchChirpsRecentParams struct {
+	Query           string
+	AuthorID        interface{}
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageSize        int64
+}
+
+func (q *Queries) SearchChirpsRecent(ctx context.Context, arg SearchChirpsRecentParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirpsRecent,
+		arg.Query,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
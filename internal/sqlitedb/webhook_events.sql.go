@@ -0,0 +1,134 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: webhook_events.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const listWebhookEvents = `-- name: ListWebhookEvents :many
+SELECT id, source, event_id, event, user_id, payload, status, error, deliveries, received_at, last_received_at FROM webhook_events
+WHERE (?1 IS NULL OR user_id = ?1)
+  AND (received_at < ?2
+    OR (received_at = ?2 AND id < ?3))
+ORDER BY received_at DESC, id DESC
+LIMIT ?4
+`
+
+type ListWebhookEventsParams struct {
+	UserID           interface{}
+	CursorReceivedAt time.Time
+	CursorID         uuid.UUID
+	PageSize         int64
+}
+
+func (q *Queries) ListWebhookEvents(ctx context.Context, arg ListWebhookEventsParams) ([]WebhookEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookEvents,
+		arg.UserID,
+		arg.CursorReceivedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEvent
+	for rows.Next() {
+		var i WebhookEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.Source,
+			&i.EventID,
+			&i.Event,
+			&i.UserID,
+			&i.Payload,
+			&i.Status,
+			&i.Error,
+			&i.Deliveries,
+			&i.ReceivedAt,
+			&i.LastReceivedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordWebhookEvent = `-- name: RecordWebhookEvent :one
+INSERT INTO webhook_events (id, source, event_id, event, user_id, payload, received_at, last_received_at)
+VALUES (?, ?, ?, ?, ?, ?, ?7, ?7)
+ON CONFLICT (source, event_id) DO UPDATE
+SET deliveries = webhook_events.deliveries + 1,
+  last_received_at = excluded.last_received_at
+RETURNING id, source, event_id, event, user_id, payload, status, error, deliveries, received_at, last_received_at
+`
+
+type RecordWebhookEventParams struct {
+	ID      uuid.UUID
+	Source  string
+	EventID sql.NullString
+	Event   string
+	UserID  uuid.NullUUID
+	Payload string
+	Now     time.Time
+}
+
+func (q *Queries) RecordWebhookEvent(ctx context.Context, arg RecordWebhookEventParams) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, recordWebhookEvent,
+		arg.ID,
+		arg.Source,
+		arg.EventID,
+		arg.Event,
+		arg.UserID,
+		arg.Payload,
+		arg.Now,
+	)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.Source,
+		&i.EventID,
+		&i.Event,
+		&i.UserID,
+		&i.Payload,
+		&i.Status,
+		&i.Error,
+		&i.Deliveries,
+		&i.ReceivedAt,
+		&i.LastReceivedAt,
+	)
+	return i, err
+}
+
+const setWebhookEventStatus = `-- name: SetWebhookEventStatus :exec
+UPDATE webhook_events
+SET status = ?,
+  error = ?
+WHERE id = ?
+`
+
+type SetWebhookEventStatusParams struct {
+	Status string
+	Error  string
+	ID     uuid.UUID
+}
+
+func (q *Queries) SetWebhookEventStatus(ctx context.Context, arg SetWebhookEventStatusParams) error {
+	_, err := q.db.ExecContext(ctx, setWebhookEventStatus, arg.Status, arg.Error, arg.ID)
+	return err
+}
@@ -0,0 +1,1549 @@
+// Package memory is an in-process implementation of store.Store.
+//
+// It mirrors the Postgres schema: emails are unique, deleting a user removes
+// their chirps and refresh tokens, and refresh tokens stop resolving once they
+// are revoked or expired. It is meant for tests and local experiments, not
+// for production data.
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/Geraetefreund/chirpy/internal/search"
+	"github.com/Geraetefreund/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// ErrDuplicateEmail mirrors the unique constraint on users.email.
+var ErrDuplicateEmail = errors.New("duplicate key value violates unique constraint on users.email")
+
+// ErrUnknownUser mirrors the foreign keys that point at users.id.
+var ErrUnknownUser = errors.New("insert violates foreign key constraint on user_id")
+
+// ErrUnknownChirp mirrors the foreign keys that point at chirps.id.
+var ErrUnknownChirp = errors.New("insert violates foreign key constraint on chirp_id")
+
+// ErrInvalidRole mirrors the check constraint on users.role.
+var ErrInvalidRole = errors.New("new row violates check constraint users_role")
+
+type Store struct {
+	mu            sync.RWMutex
+	now           func() time.Time
+	users         map[uuid.UUID]database.User
+	chirps        map[uuid.UUID]database.Chirp
+	revisions     map[uuid.UUID][]database.ChirpRevision
+	hashtags      map[uuid.UUID][]database.ChirpHashtag
+	mentions      map[uuid.UUID][]database.ChirpMention
+	follows       map[follow]time.Time
+	likes         map[like]time.Time
+	scheduled     map[uuid.UUID]database.ScheduledChirp
+	bannedWords   map[string]database.BannedWord
+	webhookEvents map[uuid.UUID]database.WebhookEvent
+	webhookSubs   map[uuid.UUID]database.WebhookSubscription
+	deliveries    map[uuid.UUID]database.WebhookDelivery
+	notifications map[uuid.UUID]database.Notification
+	notifyPrefs   map[uuid.UUID]database.NotificationPreference
+	reports       map[uuid.UUID]database.ChirpReport
+	modActions    map[uuid.UUID]database.ModerationAction
+	refreshTokens map[string]database.RefreshToken
+}
+
+type follow struct{ follower, followee uuid.UUID }
+
+type like struct{ user, chirp uuid.UUID }
+
+var _ store.Store = (*Store)(nil)
+
+func New() *Store {
+	s := &Store{now: time.Now}
+	s.clear()
+	// Seeded like the banned_words migration. Reset leaves the word list,
+	// the webhook log, the admins' webhook subscriptions and the moderation
+	// audit trail alone.
+	s.bannedWords = make(map[string]database.BannedWord)
+	for _, w := range []string{"kerfuffle", "sharbert", "fornax"} {
+		s.bannedWords[w] = database.BannedWord{Word: w, Action: "mask", CreatedAt: s.now()}
+	}
+	s.webhookEvents = make(map[uuid.UUID]database.WebhookEvent)
+	s.webhookSubs = make(map[uuid.UUID]database.WebhookSubscription)
+	s.deliveries = make(map[uuid.UUID]database.WebhookDelivery)
+	s.modActions = make(map[uuid.UUID]database.ModerationAction)
+	return s
+}
+
+func (s *Store) clear() {
+	s.users = make(map[uuid.UUID]database.User)
+	s.chirps = make(map[uuid.UUID]database.Chirp)
+	s.revisions = make(map[uuid.UUID][]database.ChirpRevision)
+	s.hashtags = make(map[uuid.UUID][]database.ChirpHashtag)
+	s.mentions = make(map[uuid.UUID][]database.ChirpMention)
+	s.follows = make(map[follow]time.Time)
+	s.likes = make(map[like]time.Time)
+	s.scheduled = make(map[uuid.UUID]database.ScheduledChirp)
+	s.notifications = make(map[uuid.UUID]database.Notification)
+	s.notifyPrefs = make(map[uuid.UUID]database.NotificationPreference)
+	s.reports = make(map[uuid.UUID]database.ChirpReport)
+	for id, a := range s.modActions {
+		if a.ModeratorID.Valid {
+			// ON DELETE SET NULL
+			a.ModeratorID = uuid.NullUUID{}
+			s.modActions[id] = a
+		}
+	}
+	// Admin subscriptions have no user to cascade from.
+	for id, sub := range s.webhookSubs {
+		if sub.UserID.Valid {
+			s.deleteWebhookSubscription(id)
+		}
+	}
+	s.refreshTokens = make(map[string]database.RefreshToken)
+}
+
+func (s *Store) Reset(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clear()
+	return nil
+}
+
+// users
+
+func (s *Store) emailTaken(email string, except uuid.UUID) bool {
+	for _, u := range s.users {
+		if u.Email == email && u.ID != except {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.emailTaken(arg.Email, uuid.Nil) {
+		return database.User{}, ErrDuplicateEmail
+	}
+	now := s.now()
+	user := database.User{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		Role:           "user",
+	}
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (s *Store) GetUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (s *Store) LookUpUserByEmail(ctx context.Context, email string) (database.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (s *Store) UpdateEmailAndPW(ctx context.Context, arg database.UpdateEmailAndPWParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[arg.ID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	if s.emailTaken(arg.Email, arg.ID) {
+		return database.User{}, ErrDuplicateEmail
+	}
+	user.Email = arg.Email
+	user.HashedPassword = arg.HashedPassword
+	user.UpdatedAt = s.now()
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (s *Store) UpgradeChirpyPlus(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	user.IsChirpyRed = true
+	s.users[id] = user
+	return user, nil
+}
+
+func (s *Store) DowngradeChirpyPlus(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	user.IsChirpyRed = false
+	s.users[id] = user
+	return user, nil
+}
+
+func (s *Store) SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (database.User, error) {
+	switch arg.Role {
+	case "user", "moderator", "admin":
+	default:
+		return database.User{}, ErrInvalidRole
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[arg.ID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	user.Role = arg.Role
+	user.UpdatedAt = s.now()
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (s *Store) SuspendUser(ctx context.Context, arg database.SuspendUserParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[arg.ID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	now := s.now()
+	for token, rt := range s.refreshTokens {
+		if rt.UserID == user.ID && !rt.RevokedAt.Valid {
+			rt.RevokedAt = sql.NullTime{Time: now, Valid: true}
+			rt.UpdatedAt = now
+			s.refreshTokens[token] = rt
+		}
+	}
+	user.SuspendedAt = sql.NullTime{Time: now, Valid: true}
+	user.SuspendedUntil = arg.SuspendedUntil
+	user.SuspensionReason = arg.SuspensionReason
+	user.UpdatedAt = now
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (s *Store) UnsuspendUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	user.SuspendedAt = sql.NullTime{}
+	user.SuspendedUntil = sql.NullTime{}
+	user.SuspensionReason = ""
+	user.UpdatedAt = s.now()
+	s.users[user.ID] = user
+	return user, nil
+}
+
+// chirps
+
+func (s *Store) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.Chirp{}, ErrUnknownUser
+	}
+	if arg.InReplyTo.Valid {
+		if _, ok := s.chirps[arg.InReplyTo.UUID]; !ok {
+			return database.Chirp{}, ErrUnknownChirp
+		}
+	}
+	now := s.now()
+	chirp := database.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+		InReplyTo: arg.InReplyTo,
+	}
+	hashtags, mentions, err := s.entities(chirp.ID, arg.Tags, arg.TagStarts, arg.TagEnds, arg.MentionUserIds, arg.MentionStarts, arg.MentionEnds)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	s.chirps[chirp.ID] = chirp
+	s.hashtags[chirp.ID] = hashtags
+	s.mentions[chirp.ID] = mentions
+	return chirp, nil
+}
+
+// entities validates the parallel entity arrays of CreateChirp and
+// UpdateChirp and turns them into rows.
+func (s *Store) entities(chirpID uuid.UUID, tags []string, tagStarts, tagEnds []int32, mentionUserIDs []uuid.UUID, mentionStarts, mentionEnds []int32) ([]database.ChirpHashtag, []database.ChirpMention, error) {
+	if len(tagStarts) != len(tags) || len(tagEnds) != len(tags) ||
+		len(mentionStarts) != len(mentionUserIDs) || len(mentionEnds) != len(mentionUserIDs) {
+		return nil, nil, errors.New("entity arrays differ in length")
+	}
+	var hashtags []database.ChirpHashtag
+	for i, tag := range tags {
+		hashtags = append(hashtags, database.ChirpHashtag{ChirpID: chirpID, Tag: tag, StartOffset: tagStarts[i], EndOffset: tagEnds[i]})
+	}
+	var mentions []database.ChirpMention
+	for i, userID := range mentionUserIDs {
+		if _, ok := s.users[userID]; !ok {
+			return nil, nil, ErrUnknownUser
+		}
+		mentions = append(mentions, database.ChirpMention{ChirpID: chirpID, UserID: userID, StartOffset: mentionStarts[i], EndOffset: mentionEnds[i]})
+	}
+	return hashtags, mentions, nil
+}
+
+func (s *Store) GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chirp, ok := s.chirps[id]
+	if !ok || chirp.HiddenAt.Valid {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+func (s *Store) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteChirp(id)
+	return nil
+}
+
+func (s *Store) deleteChirp(id uuid.UUID) {
+	delete(s.chirps, id)
+	delete(s.revisions, id)
+	delete(s.hashtags, id)
+	delete(s.mentions, id)
+	for key := range s.likes {
+		if key.chirp == id {
+			delete(s.likes, key)
+		}
+	}
+	for nid, n := range s.notifications {
+		if n.ChirpID.Valid && n.ChirpID.UUID == id {
+			delete(s.notifications, nid)
+		}
+	}
+	for rid, r := range s.reports {
+		if r.ChirpID == id {
+			delete(s.reports, rid)
+		}
+	}
+	// ON DELETE SET NULL
+	for _, c := range s.chirps {
+		if c.InReplyTo.Valid && c.InReplyTo.UUID == id {
+			c.InReplyTo = uuid.NullUUID{}
+			s.chirps[c.ID] = c
+		}
+	}
+	for _, c := range s.scheduled {
+		if c.InReplyTo.Valid && c.InReplyTo.UUID == id {
+			c.InReplyTo = uuid.NullUUID{}
+			s.scheduled[c.ID] = c
+		}
+	}
+}
+
+func (s *Store) UpdateChirp(ctx context.Context, arg database.UpdateChirpParams) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chirp, ok := s.chirps[arg.ID]
+	if !ok {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	hashtags, mentions, err := s.entities(chirp.ID, arg.Tags, arg.TagStarts, arg.TagEnds, arg.MentionUserIds, arg.MentionStarts, arg.MentionEnds)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	s.hashtags[chirp.ID] = hashtags
+	s.mentions[chirp.ID] = mentions
+	s.revisions[chirp.ID] = append(s.revisions[chirp.ID], database.ChirpRevision{
+		ID:        uuid.New(),
+		ChirpID:   chirp.ID,
+		Body:      chirp.Body,
+		CreatedAt: chirp.UpdatedAt,
+	})
+	chirp.Body = arg.Body
+	chirp.UpdatedAt = s.now()
+	s.chirps[chirp.ID] = chirp
+	return chirp, nil
+}
+
+func (s *Store) ListChirpRevisions(ctx context.Context, chirpID uuid.UUID) ([]database.ChirpRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.revisions[chirpID]), nil
+}
+
+func (s *Store) ListChirpDescendants(ctx context.Context, arg database.ListChirpDescendantsParams) ([]database.ListChirpDescendantsRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	depths := map[uuid.UUID]int32{arg.ID: 0}
+	for depth := int32(1); depth <= arg.MaxDepth; depth++ {
+		found := false
+		for _, c := range s.chirps {
+			if c.HiddenAt.Valid || !c.InReplyTo.Valid {
+				continue
+			}
+			if d, ok := depths[c.InReplyTo.UUID]; ok && d == depth-1 {
+				depths[c.ID] = depth
+				found = true
+			}
+		}
+		if !found {
+			break
+		}
+	}
+
+	page := keysetPage(s.chirps, func(c database.Chirp) bool {
+		return depths[c.ID] > 0
+	}, arg.CursorCreatedAt, arg.CursorID, arg.PageSize, false)
+	out := make([]database.ListChirpDescendantsRow, len(page))
+	for i, c := range page {
+		out[i] = database.ListChirpDescendantsRow{Chirp: c, Depth: depths[c.ID]}
+	}
+	return out, nil
+}
+
+func (s *Store) ListChirpAncestors(ctx context.Context, arg database.ListChirpAncestorsParams) ([]database.Chirp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []database.Chirp
+	chirp, ok := s.chirps[arg.ID]
+	for ok && chirp.InReplyTo.Valid && len(out) < int(arg.MaxDepth) {
+		chirp, ok = s.chirps[chirp.InReplyTo.UUID]
+		if ok && !chirp.HiddenAt.Valid {
+			out = append(out, chirp)
+		}
+	}
+	return out, nil
+}
+
+func (s *Store) CountReplies(ctx context.Context, chirpIds []uuid.UUID) ([]database.CountRepliesRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[uuid.UUID]int64)
+	for _, c := range s.chirps {
+		if c.InReplyTo.Valid && !c.HiddenAt.Valid && slices.Contains(chirpIds, c.InReplyTo.UUID) {
+			counts[c.InReplyTo.UUID]++
+		}
+	}
+	var out []database.CountRepliesRow
+	for id, n := range counts {
+		out = append(out, database.CountRepliesRow{ChirpID: id, ReplyCount: n})
+	}
+	return out, nil
+}
+
+func (s *Store) ListChirpHashtags(ctx context.Context, chirpIds []uuid.UUID) ([]database.ChirpHashtag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []database.ChirpHashtag
+	for _, id := range chirpIds {
+		out = append(out, s.hashtags[id]...)
+	}
+	return out, nil
+}
+
+func (s *Store) ListChirpMentions(ctx context.Context, chirpIds []uuid.UUID) ([]database.ChirpMention, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []database.ChirpMention
+	for _, id := range chirpIds {
+		out = append(out, s.mentions[id]...)
+	}
+	return out, nil
+}
+
+func (s *Store) ListChirpsByHashtag(ctx context.Context, arg database.ListChirpsByHashtagParams) ([]database.Chirp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return keysetPage(s.chirps, func(c database.Chirp) bool {
+		return slices.ContainsFunc(s.hashtags[c.ID], func(h database.ChirpHashtag) bool { return h.Tag == arg.Tag })
+	}, arg.CursorCreatedAt, arg.CursorID, arg.PageSize, true), nil
+}
+
+func (s *Store) ListChirpsMentioning(ctx context.Context, arg database.ListChirpsMentioningParams) ([]database.Chirp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return keysetPage(s.chirps, func(c database.Chirp) bool {
+		return slices.ContainsFunc(s.mentions[c.ID], func(m database.ChirpMention) bool { return m.UserID == arg.UserID })
+	}, arg.CursorCreatedAt, arg.CursorID, arg.PageSize, true), nil
+}
+
+// SearchChirps ranks chirps by how often the query terms occur in them.
+// Unlike Postgres it does no stemming.
+func (s *Store) SearchChirps(ctx context.Context, arg database.SearchChirpsParams) ([]database.SearchChirpsRow, error) {
+	q, err := search.ParseTSQuery(arg.Query)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []database.SearchChirpsRow
+	for _, c := range s.chirps {
+		if c.HiddenAt.Valid || arg.AuthorID.Valid && c.UserID != arg.AuthorID.UUID {
+			continue
+		}
+		score := float64(q.Score(c.Body))
+		if score == 0 {
+			continue
+		}
+		if score < arg.CursorRelevance ||
+			score == arg.CursorRelevance && compareKeyset(c.CreatedAt, c.ID, arg.CursorCreatedAt, arg.CursorID) < 0 {
+			out = append(out, database.SearchChirpsRow{Chirp: c, Relevance: score})
+		}
+	}
+	slices.SortFunc(out, func(a, b database.SearchChirpsRow) int {
+		if a.Relevance != b.Relevance {
+			return cmp.Compare(b.Relevance, a.Relevance)
+		}
+		return -compareKeyset(a.Chirp.CreatedAt, a.Chirp.ID, b.Chirp.CreatedAt, b.Chirp.ID)
+	})
+	if len(out) > int(arg.PageSize) {
+		out = out[:arg.PageSize]
+	}
+	return out, nil
+}
+
+func (s *Store) SearchChirpsRecent(ctx context.Context, arg database.SearchChirpsRecentParams) ([]database.Chirp, error) {
+	q, err := search.ParseTSQuery(arg.Query)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return keysetPage(s.chirps, func(c database.Chirp) bool {
+		return (!arg.AuthorID.Valid || c.UserID == arg.AuthorID.UUID) && q.Score(c.Body) > 0
+	}, arg.CursorCreatedAt, arg.CursorID, arg.PageSize, true), nil
+}
+
+func (s *Store) ListChirps(ctx context.Context, arg database.ListChirpsParams) ([]database.Chirp, error) {
+	return s.listChirps(uuid.Nil, arg.CursorCreatedAt, arg.CursorID, arg.PageSize, false), nil
+}
+
+func (s *Store) ListChirpsDesc(ctx context.Context, arg database.ListChirpsDescParams) ([]database.Chirp, error) {
+	return s.listChirps(uuid.Nil, arg.CursorCreatedAt, arg.CursorID, arg.PageSize, true), nil
+}
+
+func (s *Store) ListChirpsByAuthor(ctx context.Context, arg database.ListChirpsByAuthorParams) ([]database.Chirp, error) {
+	return s.listChirps(arg.UserID, arg.CursorCreatedAt, arg.CursorID, arg.PageSize, false), nil
+}
+
+func (s *Store) ListChirpsByAuthorDesc(ctx context.Context, arg database.ListChirpsByAuthorDescParams) ([]database.Chirp, error) {
+	return s.listChirps(arg.UserID, arg.CursorCreatedAt, arg.CursorID, arg.PageSize, true), nil
+}
+
+// listChirps is the keyset scan behind the List* queries. A zero author
+// matches every chirp.
+func (s *Store) listChirps(author uuid.UUID, cursorAt time.Time, cursorID uuid.UUID, limit int32, desc bool) []database.Chirp {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return keysetPage(s.chirps, func(c database.Chirp) bool {
+		return author == uuid.Nil || c.UserID == author
+	}, cursorAt, cursorID, limit, desc)
+}
+
+// keysetPage returns up to limit chirps matching keep that sort after
+// (cursorAt, cursorID), or before it when desc is set. Hidden chirps never
+// match.
+func keysetPage(chirps map[uuid.UUID]database.Chirp, keep func(database.Chirp) bool, cursorAt time.Time, cursorID uuid.UUID, limit int32, desc bool) []database.Chirp {
+	var out []database.Chirp
+	for _, c := range chirps {
+		if c.HiddenAt.Valid || !keep(c) {
+			continue
+		}
+		cmp := compareKeyset(c.CreatedAt, c.ID, cursorAt, cursorID)
+		if (!desc && cmp > 0) || (desc && cmp < 0) {
+			out = append(out, c)
+		}
+	}
+	slices.SortFunc(out, func(a, b database.Chirp) int {
+		cmp := compareKeyset(a.CreatedAt, a.ID, b.CreatedAt, b.ID)
+		if desc {
+			return -cmp
+		}
+		return cmp
+	})
+	if len(out) > int(limit) {
+		out = out[:limit]
+	}
+	return out
+}
+
+// compareKeyset orders rows by (created_at, id) the way Postgres compares
+// row constructors.
+func compareKeyset(aAt time.Time, aID uuid.UUID, bAt time.Time, bID uuid.UUID) int {
+	if c := aAt.Compare(bAt); c != 0 {
+		return c
+	}
+	return slices.Compare(aID[:], bID[:])
+}
+
+// follows
+
+func (s *Store) FollowUser(ctx context.Context, arg database.FollowUserParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, okFollower := s.users[arg.FollowerID]
+	_, okFollowee := s.users[arg.FolloweeID]
+	if !okFollower || !okFollowee {
+		return 0, ErrUnknownUser
+	}
+	if arg.FollowerID == arg.FolloweeID {
+		return 0, errors.New("new row violates check constraint follows_not_self")
+	}
+	key := follow{arg.FollowerID, arg.FolloweeID}
+	if _, ok := s.follows[key]; ok {
+		return 0, nil
+	}
+	s.follows[key] = s.now()
+	return 1, nil
+}
+
+func (s *Store) UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := follow{arg.FollowerID, arg.FolloweeID}
+	if _, ok := s.follows[key]; !ok {
+		return 0, nil
+	}
+	delete(s.follows, key)
+	return 1, nil
+}
+
+func (s *Store) ListFollowers(ctx context.Context, arg database.ListFollowersParams) ([]database.Follow, error) {
+	return s.listFollows(arg.UserID, false, arg.CursorCreatedAt, arg.CursorID, arg.PageSize), nil
+}
+
+func (s *Store) ListFollowing(ctx context.Context, arg database.ListFollowingParams) ([]database.Follow, error) {
+	return s.listFollows(arg.UserID, true, arg.CursorCreatedAt, arg.CursorID, arg.PageSize), nil
+}
+
+// listFollows pages backwards through the edges into user, or out of it
+// when outgoing is set, keyed by (created_at, other user's id).
+func (s *Store) listFollows(user uuid.UUID, outgoing bool, cursorAt time.Time, cursorID uuid.UUID, limit int32) []database.Follow {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	other := func(f database.Follow) uuid.UUID {
+		if outgoing {
+			return f.FolloweeID
+		}
+		return f.FollowerID
+	}
+	var out []database.Follow
+	for key, at := range s.follows {
+		if (outgoing && key.follower != user) || (!outgoing && key.followee != user) {
+			continue
+		}
+		f := database.Follow{FollowerID: key.follower, FolloweeID: key.followee, CreatedAt: at}
+		if compareKeyset(f.CreatedAt, other(f), cursorAt, cursorID) < 0 {
+			out = append(out, f)
+		}
+	}
+	slices.SortFunc(out, func(a, b database.Follow) int {
+		return -compareKeyset(a.CreatedAt, other(a), b.CreatedAt, other(b))
+	})
+	if len(out) > int(limit) {
+		out = out[:limit]
+	}
+	return out
+}
+
+func (s *Store) ListTimeline(ctx context.Context, arg database.ListTimelineParams) ([]database.Chirp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return keysetPage(s.chirps, func(c database.Chirp) bool {
+		_, ok := s.follows[follow{arg.UserID, c.UserID}]
+		return ok
+	}, arg.CursorCreatedAt, arg.CursorID, arg.PageSize, true), nil
+}
+
+// likes
+
+func (s *Store) LikeChirp(ctx context.Context, arg database.LikeChirpParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.UserID]; !ok {
+		return 0, ErrUnknownUser
+	}
+	if _, ok := s.chirps[arg.ChirpID]; !ok {
+		return 0, ErrUnknownChirp
+	}
+	key := like{arg.UserID, arg.ChirpID}
+	if _, ok := s.likes[key]; ok {
+		return 0, nil
+	}
+	s.likes[key] = s.now()
+	return 1, nil
+}
+
+func (s *Store) UnlikeChirp(ctx context.Context, arg database.UnlikeChirpParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := like{arg.UserID, arg.ChirpID}
+	if _, ok := s.likes[key]; !ok {
+		return 0, nil
+	}
+	delete(s.likes, key)
+	return 1, nil
+}
+
+func (s *Store) CountLikes(ctx context.Context, chirpIds []uuid.UUID) ([]database.CountLikesRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[uuid.UUID]int64)
+	for key := range s.likes {
+		if slices.Contains(chirpIds, key.chirp) {
+			counts[key.chirp]++
+		}
+	}
+	out := make([]database.CountLikesRow, 0, len(counts))
+	for id, n := range counts {
+		out = append(out, database.CountLikesRow{ChirpID: id, LikeCount: n})
+	}
+	return out, nil
+}
+
+func (s *Store) ListLikedChirpIDs(ctx context.Context, arg database.ListLikedChirpIDsParams) ([]uuid.UUID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []uuid.UUID
+	for _, id := range arg.ChirpIds {
+		if _, ok := s.likes[like{arg.UserID, id}]; ok {
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
+
+func (s *Store) ListLikes(ctx context.Context, arg database.ListLikesParams) ([]database.ListLikesRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []database.ListLikesRow
+	for key, at := range s.likes {
+		if key.user != arg.UserID || s.chirps[key.chirp].HiddenAt.Valid {
+			continue
+		}
+		if compareKeyset(at, key.chirp, arg.CursorCreatedAt, arg.CursorID) < 0 {
+			out = append(out, database.ListLikesRow{Chirp: s.chirps[key.chirp], LikedAt: at})
+		}
+	}
+	slices.SortFunc(out, func(a, b database.ListLikesRow) int {
+		return -compareKeyset(a.LikedAt, a.Chirp.ID, b.LikedAt, b.Chirp.ID)
+	})
+	if len(out) > int(arg.PageSize) {
+		out = out[:arg.PageSize]
+	}
+	return out, nil
+}
+
+// scheduled chirps
+
+func (s *Store) CreateScheduledChirp(ctx context.Context, arg database.CreateScheduledChirpParams) (database.ScheduledChirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.ScheduledChirp{}, ErrUnknownUser
+	}
+	if arg.InReplyTo.Valid {
+		if _, ok := s.chirps[arg.InReplyTo.UUID]; !ok {
+			return database.ScheduledChirp{}, ErrUnknownChirp
+		}
+	}
+	c := database.ScheduledChirp{
+		ID:        uuid.New(),
+		UserID:    arg.UserID,
+		Body:      arg.Body,
+		InReplyTo: arg.InReplyTo,
+		PublishAt: arg.PublishAt,
+		CreatedAt: s.now(),
+	}
+	s.scheduled[c.ID] = c
+	return c, nil
+}
+
+func (s *Store) ListScheduledChirps(ctx context.Context, userID uuid.UUID) ([]database.ScheduledChirp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []database.ScheduledChirp
+	for _, c := range s.scheduled {
+		if c.UserID == userID {
+			out = append(out, c)
+		}
+	}
+	slices.SortFunc(out, func(a, b database.ScheduledChirp) int {
+		return compareKeyset(a.PublishAt, a.ID, b.PublishAt, b.ID)
+	})
+	return out, nil
+}
+
+func (s *Store) DeleteScheduledChirp(ctx context.Context, arg database.DeleteScheduledChirpParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.scheduled[arg.ID]
+	if !ok || c.UserID != arg.UserID {
+		return 0, nil
+	}
+	delete(s.scheduled, arg.ID)
+	return 1, nil
+}
+
+func (s *Store) ClaimDueScheduledChirps(ctx context.Context, now time.Time) ([]database.ScheduledChirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []database.ScheduledChirp
+	for id, c := range s.scheduled {
+		// Left until the author's suspension ends.
+		author := s.users[c.UserID]
+		suspended := author.SuspendedAt.Valid && (!author.SuspendedUntil.Valid || author.SuspendedUntil.Time.After(now))
+		if !c.PublishAt.After(now) && !suspended {
+			out = append(out, c)
+			delete(s.scheduled, id)
+		}
+	}
+	return out, nil
+}
+
+// banned words
+
+// ErrInvalidAction mirrors the check constraint on banned_words.action.
+var ErrInvalidAction = errors.New("new row violates check constraint banned_words_action")
+
+func (s *Store) ListBannedWords(ctx context.Context) ([]database.BannedWord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]database.BannedWord, 0, len(s.bannedWords))
+	for _, w := range s.bannedWords {
+		out = append(out, w)
+	}
+	slices.SortFunc(out, func(a, b database.BannedWord) int { return cmp.Compare(a.Word, b.Word) })
+	return out, nil
+}
+
+func (s *Store) UpsertBannedWord(ctx context.Context, arg database.UpsertBannedWordParams) (database.BannedWord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.upsertBannedWord(arg.Word, arg.Action); err != nil {
+		return database.BannedWord{}, err
+	}
+	return s.bannedWords[arg.Word], nil
+}
+
+func (s *Store) ImportBannedWords(ctx context.Context, arg database.ImportBannedWordsParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(arg.Actions) != len(arg.Words) {
+		return 0, errors.New("words and actions differ in length")
+	}
+	for _, a := range arg.Actions {
+		if !validAction(a) {
+			return 0, ErrInvalidAction
+		}
+	}
+	for i, w := range arg.Words {
+		s.upsertBannedWord(w, arg.Actions[i])
+	}
+	return int64(len(arg.Words)), nil
+}
+
+func (s *Store) upsertBannedWord(word, action string) error {
+	if !validAction(action) {
+		return ErrInvalidAction
+	}
+	w, ok := s.bannedWords[word]
+	if !ok {
+		w = database.BannedWord{Word: word, CreatedAt: s.now()}
+	}
+	w.Action = action
+	s.bannedWords[word] = w
+	return nil
+}
+
+func validAction(a string) bool {
+	return a == "mask" || a == "flag" || a == "reject"
+}
+
+func (s *Store) DeleteBannedWord(ctx context.Context, word string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.bannedWords[word]; !ok {
+		return 0, nil
+	}
+	delete(s.bannedWords, word)
+	return 1, nil
+}
+
+// webhook events
+
+// ErrInvalidStatus mirrors the check constraint on webhook_events.status.
+var ErrInvalidStatus = errors.New("new row violates check constraint webhook_events_status")
+
+func (s *Store) RecordWebhookEvent(ctx context.Context, arg database.RecordWebhookEventParams) (database.WebhookEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if arg.EventID.Valid {
+		for id, e := range s.webhookEvents {
+			if e.Source == arg.Source && e.EventID == arg.EventID {
+				e.Deliveries++
+				e.LastReceivedAt = now
+				s.webhookEvents[id] = e
+				return e, nil
+			}
+		}
+	}
+	e := database.WebhookEvent{
+		ID:             uuid.New(),
+		Source:         arg.Source,
+		EventID:        arg.EventID,
+		Event:          arg.Event,
+		UserID:         arg.UserID,
+		Payload:        arg.Payload,
+		Status:         "received",
+		Deliveries:     1,
+		ReceivedAt:     now,
+		LastReceivedAt: now,
+	}
+	s.webhookEvents[e.ID] = e
+	return e, nil
+}
+
+func (s *Store) SetWebhookEventStatus(ctx context.Context, arg database.SetWebhookEventStatusParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.webhookEvents[arg.ID]
+	if !ok {
+		return nil
+	}
+	switch arg.Status {
+	case "received", "processed", "ignored", "failed":
+	default:
+		return ErrInvalidStatus
+	}
+	e.Status = arg.Status
+	e.Error = arg.Error
+	s.webhookEvents[arg.ID] = e
+	return nil
+}
+
+func (s *Store) ListWebhookEvents(ctx context.Context, arg database.ListWebhookEventsParams) ([]database.WebhookEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []database.WebhookEvent
+	for _, e := range s.webhookEvents {
+		if arg.UserID.Valid && e.UserID != arg.UserID {
+			continue
+		}
+		if compareKeyset(e.ReceivedAt, e.ID, arg.CursorReceivedAt, arg.CursorID) < 0 {
+			out = append(out, e)
+		}
+	}
+	slices.SortFunc(out, func(a, b database.WebhookEvent) int {
+		return compareKeyset(b.ReceivedAt, b.ID, a.ReceivedAt, a.ID)
+	})
+	if len(out) > int(arg.PageSize) {
+		out = out[:arg.PageSize]
+	}
+	return out, nil
+}
+
+// outgoing webhooks
+
+// ErrInvalidDeliveryStatus mirrors the check constraint on
+// webhook_deliveries.status.
+var ErrInvalidDeliveryStatus = errors.New("new row violates check constraint webhook_deliveries_status")
+
+func (s *Store) CreateWebhookSubscription(ctx context.Context, arg database.CreateWebhookSubscriptionParams) (database.WebhookSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if arg.UserID.Valid {
+		if _, ok := s.users[arg.UserID.UUID]; !ok {
+			return database.WebhookSubscription{}, ErrUnknownUser
+		}
+	}
+	sub := database.WebhookSubscription{
+		ID:        uuid.New(),
+		UserID:    arg.UserID,
+		Url:       arg.Url,
+		Secret:    arg.Secret,
+		Events:    arg.Events,
+		CreatedAt: s.now(),
+	}
+	s.webhookSubs[sub.ID] = sub
+	return sub, nil
+}
+
+func (s *Store) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (database.WebhookSubscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sub, ok := s.webhookSubs[id]
+	if !ok {
+		return database.WebhookSubscription{}, sql.ErrNoRows
+	}
+	return sub, nil
+}
+
+func (s *Store) ListWebhookSubscriptions(ctx context.Context, userID uuid.NullUUID) ([]database.WebhookSubscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []database.WebhookSubscription
+	for _, sub := range s.webhookSubs {
+		if sub.UserID == userID {
+			out = append(out, sub)
+		}
+	}
+	slices.SortFunc(out, func(a, b database.WebhookSubscription) int {
+		return compareKeyset(a.CreatedAt, a.ID, b.CreatedAt, b.ID)
+	})
+	return out, nil
+}
+
+func (s *Store) DeleteWebhookSubscription(ctx context.Context, arg database.DeleteWebhookSubscriptionParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.webhookSubs[arg.ID]
+	if !ok || sub.UserID != arg.UserID {
+		return 0, nil
+	}
+	s.deleteWebhookSubscription(arg.ID)
+	return 1, nil
+}
+
+func (s *Store) deleteWebhookSubscription(id uuid.UUID) {
+	delete(s.webhookSubs, id)
+	for did, d := range s.deliveries {
+		if d.SubscriptionID == id {
+			delete(s.deliveries, did)
+		}
+	}
+}
+
+func (s *Store) EnqueueWebhookEvent(ctx context.Context, arg database.EnqueueWebhookEventParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var n int64
+	for _, sub := range s.webhookSubs {
+		if !slices.Contains(strings.Fields(sub.Events), arg.Event) {
+			continue
+		}
+		if arg.UserID.Valid && sub.UserID.Valid && sub.UserID != arg.UserID {
+			continue
+		}
+		d := database.WebhookDelivery{
+			ID:             uuid.New(),
+			SubscriptionID: sub.ID,
+			Event:          arg.Event,
+			Payload:        arg.Payload,
+			Status:         "pending",
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+		s.deliveries[d.ID] = d
+		n++
+	}
+	return n, nil
+}
+
+func (s *Store) ClaimWebhookDeliveries(ctx context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []database.WebhookDelivery
+	for _, d := range s.deliveries {
+		if d.Status == "pending" && !d.NextAttemptAt.After(arg.Now) {
+			due = append(due, d)
+		}
+	}
+	slices.SortFunc(due, func(a, b database.WebhookDelivery) int {
+		return a.NextAttemptAt.Compare(b.NextAttemptAt)
+	})
+	if len(due) > int(arg.MaxDeliveries) {
+		due = due[:arg.MaxDeliveries]
+	}
+	for i, d := range due {
+		d.NextAttemptAt = arg.LeaseUntil
+		s.deliveries[d.ID] = d
+		due[i] = d
+	}
+	return due, nil
+}
+
+func (s *Store) FinishWebhookAttempt(ctx context.Context, arg database.FinishWebhookAttemptParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.deliveries[arg.ID]
+	if !ok {
+		return nil
+	}
+	switch arg.Status {
+	case "pending", "delivered", "failed":
+	default:
+		return ErrInvalidDeliveryStatus
+	}
+	d.Status = arg.Status
+	d.Attempts++
+	d.NextAttemptAt = arg.NextAttemptAt
+	d.LastError = arg.LastError
+	d.ResponseStatus = arg.ResponseStatus
+	d.DeliveredAt = sql.NullTime{}
+	if arg.Status == "delivered" {
+		d.DeliveredAt = sql.NullTime{Time: s.now(), Valid: true}
+	}
+	s.deliveries[arg.ID] = d
+	return nil
+}
+
+func (s *Store) ListWebhookDeliveries(ctx context.Context, arg database.ListWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []database.WebhookDelivery
+	for _, d := range s.deliveries {
+		if d.SubscriptionID == arg.SubscriptionID && compareKeyset(d.CreatedAt, d.ID, arg.CursorCreatedAt, arg.CursorID) < 0 {
+			out = append(out, d)
+		}
+	}
+	slices.SortFunc(out, func(a, b database.WebhookDelivery) int {
+		return compareKeyset(b.CreatedAt, b.ID, a.CreatedAt, a.ID)
+	})
+	if len(out) > int(arg.PageSize) {
+		out = out[:arg.PageSize]
+	}
+	return out, nil
+}
+
+// notifications
+
+// ErrInvalidKind mirrors the check constraint on notifications.kind.
+var ErrInvalidKind = errors.New("new row violates check constraint notifications_kind")
+
+func (s *Store) CreateNotification(ctx context.Context, arg database.CreateNotificationParams) (database.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.Notification{}, ErrUnknownUser
+	}
+	if _, ok := s.users[arg.ActorID]; !ok {
+		return database.Notification{}, ErrUnknownUser
+	}
+	if arg.ChirpID.Valid {
+		if _, ok := s.chirps[arg.ChirpID.UUID]; !ok {
+			return database.Notification{}, ErrUnknownChirp
+		}
+	}
+	wanted := true
+	p, ok := s.notifyPrefs[arg.UserID]
+	switch arg.Kind {
+	case "mention":
+		wanted = !ok || p.Mentions
+	case "reply":
+		wanted = !ok || p.Replies
+	case "like":
+		wanted = !ok || p.Likes
+	case "follow":
+		wanted = !ok || p.Follows
+	default:
+		return database.Notification{}, ErrInvalidKind
+	}
+	if !wanted {
+		return database.Notification{}, sql.ErrNoRows
+	}
+	for _, n := range s.notifications {
+		if n.UserID == arg.UserID && n.ActorID == arg.ActorID && n.Kind == arg.Kind && n.ChirpID == arg.ChirpID {
+			return database.Notification{}, sql.ErrNoRows
+		}
+	}
+
+	n := database.Notification{
+		ID:        uuid.New(),
+		UserID:    arg.UserID,
+		ActorID:   arg.ActorID,
+		Kind:      arg.Kind,
+		ChirpID:   arg.ChirpID,
+		CreatedAt: s.now(),
+	}
+	s.notifications[n.ID] = n
+	return n, nil
+}
+
+func (s *Store) ListNotifications(ctx context.Context, arg database.ListNotificationsParams) ([]database.Notification, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []database.Notification
+	for _, n := range s.notifications {
+		if n.UserID != arg.UserID || (arg.UnreadOnly && n.ReadAt.Valid) {
+			continue
+		}
+		if compareKeyset(n.CreatedAt, n.ID, arg.CursorCreatedAt, arg.CursorID) < 0 {
+			out = append(out, n)
+		}
+	}
+	slices.SortFunc(out, func(a, b database.Notification) int {
+		return compareKeyset(b.CreatedAt, b.ID, a.CreatedAt, a.ID)
+	})
+	if len(out) > int(arg.PageSize) {
+		out = out[:arg.PageSize]
+	}
+	return out, nil
+}
+
+func (s *Store) MarkNotificationsRead(ctx context.Context, arg database.MarkNotificationsReadParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.markRead(arg.UserID, func(id uuid.UUID) bool { return slices.Contains(arg.Ids, id) }), nil
+}
+
+func (s *Store) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.markRead(userID, func(uuid.UUID) bool { return true }), nil
+}
+
+func (s *Store) markRead(userID uuid.UUID, match func(uuid.UUID) bool) int64 {
+	var n int64
+	now := sql.NullTime{Time: s.now(), Valid: true}
+	for id, note := range s.notifications {
+		if note.UserID == userID && !note.ReadAt.Valid && match(id) {
+			note.ReadAt = now
+			s.notifications[id] = note
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Store) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (database.NotificationPreference, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.notifyPrefs[userID]
+	if !ok {
+		return database.NotificationPreference{}, sql.ErrNoRows
+	}
+	return p, nil
+}
+
+func (s *Store) SetNotificationPreferences(ctx context.Context, arg database.SetNotificationPreferencesParams) (database.NotificationPreference, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.NotificationPreference{}, ErrUnknownUser
+	}
+	p := database.NotificationPreference{
+		UserID:    arg.UserID,
+		Mentions:  arg.Mentions,
+		Replies:   arg.Replies,
+		Likes:     arg.Likes,
+		Follows:   arg.Follows,
+		UpdatedAt: s.now(),
+	}
+	s.notifyPrefs[arg.UserID] = p
+	return p, nil
+}
+
+// moderation
+
+// ErrInvalidModerationAction mirrors the check constraint on
+// moderation_actions.action.
+var ErrInvalidModerationAction = errors.New("new row violates check constraint moderation_actions_action")
+
+func (s *Store) CreateChirpReport(ctx context.Context, arg database.CreateChirpReportParams) (database.ChirpReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.ReporterID.UUID]; arg.ReporterID.Valid && !ok {
+		return database.ChirpReport{}, ErrUnknownUser
+	}
+	if _, ok := s.chirps[arg.ChirpID]; !ok {
+		return database.ChirpReport{}, ErrUnknownChirp
+	}
+	for _, r := range s.reports {
+		// Like the unique constraint, reports without a reporter never clash.
+		if r.ChirpID == arg.ChirpID && r.ReporterID.Valid && r.ReporterID == arg.ReporterID {
+			return database.ChirpReport{}, sql.ErrNoRows
+		}
+	}
+	report := database.ChirpReport{
+		ID:         uuid.New(),
+		ChirpID:    arg.ChirpID,
+		ReporterID: arg.ReporterID,
+		Reason:     arg.Reason,
+		CreatedAt:  s.now(),
+	}
+	s.reports[report.ID] = report
+	return report, nil
+}
+
+func (s *Store) CreateChirpFlag(ctx context.Context, arg database.CreateChirpFlagParams) (database.ChirpReport, error) {
+	return s.CreateChirpReport(ctx, database.CreateChirpReportParams{ChirpID: arg.ChirpID, Reason: arg.Reason})
+}
+
+func (s *Store) ListModerationQueue(ctx context.Context, arg database.ListModerationQueueParams) ([]database.ListModerationQueueRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	queue := make(map[uuid.UUID]database.ListModerationQueueRow)
+	for _, r := range s.reports {
+		if r.ResolvedAt.Valid {
+			continue
+		}
+		row, ok := queue[r.ChirpID]
+		if !ok || r.CreatedAt.Before(row.FirstReportedAt) {
+			row.FirstReportedAt = r.CreatedAt
+		}
+		row.Chirp = s.chirps[r.ChirpID]
+		row.OpenReports++
+		queue[r.ChirpID] = row
+	}
+	var out []database.ListModerationQueueRow
+	for _, row := range queue {
+		if compareKeyset(row.FirstReportedAt, row.Chirp.ID, arg.CursorCreatedAt, arg.CursorID) > 0 {
+			out = append(out, row)
+		}
+	}
+	slices.SortFunc(out, func(a, b database.ListModerationQueueRow) int {
+		return compareKeyset(a.FirstReportedAt, a.Chirp.ID, b.FirstReportedAt, b.Chirp.ID)
+	})
+	if len(out) > int(arg.PageSize) {
+		out = out[:arg.PageSize]
+	}
+	return out, nil
+}
+
+func (s *Store) ListOpenChirpReports(ctx context.Context, chirpIds []uuid.UUID) ([]database.ChirpReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []database.ChirpReport
+	for _, r := range s.reports {
+		if !r.ResolvedAt.Valid && slices.Contains(chirpIds, r.ChirpID) {
+			out = append(out, r)
+		}
+	}
+	slices.SortFunc(out, func(a, b database.ChirpReport) int {
+		if c := slices.Compare(a.ChirpID[:], b.ChirpID[:]); c != 0 {
+			return c
+		}
+		return compareKeyset(a.CreatedAt, a.ID, b.CreatedAt, b.ID)
+	})
+	return out, nil
+}
+
+func (s *Store) ModerateChirp(ctx context.Context, arg database.ModerateChirpParams) (database.ModerationAction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chirp, ok := s.chirps[arg.ChirpID]
+	if !ok {
+		return database.ModerationAction{}, sql.ErrNoRows
+	}
+	now := s.now()
+	switch arg.Action {
+	case "hide":
+		if !chirp.HiddenAt.Valid {
+			chirp.HiddenAt = sql.NullTime{Time: now, Valid: true}
+		}
+	case "restore":
+		chirp.HiddenAt = sql.NullTime{}
+	case "dismiss":
+	default:
+		return database.ModerationAction{}, ErrInvalidModerationAction
+	}
+	s.chirps[chirp.ID] = chirp
+	for id, r := range s.reports {
+		if r.ChirpID == chirp.ID && !r.ResolvedAt.Valid {
+			r.ResolvedAt = sql.NullTime{Time: now, Valid: true}
+			s.reports[id] = r
+		}
+	}
+	return s.recordModerationAction(chirp, arg.ModeratorID, arg.Action, arg.Reason), nil
+}
+
+func (s *Store) RemoveChirp(ctx context.Context, arg database.RemoveChirpParams) (database.ModerationAction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chirp, ok := s.chirps[arg.ChirpID]
+	if !ok {
+		return database.ModerationAction{}, sql.ErrNoRows
+	}
+	s.deleteChirp(chirp.ID)
+	return s.recordModerationAction(chirp, arg.ModeratorID, "remove", arg.Reason), nil
+}
+
+func (s *Store) recordModerationAction(chirp database.Chirp, moderator uuid.NullUUID, action, reason string) database.ModerationAction {
+	a := database.ModerationAction{
+		ID:          uuid.New(),
+		ChirpID:     chirp.ID,
+		ChirpUserID: chirp.UserID,
+		ModeratorID: moderator,
+		Action:      action,
+		Reason:      reason,
+		CreatedAt:   s.now(),
+	}
+	s.modActions[a.ID] = a
+	return a
+}
+
+func (s *Store) ListModerationActions(ctx context.Context, arg database.ListModerationActionsParams) ([]database.ModerationAction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []database.ModerationAction
+	for _, a := range s.modActions {
+		if arg.ChirpID.Valid && a.ChirpID != arg.ChirpID.UUID {
+			continue
+		}
+		if compareKeyset(a.CreatedAt, a.ID, arg.CursorCreatedAt, arg.CursorID) < 0 {
+			out = append(out, a)
+		}
+	}
+	slices.SortFunc(out, func(a, b database.ModerationAction) int {
+		return -compareKeyset(a.CreatedAt, a.ID, b.CreatedAt, b.ID)
+	})
+	if len(out) > int(arg.PageSize) {
+		out = out[:arg.PageSize]
+	}
+	return out, nil
+}
+
+// refresh tokens
+
+func (s *Store) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.RefreshToken{}, ErrUnknownUser
+	}
+	if _, ok := s.refreshTokens[arg.Token]; ok {
+		return database.RefreshToken{}, errors.New("duplicate key value violates unique constraint on refresh_tokens.token")
+	}
+	now := s.now()
+	token := database.RefreshToken{
+		Token:     arg.Token,
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+	}
+	s.refreshTokens[token.Token] = token
+	return token, nil
+}
+
+func (s *Store) GetUserFromRefreshToken(ctx context.Context, token string) (database.GetUserFromRefreshTokenRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rt, ok := s.refreshTokens[token]
+	if !ok || rt.RevokedAt.Valid || !rt.ExpiresAt.After(s.now()) {
+		return database.GetUserFromRefreshTokenRow{}, sql.ErrNoRows
+	}
+	user, ok := s.users[rt.UserID]
+	if !ok {
+		return database.GetUserFromRefreshTokenRow{}, sql.ErrNoRows
+	}
+	return database.GetUserFromRefreshTokenRow{
+		ID:               user.ID,
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
+		Email:            user.Email,
+		HashedPassword:   user.HashedPassword,
+		Role:             user.Role,
+		SuspendedAt:      user.SuspendedAt,
+		SuspendedUntil:   user.SuspendedUntil,
+		SuspensionReason: user.SuspensionReason,
+	}, nil
+}
+
+func (s *Store) RevokeRefreshToken(ctx context.Context, token string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rt, ok := s.refreshTokens[token]
+	if !ok || rt.RevokedAt.Valid {
+		return 0, nil
+	}
+	now := s.now()
+	rt.RevokedAt = sql.NullTime{Time: now, Valid: true}
+	rt.UpdatedAt = now
+	s.refreshTokens[token] = rt
+	return 1, nil
+}
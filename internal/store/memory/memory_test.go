@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+)
+
+func TestUniqueEmail(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	walt, err := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"}); !errors.Is(err, ErrDuplicateEmail) {
+		t.Errorf("want ErrDuplicateEmail, got %v", err)
+	}
+
+	jesse, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "jesse@breakingbad.com"})
+	_, err = s.UpdateEmailAndPW(ctx, database.UpdateEmailAndPWParams{ID: jesse.ID, Email: walt.Email})
+	if !errors.Is(err, ErrDuplicateEmail) {
+		t.Errorf("update to taken email: want ErrDuplicateEmail, got %v", err)
+	}
+	if _, err := s.LookUpUserByEmail(ctx, "nobody@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("unknown email: want sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestRefreshTokenLifecycle(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	user, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+
+	_, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     "live",
+		ExpiresAt: time.Now().Add(time.Hour),
+		UserID:    user.ID,
+	})
+	if err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+	s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     "expired",
+		ExpiresAt: time.Now().Add(-time.Hour),
+		UserID:    user.ID,
+	})
+
+	if got, err := s.GetUserFromRefreshToken(ctx, "live"); err != nil || got.ID != user.ID {
+		t.Errorf("live token: got %v, %v", got.ID, err)
+	}
+	if _, err := s.GetUserFromRefreshToken(ctx, "expired"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expired token: want sql.ErrNoRows, got %v", err)
+	}
+
+	if n, _ := s.RevokeRefreshToken(ctx, "live"); n != 1 {
+		t.Errorf("first revoke: want 1 row, got %d", n)
+	}
+	if n, _ := s.RevokeRefreshToken(ctx, "live"); n != 0 {
+		t.Errorf("second revoke: want 0 rows, got %d", n)
+	}
+	if _, err := s.GetUserFromRefreshToken(ctx, "live"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("revoked token: want sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestResetCascades(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	user, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+	chirp, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "say my name", UserID: user.ID})
+
+	if err := s.Reset(ctx); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if _, err := s.GetChirp(ctx, chirp.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("chirp survived reset: %v", err)
+	}
+	if _, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: user.ID}); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("chirp for deleted user: want ErrUnknownUser, got %v", err)
+	}
+}
@@ -0,0 +1,1196 @@
+// Package sqlite implements store.Store on top of the sqlc generated
+// sqlitedb queries.
+//
+// SQLite has no gen_random_uuid() or NOW(), so IDs and timestamps are
+// produced here and passed to the queries. All timestamps are UTC: they are
+// stored as text and must compare in chronological order.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/Geraetefreund/chirpy/internal/dbmetrics"
+	"github.com/Geraetefreund/chirpy/internal/search"
+	"github.com/Geraetefreund/chirpy/internal/sqlitedb"
+	"github.com/Geraetefreund/chirpy/internal/store"
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+type Store struct {
+	db      *sql.DB
+	q       *sqlitedb.Queries
+	now     func() time.Time
+	observe dbmetrics.Observer
+}
+
+var _ store.Store = (*Store)(nil)
+
+func New(db *sql.DB) *Store {
+	return NewObserved(db, nil)
+}
+
+// NewObserved is New with every query, including those run in
+// transactions, timed by observe. A nil observe times nothing.
+func NewObserved(db *sql.DB, observe dbmetrics.Observer) *Store {
+	s := &Store{
+		db:      db,
+		now:     func() time.Time { return time.Now().UTC() },
+		observe: observe,
+	}
+	s.q = s.queries(db)
+	return s
+}
+
+// queries returns the queries run on db, timed if the store is observed.
+func (s *Store) queries(db sqlitedb.DBTX) *sqlitedb.Queries {
+	if s.observe != nil {
+		db = dbmetrics.Wrap(db, s.observe)
+	}
+	return sqlitedb.New(db)
+}
+
+// Open opens the database file named by a sqlite:// URL, e.g.
+// sqlite://chirpy.db or sqlite:///var/lib/chirpy/chirpy.db, with foreign keys
+// enforced.
+func Open(dbURL string) (*sql.DB, error) {
+	path := strings.TrimPrefix(dbURL, "sqlite://")
+	q := url.Values{}
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Add("_pragma", "busy_timeout(5000)")
+	q.Add("_pragma", "journal_mode(WAL)")
+	q.Set("_time_format", "sqlite")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; serialising connections avoids
+	// SQLITE_BUSY under concurrent requests.
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// withTx runs fn inside a transaction, committing if it returns nil.
+func (s *Store) withTx(ctx context.Context, fn func(q *sqlitedb.Queries) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(s.queries(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) Reset(ctx context.Context) error {
+	return s.q.Reset(ctx)
+}
+
+// users
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	now := s.now()
+	user, err := s.q.CreateUser(ctx, sqlitedb.CreateUserParams{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+	})
+	return database.User(user), err
+}
+
+func (s *Store) GetUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, err := s.q.GetUser(ctx, id)
+	return database.User(user), err
+}
+
+func (s *Store) LookUpUserByEmail(ctx context.Context, email string) (database.User, error) {
+	user, err := s.q.LookUpUserByEmail(ctx, email)
+	return database.User(user), err
+}
+
+func (s *Store) UpdateEmailAndPW(ctx context.Context, arg database.UpdateEmailAndPWParams) (database.User, error) {
+	user, err := s.q.UpdateEmailAndPW(ctx, sqlitedb.UpdateEmailAndPWParams{
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		UpdatedAt:      s.now(),
+		ID:             arg.ID,
+	})
+	return database.User(user), err
+}
+
+func (s *Store) UpgradeChirpyPlus(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, err := s.q.UpgradeChirpyPlus(ctx, id)
+	return database.User(user), err
+}
+
+func (s *Store) DowngradeChirpyPlus(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, err := s.q.DowngradeChirpyPlus(ctx, id)
+	return database.User(user), err
+}
+
+func (s *Store) SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (database.User, error) {
+	user, err := s.q.SetUserRole(ctx, sqlitedb.SetUserRoleParams{
+		Role:      arg.Role,
+		UpdatedAt: s.now(),
+		ID:        arg.ID,
+	})
+	return database.User(user), err
+}
+
+// SuspendUser does in a transaction what the Postgres query does in one
+// statement.
+func (s *Store) SuspendUser(ctx context.Context, arg database.SuspendUserParams) (database.User, error) {
+	now := sql.NullTime{Time: s.now(), Valid: true}
+	var user sqlitedb.User
+	err := s.withTx(ctx, func(q *sqlitedb.Queries) error {
+		err := q.RevokeUserRefreshTokens(ctx, sqlitedb.RevokeUserRefreshTokensParams{Now: now, UserID: arg.ID})
+		if err != nil {
+			return err
+		}
+		until := arg.SuspendedUntil
+		if until.Valid {
+			until.Time = until.Time.UTC()
+		}
+		user, err = q.SuspendUser(ctx, sqlitedb.SuspendUserParams{
+			Now:              now,
+			SuspendedUntil:   until,
+			SuspensionReason: arg.SuspensionReason,
+			ID:               arg.ID,
+		})
+		return err
+	})
+	return database.User(user), err
+}
+
+func (s *Store) UnsuspendUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, err := s.q.UnsuspendUser(ctx, sqlitedb.UnsuspendUserParams{UpdatedAt: s.now(), ID: id})
+	return database.User(user), err
+}
+
+// chirps
+
+func (s *Store) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	now := s.now()
+	var chirp sqlitedb.Chirp
+	err := s.withTx(ctx, func(q *sqlitedb.Queries) error {
+		var err error
+		chirp, err = q.CreateChirp(ctx, sqlitedb.CreateChirpParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			Body:      arg.Body,
+			UserID:    arg.UserID,
+			InReplyTo: arg.InReplyTo,
+		})
+		if err != nil {
+			return err
+		}
+		return createEntities(ctx, q, chirp.ID, entities{
+			tags:           arg.Tags,
+			tagStarts:      arg.TagStarts,
+			tagEnds:        arg.TagEnds,
+			mentionUserIDs: arg.MentionUserIds,
+			mentionStarts:  arg.MentionStarts,
+			mentionEnds:    arg.MentionEnds,
+		})
+	})
+	return toChirp(chirp), err
+}
+
+func (s *Store) GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	chirp, err := s.q.GetChirp(ctx, id)
+	return toChirp(chirp), err
+}
+
+func (s *Store) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	return s.q.DeleteChirp(ctx, id)
+}
+
+func (s *Store) UpdateChirp(ctx context.Context, arg database.UpdateChirpParams) (database.Chirp, error) {
+	var chirp sqlitedb.Chirp
+	err := s.withTx(ctx, func(q *sqlitedb.Queries) error {
+		err := q.CreateChirpRevision(ctx, sqlitedb.CreateChirpRevisionParams{
+			ID:      uuid.New(),
+			ChirpID: arg.ID,
+		})
+		if err != nil {
+			return err
+		}
+		chirp, err = q.UpdateChirp(ctx, sqlitedb.UpdateChirpParams{
+			Body:      arg.Body,
+			UpdatedAt: s.now(),
+			ID:        arg.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if err := q.DeleteChirpHashtags(ctx, arg.ID); err != nil {
+			return err
+		}
+		if err := q.DeleteChirpMentions(ctx, arg.ID); err != nil {
+			return err
+		}
+		return createEntities(ctx, q, arg.ID, entities{
+			tags:           arg.Tags,
+			tagStarts:      arg.TagStarts,
+			tagEnds:        arg.TagEnds,
+			mentionUserIDs: arg.MentionUserIds,
+			mentionStarts:  arg.MentionStarts,
+			mentionEnds:    arg.MentionEnds,
+		})
+	})
+	return toChirp(chirp), err
+}
+
+// entities are the parallel hashtag and mention arrays of CreateChirp and
+// UpdateChirp.
+type entities struct {
+	tags                       []string
+	tagStarts, tagEnds         []int32
+	mentionUserIDs             []uuid.UUID
+	mentionStarts, mentionEnds []int32
+}
+
+func createEntities(ctx context.Context, q *sqlitedb.Queries, chirpID uuid.UUID, e entities) error {
+	if len(e.tagStarts) != len(e.tags) || len(e.tagEnds) != len(e.tags) ||
+		len(e.mentionStarts) != len(e.mentionUserIDs) || len(e.mentionEnds) != len(e.mentionUserIDs) {
+		return errors.New("entity arrays differ in length")
+	}
+	for i, tag := range e.tags {
+		err := q.CreateChirpHashtag(ctx, sqlitedb.CreateChirpHashtagParams{
+			ChirpID:     chirpID,
+			Tag:         tag,
+			StartOffset: int64(e.tagStarts[i]),
+			EndOffset:   int64(e.tagEnds[i]),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for i, userID := range e.mentionUserIDs {
+		err := q.CreateChirpMention(ctx, sqlitedb.CreateChirpMentionParams{
+			ChirpID:     chirpID,
+			UserID:      userID,
+			StartOffset: int64(e.mentionStarts[i]),
+			EndOffset:   int64(e.mentionEnds[i]),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) ListChirpRevisions(ctx context.Context, chirpID uuid.UUID) ([]database.ChirpRevision, error) {
+	rows, err := s.q.ListChirpRevisions(ctx, chirpID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.ChirpRevision, len(rows))
+	for i, r := range rows {
+		out[i] = database.ChirpRevision(r)
+	}
+	return out, nil
+}
+
+func (s *Store) ListChirps(ctx context.Context, arg database.ListChirpsParams) ([]database.Chirp, error) {
+	return chirps(s.q.ListChirps(ctx, sqlitedb.ListChirpsParams{
+		CursorCreatedAt: arg.CursorCreatedAt.UTC(),
+		CursorID:        arg.CursorID,
+		PageSize:        int64(arg.PageSize),
+	}))
+}
+
+func (s *Store) ListChirpsDesc(ctx context.Context, arg database.ListChirpsDescParams) ([]database.Chirp, error) {
+	return chirps(s.q.ListChirpsDesc(ctx, sqlitedb.ListChirpsDescParams{
+		CursorCreatedAt: arg.CursorCreatedAt.UTC(),
+		CursorID:        arg.CursorID,
+		PageSize:        int64(arg.PageSize),
+	}))
+}
+
+func (s *Store) ListChirpsByAuthor(ctx context.Context, arg database.ListChirpsByAuthorParams) ([]database.Chirp, error) {
+	return chirps(s.q.ListChirpsByAuthor(ctx, sqlitedb.ListChirpsByAuthorParams{
+		UserID:          arg.UserID,
+		CursorCreatedAt: arg.CursorCreatedAt.UTC(),
+		CursorID:        arg.CursorID,
+		PageSize:        int64(arg.PageSize),
+	}))
+}
+
+func (s *Store) ListChirpsByAuthorDesc(ctx context.Context, arg database.ListChirpsByAuthorDescParams) ([]database.Chirp, error) {
+	return chirps(s.q.ListChirpsByAuthorDesc(ctx, sqlitedb.ListChirpsByAuthorDescParams{
+		UserID:          arg.UserID,
+		CursorCreatedAt: arg.CursorCreatedAt.UTC(),
+		CursorID:        arg.CursorID,
+		PageSize:        int64(arg.PageSize),
+	}))
+}
+
+// ListChirpDescendants collects the subtree one level per query, for the
+// same reason ListChirpAncestors walks in Go, and pages through it here.
+func (s *Store) ListChirpDescendants(ctx context.Context, arg database.ListChirpDescendantsParams) ([]database.ListChirpDescendantsRow, error) {
+	var out []database.ListChirpDescendantsRow
+	parents := []uuid.NullUUID{{UUID: arg.ID, Valid: true}}
+	for depth := int32(1); depth <= arg.MaxDepth && len(parents) > 0; depth++ {
+		level, err := s.q.ListRepliesTo(ctx, parents)
+		if err != nil {
+			return nil, err
+		}
+		parents = parents[:0]
+		for _, c := range level {
+			parents = append(parents, uuid.NullUUID{UUID: c.ID, Valid: true})
+			after := c.CreatedAt.Compare(arg.CursorCreatedAt)
+			if after > 0 || after == 0 && slices.Compare(c.ID[:], arg.CursorID[:]) > 0 {
+				out = append(out, database.ListChirpDescendantsRow{Chirp: toChirp(c), Depth: depth})
+			}
+		}
+	}
+	slices.SortFunc(out, func(a, b database.ListChirpDescendantsRow) int {
+		if c := a.Chirp.CreatedAt.Compare(b.Chirp.CreatedAt); c != 0 {
+			return c
+		}
+		return slices.Compare(a.Chirp.ID[:], b.Chirp.ID[:])
+	})
+	if len(out) > int(arg.PageSize) {
+		out = out[:arg.PageSize]
+	}
+	return out, nil
+}
+
+// ListChirpAncestors follows in_reply_to one chirp at a time; sqlc cannot
+// compile recursive CTEs for SQLite, and local lookups by primary key are
+// cheap. Hidden ancestors are walked through but left out, as in Postgres.
+func (s *Store) ListChirpAncestors(ctx context.Context, arg database.ListChirpAncestorsParams) ([]database.Chirp, error) {
+	chirp, err := s.q.GetChirpIncludingHidden(ctx, arg.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []database.Chirp
+	for depth := 0; chirp.InReplyTo.Valid && depth < int(arg.MaxDepth); depth++ {
+		chirp, err = s.q.GetChirpIncludingHidden(ctx, chirp.InReplyTo.UUID)
+		if err != nil {
+			return nil, err
+		}
+		if !chirp.HiddenAt.Valid {
+			out = append(out, toChirp(chirp))
+		}
+	}
+	return out, nil
+}
+
+func (s *Store) CountReplies(ctx context.Context, chirpIds []uuid.UUID) ([]database.CountRepliesRow, error) {
+	if len(chirpIds) == 0 {
+		return nil, nil
+	}
+	ids := make([]uuid.NullUUID, len(chirpIds))
+	for i, id := range chirpIds {
+		ids[i] = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	rows, err := s.q.CountReplies(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.CountRepliesRow, len(rows))
+	for i, r := range rows {
+		out[i] = database.CountRepliesRow{ChirpID: r.ChirpID.UUID, ReplyCount: r.ReplyCount}
+	}
+	return out, nil
+}
+
+// toChirp converts a sqlite row to the shared model. The Postgres only
+// search_vector column is left empty.
+func toChirp(c sqlitedb.Chirp) database.Chirp {
+	return database.Chirp{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Body:      c.Body,
+		UserID:    c.UserID,
+		InReplyTo: c.InReplyTo,
+		HiddenAt:  c.HiddenAt,
+	}
+}
+
+// chirps converts a page of sqlite rows to the shared model.
+func chirps(rows []sqlitedb.Chirp, err error) ([]database.Chirp, error) {
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.Chirp, len(rows))
+	for i, c := range rows {
+		out[i] = toChirp(c)
+	}
+	return out, nil
+}
+
+func (s *Store) ListChirpHashtags(ctx context.Context, chirpIds []uuid.UUID) ([]database.ChirpHashtag, error) {
+	if len(chirpIds) == 0 {
+		return nil, nil
+	}
+	rows, err := s.q.ListChirpHashtags(ctx, chirpIds)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.ChirpHashtag, len(rows))
+	for i, h := range rows {
+		out[i] = database.ChirpHashtag{
+			ChirpID:     h.ChirpID,
+			Tag:         h.Tag,
+			StartOffset: int32(h.StartOffset),
+			EndOffset:   int32(h.EndOffset),
+		}
+	}
+	return out, nil
+}
+
+func (s *Store) ListChirpMentions(ctx context.Context, chirpIds []uuid.UUID) ([]database.ChirpMention, error) {
+	if len(chirpIds) == 0 {
+		return nil, nil
+	}
+	rows, err := s.q.ListChirpMentions(ctx, chirpIds)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.ChirpMention, len(rows))
+	for i, m := range rows {
+		out[i] = database.ChirpMention{
+			ChirpID:     m.ChirpID,
+			UserID:      m.UserID,
+			StartOffset: int32(m.StartOffset),
+			EndOffset:   int32(m.EndOffset),
+		}
+	}
+	return out, nil
+}
+
+func (s *Store) ListChirpsByHashtag(ctx context.Context, arg database.ListChirpsByHashtagParams) ([]database.Chirp, error) {
+	return chirps(s.q.ListChirpsByHashtag(ctx, sqlitedb.ListChirpsByHashtagParams{
+		Tag:             arg.Tag,
+		CursorCreatedAt: arg.CursorCreatedAt.UTC(),
+		CursorID:        arg.CursorID,
+		PageSize:        int64(arg.PageSize),
+	}))
+}
+
+func (s *Store) ListChirpsMentioning(ctx context.Context, arg database.ListChirpsMentioningParams) ([]database.Chirp, error) {
+	return chirps(s.q.ListChirpsMentioning(ctx, sqlitedb.ListChirpsMentioningParams{
+		UserID:          arg.UserID,
+		CursorCreatedAt: arg.CursorCreatedAt.UTC(),
+		CursorID:        arg.CursorID,
+		PageSize:        int64(arg.PageSize),
+	}))
+}
+
+// SearchChirps translates the tsquery to an FTS5 expression. Ranks come from
+// bm25() and are not comparable with the Postgres ones.
+func (s *Store) SearchChirps(ctx context.Context, arg database.SearchChirpsParams) ([]database.SearchChirpsRow, error) {
+	q, err := search.ParseTSQuery(arg.Query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.q.SearchChirps(ctx, sqlitedb.SearchChirpsParams{
+		Query:           q.FTS5(),
+		AuthorID:        arg.AuthorID,
+		CursorRelevance: arg.CursorRelevance,
+		CursorCreatedAt: arg.CursorCreatedAt.UTC(),
+		CursorID:        arg.CursorID,
+		PageSize:        int64(arg.PageSize),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.SearchChirpsRow, len(rows))
+	for i, r := range rows {
+		out[i] = database.SearchChirpsRow{Chirp: toChirp(r.Chirp), Relevance: r.Relevance}
+	}
+	return out, nil
+}
+
+func (s *Store) SearchChirpsRecent(ctx context.Context, arg database.SearchChirpsRecentParams) ([]database.Chirp, error) {
+	q, err := search.ParseTSQuery(arg.Query)
+	if err != nil {
+		return nil, err
+	}
+	return chirps(s.q.SearchChirpsRecent(ctx, sqlitedb.SearchChirpsRecentParams{
+		Query:           q.FTS5(),
+		AuthorID:        arg.AuthorID,
+		CursorCreatedAt: arg.CursorCreatedAt.UTC(),
+		CursorID:        arg.CursorID,
+		PageSize:        int64(arg.PageSize),
+	}))
+}
+
+// follows
+
+func (s *Store) FollowUser(ctx context.Context, arg database.FollowUserParams) (int64, error) {
+	return s.q.FollowUser(ctx, sqlitedb.FollowUserParams{
+		FollowerID: arg.FollowerID,
+		FolloweeID: arg.FolloweeID,
+		CreatedAt:  s.now(),
+	})
+}
+
+func (s *Store) UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) (int64, error) {
+	return s.q.UnfollowUser(ctx, sqlitedb.UnfollowUserParams(arg))
+}
+
+func (s *Store) ListFollowers(ctx context.Context, arg database.ListFollowersParams) ([]database.Follow, error) {
+	return follows(s.q.ListFollowers(ctx, sqlitedb.ListFollowersParams{
+		UserID:          arg.UserID,
+		CursorCreatedAt: arg.CursorCreatedAt.UTC(),
+		CursorID:        arg.CursorID,
+		PageSize:        int64(arg.PageSize),
+	}))
+}
+
+func (s *Store) ListFollowing(ctx context.Context, arg database.ListFollowingParams) ([]database.Follow, error) {
+	return follows(s.q.ListFollowing(ctx, sqlitedb.ListFollowingParams{
+		UserID:          arg.UserID,
+		CursorCreatedAt: arg.CursorCreatedAt.UTC(),
+		CursorID:        arg.CursorID,
+		PageSize:        int64(arg.PageSize),
+	}))
+}
+
+func (s *Store) ListTimeline(ctx context.Context, arg database.ListTimelineParams) ([]database.Chirp, error) {
+	return chirps(s.q.ListTimeline(ctx, sqlitedb.ListTimelineParams{
+		UserID:          arg.UserID,
+		CursorCreatedAt: arg.CursorCreatedAt.UTC(),
+		CursorID:        arg.CursorID,
+		PageSize:        int64(arg.PageSize),
+	}))
+}
+
+func follows(rows []sqlitedb.Follow, err error) ([]database.Follow, error) {
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.Follow, len(rows))
+	for i, f := range rows {
+		out[i] = database.Follow(f)
+	}
+	return out, nil
+}
+
+// likes
+
+func (s *Store) LikeChirp(ctx context.Context, arg database.LikeChirpParams) (int64, error) {
+	return s.q.LikeChirp(ctx, sqlitedb.LikeChirpParams{
+		UserID:    arg.UserID,
+		ChirpID:   arg.ChirpID,
+		CreatedAt: s.now(),
+	})
+}
+
+func (s *Store) UnlikeChirp(ctx context.Context, arg database.UnlikeChirpParams) (int64, error) {
+	return s.q.UnlikeChirp(ctx, sqlitedb.UnlikeChirpParams(arg))
+}
+
+func (s *Store) CountLikes(ctx context.Context, chirpIds []uuid.UUID) ([]database.CountLikesRow, error) {
+	if len(chirpIds) == 0 {
+		return nil, nil
+	}
+	rows, err := s.q.CountLikes(ctx, chirpIds)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.CountLikesRow, len(rows))
+	for i, r := range rows {
+		out[i] = database.CountLikesRow(r)
+	}
+	return out, nil
+}
+
+func (s *Store) ListLikedChirpIDs(ctx context.Context, arg database.ListLikedChirpIDsParams) ([]uuid.UUID, error) {
+	if len(arg.ChirpIds) == 0 {
+		return nil, nil
+	}
+	return s.q.ListLikedChirpIDs(ctx, sqlitedb.ListLikedChirpIDsParams(arg))
+}
+
+func (s *Store) ListLikes(ctx context.Context, arg database.ListLikesParams) ([]database.ListLikesRow, error) {
+	rows, err := s.q.ListLikes(ctx, sqlitedb.ListLikesParams{
+		UserID:          arg.UserID,
+		CursorCreatedAt: arg.CursorCreatedAt.UTC(),
+		CursorID:        arg.CursorID,
+		PageSize:        int64(arg.PageSize),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.ListLikesRow, len(rows))
+	for i, r := range rows {
+		out[i] = database.ListLikesRow{Chirp: toChirp(r.Chirp), LikedAt: r.LikedAt}
+	}
+	return out, nil
+}
+
+// scheduled chirps
+
+func (s *Store) CreateScheduledChirp(ctx context.Context, arg database.CreateScheduledChirpParams) (database.ScheduledChirp, error) {
+	c, err := s.q.CreateScheduledChirp(ctx, sqlitedb.CreateScheduledChirpParams{
+		ID:        uuid.New(),
+		UserID:    arg.UserID,
+		Body:      arg.Body,
+		InReplyTo: arg.InReplyTo,
+		PublishAt: arg.PublishAt.UTC(),
+		CreatedAt: s.now(),
+	})
+	return database.ScheduledChirp(c), err
+}
+
+func (s *Store) ListScheduledChirps(ctx context.Context, userID uuid.UUID) ([]database.ScheduledChirp, error) {
+	rows, err := s.q.ListScheduledChirps(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.ScheduledChirp, len(rows))
+	for i, c := range rows {
+		out[i] = database.ScheduledChirp(c)
+	}
+	return out, nil
+}
+
+func (s *Store) DeleteScheduledChirp(ctx context.Context, arg database.DeleteScheduledChirpParams) (int64, error) {
+	return s.q.DeleteScheduledChirp(ctx, sqlitedb.DeleteScheduledChirpParams(arg))
+}
+
+func (s *Store) ClaimDueScheduledChirps(ctx context.Context, now time.Time) ([]database.ScheduledChirp, error) {
+	rows, err := s.q.ClaimDueScheduledChirps(ctx, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.ScheduledChirp, len(rows))
+	for i, c := range rows {
+		out[i] = database.ScheduledChirp(c)
+	}
+	return out, nil
+}
+
+// banned words
+
+func (s *Store) ListBannedWords(ctx context.Context) ([]database.BannedWord, error) {
+	rows, err := s.q.ListBannedWords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.BannedWord, len(rows))
+	for i, w := range rows {
+		out[i] = database.BannedWord(w)
+	}
+	return out, nil
+}
+
+func (s *Store) UpsertBannedWord(ctx context.Context, arg database.UpsertBannedWordParams) (database.BannedWord, error) {
+	w, err := s.q.UpsertBannedWord(ctx, sqlitedb.UpsertBannedWordParams{
+		Word:      arg.Word,
+		Action:    arg.Action,
+		CreatedAt: s.now(),
+	})
+	return database.BannedWord(w), err
+}
+
+func (s *Store) ImportBannedWords(ctx context.Context, arg database.ImportBannedWordsParams) (int64, error) {
+	if len(arg.Actions) != len(arg.Words) {
+		return 0, errors.New("words and actions differ in length")
+	}
+	now := s.now()
+	err := s.withTx(ctx, func(q *sqlitedb.Queries) error {
+		for i, word := range arg.Words {
+			_, err := q.UpsertBannedWord(ctx, sqlitedb.UpsertBannedWordParams{
+				Word:      word,
+				Action:    arg.Actions[i],
+				CreatedAt: now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(arg.Words)), nil
+}
+
+func (s *Store) DeleteBannedWord(ctx context.Context, word string) (int64, error) {
+	return s.q.DeleteBannedWord(ctx, word)
+}
+
+// webhook events
+
+func toWebhookEvent(e sqlitedb.WebhookEvent) database.WebhookEvent {
+	return database.WebhookEvent{
+		ID:             e.ID,
+		Source:         e.Source,
+		EventID:        e.EventID,
+		Event:          e.Event,
+		UserID:         e.UserID,
+		Payload:        e.Payload,
+		Status:         e.Status,
+		Error:          e.Error,
+		Deliveries:     int32(e.Deliveries),
+		ReceivedAt:     e.ReceivedAt,
+		LastReceivedAt: e.LastReceivedAt,
+	}
+}
+
+func (s *Store) RecordWebhookEvent(ctx context.Context, arg database.RecordWebhookEventParams) (database.WebhookEvent, error) {
+	e, err := s.q.RecordWebhookEvent(ctx, sqlitedb.RecordWebhookEventParams{
+		ID:      uuid.New(),
+		Source:  arg.Source,
+		EventID: arg.EventID,
+		Event:   arg.Event,
+		UserID:  arg.UserID,
+		Payload: arg.Payload,
+		Now:     s.now(),
+	})
+	return toWebhookEvent(e), err
+}
+
+func (s *Store) SetWebhookEventStatus(ctx context.Context, arg database.SetWebhookEventStatusParams) error {
+	return s.q.SetWebhookEventStatus(ctx, sqlitedb.SetWebhookEventStatusParams{
+		Status: arg.Status,
+		Error:  arg.Error,
+		ID:     arg.ID,
+	})
+}
+
+func (s *Store) ListWebhookEvents(ctx context.Context, arg database.ListWebhookEventsParams) ([]database.WebhookEvent, error) {
+	rows, err := s.q.ListWebhookEvents(ctx, sqlitedb.ListWebhookEventsParams{
+		UserID:           arg.UserID,
+		CursorReceivedAt: arg.CursorReceivedAt.UTC(),
+		CursorID:         arg.CursorID,
+		PageSize:         int64(arg.PageSize),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.WebhookEvent, len(rows))
+	for i, e := range rows {
+		out[i] = toWebhookEvent(e)
+	}
+	return out, nil
+}
+
+// outgoing webhooks
+
+func (s *Store) CreateWebhookSubscription(ctx context.Context, arg database.CreateWebhookSubscriptionParams) (database.WebhookSubscription, error) {
+	sub, err := s.q.CreateWebhookSubscription(ctx, sqlitedb.CreateWebhookSubscriptionParams{
+		ID:        uuid.New(),
+		UserID:    arg.UserID,
+		Url:       arg.Url,
+		Secret:    arg.Secret,
+		Events:    arg.Events,
+		CreatedAt: s.now(),
+	})
+	return database.WebhookSubscription(sub), err
+}
+
+func (s *Store) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (database.WebhookSubscription, error) {
+	sub, err := s.q.GetWebhookSubscription(ctx, id)
+	return database.WebhookSubscription(sub), err
+}
+
+func (s *Store) ListWebhookSubscriptions(ctx context.Context, userID uuid.NullUUID) ([]database.WebhookSubscription, error) {
+	rows, err := s.q.ListWebhookSubscriptions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.WebhookSubscription, len(rows))
+	for i, sub := range rows {
+		out[i] = database.WebhookSubscription(sub)
+	}
+	return out, nil
+}
+
+func (s *Store) DeleteWebhookSubscription(ctx context.Context, arg database.DeleteWebhookSubscriptionParams) (int64, error) {
+	return s.q.DeleteWebhookSubscription(ctx, sqlitedb.DeleteWebhookSubscriptionParams(arg))
+}
+
+func (s *Store) EnqueueWebhookEvent(ctx context.Context, arg database.EnqueueWebhookEventParams) (int64, error) {
+	now := s.now()
+	var n int64
+	err := s.withTx(ctx, func(q *sqlitedb.Queries) error {
+		subs, err := q.ListWebhookSubscribers(ctx, sqlitedb.ListWebhookSubscribersParams{
+			Event:  arg.Event,
+			UserID: arg.UserID,
+		})
+		if err != nil {
+			return err
+		}
+		for _, sub := range subs {
+			err := q.CreateWebhookDelivery(ctx, sqlitedb.CreateWebhookDeliveryParams{
+				ID:             uuid.New(),
+				SubscriptionID: sub,
+				Event:          arg.Event,
+				Payload:        arg.Payload,
+				Now:            now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		n = int64(len(subs))
+		return nil
+	})
+	return n, err
+}
+
+func toWebhookDelivery(d sqlitedb.WebhookDelivery) database.WebhookDelivery {
+	return database.WebhookDelivery{
+		ID:             d.ID,
+		SubscriptionID: d.SubscriptionID,
+		Event:          d.Event,
+		Payload:        d.Payload,
+		Status:         d.Status,
+		Attempts:       int32(d.Attempts),
+		NextAttemptAt:  d.NextAttemptAt,
+		LastError:      d.LastError,
+		ResponseStatus: sql.NullInt32{Int32: int32(d.ResponseStatus.Int64), Valid: d.ResponseStatus.Valid},
+		CreatedAt:      d.CreatedAt,
+		DeliveredAt:    d.DeliveredAt,
+	}
+}
+
+func (s *Store) ClaimWebhookDeliveries(ctx context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	rows, err := s.q.ClaimWebhookDeliveries(ctx, sqlitedb.ClaimWebhookDeliveriesParams{
+		LeaseUntil:    arg.LeaseUntil.UTC(),
+		Now:           arg.Now.UTC(),
+		MaxDeliveries: int64(arg.MaxDeliveries),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.WebhookDelivery, len(rows))
+	for i, d := range rows {
+		out[i] = toWebhookDelivery(d)
+	}
+	return out, nil
+}
+
+func (s *Store) FinishWebhookAttempt(ctx context.Context, arg database.FinishWebhookAttemptParams) error {
+	var deliveredAt sql.NullTime
+	if arg.Status == "delivered" {
+		deliveredAt = sql.NullTime{Time: s.now(), Valid: true}
+	}
+	return s.q.FinishWebhookAttempt(ctx, sqlitedb.FinishWebhookAttemptParams{
+		Status:         arg.Status,
+		NextAttemptAt:  arg.NextAttemptAt.UTC(),
+		LastError:      arg.LastError,
+		ResponseStatus: sql.NullInt64{Int64: int64(arg.ResponseStatus.Int32), Valid: arg.ResponseStatus.Valid},
+		DeliveredAt:    deliveredAt,
+		ID:             arg.ID,
+	})
+}
+
+func (s *Store) ListWebhookDeliveries(ctx context.Context, arg database.ListWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	rows, err := s.q.ListWebhookDeliveries(ctx, sqlitedb.ListWebhookDeliveriesParams{
+		SubscriptionID:  arg.SubscriptionID,
+		CursorCreatedAt: arg.CursorCreatedAt.UTC(),
+		CursorID:        arg.CursorID,
+		PageSize:        int64(arg.PageSize),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.WebhookDelivery, len(rows))
+	for i, d := range rows {
+		out[i] = toWebhookDelivery(d)
+	}
+	return out, nil
+}
+
+// notifications
+
+// CreateNotification checks the preferences in Go; the Postgres query does
+// it in SQL.
+func (s *Store) CreateNotification(ctx context.Context, arg database.CreateNotificationParams) (database.Notification, error) {
+	var n sqlitedb.Notification
+	err := s.withTx(ctx, func(q *sqlitedb.Queries) error {
+		p, err := q.GetNotificationPreferences(ctx, arg.UserID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err == nil && !wantsNotification(p, arg.Kind) {
+			return sql.ErrNoRows
+		}
+		seen, err := q.HasNotification(ctx, sqlitedb.HasNotificationParams{
+			UserID:  arg.UserID,
+			ActorID: arg.ActorID,
+			Kind:    arg.Kind,
+			ChirpID: arg.ChirpID,
+		})
+		if err != nil {
+			return err
+		}
+		if seen != 0 {
+			return sql.ErrNoRows
+		}
+		n, err = q.CreateNotification(ctx, sqlitedb.CreateNotificationParams{
+			ID:        uuid.New(),
+			UserID:    arg.UserID,
+			ActorID:   arg.ActorID,
+			Kind:      arg.Kind,
+			ChirpID:   arg.ChirpID,
+			CreatedAt: s.now(),
+		})
+		return err
+	})
+	return database.Notification(n), err
+}
+
+func wantsNotification(p sqlitedb.NotificationPreference, kind string) bool {
+	switch kind {
+	case "mention":
+		return p.Mentions
+	case "reply":
+		return p.Replies
+	case "like":
+		return p.Likes
+	case "follow":
+		return p.Follows
+	}
+	// Left to the check constraint.
+	return true
+}
+
+func (s *Store) ListNotifications(ctx context.Context, arg database.ListNotificationsParams) ([]database.Notification, error) {
+	rows, err := s.q.ListNotifications(ctx, sqlitedb.ListNotificationsParams{
+		UserID:          arg.UserID,
+		UnreadOnly:      arg.UnreadOnly,
+		CursorCreatedAt: arg.CursorCreatedAt.UTC(),
+		CursorID:        arg.CursorID,
+		PageSize:        int64(arg.PageSize),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.Notification, len(rows))
+	for i, n := range rows {
+		out[i] = database.Notification(n)
+	}
+	return out, nil
+}
+
+func (s *Store) MarkNotificationsRead(ctx context.Context, arg database.MarkNotificationsReadParams) (int64, error) {
+	if len(arg.Ids) == 0 {
+		return 0, nil
+	}
+	return s.q.MarkNotificationsRead(ctx, sqlitedb.MarkNotificationsReadParams{
+		Now:    sql.NullTime{Time: s.now(), Valid: true},
+		UserID: arg.UserID,
+		Ids:    arg.Ids,
+	})
+}
+
+func (s *Store) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.MarkAllNotificationsRead(ctx, sqlitedb.MarkAllNotificationsReadParams{
+		Now:    sql.NullTime{Time: s.now(), Valid: true},
+		UserID: userID,
+	})
+}
+
+func (s *Store) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (database.NotificationPreference, error) {
+	p, err := s.q.GetNotificationPreferences(ctx, userID)
+	return database.NotificationPreference(p), err
+}
+
+func (s *Store) SetNotificationPreferences(ctx context.Context, arg database.SetNotificationPreferencesParams) (database.NotificationPreference, error) {
+	p, err := s.q.SetNotificationPreferences(ctx, sqlitedb.SetNotificationPreferencesParams{
+		UserID:    arg.UserID,
+		Mentions:  arg.Mentions,
+		Replies:   arg.Replies,
+		Likes:     arg.Likes,
+		Follows:   arg.Follows,
+		UpdatedAt: s.now(),
+	})
+	return database.NotificationPreference(p), err
+}
+
+// moderation
+
+func (s *Store) CreateChirpReport(ctx context.Context, arg database.CreateChirpReportParams) (database.ChirpReport, error) {
+	r, err := s.q.CreateChirpReport(ctx, sqlitedb.CreateChirpReportParams{
+		ID:         uuid.New(),
+		ChirpID:    arg.ChirpID,
+		ReporterID: arg.ReporterID,
+		Reason:     arg.Reason,
+		CreatedAt:  s.now(),
+	})
+	return database.ChirpReport(r), err
+}
+
+func (s *Store) CreateChirpFlag(ctx context.Context, arg database.CreateChirpFlagParams) (database.ChirpReport, error) {
+	r, err := s.q.CreateChirpFlag(ctx, sqlitedb.CreateChirpFlagParams{
+		ID:        uuid.New(),
+		ChirpID:   arg.ChirpID,
+		Reason:    arg.Reason,
+		CreatedAt: s.now(),
+	})
+	return database.ChirpReport(r), err
+}
+
+func (s *Store) ListModerationQueue(ctx context.Context, arg database.ListModerationQueueParams) ([]database.ListModerationQueueRow, error) {
+	rows, err := s.q.ListModerationQueue(ctx, sqlitedb.ListModerationQueueParams{
+		CursorCreatedAt: arg.CursorCreatedAt.UTC(),
+		CursorID:        arg.CursorID,
+		PageSize:        int64(arg.PageSize),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.ListModerationQueueRow, len(rows))
+	for i, r := range rows {
+		out[i] = database.ListModerationQueueRow{
+			Chirp:           toChirp(r.Chirp),
+			OpenReports:     r.OpenReports,
+			FirstReportedAt: r.FirstReportedAt,
+		}
+	}
+	return out, nil
+}
+
+func (s *Store) ListOpenChirpReports(ctx context.Context, chirpIds []uuid.UUID) ([]database.ChirpReport, error) {
+	if len(chirpIds) == 0 {
+		return nil, nil
+	}
+	rows, err := s.q.ListOpenChirpReports(ctx, chirpIds)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.ChirpReport, len(rows))
+	for i, r := range rows {
+		out[i] = database.ChirpReport(r)
+	}
+	return out, nil
+}
+
+// ModerateChirp does in a transaction what the Postgres query does in one
+// statement.
+func (s *Store) ModerateChirp(ctx context.Context, arg database.ModerateChirpParams) (database.ModerationAction, error) {
+	now := s.now()
+	var a sqlitedb.ModerationAction
+	err := s.withTx(ctx, func(q *sqlitedb.Queries) error {
+		chirp, err := q.GetChirpIncludingHidden(ctx, arg.ChirpID)
+		if err != nil {
+			return err
+		}
+		hiddenAt := chirp.HiddenAt
+		switch arg.Action {
+		case "hide":
+			if !hiddenAt.Valid {
+				hiddenAt = sql.NullTime{Time: now, Valid: true}
+			}
+		case "restore":
+			hiddenAt = sql.NullTime{}
+		}
+		if err := q.SetChirpHidden(ctx, sqlitedb.SetChirpHiddenParams{HiddenAt: hiddenAt, ID: chirp.ID}); err != nil {
+			return err
+		}
+		err = q.ResolveChirpReports(ctx, sqlitedb.ResolveChirpReportsParams{
+			Now:     sql.NullTime{Time: now, Valid: true},
+			ChirpID: chirp.ID,
+		})
+		if err != nil {
+			return err
+		}
+		a, err = q.CreateModerationAction(ctx, sqlitedb.CreateModerationActionParams{
+			ID:          uuid.New(),
+			ChirpID:     chirp.ID,
+			ChirpUserID: chirp.UserID,
+			ModeratorID: arg.ModeratorID,
+			Action:      arg.Action,
+			Reason:      arg.Reason,
+			CreatedAt:   now,
+		})
+		return err
+	})
+	return database.ModerationAction(a), err
+}
+
+func (s *Store) RemoveChirp(ctx context.Context, arg database.RemoveChirpParams) (database.ModerationAction, error) {
+	var a sqlitedb.ModerationAction
+	err := s.withTx(ctx, func(q *sqlitedb.Queries) error {
+		chirp, err := q.GetChirpIncludingHidden(ctx, arg.ChirpID)
+		if err != nil {
+			return err
+		}
+		if err := q.DeleteChirp(ctx, chirp.ID); err != nil {
+			return err
+		}
+		a, err = q.CreateModerationAction(ctx, sqlitedb.CreateModerationActionParams{
+			ID:          uuid.New(),
+			ChirpID:     chirp.ID,
+			ChirpUserID: chirp.UserID,
+			ModeratorID: arg.ModeratorID,
+			Action:      "remove",
+			Reason:      arg.Reason,
+			CreatedAt:   s.now(),
+		})
+		return err
+	})
+	return database.ModerationAction(a), err
+}
+
+func (s *Store) ListModerationActions(ctx context.Context, arg database.ListModerationActionsParams) ([]database.ModerationAction, error) {
+	rows, err := s.q.ListModerationActions(ctx, sqlitedb.ListModerationActionsParams{
+		ChirpID:         arg.ChirpID,
+		CursorCreatedAt: arg.CursorCreatedAt.UTC(),
+		CursorID:        arg.CursorID,
+		PageSize:        int64(arg.PageSize),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]database.ModerationAction, len(rows))
+	for i, a := range rows {
+		out[i] = database.ModerationAction(a)
+	}
+	return out, nil
+}
+
+// refresh tokens
+
+func (s *Store) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	now := s.now()
+	token, err := s.q.CreateRefreshToken(ctx, sqlitedb.CreateRefreshTokenParams{
+		Token:     arg.Token,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: arg.ExpiresAt.UTC(),
+		UserID:    arg.UserID,
+	})
+	return database.RefreshToken(token), err
+}
+
+func (s *Store) GetUserFromRefreshToken(ctx context.Context, token string) (database.GetUserFromRefreshTokenRow, error) {
+	row, err := s.q.GetUserFromRefreshToken(ctx, sqlitedb.GetUserFromRefreshTokenParams{
+		Token: token,
+		Now:   s.now(),
+	})
+	return database.GetUserFromRefreshTokenRow(row), err
+}
+
+func (s *Store) RevokeRefreshToken(ctx context.Context, token string) (int64, error) {
+	return s.q.RevokeRefreshToken(ctx, sqlitedb.RevokeRefreshTokenParams{
+		Now:   sql.NullTime{Time: s.now(), Valid: true},
+		Token: token,
+	})
+}
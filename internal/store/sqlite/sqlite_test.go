@@ -0,0 +1,820 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"maps"
+	"math"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/Geraetefreund/chirpy/internal/migrate"
+	"github.com/Geraetefreund/chirpy/internal/search"
+	"github.com/google/uuid"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := Open("sqlite://" + filepath.Join(t.TempDir(), "chirpy.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrate.New(db, migrate.SQLite)
+	if err != nil {
+		t.Fatalf("migrate.New: %v", err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	return New(db)
+}
+
+func TestUsersAndRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	user, err := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com", HashedPassword: "x"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"}); err == nil {
+		t.Errorf("duplicate email: want error, got nil")
+	}
+	got, err := s.LookUpUserByEmail(ctx, "walt@breakingbad.com")
+	if err != nil || got.ID != user.ID {
+		t.Fatalf("LookUpUserByEmail: got %v, %v", got.ID, err)
+	}
+	if up, err := s.UpgradeChirpyPlus(ctx, user.ID); err != nil || !up.IsChirpyRed {
+		t.Errorf("UpgradeChirpyPlus: got %v, %v", up.IsChirpyRed, err)
+	}
+	if user.Role != "user" {
+		t.Errorf("new user: want role %q, got %q", "user", user.Role)
+	}
+	if u, err := s.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: "moderator"}); err != nil || u.Role != "moderator" {
+		t.Errorf("SetUserRole: got %q, %v", u.Role, err)
+	}
+	if _, err := s.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: "overlord"}); err == nil {
+		t.Errorf("SetUserRole with unknown role: want an error")
+	}
+
+	s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "live", ExpiresAt: time.Now().Add(time.Hour), UserID: user.ID})
+	s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "expired", ExpiresAt: time.Now().Add(-time.Hour), UserID: user.ID})
+	if row, err := s.GetUserFromRefreshToken(ctx, "live"); err != nil || row.ID != user.ID || row.Role != "moderator" {
+		t.Errorf("live token: got %v, %q, %v", row.ID, row.Role, err)
+	}
+	if _, err := s.GetUserFromRefreshToken(ctx, "expired"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expired token: want sql.ErrNoRows, got %v", err)
+	}
+	if n, _ := s.RevokeRefreshToken(ctx, "live"); n != 1 {
+		t.Errorf("revoke: want 1 row, got %d", n)
+	}
+	if _, err := s.GetUserFromRefreshToken(ctx, "live"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("revoked token: want sql.ErrNoRows, got %v", err)
+	}
+
+	s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "other", ExpiresAt: time.Now().Add(time.Hour), UserID: user.ID})
+	until := time.Now().Add(time.Hour)
+	suspended, err := s.SuspendUser(ctx, database.SuspendUserParams{
+		ID: user.ID, SuspendedUntil: sql.NullTime{Time: until, Valid: true}, SuspensionReason: "spam",
+	})
+	if err != nil || !suspended.SuspendedAt.Valid || !suspended.SuspendedUntil.Time.Equal(until) || suspended.SuspensionReason != "spam" {
+		t.Errorf("SuspendUser: got %+v, %v", suspended, err)
+	}
+	if _, err := s.GetUserFromRefreshToken(ctx, "other"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("token after suspension: want sql.ErrNoRows, got %v", err)
+	}
+	if u, err := s.UnsuspendUser(ctx, user.ID); err != nil || u.SuspendedAt.Valid || u.SuspensionReason != "" {
+		t.Errorf("UnsuspendUser: got %+v, %v", u, err)
+	}
+
+	if err := s.Reset(ctx); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if _, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "orphan", ExpiresAt: time.Now(), UserID: user.ID}); err == nil {
+		t.Errorf("foreign keys are not enforced")
+	}
+}
+
+func TestListChirpsKeyset(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	user, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+
+	// Pin the clock so every chirp shares a timestamp and only the id
+	// tie-breaker orders them.
+	at := time.Date(2026, 1, 2, 3, 4, 5, 600, time.UTC)
+	s.now = func() time.Time { return at }
+	for i := 0; i < 5; i++ {
+		if _, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "say my name", UserID: user.ID}); err != nil {
+			t.Fatalf("CreateChirp: %v", err)
+		}
+	}
+
+	var seen []uuid.UUID
+	cursorAt, cursorID := time.Time{}, uuid.Nil
+	for {
+		page, err := s.ListChirps(ctx, database.ListChirpsParams{CursorCreatedAt: cursorAt, CursorID: cursorID, PageSize: 2})
+		if err != nil {
+			t.Fatalf("ListChirps: %v", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, c := range page {
+			if !c.CreatedAt.Equal(at) {
+				t.Errorf("created_at round trip: want %v, got %v", at, c.CreatedAt)
+			}
+			seen = append(seen, c.ID)
+		}
+		last := page[len(page)-1]
+		cursorAt, cursorID = last.CreatedAt, last.ID
+	}
+	if len(seen) != 5 {
+		t.Fatalf("want 5 chirps, got %d", len(seen))
+	}
+	for i := 1; i < len(seen); i++ {
+		if seen[i].String() <= seen[i-1].String() {
+			t.Errorf("ids out of order: %s after %s", seen[i], seen[i-1])
+		}
+	}
+}
+
+func TestUpdateChirpKeepsRevision(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	user, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+	chirp, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "say my name", UserID: user.ID})
+
+	updated, err := s.UpdateChirp(ctx, database.UpdateChirpParams{Body: "heisenberg", ID: chirp.ID})
+	if err != nil {
+		t.Fatalf("UpdateChirp: %v", err)
+	}
+	if updated.Body != "heisenberg" || !updated.UpdatedAt.After(chirp.UpdatedAt) {
+		t.Errorf("UpdateChirp: got %+v", updated)
+	}
+	revisions, err := s.ListChirpRevisions(ctx, chirp.ID)
+	if err != nil || len(revisions) != 1 {
+		t.Fatalf("ListChirpRevisions: got %d revisions, %v", len(revisions), err)
+	}
+	if revisions[0].Body != "say my name" || !revisions[0].CreatedAt.Equal(chirp.UpdatedAt) {
+		t.Errorf("revision: got %+v", revisions[0])
+	}
+
+	if _, err := s.UpdateChirp(ctx, database.UpdateChirpParams{Body: "x", ID: uuid.New()}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("missing chirp: want sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestReplies(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	user, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+	root, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "root", UserID: user.ID})
+	reply, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "reply", UserID: user.ID, InReplyTo: uuid.NullUUID{UUID: root.ID, Valid: true}})
+	nested, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "nested", UserID: user.ID, InReplyTo: uuid.NullUUID{UUID: reply.ID, Valid: true}})
+	if err != nil {
+		t.Fatalf("CreateChirp: %v", err)
+	}
+
+	ancestors, err := s.ListChirpAncestors(ctx, database.ListChirpAncestorsParams{ID: nested.ID, MaxDepth: 10})
+	if err != nil || len(ancestors) != 2 || ancestors[0].ID != reply.ID || ancestors[1].ID != root.ID {
+		t.Errorf("ListChirpAncestors: got %v, %v", ancestors, err)
+	}
+	tree, err := s.ListChirpDescendants(ctx, database.ListChirpDescendantsParams{ID: root.ID, MaxDepth: 10, PageSize: 10})
+	if err != nil || len(tree) != 2 || tree[0].Chirp.ID != reply.ID || tree[0].Depth != 1 || tree[1].Chirp.ID != nested.ID || tree[1].Depth != 2 {
+		t.Errorf("ListChirpDescendants: got %v, %v", tree, err)
+	}
+	tree, err = s.ListChirpDescendants(ctx, database.ListChirpDescendantsParams{ID: root.ID, MaxDepth: 1, PageSize: 10})
+	if err != nil || len(tree) != 1 || tree[0].Chirp.ID != reply.ID {
+		t.Errorf("ListChirpDescendants with MaxDepth 1: got %v, %v", tree, err)
+	}
+	tree, err = s.ListChirpDescendants(ctx, database.ListChirpDescendantsParams{ID: root.ID, MaxDepth: 10, CursorCreatedAt: reply.CreatedAt, CursorID: reply.ID, PageSize: 10})
+	if err != nil || len(tree) != 1 || tree[0].Chirp.ID != nested.ID {
+		t.Errorf("ListChirpDescendants after a cursor: got %v, %v", tree, err)
+	}
+	counts, err := s.CountReplies(ctx, []uuid.UUID{root.ID, reply.ID, nested.ID})
+	if err != nil || len(counts) != 2 {
+		t.Errorf("CountReplies: got %v, %v", counts, err)
+	}
+
+	if err := s.DeleteChirp(ctx, root.ID); err != nil {
+		t.Fatalf("DeleteChirp: %v", err)
+	}
+	orphan, _ := s.GetChirp(ctx, reply.ID)
+	if orphan.InReplyTo.Valid {
+		t.Errorf("in_reply_to was not cleared when the parent was deleted")
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	walt, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+	jesse, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "jesse@breakingbad.com"})
+	skyler, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "skyler@breakingbad.com"})
+	s.CreateChirp(ctx, database.CreateChirpParams{Body: "yo", UserID: jesse.ID})
+	s.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: skyler.ID})
+
+	if n, err := s.FollowUser(ctx, database.FollowUserParams{FollowerID: walt.ID, FolloweeID: jesse.ID}); err != nil || n != 1 {
+		t.Fatalf("FollowUser: got %d, %v", n, err)
+	}
+	if n, _ := s.FollowUser(ctx, database.FollowUserParams{FollowerID: walt.ID, FolloweeID: jesse.ID}); n != 0 {
+		t.Errorf("second FollowUser: want 0 rows, got %d", n)
+	}
+	if _, err := s.FollowUser(ctx, database.FollowUserParams{FollowerID: walt.ID, FolloweeID: walt.ID}); err == nil {
+		t.Errorf("self follow: want check constraint error")
+	}
+
+	timeline, err := s.ListTimeline(ctx, database.ListTimelineParams{
+		UserID:          walt.ID,
+		CursorCreatedAt: time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC),
+		CursorID:        uuid.Max,
+		PageSize:        10,
+	})
+	if err != nil || len(timeline) != 1 || timeline[0].UserID != jesse.ID {
+		t.Errorf("ListTimeline: got %v, %v", timeline, err)
+	}
+}
+
+func TestLikes(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	walt, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+	chirp, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "say my name", UserID: walt.ID})
+
+	if n, err := s.LikeChirp(ctx, database.LikeChirpParams{UserID: walt.ID, ChirpID: chirp.ID}); err != nil || n != 1 {
+		t.Fatalf("LikeChirp: got %d, %v", n, err)
+	}
+	if n, _ := s.LikeChirp(ctx, database.LikeChirpParams{UserID: walt.ID, ChirpID: chirp.ID}); n != 0 {
+		t.Errorf("second LikeChirp: want 0 rows, got %d", n)
+	}
+	counts, err := s.CountLikes(ctx, []uuid.UUID{chirp.ID})
+	if err != nil || len(counts) != 1 || counts[0].LikeCount != 1 {
+		t.Errorf("CountLikes: got %v, %v", counts, err)
+	}
+	liked, err := s.ListLikes(ctx, database.ListLikesParams{
+		UserID:          walt.ID,
+		CursorCreatedAt: time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC),
+		CursorID:        uuid.Max,
+		PageSize:        10,
+	})
+	if err != nil || len(liked) != 1 || liked[0].Chirp.ID != chirp.ID {
+		t.Errorf("ListLikes: got %v, %v", liked, err)
+	}
+
+	if err := s.DeleteChirp(ctx, chirp.ID); err != nil {
+		t.Fatalf("DeleteChirp: %v", err)
+	}
+	if ids, _ := s.ListLikedChirpIDs(ctx, database.ListLikedChirpIDsParams{UserID: walt.ID, ChirpIds: []uuid.UUID{chirp.ID}}); len(ids) != 0 {
+		t.Errorf("likes were not removed with their chirp")
+	}
+}
+
+func TestSearchChirps(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	walt, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+	named, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "say my name", UserID: walt.ID})
+	s.CreateChirp(ctx, database.CreateChirpParams{Body: "names names names", UserID: walt.ID})
+	s.CreateChirp(ctx, database.CreateChirpParams{Body: "cooking", UserID: walt.ID})
+
+	q, _ := search.Parse("name")
+	var seen []uuid.UUID
+	cursorRel, cursorAt, cursorID := math.MaxFloat64, time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC), uuid.Max
+	for {
+		page, err := s.SearchChirps(ctx, database.SearchChirpsParams{
+			Query:           q.TSQuery(),
+			CursorRelevance: cursorRel,
+			CursorCreatedAt: cursorAt,
+			CursorID:        cursorID,
+			PageSize:        1,
+		})
+		if err != nil {
+			t.Fatalf("SearchChirps: %v", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		last := page[0]
+		seen = append(seen, last.Chirp.ID)
+		cursorRel, cursorAt, cursorID = last.Relevance, last.Chirp.CreatedAt, last.Chirp.ID
+	}
+	// The porter stemmer matches "names" too, and ranks it higher.
+	if len(seen) != 2 || seen[1] != named.ID {
+		t.Errorf("SearchChirps: got %v", seen)
+	}
+
+	if _, err := s.UpdateChirp(ctx, database.UpdateChirpParams{Body: "heisenberg", ID: named.ID}); err != nil {
+		t.Fatalf("UpdateChirp: %v", err)
+	}
+	q, _ = search.Parse(`heisen*`)
+	recent, err := s.SearchChirpsRecent(ctx, database.SearchChirpsRecentParams{
+		Query:           q.TSQuery(),
+		AuthorID:        uuid.NullUUID{UUID: walt.ID, Valid: true},
+		CursorCreatedAt: cursorAt.AddDate(1, 0, 0),
+		CursorID:        uuid.Max,
+		PageSize:        10,
+	})
+	if err != nil || len(recent) != 1 || recent[0].ID != named.ID {
+		t.Errorf("SearchChirpsRecent after edit: got %v, %v", recent, err)
+	}
+}
+
+func TestChirpEntities(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	walt, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+	jesse, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "jesse@breakingbad.com"})
+
+	chirp, err := s.CreateChirp(ctx, database.CreateChirpParams{
+		Body:           "#cook with @jesse@breakingbad.com",
+		UserID:         walt.ID,
+		Tags:           []string{"cook"},
+		TagStarts:      []int32{0},
+		TagEnds:        []int32{5},
+		MentionUserIds: []uuid.UUID{jesse.ID},
+		MentionStarts:  []int32{11},
+		MentionEnds:    []int32{33},
+	})
+	if err != nil {
+		t.Fatalf("CreateChirp: %v", err)
+	}
+	tags, err := s.ListChirpHashtags(ctx, []uuid.UUID{chirp.ID})
+	if err != nil || len(tags) != 1 || tags[0].Tag != "cook" || tags[0].EndOffset != 5 {
+		t.Errorf("ListChirpHashtags: got %v, %v", tags, err)
+	}
+	top := time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	mentioning, err := s.ListChirpsMentioning(ctx, database.ListChirpsMentioningParams{UserID: jesse.ID, CursorCreatedAt: top, CursorID: uuid.Max, PageSize: 10})
+	if err != nil || len(mentioning) != 1 {
+		t.Errorf("ListChirpsMentioning: got %v, %v", mentioning, err)
+	}
+
+	if _, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "x", UserID: walt.ID, Tags: []string{"x"}}); err == nil {
+		t.Errorf("mismatched entity arrays: want error")
+	}
+
+	if _, err := s.UpdateChirp(ctx, database.UpdateChirpParams{
+		Body:      "#meth",
+		ID:        chirp.ID,
+		Tags:      []string{"meth"},
+		TagStarts: []int32{0},
+		TagEnds:   []int32{5},
+	}); err != nil {
+		t.Fatalf("UpdateChirp: %v", err)
+	}
+	tagged, _ := s.ListChirpsByHashtag(ctx, database.ListChirpsByHashtagParams{Tag: "cook", CursorCreatedAt: top, CursorID: uuid.Max, PageSize: 10})
+	if len(tagged) != 0 {
+		t.Errorf("old hashtag survived the edit")
+	}
+	mentions, _ := s.ListChirpMentions(ctx, []uuid.UUID{chirp.ID})
+	if len(mentions) != 0 {
+		t.Errorf("old mention survived the edit")
+	}
+}
+
+func TestBannedWords(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	words, err := s.ListBannedWords(ctx)
+	if err != nil || len(words) != 3 {
+		t.Fatalf("seeded list: got %v, %v", words, err)
+	}
+
+	w, err := s.UpsertBannedWord(ctx, database.UpsertBannedWordParams{Word: "kerfuffle", Action: "reject"})
+	if err != nil || w.Action != "reject" {
+		t.Errorf("UpsertBannedWord: got %+v, %v", w, err)
+	}
+	if _, err := s.UpsertBannedWord(ctx, database.UpsertBannedWordParams{Word: "heisenberg", Action: "shout"}); err == nil {
+		t.Errorf("invalid action: want error")
+	}
+
+	n, err := s.ImportBannedWords(ctx, database.ImportBannedWordsParams{
+		Words:   []string{"sharbert", "pollos"},
+		Actions: []string{"flag", "mask"},
+	})
+	if err != nil || n != 2 {
+		t.Errorf("ImportBannedWords: got %d, %v", n, err)
+	}
+	if _, err := s.ImportBannedWords(ctx, database.ImportBannedWordsParams{
+		Words:   []string{"capn", "heisenberg"},
+		Actions: []string{"mask", "shout"},
+	}); err == nil {
+		t.Errorf("import with invalid action: want error")
+	}
+
+	if n, err := s.DeleteBannedWord(ctx, "fornax"); err != nil || n != 1 {
+		t.Errorf("DeleteBannedWord: got %d, %v", n, err)
+	}
+	words, _ = s.ListBannedWords(ctx)
+	got := make(map[string]string)
+	for _, w := range words {
+		got[w.Word] = w.Action
+	}
+	want := map[string]string{"kerfuffle": "reject", "sharbert": "flag", "pollos": "mask"}
+	if !maps.Equal(got, want) {
+		t.Errorf("after changes: got %v, want %v", got, want)
+	}
+}
+
+func TestScheduledChirps(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	walt, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+	jesse, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "jesse@breakingbad.com"})
+
+	now := time.Now()
+	var ids []uuid.UUID
+	for _, in := range []time.Duration{2 * time.Hour, time.Hour, 3 * time.Hour} {
+		c, err := s.CreateScheduledChirp(ctx, database.CreateScheduledChirpParams{UserID: walt.ID, Body: "later", PublishAt: now.Add(in)})
+		if err != nil {
+			t.Fatalf("CreateScheduledChirp: %v", err)
+		}
+		ids = append(ids, c.ID)
+	}
+	list, err := s.ListScheduledChirps(ctx, walt.ID)
+	if err != nil || len(list) != 3 || list[0].ID != ids[1] || list[2].ID != ids[2] {
+		t.Fatalf("ListScheduledChirps: got %v, %v", list, err)
+	}
+
+	if n, _ := s.DeleteScheduledChirp(ctx, database.DeleteScheduledChirpParams{ID: ids[2], UserID: jesse.ID}); n != 0 {
+		t.Errorf("delete by another user: got %d rows", n)
+	}
+	if n, _ := s.DeleteScheduledChirp(ctx, database.DeleteScheduledChirpParams{ID: ids[2], UserID: walt.ID}); n != 1 {
+		t.Errorf("delete: got %d rows", n)
+	}
+
+	due, err := s.ClaimDueScheduledChirps(ctx, now.Add(90*time.Minute))
+	if err != nil || len(due) != 1 || due[0].ID != ids[1] {
+		t.Fatalf("ClaimDueScheduledChirps: got %v, %v", due, err)
+	}
+	if due, _ := s.ClaimDueScheduledChirps(ctx, now.Add(90*time.Minute)); len(due) != 0 {
+		t.Errorf("claimed twice: %v", due)
+	}
+	if list, _ := s.ListScheduledChirps(ctx, walt.ID); len(list) != 1 || list[0].ID != ids[0] {
+		t.Errorf("after claiming: got %v", list)
+	}
+
+	// A suspended user's chirps stay scheduled until the suspension ends.
+	until := now.Add(3 * time.Hour)
+	s.SuspendUser(ctx, database.SuspendUserParams{ID: walt.ID, SuspendedUntil: sql.NullTime{Time: until, Valid: true}})
+	if due, _ := s.ClaimDueScheduledChirps(ctx, now.Add(150*time.Minute)); len(due) != 0 {
+		t.Errorf("claimed while suspended: %v", due)
+	}
+	if due, _ := s.ClaimDueScheduledChirps(ctx, until.Add(time.Minute)); len(due) != 1 || due[0].ID != ids[0] {
+		t.Errorf("claimed after the suspension: got %v", due)
+	}
+}
+
+func TestWebhookEvents(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	walt, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+	if u, err := s.UpgradeChirpyPlus(ctx, walt.ID); err != nil || !u.IsChirpyRed {
+		t.Fatalf("UpgradeChirpyPlus: got %v, %v", u.IsChirpyRed, err)
+	}
+	if u, err := s.DowngradeChirpyPlus(ctx, walt.ID); err != nil || u.IsChirpyRed {
+		t.Fatalf("DowngradeChirpyPlus: got %v, %v", u.IsChirpyRed, err)
+	}
+
+	arg := database.RecordWebhookEventParams{
+		Source:  "polka",
+		EventID: sql.NullString{String: "evt_1", Valid: true},
+		Event:   "user.upgraded",
+		UserID:  uuid.NullUUID{UUID: walt.ID, Valid: true},
+		Payload: "{}",
+	}
+	first, err := s.RecordWebhookEvent(ctx, arg)
+	if err != nil || first.Deliveries != 1 || first.Status != "received" {
+		t.Fatalf("RecordWebhookEvent: got %+v, %v", first, err)
+	}
+	if err := s.SetWebhookEventStatus(ctx, database.SetWebhookEventStatusParams{ID: first.ID, Status: "processed"}); err != nil {
+		t.Fatalf("SetWebhookEventStatus: %v", err)
+	}
+	again, err := s.RecordWebhookEvent(ctx, arg)
+	if err != nil || again.ID != first.ID || again.Deliveries != 2 || again.Status != "processed" {
+		t.Errorf("redelivery: got %+v, %v", again, err)
+	}
+
+	// Of concurrent deliveries, only the one that stored the event sees it
+	// as new.
+	arg.EventID = sql.NullString{String: "evt_2", Valid: true}
+	arg.UserID = uuid.NullUUID{}
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		fresh int
+	)
+	for range 10 {
+		wg.Go(func() {
+			e, err := s.RecordWebhookEvent(ctx, arg)
+			if err != nil {
+				t.Errorf("concurrent RecordWebhookEvent: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if e.Deliveries == 1 {
+				fresh++
+			}
+		})
+	}
+	wg.Wait()
+	if fresh != 1 {
+		t.Errorf("concurrent deliveries: want one new event, got %d", fresh)
+	}
+
+	// Events without an ID are never merged.
+	arg.EventID = sql.NullString{}
+	for range 2 {
+		if _, err := s.RecordWebhookEvent(ctx, arg); err != nil {
+			t.Fatalf("RecordWebhookEvent without ID: %v", err)
+		}
+	}
+
+	top := time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	all, err := s.ListWebhookEvents(ctx, database.ListWebhookEventsParams{CursorReceivedAt: top, CursorID: uuid.Max, PageSize: 10})
+	if err != nil || len(all) != 4 {
+		t.Errorf("ListWebhookEvents: got %d, %v", len(all), err)
+	}
+	mine, err := s.ListWebhookEvents(ctx, database.ListWebhookEventsParams{
+		UserID:           uuid.NullUUID{UUID: walt.ID, Valid: true},
+		CursorReceivedAt: top,
+		CursorID:         uuid.Max,
+		PageSize:         10,
+	})
+	if err != nil || len(mine) != 1 || mine[0].ID != first.ID {
+		t.Errorf("ListWebhookEvents by user: got %v, %v", mine, err)
+	}
+}
+
+func TestOutgoingWebhooks(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	walt, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+	jesse, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "jesse@breakingbad.com"})
+	owner := func(id uuid.UUID) uuid.NullUUID { return uuid.NullUUID{UUID: id, Valid: true} }
+
+	subs := map[string]database.WebhookSubscription{}
+	for name, arg := range map[string]database.CreateWebhookSubscriptionParams{
+		"admin": {Url: "https://admin.example", Events: "chirp.created user.upgraded"},
+		"walt":  {UserID: owner(walt.ID), Url: "https://walt.example", Events: "chirp.created user.upgraded"},
+		"jesse": {UserID: owner(jesse.ID), Url: "https://jesse.example", Events: "chirp.deleted user.upgraded"},
+	} {
+		sub, err := s.CreateWebhookSubscription(ctx, arg)
+		if err != nil {
+			t.Fatalf("CreateWebhookSubscription %s: %v", name, err)
+		}
+		subs[name] = sub
+	}
+
+	if list, err := s.ListWebhookSubscriptions(ctx, uuid.NullUUID{}); err != nil || len(list) != 1 || list[0].ID != subs["admin"].ID {
+		t.Errorf("admin subscriptions: got %v, %v", list, err)
+	}
+	if list, _ := s.ListWebhookSubscriptions(ctx, owner(walt.ID)); len(list) != 1 || list[0].ID != subs["walt"].ID {
+		t.Errorf("walt's subscriptions: got %v", list)
+	}
+
+	for _, tc := range []struct {
+		arg  database.EnqueueWebhookEventParams
+		want int64
+	}{
+		{database.EnqueueWebhookEventParams{Event: "chirp.created", Payload: "{}"}, 2},
+		{database.EnqueueWebhookEventParams{Event: "chirp.create", Payload: "{}"}, 0},
+		{database.EnqueueWebhookEventParams{Event: "user.upgraded", Payload: "{}", UserID: owner(walt.ID)}, 2},
+	} {
+		if n, err := s.EnqueueWebhookEvent(ctx, tc.arg); err != nil || n != tc.want {
+			t.Errorf("EnqueueWebhookEvent %s: got %d, %v, want %d", tc.arg.Event, n, err, tc.want)
+		}
+	}
+
+	now := time.Now().UTC()
+	claimed, err := s.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{Now: now, LeaseUntil: now.Add(time.Minute), MaxDeliveries: 3})
+	if err != nil || len(claimed) != 3 {
+		t.Fatalf("ClaimWebhookDeliveries: got %d, %v", len(claimed), err)
+	}
+	if again, _ := s.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{Now: now, LeaseUntil: now.Add(time.Minute), MaxDeliveries: 10}); len(again) != 1 {
+		t.Errorf("leased deliveries were claimed again: got %d", len(again))
+	}
+
+	err = s.FinishWebhookAttempt(ctx, database.FinishWebhookAttemptParams{
+		ID:             claimed[0].ID,
+		Status:         "delivered",
+		NextAttemptAt:  now,
+		ResponseStatus: sql.NullInt32{Int32: 200, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("FinishWebhookAttempt: %v", err)
+	}
+	top := time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	deliveries, err := s.ListWebhookDeliveries(ctx, database.ListWebhookDeliveriesParams{
+		SubscriptionID:  claimed[0].SubscriptionID,
+		CursorCreatedAt: top,
+		CursorID:        uuid.Max,
+		PageSize:        10,
+	})
+	if err != nil {
+		t.Fatalf("ListWebhookDeliveries: %v", err)
+	}
+	var found bool
+	for _, d := range deliveries {
+		if d.ID == claimed[0].ID {
+			found = true
+			if d.Status != "delivered" || d.Attempts != 1 || d.ResponseStatus.Int32 != 200 || !d.DeliveredAt.Valid {
+				t.Errorf("delivered: got %+v", d)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("delivery missing from the log")
+	}
+
+	if n, _ := s.DeleteWebhookSubscription(ctx, database.DeleteWebhookSubscriptionParams{ID: subs["walt"].ID}); n != 0 {
+		t.Errorf("deleted walt's subscription as admin")
+	}
+	if n, _ := s.DeleteWebhookSubscription(ctx, database.DeleteWebhookSubscriptionParams{ID: subs["walt"].ID, UserID: owner(walt.ID)}); n != 1 {
+		t.Errorf("DeleteWebhookSubscription: got %d rows", n)
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	walt, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+	jesse, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "jesse@breakingbad.com"})
+	chirp, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "Say my name", UserID: walt.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	like, err := s.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID: walt.ID, ActorID: jesse.ID, Kind: "like", ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateNotification: %v", err)
+	}
+	follow, err := s.CreateNotification(ctx, database.CreateNotificationParams{UserID: walt.ID, ActorID: jesse.ID, Kind: "follow"})
+	if err != nil {
+		t.Fatalf("CreateNotification: %v", err)
+	}
+	for _, again := range []database.CreateNotificationParams{
+		{UserID: walt.ID, ActorID: jesse.ID, Kind: "like", ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true}},
+		{UserID: walt.ID, ActorID: jesse.ID, Kind: "follow"},
+	} {
+		if _, err := s.CreateNotification(ctx, again); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("repeated %s: want sql.ErrNoRows, got %v", again.Kind, err)
+		}
+	}
+	if _, err := s.CreateNotification(ctx, database.CreateNotificationParams{UserID: walt.ID, ActorID: jesse.ID, Kind: "poke"}); err == nil {
+		t.Error("CreateNotification with an unknown kind: want an error")
+	}
+
+	if _, err := s.GetNotificationPreferences(ctx, walt.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetNotificationPreferences before setting them: want sql.ErrNoRows, got %v", err)
+	}
+	p, err := s.SetNotificationPreferences(ctx, database.SetNotificationPreferencesParams{UserID: walt.ID, Mentions: true, Replies: true, Follows: true})
+	if err != nil || p.Likes || !p.Follows {
+		t.Fatalf("SetNotificationPreferences: got %+v, %v", p, err)
+	}
+	if _, err := s.CreateNotification(ctx, database.CreateNotificationParams{UserID: walt.ID, ActorID: jesse.ID, Kind: "like"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("muted like: want sql.ErrNoRows, got %v", err)
+	}
+
+	list := func(unread bool) []uuid.UUID {
+		t.Helper()
+		rows, err := s.ListNotifications(ctx, database.ListNotificationsParams{
+			UserID: walt.ID, UnreadOnly: unread, CursorCreatedAt: time.Now().Add(time.Hour), CursorID: uuid.Max, PageSize: 10,
+		})
+		if err != nil {
+			t.Fatalf("ListNotifications: %v", err)
+		}
+		var ids []uuid.UUID
+		for _, n := range rows {
+			ids = append(ids, n.ID)
+		}
+		return ids
+	}
+	if got := list(true); !slices.Equal(got, []uuid.UUID{follow.ID, like.ID}) {
+		t.Errorf("unread: want follow then like, got %v", got)
+	}
+	if n, err := s.MarkNotificationsRead(ctx, database.MarkNotificationsReadParams{UserID: walt.ID, Ids: []uuid.UUID{like.ID}}); err != nil || n != 1 {
+		t.Errorf("MarkNotificationsRead: got %d, %v", n, err)
+	}
+	if n, _ := s.MarkNotificationsRead(ctx, database.MarkNotificationsReadParams{UserID: jesse.ID, Ids: []uuid.UUID{follow.ID}}); n != 0 {
+		t.Errorf("MarkNotificationsRead of someone else's: got %d", n)
+	}
+	if got := list(true); !slices.Equal(got, []uuid.UUID{follow.ID}) {
+		t.Errorf("unread after marking: want follow, got %v", got)
+	}
+	if n, _ := s.MarkAllNotificationsRead(ctx, walt.ID); n != 1 {
+		t.Errorf("MarkAllNotificationsRead: got %d", n)
+	}
+	if got := list(true); len(got) != 0 {
+		t.Errorf("unread after marking all: got %v", got)
+	}
+
+	if err := s.DeleteChirp(ctx, chirp.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := list(false); !slices.Equal(got, []uuid.UUID{follow.ID}) {
+		t.Errorf("after deleting the liked chirp: want follow, got %v", got)
+	}
+}
+
+func TestModeration(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	walt, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+	jesse, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "jesse@breakingbad.com"})
+	parent, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "Say my name", UserID: walt.ID})
+	chirp, err := s.CreateChirp(ctx, database.CreateChirpParams{
+		Body: "Heisenberg", UserID: walt.ID, InReplyTo: uuid.NullUUID{UUID: parent.ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, _ := s.CreateChirp(ctx, database.CreateChirpParams{
+		Body: "You're goddamn right", UserID: jesse.ID, InReplyTo: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+	})
+
+	if _, err := s.CreateChirpReport(ctx, database.CreateChirpReportParams{ChirpID: chirp.ID, ReporterID: uuid.NullUUID{UUID: jesse.ID, Valid: true}, Reason: "spam"}); err != nil {
+		t.Fatalf("CreateChirpReport: %v", err)
+	}
+	if _, err := s.CreateChirpReport(ctx, database.CreateChirpReportParams{ChirpID: chirp.ID, ReporterID: uuid.NullUUID{UUID: jesse.ID, Valid: true}, Reason: "again"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("second report: want sql.ErrNoRows, got %v", err)
+	}
+	queue := func() []database.ListModerationQueueRow {
+		t.Helper()
+		rows, err := s.ListModerationQueue(ctx, database.ListModerationQueueParams{CursorCreatedAt: time.Time{}, PageSize: 10})
+		if err != nil {
+			t.Fatalf("ListModerationQueue: %v", err)
+		}
+		return rows
+	}
+	for range 2 {
+		if f, err := s.CreateChirpFlag(ctx, database.CreateChirpFlagParams{ChirpID: chirp.ID, Reason: "flagged words: heisenberg"}); err != nil || f.ReporterID.Valid {
+			t.Fatalf("CreateChirpFlag: got %+v, %v", f, err)
+		}
+	}
+	if q := queue(); len(q) != 1 || q[0].Chirp.ID != chirp.ID || q[0].OpenReports != 3 || q[0].FirstReportedAt.IsZero() {
+		t.Fatalf("queue: got %+v", q)
+	}
+
+	if _, err := s.ModerateChirp(ctx, database.ModerateChirpParams{ChirpID: chirp.ID, ModeratorID: uuid.NullUUID{UUID: jesse.ID, Valid: true}, Action: "hide", Reason: "spam"}); err != nil {
+		t.Fatalf("hide: %v", err)
+	}
+	if _, err := s.GetChirp(ctx, chirp.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetChirp of a hidden chirp: want sql.ErrNoRows, got %v", err)
+	}
+	rows, _ := s.ListChirpsDesc(ctx, database.ListChirpsDescParams{CursorCreatedAt: time.Now().Add(time.Hour), CursorID: uuid.Max, PageSize: 10})
+	if len(rows) != 2 {
+		t.Errorf("ListChirpsDesc with a hidden chirp: want 2 chirps, got %d", len(rows))
+	}
+	ancestors, err := s.ListChirpAncestors(ctx, database.ListChirpAncestorsParams{ID: reply.ID, MaxDepth: 10})
+	if err != nil || len(ancestors) != 1 || ancestors[0].ID != parent.ID {
+		t.Errorf("ListChirpAncestors past a hidden chirp: got %v, %v", ancestors, err)
+	}
+	if tree, err := s.ListChirpDescendants(ctx, database.ListChirpDescendantsParams{ID: parent.ID, MaxDepth: 10, PageSize: 10}); err != nil || len(tree) != 0 {
+		t.Errorf("ListChirpDescendants below a hidden chirp: got %v, %v", tree, err)
+	}
+	if q := queue(); len(q) != 0 {
+		t.Errorf("queue after hide: got %d chirps", len(q))
+	}
+
+	if _, err := s.ModerateChirp(ctx, database.ModerateChirpParams{ChirpID: chirp.ID, Action: "restore"}); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if _, err := s.GetChirp(ctx, chirp.ID); err != nil {
+		t.Errorf("GetChirp after restore: %v", err)
+	}
+	if _, err := s.RemoveChirp(ctx, database.RemoveChirpParams{ChirpID: chirp.ID, Reason: "for good"}); err != nil {
+		t.Fatalf("RemoveChirp: %v", err)
+	}
+	if _, err := s.ModerateChirp(ctx, database.ModerateChirpParams{ChirpID: chirp.ID, Action: "hide"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("hide a removed chirp: want sql.ErrNoRows, got %v", err)
+	}
+
+	actions, err := s.ListModerationActions(ctx, database.ListModerationActionsParams{
+		ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true}, CursorCreatedAt: time.Now().Add(time.Hour), CursorID: uuid.Max, PageSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("ListModerationActions: %v", err)
+	}
+	var got []string
+	for _, a := range actions {
+		got = append(got, a.Action)
+	}
+	if !slices.Equal(got, []string{"remove", "restore", "hide"}) {
+		t.Errorf("actions: want remove, restore, hide, got %v", got)
+	}
+	if actions[2].ModeratorID.UUID != jesse.ID || actions[2].ChirpUserID != walt.ID {
+		t.Errorf("hide action: got %+v", actions[2])
+	}
+}
@@ -0,0 +1,224 @@
+// Package store defines the persistence interface the HTTP handlers depend on.
+//
+// The sqlc generated *database.Queries is the Postgres implementation; the
+// memory subpackage provides an in-process implementation with the same
+// semantics for tests. Implementations report missing rows with
+// sql.ErrNoRows, exactly like database/sql does.
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type Users interface {
+	CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error)
+	GetUser(ctx context.Context, id uuid.UUID) (database.User, error)
+	LookUpUserByEmail(ctx context.Context, email string) (database.User, error)
+	UpdateEmailAndPW(ctx context.Context, arg database.UpdateEmailAndPWParams) (database.User, error)
+	UpgradeChirpyPlus(ctx context.Context, id uuid.UUID) (database.User, error)
+	DowngradeChirpyPlus(ctx context.Context, id uuid.UUID) (database.User, error)
+	SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (database.User, error)
+	// SuspendUser suspends a user until SuspendedUntil, or for good if it is
+	// null, and revokes their refresh tokens, atomically.
+	SuspendUser(ctx context.Context, arg database.SuspendUserParams) (database.User, error)
+	UnsuspendUser(ctx context.Context, id uuid.UUID) (database.User, error)
+}
+
+type Chirps interface {
+	// CreateChirp stores the chirp together with its hashtags and
+	// mentions, atomically.
+	CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error)
+	GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	DeleteChirp(ctx context.Context, id uuid.UUID) error
+	// UpdateChirp replaces the body and its hashtags and mentions, and keeps
+	// the previous body as a revision, atomically.
+	UpdateChirp(ctx context.Context, arg database.UpdateChirpParams) (database.Chirp, error)
+	ListChirpRevisions(ctx context.Context, chirpID uuid.UUID) ([]database.ChirpRevision, error)
+	ListChirps(ctx context.Context, arg database.ListChirpsParams) ([]database.Chirp, error)
+	ListChirpsDesc(ctx context.Context, arg database.ListChirpsDescParams) ([]database.Chirp, error)
+	ListChirpsByAuthor(ctx context.Context, arg database.ListChirpsByAuthorParams) ([]database.Chirp, error)
+	ListChirpsByAuthorDesc(ctx context.Context, arg database.ListChirpsByAuthorDescParams) ([]database.Chirp, error)
+	// ListChirpDescendants returns one page of the replies below a chirp,
+	// at any depth up to MaxDepth, ordered by (created_at, id).
+	ListChirpDescendants(ctx context.Context, arg database.ListChirpDescendantsParams) ([]database.ListChirpDescendantsRow, error)
+	// ListChirpAncestors returns the chain of parents of a reply, nearest
+	// first, stopping after MaxDepth chirps.
+	ListChirpAncestors(ctx context.Context, arg database.ListChirpAncestorsParams) ([]database.Chirp, error)
+	// CountReplies returns a row for every chirp in chirpIds that has replies.
+	CountReplies(ctx context.Context, chirpIds []uuid.UUID) ([]database.CountRepliesRow, error)
+	// ListChirpHashtags and ListChirpMentions return the entities of every
+	// chirp in chirpIds, ordered by chirp and then by offset.
+	ListChirpHashtags(ctx context.Context, chirpIds []uuid.UUID) ([]database.ChirpHashtag, error)
+	ListChirpMentions(ctx context.Context, chirpIds []uuid.UUID) ([]database.ChirpMention, error)
+	ListChirpsByHashtag(ctx context.Context, arg database.ListChirpsByHashtagParams) ([]database.Chirp, error)
+	ListChirpsMentioning(ctx context.Context, arg database.ListChirpsMentioningParams) ([]database.Chirp, error)
+	// SearchChirps pages through the chirps matching Query, most relevant
+	// first. Query is in the tsquery syntax produced by search.Query.TSQuery.
+	SearchChirps(ctx context.Context, arg database.SearchChirpsParams) ([]database.SearchChirpsRow, error)
+	// SearchChirpsRecent is SearchChirps ordered newest first.
+	SearchChirpsRecent(ctx context.Context, arg database.SearchChirpsRecentParams) ([]database.Chirp, error)
+}
+
+// Follows is the follow graph. FollowUser and UnfollowUser report 0 rows
+// when there was nothing to change.
+type Follows interface {
+	FollowUser(ctx context.Context, arg database.FollowUserParams) (int64, error)
+	UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) (int64, error)
+	ListFollowers(ctx context.Context, arg database.ListFollowersParams) ([]database.Follow, error)
+	ListFollowing(ctx context.Context, arg database.ListFollowingParams) ([]database.Follow, error)
+	// ListTimeline pages backwards through chirps by the users that UserID
+	// follows.
+	ListTimeline(ctx context.Context, arg database.ListTimelineParams) ([]database.Chirp, error)
+}
+
+// Likes records which users liked which chirps. LikeChirp and UnlikeChirp
+// report 0 rows when there was nothing to change.
+type Likes interface {
+	LikeChirp(ctx context.Context, arg database.LikeChirpParams) (int64, error)
+	UnlikeChirp(ctx context.Context, arg database.UnlikeChirpParams) (int64, error)
+	// CountLikes returns a row for every chirp in chirpIds that has likes.
+	CountLikes(ctx context.Context, chirpIds []uuid.UUID) ([]database.CountLikesRow, error)
+	// ListLikedChirpIDs returns the subset of ChirpIds that UserID liked.
+	ListLikedChirpIDs(ctx context.Context, arg database.ListLikedChirpIDsParams) ([]uuid.UUID, error)
+	// ListLikes pages backwards through the chirps UserID liked, most
+	// recently liked first.
+	ListLikes(ctx context.Context, arg database.ListLikesParams) ([]database.ListLikesRow, error)
+}
+
+// ScheduledChirps holds chirps that are published later. DeleteScheduledChirp
+// only deletes a chirp that belongs to UserID and reports 0 rows otherwise.
+type ScheduledChirps interface {
+	CreateScheduledChirp(ctx context.Context, arg database.CreateScheduledChirpParams) (database.ScheduledChirp, error)
+	// ListScheduledChirps returns a user's scheduled chirps, soonest first.
+	ListScheduledChirps(ctx context.Context, userID uuid.UUID) ([]database.ScheduledChirp, error)
+	DeleteScheduledChirp(ctx context.Context, arg database.DeleteScheduledChirpParams) (int64, error)
+	// ClaimDueScheduledChirps deletes and returns every chirp scheduled for
+	// now or earlier. Each chirp is returned to exactly one caller.
+	ClaimDueScheduledChirps(ctx context.Context, now time.Time) ([]database.ScheduledChirp, error)
+}
+
+// BannedWords is the moderation word list. Actions are "mask", "flag" or
+// "reject".
+type BannedWords interface {
+	ListBannedWords(ctx context.Context) ([]database.BannedWord, error)
+	// UpsertBannedWord adds a word, or changes the action of one that is
+	// already listed.
+	UpsertBannedWord(ctx context.Context, arg database.UpsertBannedWordParams) (database.BannedWord, error)
+	// ImportBannedWords upserts many words at once, atomically.
+	ImportBannedWords(ctx context.Context, arg database.ImportBannedWordsParams) (int64, error)
+	DeleteBannedWord(ctx context.Context, word string) (int64, error)
+}
+
+// WebhookEvents is the audit log of incoming webhooks.
+type WebhookEvents interface {
+	// RecordWebhookEvent stores an event with status "received". If an event
+	// with the same Source and EventID is already stored, it increments its
+	// Deliveries instead and returns it unchanged otherwise. The insert is
+	// atomic, so of concurrent deliveries only the one that stored the event
+	// gets Deliveries 1 back.
+	RecordWebhookEvent(ctx context.Context, arg database.RecordWebhookEventParams) (database.WebhookEvent, error)
+	SetWebhookEventStatus(ctx context.Context, arg database.SetWebhookEventStatusParams) error
+	// ListWebhookEvents pages backwards through the log, optionally only
+	// the events about UserID.
+	ListWebhookEvents(ctx context.Context, arg database.ListWebhookEventsParams) ([]database.WebhookEvent, error)
+}
+
+// Notifications tell users about mentions, replies, likes and follows.
+type Notifications interface {
+	// CreateNotification adds a notification unless the user has turned
+	// its Kind off, or already has one with the same actor, Kind and
+	// chirp, in which case it returns sql.ErrNoRows.
+	CreateNotification(ctx context.Context, arg database.CreateNotificationParams) (database.Notification, error)
+	// ListNotifications pages backwards through a user's notifications,
+	// newest first, or only the unread ones if UnreadOnly is set.
+	ListNotifications(ctx context.Context, arg database.ListNotificationsParams) ([]database.Notification, error)
+	// MarkNotificationsRead and MarkAllNotificationsRead report how many
+	// unread notifications they marked.
+	MarkNotificationsRead(ctx context.Context, arg database.MarkNotificationsReadParams) (int64, error)
+	MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) (int64, error)
+	// GetNotificationPreferences returns sql.ErrNoRows for a user who has
+	// never changed them.
+	GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (database.NotificationPreference, error)
+	SetNotificationPreferences(ctx context.Context, arg database.SetNotificationPreferencesParams) (database.NotificationPreference, error)
+}
+
+// Moderation holds users' reports of chirps and the audit trail of what
+// moderators did about them. Hidden chirps are left out of every Chirps
+// lookup and listing.
+type Moderation interface {
+	// CreateChirpReport returns sql.ErrNoRows if ReporterID has already
+	// reported the chirp.
+	CreateChirpReport(ctx context.Context, arg database.CreateChirpReportParams) (database.ChirpReport, error)
+	// CreateChirpFlag queues a chirp for review with a report that has no
+	// reporter, on behalf of the banned word list.
+	CreateChirpFlag(ctx context.Context, arg database.CreateChirpFlagParams) (database.ChirpReport, error)
+	// ListModerationQueue pages through the chirps with open reports, hidden
+	// or not, the one reported longest ago first.
+	ListModerationQueue(ctx context.Context, arg database.ListModerationQueueParams) ([]database.ListModerationQueueRow, error)
+	ListOpenChirpReports(ctx context.Context, chirpIds []uuid.UUID) ([]database.ChirpReport, error)
+	// ModerateChirp hides ("hide") or restores ("restore") a chirp, or
+	// leaves it be ("dismiss"), resolves its open reports and records the
+	// action, atomically. It returns sql.ErrNoRows if there is no such chirp.
+	ModerateChirp(ctx context.Context, arg database.ModerateChirpParams) (database.ModerationAction, error)
+	// RemoveChirp deletes a chirp, hidden or not, and records the action,
+	// atomically. It returns sql.ErrNoRows if there is no such chirp.
+	RemoveChirp(ctx context.Context, arg database.RemoveChirpParams) (database.ModerationAction, error)
+	// ListModerationActions pages backwards through the audit trail,
+	// optionally only the actions about ChirpID.
+	ListModerationActions(ctx context.Context, arg database.ListModerationActionsParams) ([]database.ModerationAction, error)
+}
+
+// OutgoingWebhooks holds the webhook subscriptions of users and admins and
+// the outbox of deliveries to them. Subscriptions with a null UserID belong
+// to the admins; the list and delete methods only match subscriptions of
+// exactly the given owner.
+type OutgoingWebhooks interface {
+	CreateWebhookSubscription(ctx context.Context, arg database.CreateWebhookSubscriptionParams) (database.WebhookSubscription, error)
+	GetWebhookSubscription(ctx context.Context, id uuid.UUID) (database.WebhookSubscription, error)
+	ListWebhookSubscriptions(ctx context.Context, userID uuid.NullUUID) ([]database.WebhookSubscription, error)
+	DeleteWebhookSubscription(ctx context.Context, arg database.DeleteWebhookSubscriptionParams) (int64, error)
+	// EnqueueWebhookEvent adds a pending delivery for every subscription to
+	// Event, atomically. If UserID is set, the event is private to that user
+	// and the admins.
+	EnqueueWebhookEvent(ctx context.Context, arg database.EnqueueWebhookEventParams) (int64, error)
+	// ClaimWebhookDeliveries leases due deliveries until LeaseUntil. A
+	// delivery is only handed to one caller per lease.
+	ClaimWebhookDeliveries(ctx context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.WebhookDelivery, error)
+	// FinishWebhookAttempt counts an attempt and records its outcome.
+	FinishWebhookAttempt(ctx context.Context, arg database.FinishWebhookAttemptParams) error
+	// ListWebhookDeliveries pages backwards through a subscription's
+	// deliveries, newest first.
+	ListWebhookDeliveries(ctx context.Context, arg database.ListWebhookDeliveriesParams) ([]database.WebhookDelivery, error)
+}
+
+type RefreshTokens interface {
+	CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error)
+	GetUserFromRefreshToken(ctx context.Context, token string) (database.GetUserFromRefreshTokenRow, error)
+	RevokeRefreshToken(ctx context.Context, token string) (int64, error)
+}
+
+// Store is everything the API needs from its backend.
+type Store interface {
+	Users
+	Chirps
+	Follows
+	Likes
+	ScheduledChirps
+	BannedWords
+	WebhookEvents
+	OutgoingWebhooks
+	Notifications
+	Moderation
+	RefreshTokens
+
+	// Reset deletes all users and, through them, all of their data. The
+	// banned word list, the webhook log and the moderation audit trail are
+	// kept.
+	Reset(ctx context.Context) error
+}
+
+var _ Store = (*database.Queries)(nil)
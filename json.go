@@ -2,16 +2,17 @@ package main
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 )
 
+// respondWithError responds with msg as a JSON error. err, the cause, is
+// only logged, along with the request ID.
 func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
-	if err != nil {
-		log.Println(err)
-	}
+	logger := loggerFor(w)
 	if code > 499 {
-		log.Printf("Responding with 5XX error: %s", msg)
+		logger.Error("responding with 5XX error", "status", code, "response", msg, "error", err)
+	} else if err != nil {
+		logger.Info("responding with error", "status", code, "response", msg, "error", err)
 	}
 	type errorResponse struct {
 		Error string `jsond:"error"`
@@ -25,7 +26,7 @@ func respondWithJSON(w http.ResponseWriter, code int, payload any) {
 	w.Header().Set("Content-Type", "application/json")
 	dat, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
+		loggerFor(w).Error("error marshalling JSON", "error", err)
 		w.WriteHeader(500)
 		return
 	}
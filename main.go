@@ -1,22 +1,43 @@
 package main
 
 import (
-	"database/sql"
-	"github.com/Geraetefreund/chirpy/internal/database"
+	"context"
+	"fmt"
+	"github.com/Geraetefreund/chirpy/internal/auth"
+	"github.com/Geraetefreund/chirpy/internal/moderation"
+	"github.com/Geraetefreund/chirpy/internal/store"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
-	db             *database.Queries
+	db             store.Store
+	moderator      *moderation.Moderator
 	platform       string
 	secret         string
-	polkaKey       string
+	polkaKeys      []string
+	// polkaRequireSignature refuses Polka deliveries that are not signed.
+	polkaRequireSignature bool
+	adminKey              string
+	// metricsToken is the bearer token a scraper needs for /metrics.
+	metricsToken  string
+	plans         plans
+	chirpLimiter  chirpRateLimiter
+	webhookClient *http.Client
+	chirpHub      *chirpHub
+	userHub       *userHub
+	wsConns       *connLimiter
+	metrics       *metrics
 }
 
 func main() {
@@ -26,6 +47,7 @@ func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Fatalf("error loading dotenv@")
 	}
+	slog.SetDefault(newLogger(os.Getenv("LOG_FORMAT")))
 
 	dbURL := os.Getenv("DB_URL")
 
@@ -33,43 +55,198 @@ func main() {
 		log.Fatal("DB_URL must be set")
 	}
 
-	dbConn, err := sql.Open("postgres", dbURL)
+	metrics := newMetrics()
+	b, err := openBackend(dbURL, metrics.observeQuery)
 	if err != nil {
 		log.Fatalf("error opening database: %s", err)
 	}
-	dbQueries := database.New(dbConn)
 
-	apiCfg := apiConfig{
-		fileserverHits: atomic.Int32{},
-		db:             dbQueries,
-		platform:       os.Getenv("PLATFORM"),
-		secret:         os.Getenv("SECRET"),
-		polkaKey:       os.Getenv("POLKA_KEY"),
+	ctx := context.Background()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(ctx, b, os.Args[2:]))
+	}
+	if err := prepareSchema(ctx, b, os.Getenv("MIGRATE_ON_START") == "true"); err != nil {
+		log.Fatalf("error preparing database: %s", err)
 	}
 
-	mux := http.NewServeMux()
-	fsHandler := apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot))))
-	mux.Handle("/app/", fsHandler)
+	bannedWords := moderation.Merge{storeBannedWords{b.store}}
+	if path := os.Getenv("MODERATION_WORDS_FILE"); path != "" {
+		bannedWords = append(bannedWords, moderation.File(path))
+	}
+	moderator, err := moderation.New(ctx, bannedWords)
+	if err != nil {
+		log.Fatalf("error loading banned words: %s", err)
+	}
+	go reloadOnHangup(ctx, moderator)
 
-	mux.HandleFunc("GET /api/healthz", handlerReadiness)
-	mux.HandleFunc("POST /api/users", apiCfg.handlerUsersCreate)
-	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateEmailAndPW)
-	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetAllChirpsByID)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirpByID)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirpByID)
-	mux.HandleFunc("POST /api/chirps", apiCfg.handlerChirpsCreate)
-	mux.HandleFunc("POST /api/login", apiCfg.handlerUsersLogin)
-	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefreshToken)
-	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevokeToken)
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.webhookChirpyRed)
-
-	mux.HandleFunc("POST /admin/reset", apiCfg.handlerTruncateUsersChirps)
-	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics)
+	wsMaxConns := 5
+	if err := envInt("WS_MAX_CONNECTIONS_PER_USER", &wsMaxConns); err != nil {
+		log.Fatal(err)
+	}
+
+	plans := defaultPlans
+	for key, v := range map[string]*int{
+		"MAX_CHIRP_LENGTH":      &plans.Standard.MaxChirpLength,
+		"MAX_CHIRP_LENGTH_RED":  &plans.Red.MaxChirpLength,
+		"CHIRPS_PER_MINUTE":     &plans.Standard.ChirpsPerMinute,
+		"CHIRPS_PER_MINUTE_RED": &plans.Red.ChirpsPerMinute,
+	} {
+		if err := envInt(key, v); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	apiCfg := &apiConfig{
+		fileserverHits:        atomic.Int32{},
+		db:                    b.store,
+		moderator:             moderator,
+		platform:              os.Getenv("PLATFORM"),
+		secret:                os.Getenv("SECRET"),
+		polkaKeys:             splitList(os.Getenv("POLKA_KEY")),
+		polkaRequireSignature: os.Getenv("POLKA_REQUIRE_SIGNATURE") == "true",
+		adminKey:              os.Getenv("ADMIN_API_KEY"),
+		metricsToken:          os.Getenv("METRICS_TOKEN"),
+		plans:                 plans,
+		webhookClient:         newWebhookClient(os.Getenv("WEBHOOK_ALLOW_PRIVATE") == "true"),
+		chirpHub:              newChirpHub(),
+		userHub:               newUserHub(),
+		wsConns:               newConnLimiter(wsMaxConns),
+		metrics:               metrics,
+	}
+	go apiCfg.publishScheduledChirps(ctx, 15*time.Second)
+	go apiCfg.deliverWebhooks(ctx, 5*time.Second)
 
 	srv := &http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: apiCfg.routes(filepathRoot),
 	}
 	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
 	log.Fatal(srv.ListenAndServe())
 }
+
+// newLogger logs to stderr as text, or as JSON if format is "json".
+func newLogger(format string) *slog.Logger {
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, nil))
+}
+
+// envInt sets *v from the environment variable key if it is set.
+func envInt(key string, v *int) error {
+	s := os.Getenv(key)
+	if s == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("%s must be a positive integer, got %q", key, s)
+	}
+	*v = n
+	return nil
+}
+
+// splitList splits a comma separated environment variable, dropping empty
+// entries.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// routes registers every endpoint on a new mux behind the middleware every
+// request goes through. The file server serves filepathRoot under /app/.
+func (cfg *apiConfig) routes(filepathRoot string) http.Handler {
+	mux := http.NewServeMux()
+	fsHandler := cfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot))))
+	mux.Handle("/app/", fsHandler)
+
+	mux.HandleFunc("GET /api/healthz", handlerReadiness)
+	mux.HandleFunc("GET /metrics", cfg.requireMetricsToken(cfg.metrics.handler()))
+	mux.HandleFunc("POST /api/users", cfg.handlerUsersCreate)
+	mux.HandleFunc("PUT /api/users", cfg.handlerUpdateEmailAndPW)
+	mux.HandleFunc("POST /api/users/{userID}/follow", cfg.handlerFollow)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", cfg.handlerUnfollow)
+	mux.HandleFunc("GET /api/users/{userID}/followers", cfg.handlerListFollowers)
+	mux.HandleFunc("GET /api/users/{userID}/following", cfg.handlerListFollowing)
+	mux.HandleFunc("GET /api/users/{userID}/likes", cfg.handlerListUserLikes)
+	mux.HandleFunc("GET /api/users/{userID}/mentions", cfg.handlerUserMentions)
+	mux.HandleFunc("GET /api/tags/{tag}/chirps", cfg.handlerChirpsByTag)
+	mux.HandleFunc("GET /api/timeline", cfg.handlerTimeline)
+	mux.HandleFunc("GET /api/notifications", cfg.handlerListNotifications)
+	mux.HandleFunc("POST /api/notifications/read", cfg.handlerMarkNotificationsRead)
+	mux.HandleFunc("GET /api/notifications/preferences", cfg.handlerGetNotificationPreferences)
+	mux.HandleFunc("PUT /api/notifications/preferences", cfg.handlerSetNotificationPreferences)
+	mux.HandleFunc("GET /api/chirps", cfg.handlerGetAllChirpsByID)
+	mux.HandleFunc("GET /api/chirps/search", cfg.handlerChirpsSearch)
+	mux.HandleFunc("GET /api/chirps/stream", cfg.handlerChirpStream)
+	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.handlerGetChirpByID)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.handlerDeleteChirpByID)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", cfg.handlerChirpsUpdate)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", cfg.handlerChirpRevisions)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", cfg.handlerChirpThread)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", cfg.handlerLikeChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", cfg.handlerUnlikeChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/reports", cfg.handlerReportChirp)
+	mux.HandleFunc("POST /api/chirps", cfg.handlerChirpsCreate)
+	mux.HandleFunc("GET /api/scheduled_chirps", cfg.handlerListScheduledChirps)
+	mux.HandleFunc("DELETE /api/scheduled_chirps/{chirpID}", cfg.handlerDeleteScheduledChirp)
+	mux.HandleFunc("POST /api/validate_chirp", cfg.handlerChirpsValidate)
+	mux.HandleFunc("POST /api/login", cfg.handlerUsersLogin)
+	mux.HandleFunc("POST /api/refresh", cfg.handlerRefreshToken)
+	mux.HandleFunc("POST /api/revoke", cfg.handlerRevokeToken)
+	mux.HandleFunc("POST /api/polka/webhooks", cfg.webhookChirpyRed)
+	mux.HandleFunc("GET /api/ws", cfg.handlerWebSocket)
+	mux.HandleFunc("GET /api/webhooks", cfg.handlerListWebhookSubscriptions(cfg.webhookUser))
+	mux.HandleFunc("POST /api/webhooks", cfg.handlerCreateWebhookSubscription(cfg.webhookUser))
+	mux.HandleFunc("DELETE /api/webhooks/{webhookID}", cfg.handlerDeleteWebhookSubscription(cfg.webhookUser))
+	mux.HandleFunc("GET /api/webhooks/{webhookID}/deliveries", cfg.handlerListWebhookDeliveries(cfg.webhookUser))
+
+	mux.HandleFunc("POST /admin/reset", cfg.requireRole(auth.RoleAdmin, cfg.handlerTruncateUsersChirps))
+	mux.HandleFunc("GET /admin/metrics", cfg.requireRole(auth.RoleAdmin, cfg.handlerMetrics))
+	mux.HandleFunc("GET /admin/moderation/words", cfg.requireRole(auth.RoleModerator, cfg.handlerListBannedWords))
+	mux.HandleFunc("POST /admin/moderation/words", cfg.requireRole(auth.RoleModerator, cfg.handlerAddBannedWord))
+	mux.HandleFunc("POST /admin/moderation/words/import", cfg.requireRole(auth.RoleModerator, cfg.handlerImportBannedWords))
+	mux.HandleFunc("DELETE /admin/moderation/words/{word}", cfg.requireRole(auth.RoleModerator, cfg.handlerDeleteBannedWord))
+	mux.HandleFunc("GET /admin/moderation/reports", cfg.requireRole(auth.RoleModerator, cfg.handlerModerationQueue))
+	mux.HandleFunc("GET /admin/moderation/actions", cfg.requireRole(auth.RoleModerator, cfg.handlerListModerationActions))
+	mux.HandleFunc("POST /admin/moderation/chirps/{chirpID}/hide", cfg.requireRole(auth.RoleModerator, cfg.handlerModerateChirp(moderationHide)))
+	mux.HandleFunc("POST /admin/moderation/chirps/{chirpID}/restore", cfg.requireRole(auth.RoleModerator, cfg.handlerModerateChirp(moderationRestore)))
+	mux.HandleFunc("POST /admin/moderation/chirps/{chirpID}/dismiss", cfg.requireRole(auth.RoleModerator, cfg.handlerModerateChirp(moderationDismiss)))
+	mux.HandleFunc("DELETE /admin/moderation/chirps/{chirpID}", cfg.requireRole(auth.RoleModerator, cfg.handlerModerateChirp(moderationRemove)))
+	mux.HandleFunc("GET /admin/webhooks/events", cfg.requireRole(auth.RoleAdmin, cfg.handlerListWebhookEvents))
+	mux.HandleFunc("GET /admin/webhooks", cfg.requireRole(auth.RoleAdmin, cfg.handlerListWebhookSubscriptions(webhookAdmin)))
+	mux.HandleFunc("POST /admin/webhooks", cfg.requireRole(auth.RoleAdmin, cfg.handlerCreateWebhookSubscription(webhookAdmin)))
+	mux.HandleFunc("DELETE /admin/webhooks/{webhookID}", cfg.requireRole(auth.RoleAdmin, cfg.handlerDeleteWebhookSubscription(webhookAdmin)))
+	mux.HandleFunc("GET /admin/webhooks/{webhookID}/deliveries", cfg.requireRole(auth.RoleAdmin, cfg.handlerListWebhookDeliveries(webhookAdmin)))
+	mux.HandleFunc("PUT /admin/users/{userID}/role", cfg.requireRole(auth.RoleAdmin, cfg.handlerSetUserRole))
+	mux.HandleFunc("DELETE /admin/users/{userID}/role", cfg.requireRole(auth.RoleAdmin, cfg.handlerRevokeUserRole))
+	mux.HandleFunc("PUT /admin/users/{userID}/suspension", cfg.requireRole(auth.RoleAdmin, cfg.handlerSuspendUser))
+	mux.HandleFunc("DELETE /admin/users/{userID}/suspension", cfg.requireRole(auth.RoleAdmin, cfg.handlerUnsuspendUser))
+
+	return chain(mux,
+		withRequestID,
+		cfg.accessLog,
+		cfg.instrument,
+		recoverPanic,
+		cfg.rejectSuspended,
+	)
+}
+
+// reloadOnHangup reloads the banned word list whenever the process gets a
+// SIGHUP, so the word list file can be edited without a restart.
+func reloadOnHangup(ctx context.Context, m *moderation.Moderator) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := m.Reload(ctx); err != nil {
+			log.Printf("error reloading banned words: %s", err)
+			continue
+		}
+		log.Printf("reloaded banned words")
+	}
+}
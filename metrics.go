@@ -1,8 +1,17 @@
 package main
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/auth"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func (cfg *apiConfig) handlerMetrics(w http.ResponseWriter, r *http.Request) {
@@ -26,3 +35,153 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// Kinds of streaming connection counted by metrics.streams.
+const (
+	streamSSE       = "sse"
+	streamWebSocket = "websocket"
+)
+
+// Outcomes of a login counted by metrics.logins.
+const (
+	loginSuccess   = "success"
+	loginFailure   = "failure"
+	loginSuspended = "suspended"
+)
+
+// Outcomes of an incoming webhook delivery counted by metrics.webhookEvents.
+// The first three are also the statuses recorded for the event.
+const (
+	webhookProcessed    = "processed"
+	webhookIgnored      = "ignored"
+	webhookFailed       = "failed"
+	webhookDuplicate    = "duplicate"
+	webhookUnauthorized = "unauthorized"
+	webhookBadRequest   = "bad_request"
+	webhookError        = "error"
+)
+
+// metrics are the Prometheus metrics served at /metrics. Each apiConfig has
+// its own registry, so they are not shared between test servers.
+type metrics struct {
+	registry          *prometheus.Registry
+	requests          *prometheus.CounterVec
+	requestDuration   *prometheus.HistogramVec
+	queryDuration     *prometheus.HistogramVec
+	streams           *prometheus.GaugeVec
+	logins            *prometheus.CounterVec
+	webhookDeliveries *prometheus.CounterVec
+	webhookEvents     *prometheus.CounterVec
+}
+
+func newMetrics() *metrics {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	f := promauto.With(reg)
+	return &metrics{
+		registry: reg,
+		requests: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_http_requests_total",
+			Help: "HTTP requests served, by method, route pattern and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_request_duration_seconds",
+			Help:    "Time taken to serve HTTP requests, by method and route pattern.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		queryDuration: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_db_query_duration_seconds",
+			Help:    "Time taken by database queries, by sqlc query name.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"query"}),
+		streams: f.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chirpy_stream_connections",
+			Help: "Open streaming connections, by kind (sse or websocket).",
+		}, []string{"kind"}),
+		logins: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_logins_total",
+			Help: "Login attempts, by result (success, failure or suspended).",
+		}, []string{"result"}),
+		webhookDeliveries: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_webhook_deliveries_total",
+			Help: "Attempts to deliver outgoing webhooks, by the status they left the delivery in.",
+		}, []string{"status"}),
+		webhookEvents: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_webhook_events_total",
+			Help: "Incoming webhook deliveries, by source and result.",
+		}, []string{"source", "result"}),
+	}
+}
+
+// handler serves the metrics in the Prometheus text format.
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// requireMetricsToken only lets through scrapers that send METRICS_TOKEN as
+// a bearer token. The token grants nothing else, so a Prometheus config can
+// hold it without holding admin access. Without a token the endpoint is off.
+func (cfg *apiConfig) requireMetricsToken(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cfg.metricsToken == "" {
+			respondWithError(w, http.StatusNotFound, "metrics are not enabled", nil)
+			return
+		}
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "missing or invalid credentials", err)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.metricsToken)) != 1 {
+			respondWithError(w, http.StatusUnauthorized, "missing or invalid credentials", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// webhookReceived counts an incoming webhook delivery by its result.
+func (m *metrics) webhookReceived(source, result string) {
+	m.webhookEvents.WithLabelValues(source, result).Inc()
+}
+
+// observeQuery records the time a database query took.
+func (m *metrics) observeQuery(name string, d time.Duration) {
+	m.queryDuration.WithLabelValues(name).Observe(d.Seconds())
+}
+
+// streamOpened counts a streaming connection of kind as open and returns
+// the function to call once it closes.
+func (m *metrics) streamOpened(kind string) func() {
+	g := m.streams.WithLabelValues(kind)
+	g.Inc()
+	return g.Dec
+}
+
+// instrument counts and times every request by the route pattern it
+// matched, which keeps the number of series bounded whatever the paths.
+func (cfg *apiConfig) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec, ok := w.(*responseRecorder)
+		if !ok {
+			rec = &responseRecorder{ResponseWriter: w, log: loggerFor(w)}
+		}
+		defer func() {
+			route := r.Pattern
+			if route == "" {
+				route = "unmatched"
+			}
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			cfg.metrics.requests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+			cfg.metrics.requestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
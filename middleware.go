@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// requestIDHeader carries the ID of a request, from the client or a proxy in
+// front of us, and back in the response.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestID is the longest request ID taken from a client.
+const maxRequestID = 128
+
+// middleware wraps a handler in behaviour shared by every route.
+type middleware func(http.Handler) http.Handler
+
+// chain wraps h in mws, the first of which sees the request first.
+func chain(h http.Handler, mws ...middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+type requestIDKey struct{}
+
+// withRequestID gives every request an ID, keeping the one the client sent
+// in X-Request-ID if it is sensible, and echoes it in the response.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// validRequestID accepts IDs of printable ASCII that fit in a log line.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// requestID returns the ID withRequestID gave the request ctx belongs to.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestLogger returns the logger for work done on behalf of the request
+// ctx belongs to, which tags every line with the request ID.
+func requestLogger(ctx context.Context) *slog.Logger {
+	if id := requestID(ctx); id != "" {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}
+
+// responseRecorder remembers the status of a response for the access log
+// and carries the request's logger to respondWithError.
+type responseRecorder struct {
+	http.ResponseWriter
+	log    *slog.Logger
+	status int
+}
+
+func (rec *responseRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController and websocket.Accept reach the
+// flusher and hijacker underneath.
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// loggerFor returns the logger of the request w responds to.
+func loggerFor(w http.ResponseWriter) *slog.Logger {
+	if rec, ok := w.(*responseRecorder); ok {
+		return rec.log
+	}
+	return slog.Default()
+}
+
+// accessLog writes one line per request once it has been served. The route
+// is the pattern the request matched, so requests for different chirps are
+// logged alike; the user is logged if the request carries an access token.
+func (cfg *apiConfig) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w, log: requestLogger(r.Context())}
+		// Deferred so that requests aborted by a panic are logged too.
+		defer func() {
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			attrs := []any{
+				"method", r.Method,
+				"route", r.Pattern,
+				"path", r.URL.Path,
+				"status", status,
+				"duration", time.Since(start),
+			}
+			if userID, err := cfg.authenticate(r); err == nil {
+				attrs = append(attrs, "user_id", userID)
+			}
+			rec.log.Info("request", attrs...)
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// recoverPanic turns a panicking handler into a 500, or, if the handler had
+// already started its response, into a cut-off one.
+func recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			loggerFor(w).Error("panic serving request", "panic", v, "stack", string(debug.Stack()))
+			if rec, ok := w.(*responseRecorder); ok && rec.status != 0 {
+				panic(http.ErrAbortHandler)
+			}
+			respondWithError(w, http.StatusInternalServerError, "internal server error", nil)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/Geraetefreund/chirpy/internal/auth"
+	"github.com/Geraetefreund/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Events that outgoing webhooks can subscribe to. Chirp events go to every
+// subscriber; user events only to the user's own and the admins'
+// subscriptions.
+const (
+	eventChirpCreated   = "chirp.created"
+	eventChirpUpdated   = "chirp.updated"
+	eventChirpDeleted   = "chirp.deleted"
+	eventUserUpgraded   = "user.upgraded"
+	eventUserDowngraded = "user.downgraded"
+)
+
+var webhookEventNames = []string{
+	eventChirpCreated,
+	eventChirpUpdated,
+	eventChirpDeleted,
+	eventUserUpgraded,
+	eventUserDowngraded,
+}
+
+// emit queues event with data as its payload. If about is set the event is
+// private to that user. The change the event describes has already been
+// made, so a failure is logged rather than returned.
+func (cfg *apiConfig) emit(ctx context.Context, event string, about uuid.NullUUID, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		requestLogger(ctx).Error("couldn't encode event", "event", event, "error", err)
+		return
+	}
+	_, err = cfg.db.EnqueueWebhookEvent(ctx, database.EnqueueWebhookEventParams{
+		Event:   event,
+		Payload: string(payload),
+		UserID:  about,
+	})
+	if err != nil {
+		requestLogger(ctx).Error("couldn't queue event", "event", event, "error", err)
+	}
+}
+
+const (
+	webhookBatchSize   = 20
+	webhookTimeout     = 10 * time.Second
+	webhookMaxAttempts = 10
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+)
+
+// webhookBackoff is the wait before retrying a delivery that has failed
+// attempts times: 30s, 1m, 2m, ... up to 6h.
+func webhookBackoff(attempts int32) time.Duration {
+	d := webhookBaseBackoff
+	for i := int32(1); i < attempts && d < webhookMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, webhookMaxBackoff)
+}
+
+// deliverWebhooks works off the outbox every interval until ctx is done.
+func (cfg *apiConfig) deliverWebhooks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for cfg.deliverDueWebhooks(ctx, now) == webhookBatchSize {
+				now = time.Now()
+			}
+		}
+	}
+}
+
+// deliverDueWebhooks sends one batch of deliveries that are due at now and
+// returns how many there were.
+func (cfg *apiConfig) deliverDueWebhooks(ctx context.Context, now time.Time) int {
+	due, err := cfg.db.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		Now:           now,
+		LeaseUntil:    now.Add(webhookBatchSize * webhookTimeout),
+		MaxDeliveries: webhookBatchSize,
+	})
+	if err != nil {
+		slog.Error("couldn't claim webhook deliveries", "error", err)
+		return 0
+	}
+
+	subs := make(map[uuid.UUID]database.WebhookSubscription)
+	for _, d := range due {
+		sub, ok := subs[d.SubscriptionID]
+		if !ok {
+			if sub, err = cfg.db.GetWebhookSubscription(ctx, d.SubscriptionID); err != nil {
+				// Deleted meanwhile, which also deleted the delivery.
+				continue
+			}
+			subs[sub.ID] = sub
+		}
+
+		status, err := cfg.sendWebhook(ctx, sub, d)
+		arg := database.FinishWebhookAttemptParams{
+			ID:             d.ID,
+			Status:         "delivered",
+			NextAttemptAt:  d.NextAttemptAt,
+			ResponseStatus: sql.NullInt32{Int32: int32(status), Valid: status != 0},
+		}
+		if err != nil {
+			arg.LastError = err.Error()
+			arg.Status = "pending"
+			arg.NextAttemptAt = time.Now().Add(webhookBackoff(d.Attempts + 1))
+			if d.Attempts+1 >= webhookMaxAttempts {
+				arg.Status = "failed"
+			}
+		}
+		cfg.metrics.webhookDeliveries.WithLabelValues(arg.Status).Inc()
+		if err := cfg.db.FinishWebhookAttempt(ctx, arg); err != nil {
+			slog.Error("couldn't record webhook delivery", "delivery_id", d.ID, "error", err)
+		}
+	}
+	return len(due)
+}
+
+// webhookPayload is the body of a delivery.
+type webhookPayload struct {
+	ID        uuid.UUID       `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// sendWebhook posts d to sub's URL, signed with sub's secret. It returns the
+// response status, if there was a response, and an error unless it was 2xx.
+func (cfg *apiConfig) sendWebhook(ctx context.Context, sub database.WebhookSubscription, d database.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(webhookPayload{
+		ID:        d.ID,
+		Event:     d.Event,
+		CreatedAt: d.CreatedAt,
+		Data:      json.RawMessage(d.Payload),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks")
+	req.Header.Set("Webhook-Id", d.ID.String())
+	auth.SignWebhook(req.Header, body, time.Now(), sub.Secret)
+
+	resp, err := cfg.webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+var errPrivateAddress = errors.New("webhook URL resolves to a private address")
+
+// newWebhookClient returns the client deliveries are sent with. Unless
+// allowPrivate is set it refuses to connect to loopback, private and
+// link-local addresses, so that subscriptions can't be used to probe the
+// network the server runs in.
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+				ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+				return errPrivateAddress
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+	return &http.Client{
+		Transport: transport,
+		Timeout:   webhookTimeout,
+		// A redirect could point anywhere; treat it as a failed delivery.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pageCursor is the keyset position of the last item on a page.
+// Clients only ever see it as an opaque string.
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// firstPageCursor returns a position that sorts before (asc) or after (desc)
+// every row, so the first page can use the same queries as all others.
+func firstPageCursor(desc bool) pageCursor {
+	if desc {
+		return pageCursor{
+			CreatedAt: time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC),
+			ID:        uuid.Max,
+		}
+	}
+	return pageCursor{
+		CreatedAt: time.Date(1, time.January, 1, 0, 0, 0, 0, time.UTC),
+		ID:        uuid.Nil,
+	}
+}
+
+func (c pageCursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.String()))
+}
+
+func (c pageCursor) String() string {
+	return c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+	return parseCursor(string(raw))
+}
+
+// parseCursor parses the output of pageCursor.String.
+func parseCursor(raw string) (pageCursor, error) {
+	ts, id, ok := strings.Cut(raw, "|")
+	if !ok {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+	return pageCursor{CreatedAt: createdAt, ID: uid}, nil
+}
+
+// pageRequest holds the pagination parameters of a list request.
+type pageRequest struct {
+	Cursor pageCursor
+	Limit  int
+	Desc   bool
+}
+
+// parsePageRequest reads `cursor`, `limit` and `sort` from the query string.
+func parsePageRequest(r *http.Request) (pageRequest, error) {
+	return parsePageRequestSorted(r, r.URL.Query().Get("sort") == "desc")
+}
+
+// parsePageRequestSorted reads `cursor` and `limit` for listings that have a
+// fixed sort order.
+func parsePageRequestSorted(r *http.Request, desc bool) (pageRequest, error) {
+	limit, err := parseLimit(r)
+	if err != nil {
+		return pageRequest{}, err
+	}
+	page := pageRequest{
+		Cursor: firstPageCursor(desc),
+		Limit:  limit,
+		Desc:   desc,
+	}
+	if s := r.URL.Query().Get("cursor"); s != "" {
+		cursor, err := decodeCursor(s)
+		if err != nil {
+			return pageRequest{}, err
+		}
+		page.Cursor = cursor
+	}
+	return page, nil
+}
+
+// parseLimit reads the `limit` query parameter, capped at maxPageSize.
+func parseLimit(r *http.Request) (int, error) {
+	s := r.URL.Query().Get("limit")
+	if s == "" {
+		return defaultPageSize, nil
+	}
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit < 1 {
+		return 0, errors.New("limit must be a positive integer")
+	}
+	return min(limit, maxPageSize), nil
+}
+
+// fetchSize is the number of rows to ask the database for. One extra row
+// tells us whether there is a next page without a separate COUNT query.
+func (p pageRequest) fetchSize() int32 {
+	return int32(p.Limit + 1)
+}
+
+// setNextPageLink adds an RFC 8288 Link header pointing at the next page.
+// The request's other query parameters are carried over unchanged.
+func setNextPageLink(w http.ResponseWriter, r *http.Request, next interface{ encode() string }) {
+	q := r.URL.Query()
+	q.Set("cursor", next.encode())
+	w.Header().Add("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, q.Encode()))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := pageCursor{
+		CreatedAt: time.Date(2024, time.March, 1, 12, 30, 45, 123456789, time.UTC),
+		ID:        uuid.New(),
+	}
+	got, err := decodeCursor(want.encode())
+	if err != nil {
+		t.Fatalf("decodeCursor: %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	// A cursor made from a local time still names the same instant.
+	local := pageCursor{CreatedAt: want.CreatedAt.In(time.FixedZone("CET", 3600)), ID: want.ID}
+	if got, err := decodeCursor(local.encode()); err != nil || !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Errorf("local time: want %v, got %v, %v", want.CreatedAt, got.CreatedAt, err)
+	}
+}
+
+func TestParsePageRequest(t *testing.T) {
+	cursor := pageCursor{CreatedAt: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), ID: uuid.New()}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantLimit int
+		wantErr   bool
+	}{
+		{name: "defaults", query: "", wantLimit: defaultPageSize},
+		{name: "limit", query: "limit=5", wantLimit: 5},
+		{name: "limit of one", query: "limit=1", wantLimit: 1},
+		{name: "limit at the cap", query: "limit=100", wantLimit: maxPageSize},
+		{name: "limit over the cap", query: "limit=101", wantLimit: maxPageSize},
+		{name: "zero limit", query: "limit=0", wantErr: true},
+		{name: "negative limit", query: "limit=-3", wantErr: true},
+		{name: "limit not a number", query: "limit=ten", wantErr: true},
+		{name: "valid cursor", query: "cursor=" + cursor.encode(), wantLimit: defaultPageSize},
+		{name: "cursor not base64", query: "cursor=%21%21%21", wantErr: true},
+		{name: "cursor without separator", query: "cursor=Z2FyYmFnZQ", wantErr: true},
+		{name: "cursor with bad time", query: "cursor=" + encodeRaw("yesterday|"+cursor.ID.String()), wantErr: true},
+		{name: "cursor with bad id", query: "cursor=" + encodeRaw(cursor.CreatedAt.Format(time.RFC3339Nano)+"|42"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/chirps?"+tt.query, nil)
+			page, err := parsePageRequest(r)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("want an error, got %+v", page)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if page.Limit != tt.wantLimit {
+				t.Errorf("limit: want %d, got %d", tt.wantLimit, page.Limit)
+			}
+			if page.fetchSize() != int32(tt.wantLimit+1) {
+				t.Errorf("fetchSize: want %d, got %d", tt.wantLimit+1, page.fetchSize())
+			}
+		})
+	}
+
+	r := httptest.NewRequest("GET", "/api/chirps?cursor="+cursor.encode(), nil)
+	if page, err := parsePageRequest(r); err != nil || page.Cursor.ID != cursor.ID || !page.Cursor.CreatedAt.Equal(cursor.CreatedAt) {
+		t.Errorf("cursor: want %v, got %v, %v", cursor, page.Cursor, err)
+	}
+	r = httptest.NewRequest("GET", "/api/chirps?sort=desc", nil)
+	if page, err := parsePageRequest(r); err != nil || !page.Desc || page.Cursor != firstPageCursor(true) {
+		t.Errorf("sort=desc: want a descending first page, got %+v, %v", page, err)
+	}
+}
+
+func TestSetNextPageLink(t *testing.T) {
+	cursor := pageCursor{CreatedAt: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), ID: uuid.New()}
+	r := httptest.NewRequest("GET", "/api/chirps?limit=2&sort=desc&cursor=old", nil)
+	w := httptest.NewRecorder()
+	setNextPageLink(w, r, cursor)
+
+	next := nextLink(w.Header().Get("Link"))
+	if next == "" {
+		t.Fatalf("want a next link, got %q", w.Header().Get("Link"))
+	}
+	page, err := parsePageRequest(httptest.NewRequest("GET", next, nil))
+	if err != nil || page.Limit != 2 || !page.Desc || page.Cursor.ID != cursor.ID {
+		t.Errorf("next link %q: got %+v, %v", next, page, err)
+	}
+}
+
+// encodeRaw encodes a cursor string the way pageCursor.encode does.
+func encodeRaw(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
@@ -0,0 +1,19 @@
+-- name: ListBannedWords :many
+SELECT * FROM banned_words
+ORDER BY word;
+
+-- name: UpsertBannedWord :one
+INSERT INTO banned_words (word, action, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (word) DO UPDATE SET action = excluded.action
+RETURNING *;
+
+-- name: ImportBannedWords :execrows
+-- words and actions are parallel arrays. Words must not repeat.
+INSERT INTO banned_words (word, action, created_at)
+SELECT (sqlc.arg(words)::text[])[i], (sqlc.arg(actions)::text[])[i], NOW()
+FROM generate_subscripts(sqlc.arg(words)::text[], 1) AS i
+ON CONFLICT (word) DO UPDATE SET action = excluded.action;
+
+-- name: DeleteBannedWord :execrows
+DELETE FROM banned_words WHERE word = $1;
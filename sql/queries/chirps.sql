@@ -1,36 +1,155 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (
-  gen_random_uuid(),
-  NOW(),
-  NOW(),
-  $1,
-  $2
+-- Hashtags and mentions come in as parallel arrays, indexed by
+-- generate_subscripts, and are stored by the same statement. Foreign keys
+-- are checked at the end of the statement, by which time the chirp row
+-- exists.
+WITH new_chirp AS (
+  SELECT gen_random_uuid() AS id
+), hashtags AS (
+  INSERT INTO chirp_hashtags (chirp_id, tag, start_offset, end_offset)
+  SELECT new_chirp.id,
+    (sqlc.arg(tags)::text[])[i],
+    (sqlc.arg(tag_starts)::int[])[i],
+    (sqlc.arg(tag_ends)::int[])[i]
+  FROM new_chirp, generate_subscripts(sqlc.arg(tags)::text[], 1) AS i
+), mentions AS (
+  INSERT INTO chirp_mentions (chirp_id, user_id, start_offset, end_offset)
+  SELECT new_chirp.id,
+    (sqlc.arg(mention_user_ids)::uuid[])[i],
+    (sqlc.arg(mention_starts)::int[])[i],
+    (sqlc.arg(mention_ends)::int[])[i]
+  FROM new_chirp, generate_subscripts(sqlc.arg(mention_user_ids)::uuid[], 1) AS i
 )
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to)
+SELECT new_chirp.id, NOW(), NOW(), sqlc.arg(body), sqlc.arg(user_id), sqlc.narg(in_reply_to)
+FROM new_chirp
 RETURNING *;
 
--- name: GetChirps :many
-SELECT * FROM chirps
-ORDER BY created_at ASC;
-
 -- name: GetChirp :one
 SELECT * FROM chirps
-WHERE id =$1;
+WHERE id = $1
+  AND hidden_at IS NULL;
 
 -- name: DeleteChirp :exec
 DELETE FROM chirps
 WHERE id =$1;
 
--- name: GetChirpsByID :many
+-- name: ListChirps :many
 SELECT * FROM chirps
-WHERE user_id =$1
-ORDER BY created_at ASC;
+WHERE (created_at, id) > (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::uuid)
+  AND hidden_at IS NULL
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
 
--- name: GetChirpsByIDDesc :many
+-- name: ListChirpsDesc :many
 SELECT * FROM chirps
-WHERE user_id =$1
-ORDER BY created_at DESC;
+WHERE (created_at, id) < (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::uuid)
+  AND hidden_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
 
--- name: GetAllChirpsDesc :many
+-- name: ListChirpsByAuthor :many
 SELECT * FROM chirps
-ORDER BY created_at DESC;
+WHERE user_id = sqlc.arg(user_id)
+  AND (created_at, id) > (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::uuid)
+  AND hidden_at IS NULL
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListChirpsByAuthorDesc :many
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+  AND (created_at, id) < (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::uuid)
+  AND hidden_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: UpdateChirp :one
+-- The previous body is kept as a revision, stamped with the time it was
+-- written, and the hashtags and mentions are replaced with those of the new
+-- body.
+WITH revision AS (
+  INSERT INTO chirp_revisions (id, chirp_id, body, created_at)
+  SELECT gen_random_uuid(), chirps.id, chirps.body, chirps.updated_at
+  FROM chirps
+  WHERE chirps.id = sqlc.arg(id)
+), old_hashtags AS (
+  DELETE FROM chirp_hashtags WHERE chirp_hashtags.chirp_id = sqlc.arg(id)
+), old_mentions AS (
+  DELETE FROM chirp_mentions WHERE chirp_mentions.chirp_id = sqlc.arg(id)
+), hashtags AS (
+  INSERT INTO chirp_hashtags (chirp_id, tag, start_offset, end_offset)
+  SELECT chirps.id,
+    (sqlc.arg(tags)::text[])[i],
+    (sqlc.arg(tag_starts)::int[])[i],
+    (sqlc.arg(tag_ends)::int[])[i]
+  FROM chirps, generate_subscripts(sqlc.arg(tags)::text[], 1) AS i
+  WHERE chirps.id = sqlc.arg(id)
+), mentions AS (
+  INSERT INTO chirp_mentions (chirp_id, user_id, start_offset, end_offset)
+  SELECT chirps.id,
+    (sqlc.arg(mention_user_ids)::uuid[])[i],
+    (sqlc.arg(mention_starts)::int[])[i],
+    (sqlc.arg(mention_ends)::int[])[i]
+  FROM chirps, generate_subscripts(sqlc.arg(mention_user_ids)::uuid[], 1) AS i
+  WHERE chirps.id = sqlc.arg(id)
+)
+UPDATE chirps
+SET body = sqlc.arg(body),
+  updated_at = NOW()
+WHERE chirps.id = sqlc.arg(id)
+RETURNING *;
+
+-- name: ListChirpRevisions :many
+SELECT * FROM chirp_revisions
+WHERE chirp_id = $1
+ORDER BY created_at ASC, id ASC;
+
+-- name: ListChirpDescendants :many
+-- Walks in_reply_to down from the given chirp, max_depth levels at most.
+-- A hidden chirp is left out together with the replies below it. The whole
+-- subtree is paged oldest first, so a reply always comes after its parent.
+WITH RECURSIVE descendants (id, depth) AS (
+  SELECT chirps.id, 1
+  FROM chirps
+  WHERE chirps.in_reply_to = sqlc.arg(id)::uuid
+    AND chirps.hidden_at IS NULL
+  UNION ALL
+  SELECT chirps.id, descendants.depth + 1
+  FROM descendants
+  JOIN chirps ON chirps.in_reply_to = descendants.id
+  WHERE chirps.hidden_at IS NULL
+    AND descendants.depth < sqlc.arg(max_depth)::int
+)
+SELECT sqlc.embed(chirps), descendants.depth::int AS depth
+FROM descendants
+JOIN chirps ON chirps.id = descendants.id
+WHERE (chirps.created_at, chirps.id) > (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::uuid)
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListChirpAncestors :many
+-- Walks in_reply_to up from the given chirp, nearest parent first.
+WITH RECURSIVE ancestors (id, in_reply_to, depth) AS (
+  SELECT parent.id, parent.in_reply_to, 1
+  FROM chirps AS child
+  JOIN chirps AS parent ON parent.id = child.in_reply_to
+  WHERE child.id = sqlc.arg(id)
+  UNION ALL
+  SELECT parent.id, parent.in_reply_to, ancestors.depth + 1
+  FROM ancestors
+  JOIN chirps AS parent ON parent.id = ancestors.in_reply_to
+  WHERE ancestors.depth < sqlc.arg(max_depth)::int
+)
+SELECT chirps.*
+FROM ancestors
+JOIN chirps ON chirps.id = ancestors.id
+WHERE chirps.hidden_at IS NULL
+ORDER BY ancestors.depth ASC;
+
+-- name: CountReplies :many
+SELECT in_reply_to::uuid AS chirp_id, COUNT(*) AS reply_count
+FROM chirps
+WHERE in_reply_to = ANY(sqlc.arg(chirp_ids)::uuid[])
+  AND hidden_at IS NULL
+GROUP BY in_reply_to;
@@ -0,0 +1,33 @@
+-- name: ListChirpHashtags :many
+SELECT * FROM chirp_hashtags
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, start_offset;
+
+-- name: ListChirpMentions :many
+SELECT * FROM chirp_mentions
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, start_offset;
+
+-- name: ListChirpsByHashtag :many
+SELECT * FROM chirps
+WHERE EXISTS (
+    SELECT 1 FROM chirp_hashtags
+    WHERE chirp_hashtags.chirp_id = chirps.id
+      AND chirp_hashtags.tag = sqlc.arg(tag)
+  )
+  AND (created_at, id) < (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::uuid)
+  AND hidden_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListChirpsMentioning :many
+SELECT * FROM chirps
+WHERE EXISTS (
+    SELECT 1 FROM chirp_mentions
+    WHERE chirp_mentions.chirp_id = chirps.id
+      AND chirp_mentions.user_id = sqlc.arg(user_id)
+  )
+  AND (created_at, id) < (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::uuid)
+  AND hidden_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,41 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = $1
+  AND followee_id = $2;
+
+-- name: ListFollowers :many
+SELECT * FROM follows
+WHERE followee_id = sqlc.arg(user_id)
+  AND (created_at, follower_id) < (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::uuid)
+ORDER BY created_at DESC, follower_id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListFollowing :many
+SELECT * FROM follows
+WHERE follower_id = sqlc.arg(user_id)
+  AND (created_at, followee_id) < (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::uuid)
+ORDER BY created_at DESC, followee_id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListTimeline :many
+-- Newest chirps by everyone user_id follows. The lateral subquery reads at
+-- most page_size rows per followee from chirps_user_id_created_at_id_idx, so
+-- the cost is bounded by followees * page_size rather than by the followees'
+-- full history.
+SELECT recent.* FROM follows
+CROSS JOIN LATERAL (
+  SELECT * FROM chirps
+  WHERE chirps.user_id = follows.followee_id
+    AND (chirps.created_at, chirps.id) < (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::uuid)
+    AND chirps.hidden_at IS NULL
+  ORDER BY chirps.created_at DESC, chirps.id DESC
+  LIMIT sqlc.arg(page_size)
+) AS recent
+WHERE follows.follower_id = sqlc.arg(user_id)
+ORDER BY recent.created_at DESC, recent.id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,75 @@
+-- name: CreateChirpReport :one
+-- Returns no row if the user has already reported the chirp.
+INSERT INTO chirp_reports (id, chirp_id, reporter_id, reason, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, NOW())
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+RETURNING *;
+
+-- name: ListModerationQueue :many
+-- Chirps with open reports, longest waiting first.
+SELECT sqlc.embed(chirps),
+  COUNT(*) AS open_reports,
+  MIN(chirp_reports.created_at)::timestamptz AS first_reported_at
+FROM chirp_reports
+JOIN chirps ON chirps.id = chirp_reports.chirp_id
+WHERE chirp_reports.resolved_at IS NULL
+GROUP BY chirps.id
+HAVING (MIN(chirp_reports.created_at), chirps.id) > (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::uuid)
+ORDER BY first_reported_at ASC, chirps.id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListOpenChirpReports :many
+SELECT * FROM chirp_reports
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+  AND resolved_at IS NULL
+ORDER BY chirp_id, created_at, id;
+
+-- name: ModerateChirp :one
+-- Hides or restores the chirp, or leaves it as it is for 'dismiss', resolves
+-- its open reports and records the action. Returns no row if there is no
+-- such chirp.
+WITH target AS (
+  UPDATE chirps
+  SET hidden_at = CASE sqlc.arg(action)::text
+      WHEN 'hide' THEN COALESCE(chirps.hidden_at, NOW())
+      WHEN 'restore' THEN NULL
+      ELSE chirps.hidden_at
+    END
+  WHERE chirps.id = sqlc.arg(chirp_id)
+  RETURNING chirps.id, chirps.user_id
+), resolved AS (
+  UPDATE chirp_reports
+  SET resolved_at = NOW()
+  WHERE chirp_reports.chirp_id IN (SELECT id FROM target)
+    AND chirp_reports.resolved_at IS NULL
+)
+INSERT INTO moderation_actions (id, chirp_id, chirp_user_id, moderator_id, action, reason, created_at)
+SELECT gen_random_uuid(), target.id, target.user_id, sqlc.narg(moderator_id), sqlc.arg(action), sqlc.arg(reason), NOW()
+FROM target
+RETURNING *;
+
+-- name: RemoveChirp :one
+-- Deletes the chirp, and with it its reports, and records the action.
+-- Returns no row if there is no such chirp.
+WITH target AS (
+  DELETE FROM chirps
+  WHERE chirps.id = sqlc.arg(chirp_id)
+  RETURNING chirps.id, chirps.user_id
+)
+INSERT INTO moderation_actions (id, chirp_id, chirp_user_id, moderator_id, action, reason, created_at)
+SELECT gen_random_uuid(), target.id, target.user_id, sqlc.narg(moderator_id), 'remove', sqlc.arg(reason), NOW()
+FROM target
+RETURNING *;
+
+-- name: ListModerationActions :many
+SELECT * FROM moderation_actions
+WHERE (sqlc.narg(chirp_id)::uuid IS NULL OR chirp_id = sqlc.narg(chirp_id))
+  AND (created_at, id) < (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: CreateChirpFlag :one
+-- Queues the chirp for review on behalf of the banned word list.
+INSERT INTO chirp_reports (id, chirp_id, reporter_id, reason, created_at)
+VALUES (gen_random_uuid(), $1, NULL, $2, NOW())
+RETURNING *;
@@ -0,0 +1,60 @@
+-- name: CreateNotification :one
+-- Adds a notification unless the user has turned its kind off, or has
+-- already been told that this actor did this to this chirp, in which case
+-- no row is returned. Unliking and liking again, or unfollowing and
+-- following again, doesn't notify a second time.
+INSERT INTO notifications (id, user_id, actor_id, kind, chirp_id, created_at)
+SELECT gen_random_uuid(), sqlc.arg(user_id)::uuid, sqlc.arg(actor_id)::uuid, sqlc.arg(kind)::text, sqlc.narg(chirp_id)::uuid, NOW()
+WHERE NOT EXISTS (
+  SELECT 1 FROM notifications n
+  WHERE n.user_id = sqlc.arg(user_id)::uuid
+    AND n.actor_id = sqlc.arg(actor_id)::uuid
+    AND n.kind = sqlc.arg(kind)::text
+    AND n.chirp_id IS NOT DISTINCT FROM sqlc.narg(chirp_id)::uuid
+)
+AND COALESCE((
+  SELECT CASE sqlc.arg(kind)::text
+    WHEN 'mention' THEN p.mentions
+    WHEN 'reply' THEN p.replies
+    WHEN 'like' THEN p.likes
+    WHEN 'follow' THEN p.follows
+  END
+  FROM notification_preferences p
+  WHERE p.user_id = sqlc.arg(user_id)::uuid
+), TRUE)
+RETURNING *;
+
+-- name: ListNotifications :many
+SELECT * FROM notifications
+WHERE user_id = sqlc.arg(user_id)
+  AND (NOT sqlc.arg(unread_only)::boolean OR read_at IS NULL)
+  AND (created_at, id) < (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = sqlc.arg(user_id)
+  AND id = ANY(sqlc.arg(ids)::uuid[])
+  AND read_at IS NULL;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1
+  AND read_at IS NULL;
+
+-- name: GetNotificationPreferences :one
+SELECT * FROM notification_preferences WHERE user_id = $1;
+
+-- name: SetNotificationPreferences :one
+INSERT INTO notification_preferences (user_id, mentions, replies, likes, follows, updated_at)
+VALUES ($1, $2, $3, $4, $5, NOW())
+ON CONFLICT (user_id) DO UPDATE
+SET mentions = EXCLUDED.mentions,
+  replies = EXCLUDED.replies,
+  likes = EXCLUDED.likes,
+  follows = EXCLUDED.follows,
+  updated_at = EXCLUDED.updated_at
+RETURNING *;
@@ -0,0 +1,61 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (id, user_id, url, secret, events, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW())
+RETURNING *;
+
+-- name: GetWebhookSubscription :one
+SELECT * FROM webhook_subscriptions WHERE id = $1;
+
+-- name: ListWebhookSubscriptions :many
+SELECT * FROM webhook_subscriptions
+WHERE user_id IS NOT DISTINCT FROM sqlc.narg(user_id)::uuid
+ORDER BY created_at, id;
+
+-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE id = sqlc.arg(id)
+  AND user_id IS NOT DISTINCT FROM sqlc.narg(user_id)::uuid;
+
+-- name: EnqueueWebhookEvent :execrows
+-- Adds a delivery for every subscription to the event. Events about a user
+-- (UserID set) only go to that user's and the admins' subscriptions.
+INSERT INTO webhook_deliveries (id, subscription_id, event, payload, next_attempt_at, created_at)
+SELECT gen_random_uuid(), s.id, sqlc.arg(event)::text, sqlc.arg(payload)::text, NOW(), NOW()
+FROM webhook_subscriptions s
+WHERE ' ' || s.events || ' ' LIKE '% ' || sqlc.arg(event)::text || ' %'
+  AND (sqlc.narg(user_id)::uuid IS NULL OR s.user_id IS NULL OR s.user_id = sqlc.narg(user_id)::uuid);
+
+-- name: ClaimWebhookDeliveries :many
+-- Leases up to MaxDeliveries due deliveries by pushing their next attempt
+-- to LeaseUntil. A worker that dies mid-delivery leaves them to be retried
+-- once the lease runs out.
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(lease_until)
+WHERE id IN (
+  SELECT d.id FROM webhook_deliveries d
+  WHERE d.status = 'pending'
+    AND d.next_attempt_at <= sqlc.arg(now)
+  ORDER BY d.next_attempt_at
+  LIMIT sqlc.arg(max_deliveries)
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: FinishWebhookAttempt :exec
+-- Records the outcome of one delivery attempt. Status is 'delivered',
+-- 'pending' to retry at NextAttemptAt, or 'failed' to give up.
+UPDATE webhook_deliveries
+SET status = sqlc.arg(status),
+  attempts = attempts + 1,
+  next_attempt_at = sqlc.arg(next_attempt_at),
+  last_error = sqlc.arg(last_error),
+  response_status = sqlc.narg(response_status),
+  delivered_at = CASE WHEN sqlc.arg(status) = 'delivered' THEN NOW() END
+WHERE id = sqlc.arg(id);
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE subscription_id = sqlc.arg(subscription_id)
+  AND (created_at, id) < (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
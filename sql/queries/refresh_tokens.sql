@@ -4,7 +4,11 @@ SELECT
   users.created_at,
   users.updated_at,
   users.email,
-  users.hashed_password
+  users.hashed_password,
+  users.role,
+  users.suspended_at,
+  users.suspended_until,
+  users.suspension_reason
 FROM users
 JOIN refresh_tokens ON refresh_tokens.user_id = users.id
 WHERE refresh_tokens.token = $1
@@ -0,0 +1,27 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, user_id, body, in_reply_to, publish_at, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW())
+RETURNING *;
+
+-- name: ListScheduledChirps :many
+SELECT * FROM scheduled_chirps
+WHERE user_id = $1
+ORDER BY publish_at, id;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM scheduled_chirps
+WHERE id = $1
+  AND user_id = $2;
+
+-- name: ClaimDueScheduledChirps :many
+-- Removes and returns the chirps that are due, so that concurrent publishers
+-- never see the same one twice. The chirps of a suspended user are left
+-- until the suspension ends.
+DELETE FROM scheduled_chirps
+WHERE publish_at <= sqlc.arg(now)
+  AND user_id NOT IN (
+    SELECT id FROM users
+    WHERE suspended_at IS NOT NULL
+      AND (suspended_until IS NULL OR suspended_until > sqlc.arg(now))
+  )
+RETURNING *;
@@ -0,0 +1,21 @@
+-- name: SearchChirps :many
+-- Matches ranked by relevance, best first. query is tsquery syntax.
+SELECT sqlc.embed(chirps), ts_rank(chirps.search_vector, query)::float8 AS relevance
+FROM chirps, to_tsquery('english', sqlc.arg(query)) AS query
+WHERE chirps.search_vector @@ query
+  AND (sqlc.narg(author_id)::uuid IS NULL OR chirps.user_id = sqlc.narg(author_id))
+  AND (ts_rank(chirps.search_vector, query)::float8, chirps.created_at, chirps.id)
+    < (sqlc.arg(cursor_relevance)::float8, sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::uuid)
+  AND chirps.hidden_at IS NULL
+ORDER BY relevance DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: SearchChirpsRecent :many
+-- Matches newest first. query is tsquery syntax.
+SELECT chirps.* FROM chirps
+WHERE chirps.search_vector @@ to_tsquery('english', sqlc.arg(query))
+  AND (sqlc.narg(author_id)::uuid IS NULL OR chirps.user_id = sqlc.narg(author_id))
+  AND (chirps.created_at, chirps.id) < (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::uuid)
+  AND chirps.hidden_at IS NULL
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
@@ -36,3 +36,45 @@ UPDATE users
 SET is_chirpy_red = TRUE
 WHERE id = $1
 RETURNING *;
+
+-- name: GetUser :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: DowngradeChirpyPlus :one
+UPDATE users
+SET is_chirpy_red = FALSE
+WHERE id = $1
+RETURNING *;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $2,
+  updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SuspendUser :one
+-- Also revokes the user's refresh tokens.
+WITH revoked AS (
+  UPDATE refresh_tokens
+  SET revoked_at = NOW(),
+    updated_at = NOW()
+  WHERE refresh_tokens.user_id = sqlc.arg(id)
+    AND refresh_tokens.revoked_at IS NULL
+)
+UPDATE users
+SET suspended_at = NOW(),
+  suspended_until = sqlc.narg(suspended_until),
+  suspension_reason = sqlc.arg(suspension_reason),
+  updated_at = NOW()
+WHERE users.id = sqlc.arg(id)
+RETURNING *;
+
+-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL,
+  suspended_until = NULL,
+  suspension_reason = '',
+  updated_at = NOW()
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,24 @@
+-- name: RecordWebhookEvent :one
+-- Stores an incoming event, or counts a redelivery of one already stored
+-- under the same event_id. Deliveries is 1 only for the delivery whose
+-- insert won: concurrent redeliveries wait on the conflicting row and see
+-- the count after theirs.
+INSERT INTO webhook_events (id, source, event_id, event, user_id, payload, received_at, last_received_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, NOW(), NOW())
+ON CONFLICT (source, event_id) DO UPDATE
+SET deliveries = webhook_events.deliveries + 1,
+  last_received_at = EXCLUDED.last_received_at
+RETURNING *;
+
+-- name: SetWebhookEventStatus :exec
+UPDATE webhook_events
+SET status = $2,
+  error = $3
+WHERE id = $1;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (sqlc.narg(user_id)::uuid IS NULL OR user_id = sqlc.narg(user_id))
+  AND (received_at, id) < (sqlc.arg(cursor_received_at)::timestamptz, sqlc.arg(cursor_id)::uuid)
+ORDER BY received_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
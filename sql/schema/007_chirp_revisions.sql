@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE chirp_revisions (
+  id UUID PRIMARY KEY,
+  chirp_id UUID NOT NULL,
+  body TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  CONSTRAINT fk_chirp_revisions_chirps
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_revisions_chirp_id_idx ON chirp_revisions (chirp_id, created_at);
+
+-- +goose Down
+DROP TABLE chirp_revisions;
@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE follows (
+  follower_id UUID NOT NULL,
+  followee_id UUID NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (follower_id, followee_id),
+  CONSTRAINT fk_follows_follower
+    FOREIGN KEY (follower_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  CONSTRAINT fk_follows_followee
+    FOREIGN KEY (followee_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  CONSTRAINT follows_not_self CHECK (follower_id <> followee_id)
+);
+
+-- The primary key serves "who does X follow"; these serve the paginated
+-- listings in both directions.
+CREATE INDEX follows_follower_created_at_idx ON follows (follower_id, created_at, followee_id);
+CREATE INDEX follows_followee_created_at_idx ON follows (followee_id, created_at, follower_id);
+
+-- +goose Down
+DROP TABLE follows;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE likes (
+  user_id UUID NOT NULL,
+  chirp_id UUID NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (user_id, chirp_id),
+  CONSTRAINT fk_likes_user
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  CONSTRAINT fk_likes_chirp
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE
+);
+
+-- The primary key answers "has X liked these chirps"; these serve like
+-- counts and the paginated list of a user's likes.
+CREATE INDEX likes_chirp_id_idx ON likes (chirp_id);
+CREATE INDEX likes_user_created_at_idx ON likes (user_id, created_at, chirp_id);
+
+-- +goose Down
+DROP TABLE likes;
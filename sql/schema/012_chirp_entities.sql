@@ -0,0 +1,39 @@
+-- +goose Up
+-- Hashtags and mentions found in chirp bodies. Offsets are byte offsets
+-- into chirps.body, end exclusive. There is one row per occurrence, so a
+-- chirp repeating a tag has several rows for it.
+CREATE TABLE chirp_hashtags (
+  chirp_id UUID NOT NULL,
+  tag TEXT NOT NULL,
+  start_offset INTEGER NOT NULL,
+  end_offset INTEGER NOT NULL,
+  CONSTRAINT fk_chirp_hashtags_chirp
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_hashtags_chirp_id_idx ON chirp_hashtags (chirp_id);
+CREATE INDEX chirp_hashtags_tag_idx ON chirp_hashtags (tag, chirp_id);
+
+CREATE TABLE chirp_mentions (
+  chirp_id UUID NOT NULL,
+  user_id UUID NOT NULL,
+  start_offset INTEGER NOT NULL,
+  end_offset INTEGER NOT NULL,
+  CONSTRAINT fk_chirp_mentions_chirp
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE,
+  CONSTRAINT fk_chirp_mentions_user
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_mentions_chirp_id_idx ON chirp_mentions (chirp_id);
+CREATE INDEX chirp_mentions_user_id_idx ON chirp_mentions (user_id, chirp_id);
+
+-- +goose Down
+DROP TABLE chirp_mentions;
+DROP TABLE chirp_hashtags;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE banned_words (
+  word TEXT PRIMARY KEY,
+  action TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  CONSTRAINT banned_words_action CHECK (action IN ('mask', 'flag', 'reject'))
+);
+
+-- The list that used to be compiled in.
+INSERT INTO banned_words (word, action, created_at)
+VALUES
+  ('kerfuffle', 'mask', NOW()),
+  ('sharbert', 'mask', NOW()),
+  ('fornax', 'mask', NOW());
+
+-- +goose Down
+DROP TABLE banned_words;
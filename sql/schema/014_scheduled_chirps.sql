@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE scheduled_chirps (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL,
+  body TEXT NOT NULL,
+  in_reply_to UUID DEFAULT NULL,
+  publish_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  CONSTRAINT fk_scheduled_chirps_user
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  CONSTRAINT fk_scheduled_chirps_in_reply_to
+    FOREIGN KEY (in_reply_to)
+    REFERENCES chirps(id)
+    ON DELETE SET NULL
+);
+
+CREATE INDEX scheduled_chirps_publish_at_idx ON scheduled_chirps (publish_at);
+CREATE INDEX scheduled_chirps_user_id_idx ON scheduled_chirps (user_id, publish_at);
+
+-- +goose Down
+DROP TABLE scheduled_chirps;
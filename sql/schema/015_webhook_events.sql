@@ -0,0 +1,24 @@
+-- +goose Up
+-- One row per incoming webhook event. Redeliveries of an event with the
+-- same event_id update its row instead of adding one.
+CREATE TABLE webhook_events (
+  id UUID PRIMARY KEY,
+  source TEXT NOT NULL,
+  event_id TEXT,
+  event TEXT NOT NULL,
+  user_id UUID,
+  payload TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'received',
+  error TEXT NOT NULL DEFAULT '',
+  deliveries INTEGER NOT NULL DEFAULT 1,
+  received_at TIMESTAMPTZ NOT NULL,
+  last_received_at TIMESTAMPTZ NOT NULL,
+  CONSTRAINT webhook_events_event_id UNIQUE (source, event_id),
+  CONSTRAINT webhook_events_status CHECK (status IN ('received', 'processed', 'ignored', 'failed'))
+);
+
+CREATE INDEX webhook_events_received_at_idx ON webhook_events (received_at, id);
+CREATE INDEX webhook_events_user_id_idx ON webhook_events (user_id, received_at, id);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,46 @@
+-- +goose Up
+-- A subscription without a user belongs to the admins and also receives
+-- events about users.
+CREATE TABLE webhook_subscriptions (
+  id UUID PRIMARY KEY,
+  user_id UUID,
+  url TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  -- Space separated event names.
+  events TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  CONSTRAINT fk_webhook_subscriptions_user
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_subscriptions_user_id_idx ON webhook_subscriptions (user_id);
+
+-- The outbox: one row per event and subscription, worked off by the
+-- delivery worker.
+CREATE TABLE webhook_deliveries (
+  id UUID PRIMARY KEY,
+  subscription_id UUID NOT NULL,
+  event TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL,
+  last_error TEXT NOT NULL DEFAULT '',
+  response_status INTEGER,
+  created_at TIMESTAMPTZ NOT NULL,
+  delivered_at TIMESTAMPTZ,
+  CONSTRAINT fk_webhook_deliveries_subscription
+    FOREIGN KEY (subscription_id)
+    REFERENCES webhook_subscriptions(id)
+    ON DELETE CASCADE,
+  CONSTRAINT webhook_deliveries_status CHECK (status IN ('pending', 'delivered', 'failed'))
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX webhook_deliveries_subscription_idx ON webhook_deliveries (subscription_id, created_at, id);
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_subscriptions;
@@ -0,0 +1,45 @@
+-- +goose Up
+CREATE TABLE notifications (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL,
+  actor_id UUID NOT NULL,
+  kind TEXT NOT NULL,
+  -- The chirp that mentioned or replied to the user, or that was liked.
+  chirp_id UUID,
+  created_at TIMESTAMPTZ NOT NULL,
+  read_at TIMESTAMPTZ,
+  CONSTRAINT fk_notifications_user
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  CONSTRAINT fk_notifications_actor
+    FOREIGN KEY (actor_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  CONSTRAINT fk_notifications_chirp
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE,
+  CONSTRAINT notifications_kind CHECK (kind IN ('mention', 'reply', 'like', 'follow'))
+);
+
+CREATE INDEX notifications_user_idx ON notifications (user_id, created_at, id);
+CREATE INDEX notifications_unread_idx ON notifications (user_id, created_at, id) WHERE read_at IS NULL;
+
+-- Users without a row get every kind of notification.
+CREATE TABLE notification_preferences (
+  user_id UUID PRIMARY KEY,
+  mentions BOOLEAN NOT NULL DEFAULT TRUE,
+  replies BOOLEAN NOT NULL DEFAULT TRUE,
+  likes BOOLEAN NOT NULL DEFAULT TRUE,
+  follows BOOLEAN NOT NULL DEFAULT TRUE,
+  updated_at TIMESTAMPTZ NOT NULL,
+  CONSTRAINT fk_notification_preferences_user
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notifications;
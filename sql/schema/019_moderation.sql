@@ -0,0 +1,49 @@
+-- +goose Up
+-- Hidden chirps are left out of every listing and lookup until restored.
+ALTER TABLE chirps ADD COLUMN hidden_at TIMESTAMPTZ;
+
+CREATE TABLE chirp_reports (
+  id UUID PRIMARY KEY,
+  chirp_id UUID NOT NULL,
+  reporter_id UUID NOT NULL,
+  reason TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  -- Set when a moderator acts on the chirp.
+  resolved_at TIMESTAMPTZ,
+  CONSTRAINT fk_chirp_reports_chirp
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE,
+  CONSTRAINT fk_chirp_reports_reporter
+    FOREIGN KEY (reporter_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+  CONSTRAINT chirp_reports_once UNIQUE (chirp_id, reporter_id)
+);
+
+CREATE INDEX chirp_reports_open_idx ON chirp_reports (chirp_id) WHERE resolved_at IS NULL;
+
+-- The audit trail outlives the chirps, so chirp_id is not a foreign key.
+CREATE TABLE moderation_actions (
+  id UUID PRIMARY KEY,
+  chirp_id UUID NOT NULL,
+  chirp_user_id UUID NOT NULL,
+  -- NULL when the action was taken with ADMIN_API_KEY.
+  moderator_id UUID,
+  action TEXT NOT NULL,
+  reason TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  CONSTRAINT fk_moderation_actions_moderator
+    FOREIGN KEY (moderator_id)
+    REFERENCES users(id)
+    ON DELETE SET NULL,
+  CONSTRAINT moderation_actions_action CHECK (action IN ('hide', 'restore', 'remove', 'dismiss'))
+);
+
+CREATE INDEX moderation_actions_created_idx ON moderation_actions (created_at, id);
+CREATE INDEX moderation_actions_chirp_idx ON moderation_actions (chirp_id, created_at, id);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE chirp_reports;
+ALTER TABLE chirps DROP COLUMN hidden_at;
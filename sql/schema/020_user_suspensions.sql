@@ -0,0 +1,13 @@
+-- +goose Up
+-- A user is suspended from suspended_at until suspended_until, or for good
+-- when suspended_until is NULL.
+ALTER TABLE users
+  ADD COLUMN suspended_at TIMESTAMPTZ,
+  ADD COLUMN suspended_until TIMESTAMPTZ,
+  ADD COLUMN suspension_reason TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE users
+  DROP COLUMN suspended_at,
+  DROP COLUMN suspended_until,
+  DROP COLUMN suspension_reason;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Chirps the banned word list flags for review are queued as reports
+-- without a reporter.
+ALTER TABLE chirp_reports ALTER COLUMN reporter_id DROP NOT NULL;
+
+-- +goose Down
+DELETE FROM chirp_reports WHERE reporter_id IS NULL;
+ALTER TABLE chirp_reports ALTER COLUMN reporter_id SET NOT NULL;
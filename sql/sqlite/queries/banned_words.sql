@@ -0,0 +1,12 @@
+-- name: ListBannedWords :many
+SELECT * FROM banned_words
+ORDER BY word;
+
+-- name: UpsertBannedWord :one
+INSERT INTO banned_words (word, action, created_at)
+VALUES (?, ?, ?)
+ON CONFLICT (word) DO UPDATE SET action = excluded.action
+RETURNING *;
+
+-- name: DeleteBannedWord :execrows
+DELETE FROM banned_words WHERE word = ?;
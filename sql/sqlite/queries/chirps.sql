@@ -0,0 +1,81 @@
+-- name: CreateChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetChirp :one
+SELECT * FROM chirps
+WHERE id = ?
+  AND hidden_at IS NULL;
+
+-- name: DeleteChirp :exec
+DELETE FROM chirps
+WHERE id = ?;
+
+-- name: ListChirps :many
+SELECT * FROM chirps
+WHERE (created_at > sqlc.arg(cursor_created_at)
+    OR (created_at = sqlc.arg(cursor_created_at) AND id > sqlc.arg(cursor_id)))
+  AND hidden_at IS NULL
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListChirpsDesc :many
+SELECT * FROM chirps
+WHERE (created_at < sqlc.arg(cursor_created_at)
+    OR (created_at = sqlc.arg(cursor_created_at) AND id < sqlc.arg(cursor_id)))
+  AND hidden_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListChirpsByAuthor :many
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+  AND (created_at > sqlc.arg(cursor_created_at)
+    OR (created_at = sqlc.arg(cursor_created_at) AND id > sqlc.arg(cursor_id)))
+  AND hidden_at IS NULL
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListChirpsByAuthorDesc :many
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+  AND (created_at < sqlc.arg(cursor_created_at)
+    OR (created_at = sqlc.arg(cursor_created_at) AND id < sqlc.arg(cursor_id)))
+  AND hidden_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: CreateChirpRevision :exec
+INSERT INTO chirp_revisions (id, chirp_id, body, created_at)
+SELECT sqlc.arg(id), chirps.id, chirps.body, chirps.updated_at
+FROM chirps
+WHERE chirps.id = sqlc.arg(chirp_id);
+
+-- name: UpdateChirp :one
+UPDATE chirps
+SET body = sqlc.arg(body),
+  updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: ListChirpRevisions :many
+SELECT * FROM chirp_revisions
+WHERE chirp_id = ?
+ORDER BY created_at ASC, id ASC;
+
+-- name: ListRepliesTo :many
+SELECT * FROM chirps
+WHERE in_reply_to IN (sqlc.slice(chirp_ids))
+  AND hidden_at IS NULL;
+
+-- name: CountReplies :many
+SELECT in_reply_to AS chirp_id, COUNT(*) AS reply_count
+FROM chirps
+WHERE in_reply_to IN (sqlc.slice(chirp_ids))
+  AND hidden_at IS NULL
+GROUP BY in_reply_to;
+
+-- name: GetChirpIncludingHidden :one
+SELECT * FROM chirps
+WHERE id = ?;
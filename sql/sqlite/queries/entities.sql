@@ -0,0 +1,49 @@
+-- name: CreateChirpHashtag :exec
+INSERT INTO chirp_hashtags (chirp_id, tag, start_offset, end_offset)
+VALUES (?, ?, ?, ?);
+
+-- name: CreateChirpMention :exec
+INSERT INTO chirp_mentions (chirp_id, user_id, start_offset, end_offset)
+VALUES (?, ?, ?, ?);
+
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags WHERE chirp_id = ?;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions WHERE chirp_id = ?;
+
+-- name: ListChirpHashtags :many
+SELECT * FROM chirp_hashtags
+WHERE chirp_id IN (sqlc.slice(chirp_ids))
+ORDER BY chirp_id, start_offset;
+
+-- name: ListChirpMentions :many
+SELECT * FROM chirp_mentions
+WHERE chirp_id IN (sqlc.slice(chirp_ids))
+ORDER BY chirp_id, start_offset;
+
+-- name: ListChirpsByHashtag :many
+SELECT * FROM chirps
+WHERE EXISTS (
+    SELECT 1 FROM chirp_hashtags
+    WHERE chirp_hashtags.chirp_id = chirps.id
+      AND chirp_hashtags.tag = sqlc.arg(tag)
+  )
+  AND (created_at < sqlc.arg(cursor_created_at)
+    OR (created_at = sqlc.arg(cursor_created_at) AND id < sqlc.arg(cursor_id)))
+  AND hidden_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListChirpsMentioning :many
+SELECT * FROM chirps
+WHERE EXISTS (
+    SELECT 1 FROM chirp_mentions
+    WHERE chirp_mentions.chirp_id = chirps.id
+      AND chirp_mentions.user_id = sqlc.arg(user_id)
+  )
+  AND (created_at < sqlc.arg(cursor_created_at)
+    OR (created_at = sqlc.arg(cursor_created_at) AND id < sqlc.arg(cursor_id)))
+  AND hidden_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,35 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (?, ?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = ?
+  AND followee_id = ?;
+
+-- name: ListFollowers :many
+SELECT * FROM follows
+WHERE followee_id = sqlc.arg(user_id)
+  AND (created_at < sqlc.arg(cursor_created_at)
+    OR (created_at = sqlc.arg(cursor_created_at) AND follower_id < sqlc.arg(cursor_id)))
+ORDER BY created_at DESC, follower_id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListFollowing :many
+SELECT * FROM follows
+WHERE follower_id = sqlc.arg(user_id)
+  AND (created_at < sqlc.arg(cursor_created_at)
+    OR (created_at = sqlc.arg(cursor_created_at) AND followee_id < sqlc.arg(cursor_id)))
+ORDER BY created_at DESC, followee_id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListTimeline :many
+SELECT chirps.* FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = sqlc.arg(user_id)
+  AND (chirps.created_at < sqlc.arg(cursor_created_at)
+    OR (chirps.created_at = sqlc.arg(cursor_created_at) AND chirps.id < sqlc.arg(cursor_id)))
+  AND chirps.hidden_at IS NULL
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,31 @@
+-- name: LikeChirp :execrows
+INSERT INTO likes (user_id, chirp_id, created_at)
+VALUES (?, ?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: UnlikeChirp :execrows
+DELETE FROM likes
+WHERE user_id = ?
+  AND chirp_id = ?;
+
+-- name: CountLikes :many
+SELECT chirp_id, COUNT(*) AS like_count
+FROM likes
+WHERE chirp_id IN (sqlc.slice(chirp_ids))
+GROUP BY chirp_id;
+
+-- name: ListLikedChirpIDs :many
+SELECT chirp_id FROM likes
+WHERE user_id = sqlc.arg(user_id)
+  AND chirp_id IN (sqlc.slice(chirp_ids));
+
+-- name: ListLikes :many
+SELECT sqlc.embed(chirps), likes.created_at AS liked_at
+FROM likes
+JOIN chirps ON chirps.id = likes.chirp_id
+WHERE likes.user_id = sqlc.arg(user_id)
+  AND (likes.created_at < sqlc.arg(cursor_created_at)
+    OR (likes.created_at = sqlc.arg(cursor_created_at) AND likes.chirp_id < sqlc.arg(cursor_id)))
+  AND chirps.hidden_at IS NULL
+ORDER BY likes.created_at DESC, likes.chirp_id DESC
+LIMIT sqlc.arg(page_size);
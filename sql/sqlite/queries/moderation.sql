@@ -0,0 +1,62 @@
+-- name: CreateChirpReport :one
+INSERT INTO chirp_reports (id, chirp_id, reporter_id, reason, created_at)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+RETURNING *;
+
+-- name: ListModerationQueue :many
+-- Joins each chirp's oldest open report rather than taking MIN(created_at),
+-- which SQLite would return as text.
+SELECT sqlc.embed(chirps),
+  (SELECT COUNT(*) FROM chirp_reports AS open
+    WHERE open.chirp_id = chirps.id AND open.resolved_at IS NULL) AS open_reports,
+  first.created_at AS first_reported_at
+FROM chirp_reports AS first
+JOIN chirps ON chirps.id = first.chirp_id
+WHERE first.resolved_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM chirp_reports AS older
+    WHERE older.chirp_id = first.chirp_id
+      AND older.resolved_at IS NULL
+      AND (older.created_at < first.created_at
+        OR (older.created_at = first.created_at AND older.id < first.id))
+  )
+  AND (first.created_at > sqlc.arg(cursor_created_at)
+    OR (first.created_at = sqlc.arg(cursor_created_at) AND chirps.id > sqlc.arg(cursor_id)))
+ORDER BY first.created_at ASC, chirps.id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListOpenChirpReports :many
+SELECT * FROM chirp_reports
+WHERE chirp_id IN (sqlc.slice(chirp_ids))
+  AND resolved_at IS NULL
+ORDER BY chirp_id, created_at, id;
+
+-- name: SetChirpHidden :exec
+UPDATE chirps
+SET hidden_at = ?
+WHERE id = ?;
+
+-- name: ResolveChirpReports :exec
+UPDATE chirp_reports
+SET resolved_at = sqlc.arg(now)
+WHERE chirp_id = sqlc.arg(chirp_id)
+  AND resolved_at IS NULL;
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, chirp_id, chirp_user_id, moderator_id, action, reason, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListModerationActions :many
+SELECT * FROM moderation_actions
+WHERE (sqlc.narg(chirp_id) IS NULL OR chirp_id = sqlc.narg(chirp_id))
+  AND (created_at < sqlc.arg(cursor_created_at)
+    OR (created_at = sqlc.arg(cursor_created_at) AND id < sqlc.arg(cursor_id)))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: CreateChirpFlag :one
+INSERT INTO chirp_reports (id, chirp_id, reporter_id, reason, created_at)
+VALUES (?, ?, NULL, ?, ?)
+RETURNING *;
@@ -0,0 +1,53 @@
+-- name: CreateNotification :one
+-- The preferences and earlier notifications are checked by the caller, in
+-- the same transaction.
+INSERT INTO notifications (id, user_id, actor_id, kind, chirp_id, created_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: HasNotification :one
+-- Whether the user has already been told that this actor did this to this
+-- chirp.
+SELECT EXISTS (
+  SELECT 1 FROM notifications
+  WHERE user_id = sqlc.arg(user_id)
+    AND actor_id = sqlc.arg(actor_id)
+    AND kind = sqlc.arg(kind)
+    AND chirp_id IS sqlc.narg(chirp_id)
+);
+
+-- name: ListNotifications :many
+SELECT * FROM notifications
+WHERE user_id = sqlc.arg(user_id)
+  AND (CAST(sqlc.arg(unread_only) AS BOOLEAN) = FALSE OR read_at IS NULL)
+  AND (created_at < sqlc.arg(cursor_created_at)
+    OR (created_at = sqlc.arg(cursor_created_at) AND id < sqlc.arg(cursor_id)))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications
+SET read_at = sqlc.arg(now)
+WHERE user_id = sqlc.arg(user_id)
+  AND id IN (sqlc.slice(ids))
+  AND read_at IS NULL;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = sqlc.arg(now)
+WHERE user_id = sqlc.arg(user_id)
+  AND read_at IS NULL;
+
+-- name: GetNotificationPreferences :one
+SELECT * FROM notification_preferences WHERE user_id = ?;
+
+-- name: SetNotificationPreferences :one
+INSERT INTO notification_preferences (user_id, mentions, replies, likes, follows, updated_at)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (user_id) DO UPDATE
+SET mentions = excluded.mentions,
+  replies = excluded.replies,
+  likes = excluded.likes,
+  follows = excluded.follows,
+  updated_at = excluded.updated_at
+RETURNING *;
@@ -0,0 +1,58 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (id, user_id, url, secret, events, created_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetWebhookSubscription :one
+SELECT * FROM webhook_subscriptions WHERE id = ?;
+
+-- name: ListWebhookSubscriptions :many
+SELECT * FROM webhook_subscriptions
+WHERE user_id IS sqlc.narg(user_id)
+ORDER BY created_at, id;
+
+-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE id = sqlc.arg(id)
+  AND user_id IS sqlc.narg(user_id);
+
+-- name: ListWebhookSubscribers :many
+-- SQLite can't generate UUIDs, so EnqueueWebhookEvent is a query for the
+-- subscriptions followed by an insert for each.
+SELECT id FROM webhook_subscriptions
+WHERE ' ' || events || ' ' LIKE '% ' || CAST(sqlc.arg(event) AS TEXT) || ' %'
+  AND (sqlc.narg(user_id) IS NULL OR user_id IS NULL OR user_id = sqlc.narg(user_id));
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (id, subscription_id, event, payload, next_attempt_at, created_at)
+VALUES (?, ?, ?, ?, sqlc.arg(now), sqlc.arg(now));
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(lease_until)
+WHERE id IN (
+  SELECT d.id FROM webhook_deliveries d
+  WHERE d.status = 'pending'
+    AND d.next_attempt_at <= sqlc.arg(now)
+  ORDER BY d.next_attempt_at
+  LIMIT sqlc.arg(max_deliveries)
+)
+RETURNING *;
+
+-- name: FinishWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET status = sqlc.arg(status),
+  attempts = attempts + 1,
+  next_attempt_at = sqlc.arg(next_attempt_at),
+  last_error = sqlc.arg(last_error),
+  response_status = sqlc.narg(response_status),
+  delivered_at = sqlc.narg(delivered_at)
+WHERE id = sqlc.arg(id);
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE subscription_id = sqlc.arg(subscription_id)
+  AND (created_at < sqlc.arg(cursor_created_at)
+    OR (created_at = sqlc.arg(cursor_created_at) AND id < sqlc.arg(cursor_id)))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,30 @@
+-- name: GetUserFromRefreshToken :one
+SELECT
+  users.id,
+  users.created_at,
+  users.updated_at,
+  users.email,
+  users.hashed_password,
+  users.role,
+  users.suspended_at,
+  users.suspended_until,
+  users.suspension_reason
+FROM users
+JOIN refresh_tokens ON refresh_tokens.user_id = users.id
+WHERE refresh_tokens.token = sqlc.arg(token)
+  AND refresh_tokens.revoked_at IS NULL
+  AND refresh_tokens.expires_at > sqlc.arg(now);
+
+-- name: RevokeRefreshToken :execrows
+UPDATE refresh_tokens
+SET revoked_at = sqlc.arg(now),
+  updated_at = sqlc.arg(now)
+WHERE token = sqlc.arg(token)
+  AND revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = sqlc.arg(now),
+  updated_at = sqlc.arg(now)
+WHERE user_id = sqlc.arg(user_id)
+  AND revoked_at IS NULL;
@@ -0,0 +1,24 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, user_id, body, in_reply_to, publish_at, created_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListScheduledChirps :many
+SELECT * FROM scheduled_chirps
+WHERE user_id = ?
+ORDER BY publish_at, id;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM scheduled_chirps
+WHERE id = ?
+  AND user_id = ?;
+
+-- name: ClaimDueScheduledChirps :many
+DELETE FROM scheduled_chirps
+WHERE publish_at <= sqlc.arg(now)
+  AND user_id NOT IN (
+    SELECT id FROM users
+    WHERE suspended_at IS NOT NULL
+      AND (suspended_until IS NULL OR suspended_until > sqlc.arg(now))
+  )
+RETURNING *;
@@ -0,0 +1,25 @@
+-- name: SearchChirps :many
+-- bm25() is lower for better matches, so it is negated to rank like
+-- ts_rank in Postgres.
+SELECT sqlc.embed(chirps), CAST(-bm25(chirps_fts) AS REAL) AS relevance
+FROM chirps_fts
+JOIN chirps ON chirps.rowid = chirps_fts.rowid
+WHERE chirps_fts.body MATCH sqlc.arg(query)
+  AND (sqlc.narg(author_id) IS NULL OR chirps.user_id = sqlc.narg(author_id))
+  AND (-bm25(chirps_fts) < sqlc.arg(cursor_relevance)
+    OR (-bm25(chirps_fts) = sqlc.arg(cursor_relevance) AND chirps.created_at < sqlc.arg(cursor_created_at))
+    OR (-bm25(chirps_fts) = sqlc.arg(cursor_relevance) AND chirps.created_at = sqlc.arg(cursor_created_at) AND chirps.id < sqlc.arg(cursor_id)))
+  AND chirps.hidden_at IS NULL
+ORDER BY relevance DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: SearchChirpsRecent :many
+SELECT chirps.* FROM chirps_fts
+JOIN chirps ON chirps.rowid = chirps_fts.rowid
+WHERE chirps_fts.body MATCH sqlc.arg(query)
+  AND (sqlc.narg(author_id) IS NULL OR chirps.user_id = sqlc.narg(author_id))
+  AND (chirps.created_at < sqlc.arg(cursor_created_at)
+    OR (chirps.created_at = sqlc.arg(cursor_created_at) AND chirps.id < sqlc.arg(cursor_id)))
+  AND chirps.hidden_at IS NULL
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,60 @@
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: LookUpUserByEmail :one
+SELECT * FROM users WHERE email = ?;
+
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (token, created_at, updated_at, expires_at, user_id)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: UpdateEmailAndPW :one
+UPDATE users
+SET email = ?,
+  hashed_password = ?,
+  updated_at = ?
+WHERE id = ?
+RETURNING *;
+
+-- name: UpgradeChirpyPlus :one
+UPDATE users
+SET is_chirpy_red = TRUE
+WHERE id = ?
+RETURNING *;
+
+-- name: DowngradeChirpyPlus :one
+UPDATE users
+SET is_chirpy_red = FALSE
+WHERE id = ?
+RETURNING *;
+
+-- name: GetUser :one
+SELECT * FROM users WHERE id = ?;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = ?,
+  updated_at = ?
+WHERE id = ?
+RETURNING *;
+
+-- name: SuspendUser :one
+UPDATE users
+SET suspended_at = sqlc.arg(now),
+  suspended_until = sqlc.narg(suspended_until),
+  suspension_reason = sqlc.arg(suspension_reason),
+  updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL,
+  suspended_until = NULL,
+  suspension_reason = '',
+  updated_at = ?
+WHERE id = ?
+RETURNING *;
@@ -0,0 +1,21 @@
+-- name: RecordWebhookEvent :one
+INSERT INTO webhook_events (id, source, event_id, event, user_id, payload, received_at, last_received_at)
+VALUES (?, ?, ?, ?, ?, ?, sqlc.arg(now), sqlc.arg(now))
+ON CONFLICT (source, event_id) DO UPDATE
+SET deliveries = webhook_events.deliveries + 1,
+  last_received_at = excluded.last_received_at
+RETURNING *;
+
+-- name: SetWebhookEventStatus :exec
+UPDATE webhook_events
+SET status = ?,
+  error = ?
+WHERE id = ?;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (sqlc.narg(user_id) IS NULL OR user_id = sqlc.narg(user_id))
+  AND (received_at < sqlc.arg(cursor_received_at)
+    OR (received_at = sqlc.arg(cursor_received_at) AND id < sqlc.arg(cursor_id)))
+ORDER BY received_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,36 @@
+-- SQLite counterpart of sql/schema/001-006. Timestamps are written by the
+-- application in UTC so that they compare correctly as text.
+-- +goose Up
+CREATE TABLE users (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  email TEXT NOT NULL UNIQUE,
+  hashed_password TEXT NOT NULL DEFAULT 'unset',
+  is_chirpy_red BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE chirps (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  body TEXT NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX chirps_created_at_id_idx ON chirps (created_at, id);
+CREATE INDEX chirps_user_id_created_at_id_idx ON chirps (user_id, created_at, id);
+
+CREATE TABLE refresh_tokens (
+  token TEXT PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  expires_at TIMESTAMP NOT NULL,
+  revoked_at TIMESTAMP DEFAULT NULL
+);
+
+-- +goose Down
+DROP TABLE refresh_tokens;
+DROP TABLE chirps;
+DROP TABLE users;
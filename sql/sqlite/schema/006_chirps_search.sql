@@ -0,0 +1,36 @@
+-- SQLite has no tsvector; an external content FTS5 table indexes chirp
+-- bodies instead and is kept in sync by triggers.
+-- +goose Up
+CREATE VIRTUAL TABLE chirps_fts USING fts5(
+  body,
+  content='chirps',
+  content_rowid='rowid',
+  tokenize='porter unicode61'
+);
+
+INSERT INTO chirps_fts (rowid, body) SELECT rowid, body FROM chirps;
+
+-- +goose StatementBegin
+CREATE TRIGGER chirps_fts_insert AFTER INSERT ON chirps BEGIN
+  INSERT INTO chirps_fts (rowid, body) VALUES (new.rowid, new.body);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER chirps_fts_delete AFTER DELETE ON chirps BEGIN
+  INSERT INTO chirps_fts (chirps_fts, rowid, body) VALUES ('delete', old.rowid, old.body);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER chirps_fts_update AFTER UPDATE OF body ON chirps BEGIN
+  INSERT INTO chirps_fts (chirps_fts, rowid, body) VALUES ('delete', old.rowid, old.body);
+  INSERT INTO chirps_fts (rowid, body) VALUES (new.rowid, new.body);
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER chirps_fts_update;
+DROP TRIGGER chirps_fts_delete;
+DROP TRIGGER chirps_fts_insert;
+DROP TABLE chirps_fts;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE banned_words (
+  word TEXT PRIMARY KEY,
+  action TEXT NOT NULL CHECK (action IN ('mask', 'flag', 'reject')),
+  created_at TIMESTAMP NOT NULL
+);
+
+INSERT INTO banned_words (word, action, created_at)
+VALUES
+  ('kerfuffle', 'mask', strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  ('sharbert', 'mask', strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  ('fornax', 'mask', strftime('%Y-%m-%d %H:%M:%f', 'now'));
+
+-- +goose Down
+DROP TABLE banned_words;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE scheduled_chirps (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  body TEXT NOT NULL,
+  in_reply_to UUID DEFAULT NULL REFERENCES chirps(id) ON DELETE SET NULL,
+  publish_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX scheduled_chirps_publish_at_idx ON scheduled_chirps (publish_at);
+CREATE INDEX scheduled_chirps_user_id_idx ON scheduled_chirps (user_id, publish_at);
+
+-- +goose Down
+DROP TABLE scheduled_chirps;
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE webhook_events (
+  id UUID PRIMARY KEY,
+  source TEXT NOT NULL,
+  event_id TEXT,
+  event TEXT NOT NULL,
+  user_id UUID,
+  payload TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'received' CHECK (status IN ('received', 'processed', 'ignored', 'failed')),
+  error TEXT NOT NULL DEFAULT '',
+  deliveries INTEGER NOT NULL DEFAULT 1,
+  received_at TIMESTAMP NOT NULL,
+  last_received_at TIMESTAMP NOT NULL,
+  UNIQUE (source, event_id)
+);
+
+CREATE INDEX webhook_events_received_at_idx ON webhook_events (received_at, id);
+CREATE INDEX webhook_events_user_id_idx ON webhook_events (user_id, received_at, id);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE notifications (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  kind TEXT NOT NULL CHECK (kind IN ('mention', 'reply', 'like', 'follow')),
+  chirp_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  read_at TIMESTAMP
+);
+
+CREATE INDEX notifications_user_idx ON notifications (user_id, created_at, id);
+
+CREATE TABLE notification_preferences (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  mentions BOOLEAN NOT NULL DEFAULT TRUE,
+  replies BOOLEAN NOT NULL DEFAULT TRUE,
+  likes BOOLEAN NOT NULL DEFAULT TRUE,
+  follows BOOLEAN NOT NULL DEFAULT TRUE,
+  updated_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notifications;
@@ -0,0 +1,30 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN hidden_at TIMESTAMP;
+
+CREATE TABLE chirp_reports (
+  id UUID PRIMARY KEY,
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  reason TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  resolved_at TIMESTAMP,
+  UNIQUE (chirp_id, reporter_id)
+);
+
+CREATE TABLE moderation_actions (
+  id UUID PRIMARY KEY,
+  chirp_id UUID NOT NULL,
+  chirp_user_id UUID NOT NULL,
+  moderator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  action TEXT NOT NULL CHECK (action IN ('hide', 'restore', 'remove', 'dismiss')),
+  reason TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX moderation_actions_created_idx ON moderation_actions (created_at, id);
+CREATE INDEX moderation_actions_chirp_idx ON moderation_actions (chirp_id, created_at, id);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE chirp_reports;
+ALTER TABLE chirps DROP COLUMN hidden_at;
@@ -0,0 +1,28 @@
+-- +goose Up
+-- SQLite cannot drop NOT NULL from a column, so the table is rebuilt.
+CREATE TABLE chirp_reports_new (
+  id UUID PRIMARY KEY,
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  reporter_id UUID REFERENCES users(id) ON DELETE CASCADE,
+  reason TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  resolved_at TIMESTAMP,
+  UNIQUE (chirp_id, reporter_id)
+);
+INSERT INTO chirp_reports_new SELECT * FROM chirp_reports;
+DROP TABLE chirp_reports;
+ALTER TABLE chirp_reports_new RENAME TO chirp_reports;
+
+-- +goose Down
+CREATE TABLE chirp_reports_old (
+  id UUID PRIMARY KEY,
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  reason TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  resolved_at TIMESTAMP,
+  UNIQUE (chirp_id, reporter_id)
+);
+INSERT INTO chirp_reports_old SELECT * FROM chirp_reports WHERE reporter_id IS NOT NULL;
+DROP TABLE chirp_reports;
+ALTER TABLE chirp_reports_old RENAME TO chirp_reports;
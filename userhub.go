@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// eventUserSuspended is published to a user who has just been suspended.
+// It closes their WebSocket connections and is not forwarded to clients.
+const eventUserSuspended = "user.suspended"
+
+// userEvent is a private event for one user.
+type userEvent struct {
+	Name string
+	Data json.RawMessage
+}
+
+// userHub pushes private events to the open connections of their user.
+// Like chirpHub it never blocks: a connection that falls behind is dropped.
+// Nothing is kept for users who aren't connected.
+type userHub struct {
+	mu   sync.Mutex
+	subs map[uuid.UUID]map[*userSub]struct{}
+}
+
+// userSub receives one user's events. C is closed when the subscriber is
+// dropped or unsubscribed.
+type userSub struct {
+	user uuid.UUID
+	C    chan userEvent
+}
+
+func newUserHub() *userHub {
+	return &userHub{subs: make(map[uuid.UUID]map[*userSub]struct{})}
+}
+
+func (h *userHub) publish(user uuid.UUID, name string, data any) {
+	dat, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("couldn't encode event", "event", name, "error", err)
+		return
+	}
+	e := userEvent{Name: name, Data: dat}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs[user] {
+		select {
+		case sub.C <- e:
+		default:
+			h.remove(sub)
+		}
+	}
+}
+
+func (h *userHub) subscribe(user uuid.UUID) *userSub {
+	sub := &userSub{user: user, C: make(chan userEvent, chirpHubBuffer)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[user] == nil {
+		h.subs[user] = make(map[*userSub]struct{})
+	}
+	h.subs[user][sub] = struct{}{}
+	return sub
+}
+
+func (h *userHub) unsubscribe(sub *userSub) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub.user][sub]; ok {
+		h.remove(sub)
+	}
+}
+
+// remove drops sub. h.mu must be held.
+func (h *userHub) remove(sub *userSub) {
+	delete(h.subs[sub.user], sub)
+	if len(h.subs[sub.user]) == 0 {
+		delete(h.subs, sub.user)
+	}
+	close(sub.C)
+}
@@ -1,30 +1,66 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"strings"
+	"unicode"
+
+	"github.com/Geraetefreund/chirpy/internal/moderation"
+	"github.com/Geraetefreund/chirpy/internal/store"
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
 )
 
-func validateAndClean(input string) (string, error) {
-	const maxChirpLength = 140
+// storeBannedWords is the banned_words table as a moderation.Source.
+type storeBannedWords struct {
+	db store.BannedWords
+}
 
-	if len(input) > 140 {
-		return input, errors.New("Chirp exeeds 140 chars")
+func (s storeBannedWords) Rules(ctx context.Context) ([]moderation.Rule, error) {
+	words, err := s.db.ListBannedWords(ctx)
+	if err != nil {
+		return nil, err
 	}
+	rules := make([]moderation.Rule, 0, len(words))
+	for _, w := range words {
+		action, err := moderation.ParseAction(w.Action)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, moderation.Rule{Word: w.Word, Action: action})
+	}
+	return rules, nil
+}
 
-	profanityMap := map[string]struct{}{
-		"kerfuffle": {},
-		"sharbert":  {},
-		"fornax":    {},
+var errChirpRejected = errors.New("Chirp contains banned words")
+
+// validateAndClean normalizes a chirp body, checks it against maxLength and
+// runs it through moderation. The result is what gets stored; if it contains
+// flagged words, flagForReview queues the stored chirp for moderators.
+func (cfg *apiConfig) validateAndClean(input string, maxLength int) (string, error) {
+	body := normalizeChirp(input)
+	if uniseg.GraphemeClusterCount(body) > maxLength {
+		return input, fmt.Errorf("Chirp exceeds %d characters", maxLength)
 	}
 
-	words := strings.Fields(input)
-	for i, w := range words {
-		if _, ok := profanityMap[strings.ToLower(w)]; ok {
-			words[i] = "****"
-		}
+	result := cfg.moderator.Moderate(body)
+	if result.Rejected() {
+		return input, errChirpRejected
 	}
-	cleanedInput := strings.Join(words, " ")
+	return result.Text, nil
+}
 
-	return cleanedInput, nil
+// normalizeChirp puts s in NFC, so that "é" is one character however the
+// client sent it, and removes control characters other than tabs and
+// newlines. Whitespace inside the text is kept; only the ends are trimmed.
+func normalizeChirp(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.TrimSpace(norm.NFC.String(s))
 }